@@ -0,0 +1,68 @@
+package pocsag
+
+import (
+	"fmt"
+)
+
+// MaxAddress is the largest RIC POCSAG can carry: 21 bits, of which 18 are
+// sent in the address codeword and 3 are implied by the frame.
+const MaxAddress = 1<<21 - 1
+
+// AddressCodeword is the bit-level breakdown of an address codeword, for
+// checking this encoder against others field by field. Bit 31 (the
+// message flag) is always 0 in a valid address codeword.
+type AddressCodeword struct {
+	Codeword    uint32 `json:"codeword"`
+	RIC         uint32 `json:"ric"`          // AddressBits<<3 | Frame
+	Frame       int    `json:"frame"`        // low 3 bits of the RIC, implied by position
+	AddressBits uint32 `json:"address_bits"` // 18 transmitted address bits, codeword bits 30-13
+	Function    uint8  `json:"function"`     // codeword bits 12-11
+	BCH         uint16 `json:"bch"`          // 10 BCH(31,21) check bits, codeword bits 10-1
+	Parity      uint8  `json:"parity"`       // even parity, codeword bit 0
+	MessageFlag bool   `json:"message_flag"` // bit 31; set means this is not an address codeword
+	BCHValid    bool   `json:"bch_valid"`
+	ParityValid bool   `json:"parity_valid"`
+}
+
+// ExplainAddressCodeword returns the fields of the address codeword sent
+// for ric and function. RICs above MaxAddress are an error rather than
+// being silently truncated.
+func ExplainAddressCodeword(ric uint32, function uint8) (AddressCodeword, error) {
+	if ric > MaxAddress {
+		return AddressCodeword{}, fmt.Errorf("address %d exceeds the 21-bit maximum %d", ric, MaxAddress)
+	}
+	if function > 3 {
+		return AddressCodeword{}, fmt.Errorf("function %d is not a 2-bit value", function)
+	}
+	return ParseAddressCodeword(EncodeAddress(ric, function), FrameForAddress(ric)), nil
+}
+
+// ParseAddressCodeword splits a received or foreign codeword into its
+// address fields. frame is the frame it arrived in, which supplies the
+// low 3 bits of the RIC. No error correction is applied; BCHValid and
+// ParityValid report whether the check bits match.
+func ParseAddressCodeword(cw uint32, frame int) AddressCodeword {
+	a := AddressCodeword{
+		Codeword:    cw,
+		Frame:       frame & 7,
+		AddressBits: (cw >> 13) & 0x3FFFF,
+		Function:    uint8((cw >> 11) & 3),
+		BCH:         uint16((cw >> 1) & 0x3FF),
+		Parity:      uint8(cw & 1),
+		MessageFlag: cw&0x80000000 != 0,
+	}
+	a.RIC = a.AddressBits<<3 | uint32(a.Frame)
+	a.BCHValid = CalculateBCH(cw)&^1 == cw&^1
+	a.ParityValid = CalculateEvenParity(cw) == cw
+	return a
+}
+
+// String lays the codeword out as flag | address | function | BCH | parity.
+func (a AddressCodeword) String() string {
+	flag := 0
+	if a.MessageFlag {
+		flag = 1
+	}
+	return fmt.Sprintf("%d %018b %02b %010b %d  (0x%08X, RIC %d, frame %d)",
+		flag, a.AddressBits, a.Function, a.BCH, a.Parity, a.Codeword, a.RIC, a.Frame)
+}
@@ -0,0 +1,204 @@
+package pocsag
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// AddressEntry is a named pager, or a group address shared by several, in
+// an AddressBook.
+type AddressEntry struct {
+	Name     string `yaml:"name"`
+	Address  uint32 `yaml:"address"`
+	Function uint8  `yaml:"function"`
+	// BaudRate is the rate the pager listens at, or 0 for the sender's.
+	BaudRate int `yaml:"baud,omitempty"`
+	// Key, if set, is the password pages to the entry are encrypted with.
+	Key string `yaml:"key,omitempty"`
+	// Type, numeric or alpha, is how the pager's messages are decoded
+	// whatever their function, for networks that send numeric pages on
+	// functions other than 0. Empty goes by the function.
+	Type string `yaml:"type,omitempty"`
+}
+
+// AddressBook maps names to pager addresses, so senders can page
+// "ops-team" rather than a RIC. As a PostProcessor it sets the Alias of
+// decoded messages to the name of their address and function, or of their
+// address alone if no entry has the function.
+type AddressBook struct {
+	entries []AddressEntry
+	byName  map[string]int
+}
+
+// LoadAddressBook parses an address book in format "yaml" (which takes
+// JSON too) or "csv". YAML is a list of entries with the fields of
+// AddressEntry. CSV has name,address,function,baud,key,type columns, of
+// which the last four may be left out or blank, and a first line naming
+// the columns may reorder them.
+func LoadAddressBook(data []byte, format string) (*AddressBook, error) {
+	var entries []AddressEntry
+	switch format {
+	case "yaml", "yml", "json":
+		if err := yaml.Unmarshal(data, &entries); err != nil {
+			return nil, fmt.Errorf("parsing address book: %v", err)
+		}
+	case "csv":
+		var err error
+		if entries, err = parseAddressCSV(data); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown address book format %q (supported: yaml, csv)", format)
+	}
+
+	book := &AddressBook{byName: make(map[string]int)}
+	for i, e := range entries {
+		e.Name = strings.TrimSpace(e.Name)
+		switch {
+		case e.Name == "":
+			return nil, fmt.Errorf("address book entry %d has no name", i+1)
+		case e.Address > MaxAddress:
+			return nil, fmt.Errorf("%s: address %d is out of range", e.Name, e.Address)
+		case e.Function > 3:
+			return nil, fmt.Errorf("%s: function %d is not 0-3", e.Name, e.Function)
+		case e.BaudRate != 0 && e.BaudRate != BaudRate512 && e.BaudRate != BaudRate1200 && e.BaudRate != BaudRate2400:
+			return nil, fmt.Errorf("%s: baud rate %d is not 512, 1200, or 2400", e.Name, e.BaudRate)
+		}
+		if e.Type != "" {
+			if e.Type = normalizePayloadType(e.Type); e.Type == "" {
+				return nil, fmt.Errorf("%s: type must be numeric or alpha", e.Name)
+			}
+		}
+		key := strings.ToLower(e.Name)
+		if _, dup := book.byName[key]; dup {
+			return nil, fmt.Errorf("%s is in the address book twice", e.Name)
+		}
+		book.byName[key] = len(book.entries)
+		book.entries = append(book.entries, e)
+	}
+	return book, nil
+}
+
+// addressColumns is the column order of an address book CSV without a
+// header line.
+var addressColumns = []string{"name", "address", "function", "baud", "key", "type"}
+
+func parseAddressCSV(data []byte) ([]AddressEntry, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("reading address book: %v", err)
+	}
+
+	columns := addressColumns
+	if len(rows) > 0 && strings.EqualFold(strings.TrimSpace(rows[0][0]), "name") {
+		columns = make([]string, len(rows[0]))
+		for i, name := range rows[0] {
+			columns[i] = strings.ToLower(strings.TrimSpace(name))
+		}
+		rows = rows[1:]
+	}
+
+	entries := make([]AddressEntry, 0, len(rows))
+	for n, row := range rows {
+		if len(row) > len(columns) {
+			return nil, fmt.Errorf("address book row %d: %d fields, expected at most %d", n+1, len(row), len(columns))
+		}
+		var e AddressEntry
+		for i, value := range row {
+			value = strings.TrimSpace(value)
+			if value == "" {
+				continue
+			}
+			switch columns[i] {
+			case "name":
+				e.Name = value
+			case "address":
+				v, err := strconv.ParseUint(value, 10, 32)
+				if err != nil {
+					return nil, fmt.Errorf("address book row %d: invalid address %q", n+1, value)
+				}
+				e.Address = uint32(v)
+			case "function":
+				v, err := strconv.ParseUint(value, 10, 8)
+				if err != nil {
+					return nil, fmt.Errorf("address book row %d: invalid function %q", n+1, value)
+				}
+				e.Function = uint8(v)
+			case "baud":
+				v, err := strconv.Atoi(value)
+				if err != nil {
+					return nil, fmt.Errorf("address book row %d: invalid baud %q", n+1, value)
+				}
+				e.BaudRate = v
+			case "key":
+				e.Key = value
+			case "type":
+				e.Type = value
+			default:
+				return nil, fmt.Errorf("unknown address book column %q", columns[i])
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// Lookup returns the entry called name, ignoring case.
+func (b *AddressBook) Lookup(name string) (AddressEntry, bool) {
+	i, ok := b.byName[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return AddressEntry{}, false
+	}
+	return b.entries[i], true
+}
+
+// Entries returns the entries in the order they were loaded.
+func (b *AddressBook) Entries() []AddressEntry {
+	return append([]AddressEntry(nil), b.entries...)
+}
+
+// Name returns the name of the entry for address and function, or of the
+// first entry for address if none has the function.
+func (b *AddressBook) Name(address uint32, function uint8) (string, bool) {
+	name, found := "", false
+	for _, e := range b.entries {
+		if e.Address != address {
+			continue
+		}
+		if e.Function == function {
+			return e.Name, true
+		}
+		if !found {
+			name, found = e.Name, true
+		}
+	}
+	return name, found
+}
+
+// PayloadTypes returns the Type of the entries that have one by address,
+// for DecodeOptions.PayloadTypes.
+func (b *AddressBook) PayloadTypes() map[uint32]string {
+	types := make(map[uint32]string)
+	for _, e := range b.entries {
+		if e.Type != "" {
+			types[e.Address] = e.Type
+		}
+	}
+	return types
+}
+
+func (b *AddressBook) Process(msg DecodedMessage) DecodedMessage {
+	if name, ok := b.Name(msg.Address, msg.Function); ok {
+		msg.Alias = name
+	}
+	return msg
+}
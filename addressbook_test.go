@@ -0,0 +1,65 @@
+package pocsag
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAddressBook(t *testing.T) {
+	yamlBook, err := LoadAddressBook([]byte(`
+- name: ops-team
+  address: 123456
+  function: 3
+  baud: 512
+  key: s3cret
+- name: Ops beep
+  address: 123456
+- name: duty-officer
+  address: 8
+  type: Numeric
+`), "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	csvBook, err := LoadAddressBook([]byte("# pagers\nname,address,function,baud,key,type\nops-team,123456,3,512,s3cret\nOps beep,123456\nduty-officer,8,,,,numeric\n"), "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(yamlBook, csvBook) {
+		t.Errorf("YAML %+v, CSV %+v", yamlBook.Entries(), csvBook.Entries())
+	}
+
+	want := AddressEntry{Name: "ops-team", Address: 123456, Function: 3, BaudRate: 512, Key: "s3cret"}
+	if e, ok := yamlBook.Lookup("OPS-Team"); !ok || e != want {
+		t.Errorf("Lookup: %+v, %v", e, ok)
+	}
+	if types := yamlBook.PayloadTypes(); !reflect.DeepEqual(types, map[uint32]string{8: PayloadTypeNumeric}) {
+		t.Errorf("PayloadTypes: %v", types)
+	}
+	if _, ok := yamlBook.Lookup("nobody"); ok {
+		t.Error("found nobody")
+	}
+
+	for _, tc := range []struct {
+		function uint8
+		alias    string
+	}{{3, "ops-team"}, {0, "Ops beep"}, {1, "ops-team"}} {
+		msg := yamlBook.Process(DecodedMessage{Address: 123456, Function: tc.function})
+		if msg.Alias != tc.alias {
+			t.Errorf("function %d: alias %q, want %q", tc.function, msg.Alias, tc.alias)
+		}
+	}
+	if msg := yamlBook.Process(DecodedMessage{Address: 16}); msg.Alias != "" {
+		t.Errorf("unknown address named %q", msg.Alias)
+	}
+
+	for _, bad := range []string{"- name: a\n  address: 8\n- name: A\n  address: 16\n", "- address: 8\n", "- name: a\n  address: 8\n  baud: 300\n", "- name: a\n  address: 8\n  type: bcd\n"} {
+		if _, err := LoadAddressBook([]byte(bad), "yaml"); err == nil {
+			t.Errorf("accepted %q", bad)
+		}
+	}
+	if _, err := LoadAddressBook([]byte("a,eight\n"), "csv"); err == nil || !strings.Contains(err.Error(), "row 1") {
+		t.Errorf("bad CSV address: %v", err)
+	}
+}
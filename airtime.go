@@ -0,0 +1,87 @@
+package pocsag
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// transmissionBits returns the number of bits on air for a transmission of
+// batches: the preamble plus, per batch, a sync word and its codewords.
+func transmissionBits(preambleBits int, batches [][]uint32) int {
+	bits := preambleBits
+	for _, batch := range batches {
+		bits += (1 + len(batch)) * CodewordBits
+	}
+	return bits
+}
+
+// bitsDuration converts a bit count at baudRate to a time.Duration without
+// floating-point rounding.
+func bitsDuration(bits int, baudRate int) time.Duration {
+	return time.Duration(int64(bits) * int64(time.Second) / int64(baudRate))
+}
+
+// EstimateDuration returns the exact on-air time of the burst that
+// CreatePOCSAGBurstWithBaudRate would produce for messages, including the
+// preamble, frame sync words, and idle padding. Gateways can use it to
+// enforce duty-cycle limits before encoding.
+func EstimateDuration(messages []MessageInfo, baudRate int) time.Duration {
+	return NewEncoder(WithBaudRate(baudRate)).EstimateDuration(messages)
+}
+
+// EstimateDuration returns the exact on-air time of the burst CreateBurst
+// would produce for messages. If they cannot be encrypted, it measures
+// them as given.
+func (e *Encoder) EstimateDuration(messages []MessageInfo) time.Duration {
+	if encrypted, err := e.encrypt(messages); err == nil {
+		messages = encrypted
+	}
+	return e.duration(messages)
+}
+
+// duration returns the on-air time of messages, already encrypted.
+func (e *Encoder) duration(messages []MessageInfo) time.Duration {
+	batches, _ := e.layout(messages)
+	return bitsDuration(transmissionBits(e.preambleBits, batches), e.baudRate)
+}
+
+// EstimateAirtime returns the on-air time of msg sent as its own
+// transmission, including preamble, sync words, and idle padding.
+func EstimateAirtime(msg MessageInfo, baudRate int) time.Duration {
+	return EstimateDuration([]MessageInfo{msg}, baudRate)
+}
+
+// EncodeWithBudget encodes the longest run of messages, from the front,
+// whose burst at baudRate lasts at most maxDuration, and returns the
+// messages left over. Repeater controllers with a fixed transmit window can
+// call it until their queue is empty. It fails if the first message alone
+// does not fit.
+func EncodeWithBudget(messages []MessageInfo, baudRate int, maxDuration time.Duration) ([]byte, []MessageInfo, error) {
+	return NewEncoder(WithBaudRate(baudRate)).EncodeWithBudget(messages, maxDuration)
+}
+
+// EncodeWithBudget encodes as many messages as fit into maxDuration of
+// airtime, as the package-level EncodeWithBudget does.
+func (e *Encoder) EncodeWithBudget(messages []MessageInfo, maxDuration time.Duration) ([]byte, []MessageInfo, error) {
+	if len(messages) == 0 {
+		return nil, nil, nil
+	}
+	encrypted, err := e.encrypt(messages)
+	if err != nil {
+		return nil, messages, err
+	}
+	// Adding a message never shortens the burst, so the longest run that
+	// fits can be found by bisection.
+	n := sort.Search(len(encrypted), func(n int) bool {
+		return e.duration(encrypted[:n+1]) > maxDuration
+	})
+	if n == 0 {
+		return nil, messages, fmt.Errorf("message 0 needs %v of airtime, more than the %v budget", e.duration(encrypted[:1]), maxDuration)
+	}
+	burst, err := e.burst(encrypted[:n])
+	if err != nil {
+		return nil, messages, err
+	}
+	return burst, messages[n:], nil
+}
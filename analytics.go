@@ -0,0 +1,373 @@
+package pocsag
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AnalyticsFormat selects how Analytics writes its data points.
+type AnalyticsFormat string
+
+const (
+	// AnalyticsInflux writes InfluxDB line protocol.
+	AnalyticsInflux AnalyticsFormat = "influx"
+	// AnalyticsSQL writes a SQL script, creating the tables first, for
+	// piping into psql; they can be made TimescaleDB hypertables.
+	// Analytics does not connect to a database itself.
+	AnalyticsSQL AnalyticsFormat = "sql"
+)
+
+// DefaultAnalyticsInterval is the period traffic is aggregated over if
+// AnalyticsConfig.Interval is not set.
+const DefaultAnalyticsInterval = time.Minute
+
+// AnalyticsConfig configures an Analytics sink.
+type AnalyticsConfig struct {
+	Format   AnalyticsFormat // default AnalyticsInflux
+	Interval time.Duration   // for the caller's flush loop (default DefaultAnalyticsInterval)
+	Token    string          // InfluxDB API token, for DialAnalytics to an http(s) URL
+}
+
+// Analytics records decoded traffic for dashboards: a data point per
+// message, and per interval the number of messages, the share of them
+// that were partial, their mean RSSI and SNR, and counts per RIC and per
+// baud rate. Points are buffered and written together by Flush, which the
+// caller runs every Interval.
+type Analytics struct {
+	config AnalyticsConfig
+
+	// flushMu orders writes to w, which may be a slow HTTP POST, without
+	// holding up Record
+	flushMu sync.Mutex
+	w       io.Writer
+	created bool // the SQL tables
+
+	mu       sync.Mutex
+	buf      bytes.Buffer
+	interval IntervalStats
+}
+
+// IntervalStats aggregates the messages of one interval.
+type IntervalStats struct {
+	Start, End time.Time
+	Messages   int
+	Partial    int
+	ByAddress  map[uint32]int
+	ByBaud     map[int]int
+	// RSSI and SNR are the means over the messages that have them, from
+	// the IQ decoders; Measured is how many did.
+	RSSI, SNR float64
+	Measured  int
+}
+
+// ErrorRate returns the share of the interval's messages that were
+// partial, or 0 if there were none.
+func (s IntervalStats) ErrorRate() float64 {
+	if s.Messages == 0 {
+		return 0
+	}
+	return float64(s.Partial) / float64(s.Messages)
+}
+
+// NewAnalytics creates an Analytics sink writing to w, its first interval
+// starting now.
+func NewAnalytics(w io.Writer, config AnalyticsConfig) (*Analytics, error) {
+	if config.Format == "" {
+		config.Format = AnalyticsInflux
+	}
+	if config.Interval == 0 {
+		config.Interval = DefaultAnalyticsInterval
+	}
+	switch config.Format {
+	case AnalyticsInflux, AnalyticsSQL:
+	default:
+		return nil, fmt.Errorf("unknown analytics format %q", config.Format)
+	}
+	a := &Analytics{config: config, w: w}
+	a.reset(time.Now())
+	return a, nil
+}
+
+// DialAnalytics opens target and returns an Analytics sink writing to it,
+// along with the file so the caller can close it. An http:// or https://
+// target is an InfluxDB write endpoint, e.g.
+// http://localhost:8086/api/v2/write?org=ops&bucket=paging, each Flush
+// POSTed to it; anything else is a file appended to, or a pipe.
+func DialAnalytics(target string, config AnalyticsConfig) (*Analytics, io.Closer, error) {
+	var w io.WriteCloser
+	if strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://") {
+		if config.Format != "" && config.Format != AnalyticsInflux {
+			return nil, nil, fmt.Errorf("%s analytics cannot be sent to %s; write them to a file or pipe", config.Format, target)
+		}
+		w = &influxWriter{url: target, token: config.Token, client: &http.Client{Timeout: 10 * time.Second}}
+	} else {
+		f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open %s: %v", target, err)
+		}
+		w = f
+	}
+
+	a, err := NewAnalytics(w, config)
+	if err != nil {
+		w.Close()
+		return nil, nil, err
+	}
+	return a, w, nil
+}
+
+// Interval returns how often Flush should be called.
+func (a *Analytics) Interval() time.Duration {
+	return a.config.Interval
+}
+
+func (a *Analytics) reset(start time.Time) {
+	a.interval = IntervalStats{Start: start, ByAddress: make(map[uint32]int), ByBaud: make(map[int]int)}
+}
+
+// Record adds rec to the current interval. A zero Time is taken as now.
+func (a *Analytics) Record(rec MessageRecord) {
+	if rec.Time.IsZero() {
+		rec.Time = time.Now()
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	s := &a.interval
+	s.Messages++
+	if rec.Partial {
+		s.Partial++
+	}
+	s.ByAddress[rec.Address]++
+	s.ByBaud[rec.BaudRate]++
+	if rec.SNRValid {
+		s.Measured++
+		s.RSSI += (rec.RSSI - s.RSSI) / float64(s.Measured)
+		s.SNR += (rec.SNR - s.SNR) / float64(s.Measured)
+	}
+
+	switch a.config.Format {
+	case AnalyticsInflux:
+		writeInfluxMessage(&a.buf, rec)
+	case AnalyticsSQL:
+		writeSQLMessage(&a.buf, rec)
+	}
+}
+
+// Flush ends the current interval at now, writes its aggregates and the
+// points of its messages in one write, and starts the next interval. The
+// interval's points are dropped if the write fails. Messages recorded
+// during the write go into the next interval.
+func (a *Analytics) Flush(now time.Time) (IntervalStats, error) {
+	a.flushMu.Lock()
+	defer a.flushMu.Unlock()
+
+	a.mu.Lock()
+	s := a.interval
+	s.End = now
+	points := a.buf.Bytes()
+	a.buf = bytes.Buffer{}
+	a.reset(now)
+	a.mu.Unlock()
+
+	var out bytes.Buffer
+	switch a.config.Format {
+	case AnalyticsInflux:
+		out.Write(points)
+		writeInfluxInterval(&out, s)
+	case AnalyticsSQL:
+		if !a.created {
+			out.WriteString(sqlSchema)
+		}
+		out.WriteString("BEGIN;\n")
+		out.Write(points)
+		writeSQLInterval(&out, s)
+		out.WriteString("COMMIT;\n")
+	}
+
+	if _, err := a.w.Write(out.Bytes()); err != nil {
+		return s, fmt.Errorf("failed to write analytics: %v", err)
+	}
+	a.created = true
+	return s, nil
+}
+
+// influxTag escapes a tag value for line protocol.
+var influxTag = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+
+func writeInfluxMessage(b *bytes.Buffer, rec MessageRecord) {
+	msgType := "alphanumeric"
+	if rec.IsNumeric {
+		msgType = "numeric"
+	}
+	fmt.Fprintf(b, "pocsag_message,address=%d,function=%d,baud=%d,type=%s", rec.Address, rec.Function, rec.BaudRate, msgType)
+	if rec.FrequencyHz != 0 {
+		fmt.Fprintf(b, ",frequency=%d", rec.FrequencyHz)
+	}
+	if rec.Alias != "" {
+		fmt.Fprintf(b, ",alias=%s", influxTag.Replace(rec.Alias))
+	}
+	fmt.Fprintf(b, " partial=%t,length=%di", rec.Partial, len(rec.Message))
+	if rec.SNRValid {
+		fmt.Fprintf(b, ",rssi=%s,snr=%s", influxFloat(rec.RSSI), influxFloat(rec.SNR))
+	}
+	if rec.Position != nil {
+		fmt.Fprintf(b, ",lat=%s,lon=%s", influxFloat(rec.Position.Latitude), influxFloat(rec.Position.Longitude))
+	}
+	fmt.Fprintf(b, " %d\n", rec.Time.UnixNano())
+}
+
+func writeInfluxInterval(b *bytes.Buffer, s IntervalStats) {
+	ts := s.End.UnixNano()
+	fmt.Fprintf(b, "pocsag_interval messages=%di,partial=%di,error_rate=%s,seconds=%s",
+		s.Messages, s.Partial, influxFloat(s.ErrorRate()), influxFloat(s.End.Sub(s.Start).Seconds()))
+	if s.Measured > 0 {
+		fmt.Fprintf(b, ",rssi=%s,snr=%s", influxFloat(s.RSSI), influxFloat(s.SNR))
+	}
+	fmt.Fprintf(b, " %d\n", ts)
+	for _, addr := range sortedKeys(s.ByAddress) {
+		fmt.Fprintf(b, "pocsag_address,address=%d messages=%di %d\n", addr, s.ByAddress[addr], ts)
+	}
+	for _, baud := range sortedKeys(s.ByBaud) {
+		fmt.Fprintf(b, "pocsag_baud,baud=%d messages=%di %d\n", baud, s.ByBaud[baud], ts)
+	}
+}
+
+func influxFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// sqlSchema creates the tables Analytics inserts into, in PostgreSQL.
+const sqlSchema = `CREATE TABLE IF NOT EXISTS pocsag_messages (
+  time timestamptz NOT NULL,
+  address integer NOT NULL,
+  function smallint NOT NULL,
+  baud integer NOT NULL,
+  type text NOT NULL,
+  alias text,
+  frequency_hz bigint,
+  partial boolean NOT NULL,
+  length integer NOT NULL,
+  rssi_dbfs real,
+  snr_db real,
+  lat double precision,
+  lon double precision
+);
+CREATE TABLE IF NOT EXISTS pocsag_intervals (
+  start_time timestamptz NOT NULL,
+  end_time timestamptz NOT NULL,
+  messages integer NOT NULL,
+  partial integer NOT NULL,
+  error_rate real NOT NULL,
+  rssi_dbfs real,
+  snr_db real
+);
+CREATE TABLE IF NOT EXISTS pocsag_address_counts (
+  end_time timestamptz NOT NULL,
+  address integer NOT NULL,
+  messages integer NOT NULL
+);
+CREATE TABLE IF NOT EXISTS pocsag_baud_counts (
+  end_time timestamptz NOT NULL,
+  baud integer NOT NULL,
+  messages integer NOT NULL
+);
+`
+
+func writeSQLMessage(b *bytes.Buffer, rec MessageRecord) {
+	msgType := "alphanumeric"
+	if rec.IsNumeric {
+		msgType = "numeric"
+	}
+	alias := "NULL"
+	if rec.Alias != "" {
+		alias = sqlString(rec.Alias)
+	}
+	frequency := "NULL"
+	if rec.FrequencyHz != 0 {
+		frequency = strconv.FormatInt(rec.FrequencyHz, 10)
+	}
+	rssi, snr := "NULL", "NULL"
+	if rec.SNRValid {
+		rssi, snr = influxFloat(rec.RSSI), influxFloat(rec.SNR)
+	}
+	lat, lon := "NULL", "NULL"
+	if rec.Position != nil {
+		lat, lon = influxFloat(rec.Position.Latitude), influxFloat(rec.Position.Longitude)
+	}
+	fmt.Fprintf(b, "INSERT INTO pocsag_messages VALUES (%s, %d, %d, %d, '%s', %s, %s, %t, %d, %s, %s, %s, %s);\n",
+		sqlTime(rec.Time), rec.Address, rec.Function, rec.BaudRate, msgType, alias, frequency, rec.Partial, len(rec.Message), rssi, snr, lat, lon)
+}
+
+func writeSQLInterval(b *bytes.Buffer, s IntervalStats) {
+	rssi, snr := "NULL", "NULL"
+	if s.Measured > 0 {
+		rssi, snr = influxFloat(s.RSSI), influxFloat(s.SNR)
+	}
+	end := sqlTime(s.End)
+	fmt.Fprintf(b, "INSERT INTO pocsag_intervals VALUES (%s, %s, %d, %d, %s, %s, %s);\n",
+		sqlTime(s.Start), end, s.Messages, s.Partial, influxFloat(s.ErrorRate()), rssi, snr)
+	for _, addr := range sortedKeys(s.ByAddress) {
+		fmt.Fprintf(b, "INSERT INTO pocsag_address_counts VALUES (%s, %d, %d);\n", end, addr, s.ByAddress[addr])
+	}
+	for _, baud := range sortedKeys(s.ByBaud) {
+		fmt.Fprintf(b, "INSERT INTO pocsag_baud_counts VALUES (%s, %d, %d);\n", end, baud, s.ByBaud[baud])
+	}
+}
+
+func sqlTime(t time.Time) string {
+	return "'" + t.UTC().Format("2006-01-02 15:04:05.999999Z07:00") + "'"
+}
+
+// sqlString quotes s as a standard-conforming SQL string literal.
+func sqlString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func sortedKeys[K uint32 | int](m map[K]int) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+// influxWriter POSTs each write to an InfluxDB write endpoint.
+type influxWriter struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+func (w *influxWriter) Write(p []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(p))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if w.token != "" {
+		req.Header.Set("Authorization", "Token "+w.token)
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return 0, fmt.Errorf("InfluxDB returned status %d", resp.StatusCode)
+	}
+	return len(p), nil
+}
+
+func (w *influxWriter) Close() error {
+	return nil
+}
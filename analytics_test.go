@@ -0,0 +1,149 @@
+package pocsag
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAnalytics(t *testing.T) {
+	at := time.Date(2026, 10, 15, 9, 30, 0, 0, time.UTC)
+	records := []MessageRecord{
+		{DecodedMessage: DecodedMessage{Address: 123456, Message: "SMOKE", BaudRate: 1200, Alias: "Fire Station 3", RSSI: -30, SNR: 20, SNRValid: true}, Time: at},
+		{DecodedMessage: DecodedMessage{Address: 123456, Message: "O'BRIEN", BaudRate: 1200, Partial: true, RSSI: -40, SNR: 0, SNRValid: true, Position: &Position{Latitude: 52.52, Longitude: 13.405}}, Time: at.Add(time.Second)},
+		{DecodedMessage: DecodedMessage{Address: 8, Function: 3, Message: "0123", IsNumeric: true, BaudRate: 512, FrequencyHz: 439987500}, Time: at.Add(2 * time.Second)},
+	}
+
+	var buf bytes.Buffer
+	a, err := NewAnalytics(&buf, AnalyticsConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.reset(at)
+	for _, rec := range records {
+		a.Record(rec)
+	}
+	s, err := a.Flush(at.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Messages != 3 || s.Partial != 1 || s.ByAddress[123456] != 2 || s.ByBaud[512] != 1 || s.Measured != 2 || s.RSSI != -35 || s.SNR != 10 {
+		t.Errorf("stats %+v", s)
+	}
+	want := `pocsag_message,address=123456,function=0,baud=1200,type=alphanumeric,alias=Fire\ Station\ 3 partial=false,length=5i,rssi=-30,snr=20 1792056600000000000
+pocsag_message,address=123456,function=0,baud=1200,type=alphanumeric partial=true,length=7i,rssi=-40,snr=0,lat=52.52,lon=13.405 1792056601000000000
+pocsag_message,address=8,function=3,baud=512,type=numeric,frequency=439987500 partial=false,length=4i 1792056602000000000
+pocsag_interval messages=3i,partial=1i,error_rate=0.3333333333333333,seconds=60,rssi=-35,snr=10 1792056660000000000
+pocsag_address,address=8 messages=1i 1792056660000000000
+pocsag_address,address=123456 messages=2i 1792056660000000000
+pocsag_baud,baud=512 messages=1i 1792056660000000000
+pocsag_baud,baud=1200 messages=2i 1792056660000000000
+`
+	if buf.String() != want {
+		t.Errorf("line protocol:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	// An empty interval still reports zero traffic
+	buf.Reset()
+	if _, err := a.Flush(at.Add(2 * time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "pocsag_interval messages=0i,partial=0i,error_rate=0,seconds=60 1792056720000000000\n" {
+		t.Errorf("empty interval: %q", buf.String())
+	}
+
+	// The SQL script creates its tables once and quotes text
+	buf.Reset()
+	pg, _ := NewAnalytics(&buf, AnalyticsConfig{Format: AnalyticsSQL})
+	pg.Record(MessageRecord{DecodedMessage: DecodedMessage{Address: 8, Message: "HI", BaudRate: 1200, Alias: "O'Brien"}, Time: at})
+	pg.Flush(at.Add(time.Minute))
+	pg.Flush(at.Add(2 * time.Minute))
+	out := buf.String()
+	if strings.Count(out, "CREATE TABLE") != 4 || strings.Count(out, "CREATE TABLE IF NOT EXISTS pocsag_messages") != 1 || strings.Count(out, "COMMIT;") != 2 {
+		t.Errorf("sql output:\n%s", out)
+	}
+	if !strings.Contains(out, "INSERT INTO pocsag_messages VALUES ('2026-10-15 09:30:00Z', 8, 0, 1200, 'alphanumeric', 'O''Brien', NULL, false, 2, NULL, NULL, NULL, NULL);") {
+		t.Errorf("message row missing:\n%s", out)
+	}
+
+	if _, err := NewAnalytics(&buf, AnalyticsConfig{Format: "csv"}); err == nil {
+		t.Error("unknown format accepted")
+	}
+}
+
+func TestDialAnalyticsInflux(t *testing.T) {
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got = r.Header.Get("Authorization") + "|" + string(body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	a, conn, err := DialAnalytics(srv.URL+"/api/v2/write?org=ops&bucket=paging", AnalyticsConfig{Token: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	a.Record(MessageRecord{DecodedMessage: DecodedMessage{Address: 8, BaudRate: 1200}})
+	if _, err := a.Flush(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(got, "Token secret|pocsag_message,address=8,") || !strings.Contains(got, "\npocsag_interval messages=1i,") {
+		t.Errorf("InfluxDB got %q", got)
+	}
+
+	if _, _, err := DialAnalytics(srv.URL, AnalyticsConfig{Format: AnalyticsSQL}); err == nil {
+		t.Error("sql over HTTP accepted")
+	}
+}
+
+// blockingWriter holds each Write until release is closed.
+type blockingWriter struct {
+	started, release chan struct{}
+	bytes.Buffer
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	close(w.started)
+	<-w.release
+	return w.Buffer.Write(p)
+}
+
+func TestAnalyticsRecordDuringFlush(t *testing.T) {
+	w := &blockingWriter{started: make(chan struct{}), release: make(chan struct{})}
+	a, err := NewAnalytics(w, AnalyticsConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Record(MessageRecord{DecodedMessage: DecodedMessage{Address: 8}})
+	flushed := make(chan IntervalStats)
+	go func() {
+		s, _ := a.Flush(time.Now())
+		flushed <- s
+	}()
+	<-w.started
+
+	// Record must not wait for the write, and goes into the next interval
+	recorded := make(chan struct{})
+	go func() {
+		a.Record(MessageRecord{DecodedMessage: DecodedMessage{Address: 16}})
+		close(recorded)
+	}()
+	select {
+	case <-recorded:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Record waited for the flush to be written")
+	}
+	close(w.release)
+	if s := <-flushed; s.Messages != 1 {
+		t.Errorf("flushed %d messages, want 1", s.Messages)
+	}
+	if strings.Contains(w.String(), "address=16") {
+		t.Error("a message recorded during the write went into the flushed interval")
+	}
+}
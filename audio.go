@@ -1,8 +1,6 @@
 package pocsag
 
 import (
-	"bytes"
-	"encoding/binary"
 	"math"
 	"math/rand"
 	"time"
@@ -21,9 +19,10 @@ const (
 // BaudRate is the default baud rate for backward compatibility
 const BaudRate = BaudRate1200
 
-var (
-	SymbolHigh = int16(-12287) // bit 1 (0xD001 as signed)
-	SymbolLow  = int16(12287)  // bit 0 (0x2FFF as signed)
+// Default baseband symbol levels. Use WithSymbols to change them per Encoder.
+const (
+	SymbolHigh int16 = -12287 // bit 1 (0xD001 as signed)
+	SymbolLow  int16 = 12287  // bit 0 (0x2FFF as signed)
 )
 
 // ConvertToAudio converts POCSAG bytes to WAV audio - exact port from bin2audio.c
@@ -35,35 +34,20 @@ func ConvertToAudio(pocsagData []byte) []byte {
 // ConvertToAudioWithBaudRate converts POCSAG bytes to WAV audio with specified baud rate.
 // Uses baseband (DC levels): bit 1 = negative, bit 0 = positive. Compatible with pocsag-decode.
 func ConvertToAudioWithBaudRate(pocsagData []byte, baudRate int) []byte {
-	samplesPerSymbol := float64(SampleRate) / float64(baudRate)
-	numBits := len(pocsagData) * 8
-	numSamples := int(float64(numBits) * samplesPerSymbol)
-
-	audioData := make([]int16, numSamples)
-
-	for byteIdx, b := range pocsagData {
-		for bitPos := 7; bitPos >= 0; bitPos-- {
-			bit := (b >> bitPos) & 1
-			var sample int16
-
-			if bit == 1 {
-				sample = int16(SymbolHigh)
-			} else {
-				sample = int16(SymbolLow)
-			}
-
-			bitIndex := byteIdx*8 + (7 - bitPos)
-			startIdx := int(math.Round(float64(bitIndex) * samplesPerSymbol))
-			endIdx := int(math.Round(float64(bitIndex+1) * samplesPerSymbol))
+	return NewEncoder(WithBaudRate(baudRate)).ConvertToAudio(pocsagData)
+}
 
-			for j := startIdx; j < endIdx; j++ {
-				audioData[j] = sample
-			}
-		}
-	}
+// ConvertToAudioWithSampleRate converts POCSAG bytes to WAV audio at an
+// arbitrary output sample rate. The baseband is generated at SampleRate and
+// resampled, so the result is band-limited rather than aliased.
+func ConvertToAudioWithSampleRate(pocsagData []byte, baudRate int, sampleRate int) []byte {
+	return NewEncoder(WithBaudRate(baudRate), WithSampleRate(sampleRate)).ConvertToAudio(pocsagData)
+}
 
-	// Create WAV file
-	return createWAVFile(audioData)
+// ConvertToAudioWithOptions converts POCSAG bytes to WAV audio with the
+// output sample rate, WAV format, and symbol levels selected by opts.
+func ConvertToAudioWithOptions(pocsagData []byte, baudRate int, opts ...Option) []byte {
+	return NewEncoder(append([]Option{WithBaudRate(baudRate)}, opts...)...).ConvertToAudio(pocsagData)
 }
 
 // FSK tone frequencies for multimon-ng compatibility (mark=1, space=0)
@@ -111,38 +95,7 @@ func ConvertToAudioFSK(pocsagData []byte, baudRate int) []byte {
 }
 
 func createWAVFile(samples []int16) []byte {
-	var buf bytes.Buffer
-
-	dataSize := uint32(len(samples) * 2)
-	fileSize := 36 + dataSize
-	byteRate := uint32(SampleRate * NumChannels * BitsPerSample / 8)
-	blockAlign := uint16(NumChannels * BitsPerSample / 8) // Correct block align for Firefox compatibility
-
-	// RIFF header
-	buf.WriteString("RIFF")
-	binary.Write(&buf, binary.LittleEndian, fileSize)
-	buf.WriteString("WAVE")
-
-	// fmt chunk
-	buf.WriteString("fmt ")
-	binary.Write(&buf, binary.LittleEndian, uint32(16))            // chunk size
-	binary.Write(&buf, binary.LittleEndian, uint16(1))             // PCM format
-	binary.Write(&buf, binary.LittleEndian, uint16(NumChannels))   // channels
-	binary.Write(&buf, binary.LittleEndian, uint32(SampleRate))    // sample rate
-	binary.Write(&buf, binary.LittleEndian, byteRate)              // byte rate
-	binary.Write(&buf, binary.LittleEndian, blockAlign)            // block align
-	binary.Write(&buf, binary.LittleEndian, uint16(BitsPerSample)) // bits per sample
-
-	// data chunk
-	buf.WriteString("data")
-	binary.Write(&buf, binary.LittleEndian, dataSize) // Write actual data size for Firefox compatibility
-
-	// Write samples
-	for _, sample := range samples {
-		binary.Write(&buf, binary.LittleEndian, sample)
-	}
-
-	return buf.Bytes()
+	return encodeWAV(samples, SampleRate, WAVPCM16)
 }
 
 // GenerateFSKSamples generates IQ samples from POCSAG bytes for SDR-style waterfall
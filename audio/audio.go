@@ -0,0 +1,52 @@
+// Package audio joins WAV files and inserts pauses between them, for
+// composing several transmissions (at different baud rates, say) into one
+// file without ffmpeg or a sound editor.
+package audio
+
+import (
+	"fmt"
+	"time"
+
+	pocsag "github.com/sqpp/pocsag-golang/v2"
+)
+
+// Concat joins WAV files end to end. The result has the sample rate and
+// sample format of the first file; later files are resampled and converted
+// to match, and multi-channel files are reduced to their first channel.
+func Concat(wavs ...[]byte) ([]byte, error) {
+	if len(wavs) == 0 {
+		return nil, fmt.Errorf("no WAV files to join")
+	}
+
+	var (
+		joined     []int16
+		sampleRate int
+		format     pocsag.WAVFormat
+	)
+	for i, wavData := range wavs {
+		f, ok := pocsag.DetectWAVFormat(wavData)
+		if !ok {
+			return nil, fmt.Errorf("file %d is not a 16-bit PCM or 32-bit float WAV file", i+1)
+		}
+		samples, rate := pocsag.ParseWAVSamples(wavData)
+		if err := pocsag.ValidateSampleRate(rate); err != nil {
+			return nil, fmt.Errorf("file %d: %v", i+1, err)
+		}
+		if i == 0 {
+			sampleRate, format = rate, f
+		} else if rate != sampleRate {
+			samples = pocsag.Resample(samples, rate, sampleRate)
+		}
+		joined = append(joined, samples...)
+	}
+	return pocsag.SamplesToWAV(joined, sampleRate, format), nil
+}
+
+// Silence returns a WAV file of d of silence. opts select the sample rate
+// and format as for pocsag.NewEncoder (48 kHz 16-bit PCM by default), so
+// the pause can match the transmissions it goes between.
+func Silence(d time.Duration, opts ...pocsag.Option) []byte {
+	enc := pocsag.NewEncoder(opts...)
+	n := int(d.Seconds() * float64(enc.SampleRate()))
+	return pocsag.SamplesToWAV(make([]int16, max(n, 0)), enc.SampleRate(), enc.WAVFormat())
+}
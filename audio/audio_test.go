@@ -0,0 +1,59 @@
+package audio
+
+import (
+	"testing"
+	"time"
+
+	pocsag "github.com/sqpp/pocsag-golang/v2"
+)
+
+func TestConcatAndSilence(t *testing.T) {
+	slow := pocsag.NewEncoder(pocsag.WithBaudRate(pocsag.BaudRate512)).EncodeWAV([]pocsag.MessageInfo{
+		{Address: 123456, Message: "SLOW", Function: pocsag.FuncAlphanumeric},
+	})
+	// A different rate and format, converted to match the first file
+	fast := pocsag.NewEncoder(pocsag.WithBaudRate(pocsag.BaudRate1200), pocsag.WithSampleRate(22050), pocsag.WithWAVFormat(pocsag.WAVFloat32)).EncodeWAV([]pocsag.MessageInfo{
+		{Address: 654320, Message: "FAST", Function: pocsag.FuncAlphanumeric},
+	})
+	pause := Silence(2 * time.Second)
+
+	joined, err := Concat(slow, pause, fast)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format, ok := pocsag.DetectWAVFormat(joined); !ok || format != pocsag.WAVPCM16 {
+		t.Errorf("format %v, ok %v", format, ok)
+	}
+	want := pocsag.WAVDuration(slow) + 2*time.Second + pocsag.WAVDuration(fast)
+	if got := pocsag.WAVDuration(joined); got < want-time.Millisecond || got > want+time.Millisecond {
+		t.Errorf("duration %v, want %v", got, want)
+	}
+
+	for _, tt := range []struct {
+		baud int
+		want string
+	}{
+		{pocsag.BaudRate512, "SLOW"},
+		{pocsag.BaudRate1200, "FAST"},
+	} {
+		decoded, err := pocsag.DecodeFromAudioWithBaudRate(joined, tt.baud)
+		if err != nil || len(decoded) != 1 || decoded[0].Message != tt.want {
+			t.Errorf("%d baud: got %v, err %v", tt.baud, decoded, err)
+		}
+	}
+
+	silence := Silence(500*time.Millisecond, pocsag.WithSampleRate(8000), pocsag.WithWAVFormat(pocsag.WAVFloat32))
+	if samples, rate := pocsag.ParseWAVSamples(silence); len(samples) != 4000 || rate != 8000 {
+		t.Errorf("silence: %d samples at %d Hz", len(samples), rate)
+	}
+	if format, _ := pocsag.DetectWAVFormat(silence); format != pocsag.WAVFloat32 {
+		t.Errorf("silence format %v", format)
+	}
+
+	if _, err := Concat(); err == nil {
+		t.Error("Concat of nothing succeeded")
+	}
+	if _, err := Concat(slow, []byte("not a wav")); err == nil {
+		t.Error("Concat accepted a non-WAV file")
+	}
+}
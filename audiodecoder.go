@@ -0,0 +1,80 @@
+package pocsag
+
+import "sync"
+
+// Decoder decodes audio at one baud rate with one set of DecodeOptions and
+// keeps statistics of everything it has decoded. It holds no state between
+// recordings other than the statistics, so one Decoder may be used from
+// several goroutines, and decoders with different configurations can run
+// side by side in one process.
+type Decoder struct {
+	baudRate int
+	opts     DecodeOptions
+
+	mu         sync.Mutex
+	recordings int
+	messages   int
+	stats      DecodeStats
+}
+
+// NewDecoder creates a Decoder for baudRate. opts is copied; the
+// PayloadTypes map and Keyring it refers to must not change while the
+// Decoder is in use.
+func NewDecoder(baudRate int, opts DecodeOptions) *Decoder {
+	return &Decoder{baudRate: baudRate, opts: opts}
+}
+
+// BaudRate returns the baud rate the Decoder demodulates at.
+func (d *Decoder) BaudRate() int {
+	return d.baudRate
+}
+
+// Options returns the options the Decoder was created with.
+func (d *Decoder) Options() DecodeOptions {
+	return d.opts
+}
+
+// Decode decodes a WAV recording as DecodeFromAudioWithOptions does and
+// adds its statistics to the Decoder's.
+func (d *Decoder) Decode(wavData []byte) ([]DecodedMessage, error) {
+	var stats DecodeStats
+	messages, err := decodeAudio(wavData, d.baudRate, d.opts, &stats)
+	if err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	d.recordings++
+	d.messages += len(messages)
+	d.stats.merge(stats)
+	d.mu.Unlock()
+	return messages, nil
+}
+
+// DecoderStats is what a Decoder has decoded since it was created.
+type DecoderStats struct {
+	Recordings int `json:"recordings"` // calls to Decode that succeeded
+	Messages   int `json:"messages"`
+	// DecodeStats combines the recordings' demodulator statistics, with
+	// drift and eye opening averaged by batches.
+	DecodeStats
+}
+
+// Stats returns the Decoder's statistics so far.
+func (d *Decoder) Stats() DecoderStats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return DecoderStats{Recordings: d.recordings, Messages: d.messages, DecodeStats: d.stats}
+}
+
+// NewStream creates a StreamDecoder for audio at sampleRate with the
+// Decoder's baud rate and options. Its messages are not counted in Stats.
+func (d *Decoder) NewStream(sampleRate int) *StreamDecoder {
+	return NewStreamDecoder(sampleRate, d.baudRate, d.opts)
+}
+
+// NewIQ creates an IQDecoder for IQ samples at sampleRate with the
+// Decoder's baud rate and options. Its messages are not counted in Stats.
+func (d *Decoder) NewIQ(sampleRate int) *IQDecoder {
+	return NewIQDecoder(sampleRate, d.baudRate, d.opts)
+}
@@ -0,0 +1,60 @@
+package pocsag
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AudioFormat is a file format generated audio can be delivered in.
+type AudioFormat string
+
+const (
+	AudioWAV  AudioFormat = "wav"
+	AudioFLAC AudioFormat = "flac"
+	AudioMP3  AudioFormat = "mp3"
+	AudioOpus AudioFormat = "opus"
+)
+
+// ParseAudioFormat parses a format name such as "flac" (case-insensitive).
+func ParseAudioFormat(name string) (AudioFormat, error) {
+	switch f := AudioFormat(strings.ToLower(strings.TrimSpace(name))); f {
+	case "":
+		return AudioWAV, nil
+	case AudioWAV, AudioFLAC, AudioMP3, AudioOpus:
+		return f, nil
+	case "ogg":
+		return AudioOpus, nil
+	default:
+		return "", fmt.Errorf("unknown audio format %q (supported: wav, flac, mp3, opus)", name)
+	}
+}
+
+// FileExtension returns the usual file extension for the format.
+func (f AudioFormat) FileExtension() string {
+	if f == AudioOpus {
+		return ".ogg"
+	}
+	return "." + string(f)
+}
+
+// EncodeAudio converts a WAV produced by the ConvertToAudio functions into
+// format. WAV input is returned unchanged and FLAC is encoded natively. MP3
+// and Opus are lossy, so they need a build with the ffmpeg tag and an ffmpeg
+// binary on PATH; the decoder copes with their artifacts at 1200 baud and
+// below but is not guaranteed to at 2400.
+func EncodeAudio(wavData []byte, format AudioFormat) ([]byte, error) {
+	switch format {
+	case AudioWAV, "":
+		return wavData, nil
+	case AudioFLAC:
+		samples, sampleRate := ParseWAVSamples(wavData)
+		if sampleRate <= 0 {
+			return nil, fmt.Errorf("invalid WAV input")
+		}
+		return EncodeFLAC(samples, sampleRate), nil
+	case AudioMP3, AudioOpus:
+		return encodeLossy(wavData, format)
+	default:
+		return nil, fmt.Errorf("unknown audio format %q", format)
+	}
+}
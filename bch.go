@@ -1,5 +1,10 @@
 package pocsag
 
+import (
+	"fmt"
+	"math/bits"
+)
+
 // BCH(31,21) error correction code - EXACT port from pocsag-tool
 
 const (
@@ -9,8 +14,29 @@ const (
 	NumTotalBits  = 31
 )
 
-// CalculateBCH calculates BCH(31,21) parity - exact port from pocsag.c lines 53-80
+// CalculateBCH calculates BCH(31,21) parity. The code is linear, so the
+// parity of the 21 data bits is that of their upper 11 bits XOR that of
+// their lower 10, each looked up in a table built with calculateBCHBitwise.
 func CalculateBCH(x uint32) uint32 {
+	x &= AddressMask
+	return x | (bchParityHigh[x>>21] ^ bchParityLow[x>>11&0x3FF])
+}
+
+// bchParityHigh and bchParityLow hold the parity bits (10-1) of data bits
+// 31-21 and 20-11 on their own.
+var bchParityHigh, bchParityLow = func() (high [1 << 11]uint32, low [1 << 10]uint32) {
+	for i := range high {
+		high[i] = calculateBCHBitwise(uint32(i)<<21) &^ AddressMask
+	}
+	for i := range low {
+		low[i] = calculateBCHBitwise(uint32(i)<<11) &^ AddressMask
+	}
+	return high, low
+}()
+
+// calculateBCHBitwise is CalculateBCH by long division, 21 steps per
+// codeword - exact port from pocsag.c lines 53-80
+func calculateBCHBitwise(x uint32) uint32 {
 	x &= AddressMask // keep only data bits (11-31)
 	dividend := x
 	generator := uint32(GeneratorPoly << NumDataBits)
@@ -39,20 +65,81 @@ func CalculateEvenParity(x uint32) uint32 {
 	return (x &^ uint32(1)) | parity // Clear bit 0 and set new parity
 }
 
-// DoesWordPassBCH checks if a codeword matches its BCH(31,21) parity and even parity
+// DoesWordPassBCH checks if a codeword matches its BCH(31,21) parity and
+// even parity: bits 31-1 leave no remainder and the word has an even number
+// of ones.
 func DoesWordPassBCH(cw uint32) bool {
-	// 1. Check BCH bits (1-31)
-	// CalculateBCH returns (data | parity_bits) where bit 0 is 0.
-	dataBits := cw & AddressMask
-	expected := CalculateBCH(dataBits)
+	return bchSyndrome(cw) == 0 && bits.OnesCount32(cw)%2 == 0
+}
+
+// VerifyCodeword checks cw independently of CalculateBCH and
+// CalculateEvenParity: bits 31-1 must divide by the generator polynomial
+// and the whole word must have even parity. It reports which check fails.
+func VerifyCodeword(cw uint32) error {
+	if rem := bchSyndrome(cw); rem != 0 {
+		return fmt.Errorf("codeword 0x%08X fails the BCH check (syndrome 0x%03X)", cw, rem)
+	}
+	if bits.OnesCount32(cw)%2 != 0 {
+		return fmt.Errorf("codeword 0x%08X has odd parity", cw)
+	}
+	return nil
+}
 
-	// Compare bits 1-31 (mask out bit 0)
-	if (expected & ^uint32(1)) != (cw & ^uint32(1)) {
-		return false
+// bchSyndrome returns the remainder of bits 31-1 of cw divided by the
+// generator polynomial, zero for a valid code word. Remainders add like
+// the words, so it is the XOR of those of cw's four bytes.
+func bchSyndrome(cw uint32) uint32 {
+	return bchSyndromeBytes[0][cw>>24] ^ bchSyndromeBytes[1][cw>>16&0xFF] ^
+		bchSyndromeBytes[2][cw>>8&0xFF] ^ bchSyndromeBytes[3][cw&0xFF]
+}
+
+// bchSyndromeBytes holds the remainder of each byte of a codeword on its
+// own, most significant byte first.
+var bchSyndromeBytes = func() (t [4][256]uint32) {
+	for i := range t {
+		for b := uint32(0); b < 256; b++ {
+			t[i][b] = bchSyndromeBitwise(b << (24 - 8*i))
+		}
+	}
+	return t
+}()
+
+// bchSyndromeBitwise is bchSyndrome by long division.
+func bchSyndromeBitwise(cw uint32) uint32 {
+	// Long division of the 31-bit code word by the 11-bit generator
+	// x^10+x^9+x^8+x^6+x^5+x^3+1, from the top bit down
+	rem := cw >> 1
+	for bit := NumTotalBits - 1; bit >= NumTotalBits-NumDataBits; bit-- {
+		if rem&(1<<bit) != 0 {
+			rem ^= GeneratorPoly << (bit - 10)
+		}
 	}
+	return rem
+}
+
+// bchErrors maps the syndrome and parity of every one- and two-bit error
+// to the error, which they identify since the code's distance is 6.
+var bchErrors = func() map[uint32]uint32 {
+	errs := make(map[uint32]uint32)
+	for i := 0; i < 32; i++ {
+		for j := i; j < 32; j++ {
+			e := uint32(1)<<i | uint32(1)<<j
+			errs[bchSyndrome(e)<<1|uint32(bits.OnesCount32(e)&1)] = e
+		}
+	}
+	return errs
+}()
 
-	// 2. Check overall even parity (bit 0)
-	// CalculateEvenParity returns the word with the correct bit 0 for even parity
-	expectedParity := CalculateEvenParity(cw)
-	return expectedParity == cw
+// CorrectCodeword repairs up to two flipped bits in cw. It reports false,
+// returning cw unchanged, when cw has more errors than that; three are
+// always detected, more may be taken for a different valid codeword.
+func CorrectCodeword(cw uint32) (uint32, bool) {
+	key := bchSyndrome(cw)<<1 | uint32(bits.OnesCount32(cw)&1)
+	if key == 0 {
+		return cw, true
+	}
+	if e, ok := bchErrors[key]; ok {
+		return cw ^ e, true
+	}
+	return cw, false
 }
@@ -0,0 +1,31 @@
+package pocsag
+
+import "math/rand"
+
+// FlipBits returns a copy of data with the bits at positions inverted, for
+// testing error correction against exact error patterns. Bits are counted
+// in the order they are sent: position 0 is the most significant bit of
+// data[0], and a codeword's bit 31 comes first. A position given twice is
+// flipped back. It panics if a position is outside data.
+func FlipBits(data []byte, positions ...int) []byte {
+	out := append([]byte(nil), data...)
+	for _, p := range positions {
+		out[p/8] ^= 0x80 >> (p % 8)
+	}
+	return out
+}
+
+// FlipBitsAtRate returns a copy of data with each bit inverted with
+// probability rate, as a channel with that bit error rate would, and the
+// positions it inverted in order. The errors are drawn from seed, so a
+// test sees the same ones every run.
+func FlipBitsAtRate(data []byte, rate float64, seed int64) ([]byte, []int) {
+	r := rand.New(rand.NewSource(seed))
+	var positions []int
+	for p := 0; p < len(data)*8; p++ {
+		if r.Float64() < rate {
+			positions = append(positions, p)
+		}
+	}
+	return FlipBits(data, positions...), positions
+}
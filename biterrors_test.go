@@ -0,0 +1,62 @@
+package pocsag
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func TestFlipBits(t *testing.T) {
+	data := []byte{0x00, 0xFF}
+	if got := FlipBits(data, 0, 7, 8, 3, 3); !bytes.Equal(got, []byte{0x81, 0x7F}) || data[0] != 0 {
+		t.Errorf("got % X, data % X", got, data)
+	}
+
+	// Every single and double bit error in every codeword of a burst is
+	// detected, and repaired by CorrectCodeword
+	msgs := []MessageInfo{
+		{Address: 123456, Message: "EVERY ERROR PATTERN", Function: FuncAlphanumeric},
+		{Address: 8, Message: "0123456789", PayloadType: PayloadTypeNumeric},
+	}
+	burst := CreatePOCSAGBurst(msgs)
+	start := PreambleLength / 8
+	for off := start; off+4 <= len(burst); off += 4 {
+		cw := binary.BigEndian.Uint32(burst[off:])
+		if cw == FrameSyncWord {
+			continue
+		}
+		for i := 0; i < 32; i++ {
+			for j := i; j < 32; j++ {
+				positions := []int{8*off + i}
+				if j != i {
+					positions = append(positions, 8*off+j)
+				}
+				bad := binary.BigEndian.Uint32(FlipBits(burst, positions...)[off:])
+				if DoesWordPassBCH(bad) || VerifyCodeword(bad) == nil {
+					t.Fatalf("0x%08X with bits %v flipped passes", cw, positions)
+				}
+				if fixed, ok := CorrectCodeword(bad); !ok || fixed != cw {
+					t.Fatalf("0x%08X with bits %v flipped: got 0x%08X, %v", cw, positions, fixed, ok)
+				}
+			}
+		}
+	}
+
+	// The rate-based mode is repeatable and flips about as many bits as
+	// asked
+	noisy, positions := FlipBitsAtRate(burst, 0.01, 2940)
+	again, positionsAgain := FlipBitsAtRate(burst, 0.01, 2940)
+	if !bytes.Equal(noisy, again) || !reflect.DeepEqual(positions, positionsAgain) {
+		t.Error("same seed, different errors")
+	}
+	if !bytes.Equal(FlipBits(burst, positions...), noisy) {
+		t.Error("positions do not match the errors")
+	}
+	if n, want := len(positions), len(burst)*8/100; n < want/2 || n > want*2 {
+		t.Errorf("%d errors, want about %d", n, want)
+	}
+	if clean, none := FlipBitsAtRate(burst, 0, 1); !bytes.Equal(clean, burst) || len(none) != 0 {
+		t.Error("errors at rate 0")
+	}
+}
@@ -0,0 +1,130 @@
+package pocsag
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"sync"
+)
+
+// Capture records mono 16-bit audio from a sound card, such as a USB radio
+// interface on a receiver's discriminator or audio output, by running the
+// platform's recorder: arecord (ALSA) on Linux, ffmpeg with AVFoundation
+// on macOS and DirectShow on Windows. The recorder is an external program
+// that must be on PATH; there is no native audio backend.
+type Capture struct {
+	cmd        *exec.Cmd
+	name       string
+	out        *bufio.Reader
+	stderr     bytes.Buffer
+	sampleRate int
+	buf        []byte
+
+	stopOnce sync.Once
+	stopErr  error
+}
+
+// OpenCapture starts recording from device at sampleRate. An empty device
+// selects the system default on Linux and macOS; Windows needs the
+// DirectShow device name. Devices are listed by "arecord -L" or
+// "ffmpeg -list_devices true -f avfoundation|dshow -i dummy".
+func OpenCapture(device string, sampleRate int) (*Capture, error) {
+	rate := strconv.Itoa(sampleRate)
+	var name string
+	var args []string
+	switch runtime.GOOS {
+	case "linux":
+		if device == "" {
+			device = "default"
+		}
+		name = "arecord"
+		args = []string{"-q", "-D", device, "-t", "raw", "-f", "S16_LE", "-c", "1", "-r", rate}
+	case "darwin":
+		if device == "" {
+			device = "default"
+		}
+		name, args = "ffmpeg", ffmpegCaptureArgs("avfoundation", ":"+device, rate)
+	case "windows":
+		if device == "" {
+			return nil, fmt.Errorf("a DirectShow capture device name is required")
+		}
+		name, args = "ffmpeg", ffmpegCaptureArgs("dshow", "audio="+device, rate)
+	default:
+		return nil, fmt.Errorf("audio capture is not supported on %s", runtime.GOOS)
+	}
+
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return nil, fmt.Errorf("audio capture needs %s on PATH: %v", name, err)
+	}
+	c := &Capture{cmd: exec.Command(path, args...), name: name, sampleRate: sampleRate}
+	c.cmd.Stderr = &c.stderr
+	stdout, err := c.cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("starting %s: %v", name, err)
+	}
+	c.out = bufio.NewReader(stdout)
+	return c, nil
+}
+
+// SampleRate returns the rate the audio is recorded at.
+func (c *Capture) SampleRate() int {
+	return c.sampleRate
+}
+
+// Read fills samples with audio in the 16-bit range, as StreamDecoder
+// takes it, blocking until some is recorded. It returns io.EOF after
+// Close, or the recorder's error if it stops by itself.
+func (c *Capture) Read(samples []float32) (int, error) {
+	if cap(c.buf) < 2*len(samples) {
+		c.buf = make([]byte, 2*len(samples))
+	}
+	n, err := io.ReadFull(c.out, c.buf[:2*len(samples)])
+	for i := 0; i < n/2; i++ {
+		samples[i] = float32(int16(binary.LittleEndian.Uint16(c.buf[2*i:])))
+	}
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		err = io.EOF
+		if stopErr := c.stop(false); stopErr != nil {
+			err = stopErr
+		}
+	}
+	return n / 2, err
+}
+
+// Close stops the recorder.
+func (c *Capture) Close() error {
+	c.stop(true)
+	return nil
+}
+
+// stop ends the recorder and returns why it exited, unless it was killed.
+func (c *Capture) stop(kill bool) error {
+	c.stopOnce.Do(func() {
+		if kill {
+			c.cmd.Process.Kill()
+		}
+		err := c.cmd.Wait()
+		switch {
+		case kill || err == nil:
+		case c.stderr.Len() > 0:
+			c.stopErr = fmt.Errorf("%s: %v: %s", c.name, err, bytes.TrimSpace(c.stderr.Bytes()))
+		default:
+			c.stopErr = fmt.Errorf("%s: %v", c.name, err)
+		}
+	})
+	return c.stopErr
+}
+
+func ffmpegCaptureArgs(format, input, rate string) []string {
+	return []string{"-hide_banner", "-loglevel", "error", "-f", format, "-i", input,
+		"-ac", "1", "-ar", rate, "-f", "s16le", "pipe:1"}
+}
@@ -0,0 +1,21 @@
+package pocsag
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestOpenCaptureMissingRecorder(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("needs a platform with a default device")
+	}
+	t.Setenv("PATH", t.TempDir())
+	_, err := OpenCapture("", 48000)
+	if err == nil {
+		t.Fatal("OpenCapture succeeded without a recorder on PATH")
+	}
+	if !strings.Contains(err.Error(), "on PATH") {
+		t.Errorf("error %q does not say the recorder is missing", err)
+	}
+}
@@ -0,0 +1,190 @@
+package pocsag
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultChainLength is the longest part ChainMessage produces when no
+// limit is given. Many alphanumeric pagers hold 80 characters per page.
+const DefaultChainLength = 80
+
+// chainMarker prefixes each part of a chained message: "[2/3] ".
+const chainMarker = "[%d/%d] "
+
+var chainMarkerRe = regexp.MustCompile(`^\[(\d{1,2})/(\d{1,2})\] `)
+
+// maxChainParts keeps markers to two digits.
+const maxChainParts = 99
+
+// ChainMessage splits a message longer than maxLen characters into parts
+// of at most maxLen, each prefixed with a "[n/m] " continuation marker, for
+// pagers that cannot hold a long page. Breaks fall after a space where
+// possible, and concatenating the parts without markers gives back the
+// original text. Messages that fit are returned unchanged. Numeric
+// messages cannot carry the markers and are rejected if too long.
+func ChainMessage(msg MessageInfo, maxLen int) ([]MessageInfo, error) {
+	if maxLen <= 0 {
+		maxLen = DefaultChainLength
+	}
+	if len(msg.Message) <= maxLen {
+		return []MessageInfo{msg}, nil
+	}
+	if messagePayloadType(msg) == PayloadTypeNumeric {
+		return nil, fmt.Errorf("numeric message of %d digits exceeds %d and cannot be chained", len(msg.Message), maxLen)
+	}
+
+	// Room for the marker depends on the part count: try one-digit
+	// markers first and fall back to two digits if more parts are needed.
+	texts := splitChain(msg.Message, maxLen-len(fmt.Sprintf(chainMarker, 9, 9)))
+	if len(texts) > 9 {
+		texts = splitChain(msg.Message, maxLen-len(fmt.Sprintf(chainMarker, maxChainParts, maxChainParts)))
+	}
+	if texts == nil {
+		return nil, fmt.Errorf("chain length %d leaves no room for text", maxLen)
+	}
+	if len(texts) > maxChainParts {
+		return nil, fmt.Errorf("message needs %d parts, at most %d allowed", len(texts), maxChainParts)
+	}
+
+	parts := make([]MessageInfo, len(texts))
+	for i, text := range texts {
+		parts[i] = msg
+		parts[i].Message = fmt.Sprintf(chainMarker, i+1, len(texts)) + text
+	}
+	return parts, nil
+}
+
+// splitChain cuts text into pieces of at most room bytes, breaking after
+// a space when one falls in the second half of the piece.
+func splitChain(text string, room int) []string {
+	if room <= 0 {
+		return nil
+	}
+	var texts []string
+	for len(text) > room {
+		cut := strings.LastIndexByte(text[:room], ' ') + 1
+		if cut <= room/2 {
+			cut = room // no usable space: break mid-word
+		}
+		texts = append(texts, text[:cut])
+		text = text[cut:]
+	}
+	return append(texts, text)
+}
+
+// Reassembler joins chained message parts back together per address.
+// Messages without a continuation marker pass straight through. A chain
+// whose parts stop arriving is delivered after the timeout with the
+// missing parts shown as "..." and Partial set.
+type Reassembler struct {
+	timeout time.Duration
+	pending map[uint32]*chain
+}
+
+type chain struct {
+	first     DecodedMessage
+	parts     []string
+	codewords [][]uint32 // of each part, with DecodeOptions.IncludeRaw
+	have      []bool
+	count     int
+	partial   bool
+	updated   time.Time
+}
+
+// NewReassembler creates a Reassembler that gives up on an incomplete
+// chain after timeout without a new part.
+func NewReassembler(timeout time.Duration) *Reassembler {
+	return &Reassembler{timeout: timeout, pending: make(map[uint32]*chain)}
+}
+
+// Add takes a decoded message received at now and returns the messages
+// ready for delivery: msg itself if it is not part of a chain, the joined
+// message once its last part arrives, and any chain it displaced.
+func (r *Reassembler) Add(msg DecodedMessage, now time.Time) []DecodedMessage {
+	out := r.Expire(now)
+
+	m := chainMarkerRe.FindStringSubmatch(msg.Message)
+	if m == nil || msg.IsNumeric {
+		return append(out, msg)
+	}
+	index, _ := strconv.Atoi(m[1])
+	total, _ := strconv.Atoi(m[2])
+	if index < 1 || index > total {
+		return append(out, msg)
+	}
+
+	c := r.pending[msg.Address]
+	if c != nil && (len(c.parts) != total || c.have[index-1]) {
+		// A different or repeated chain: deliver what we have of the old one
+		out = append(out, c.join())
+		c = nil
+	}
+	if c == nil {
+		c = &chain{first: msg, parts: make([]string, total), codewords: make([][]uint32, total), have: make([]bool, total)}
+		r.pending[msg.Address] = c
+	}
+	c.parts[index-1] = msg.Message[len(m[0]):]
+	c.codewords[index-1] = msg.Codewords
+	c.have[index-1] = true
+	c.count++
+	c.partial = c.partial || msg.Partial
+	c.updated = now
+
+	if c.count == total {
+		delete(r.pending, msg.Address)
+		out = append(out, c.join())
+	}
+	return out
+}
+
+// Expire returns the chains that have had no new part for longer than the
+// timeout, joined as far as they go.
+func (r *Reassembler) Expire(now time.Time) []DecodedMessage {
+	return r.take(func(c *chain) bool { return now.Sub(c.updated) > r.timeout })
+}
+
+// Flush returns every pending chain, e.g. at the end of a recording.
+func (r *Reassembler) Flush() []DecodedMessage {
+	return r.take(func(*chain) bool { return true })
+}
+
+// take removes the chains selected by done and returns them joined, oldest
+// first.
+func (r *Reassembler) take(done func(*chain) bool) []DecodedMessage {
+	var chains []*chain
+	for addr, c := range r.pending {
+		if done(c) {
+			delete(r.pending, addr)
+			chains = append(chains, c)
+		}
+	}
+	sort.Slice(chains, func(i, j int) bool { return chains[i].updated.Before(chains[j].updated) })
+
+	var out []DecodedMessage
+	for _, c := range chains {
+		out = append(out, c.join())
+	}
+	return out
+}
+
+func (c *chain) join() DecodedMessage {
+	msg := c.first
+	msg.Codewords = nil
+	var b strings.Builder
+	for i, part := range c.parts {
+		if !c.have[i] {
+			b.WriteString("...")
+			continue
+		}
+		b.WriteString(part)
+		msg.Codewords = append(msg.Codewords, c.codewords[i]...)
+	}
+	msg.Message = b.String()
+	msg.Partial = c.partial || c.count < len(c.parts)
+	return msg
+}
@@ -0,0 +1,82 @@
+package pocsag
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChainMessageRoundTrip(t *testing.T) {
+	text := strings.Repeat("THE QUICK BROWN FOX JUMPS OVER THE LAZY DOG ", 6)
+	parts, err := ChainMessage(MessageInfo{Address: 1234, Message: text, Function: FuncAlphanumeric}, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) < 7 {
+		t.Fatalf("got %d parts", len(parts))
+	}
+	for i, p := range parts {
+		if len(p.Message) > 40 {
+			t.Errorf("part %d is %d characters: %q", i+1, len(p.Message), p.Message)
+		}
+		if p.Address != 1234 {
+			t.Errorf("part %d address %d", i+1, p.Address)
+		}
+	}
+	if !strings.HasPrefix(parts[0].Message, "[1/") || strings.HasSuffix(parts[0].Message, "QU") {
+		t.Errorf("first part %q: want a marker and a word break", parts[0].Message)
+	}
+
+	// Send the parts on air, in a burst, and put them back together
+	decoded, err := DecodeFromBinary(CreatePOCSAGBurst(parts))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewReassembler(time.Minute)
+	now := time.Now()
+	var out []DecodedMessage
+	for _, msg := range decoded {
+		out = append(out, r.Add(msg, now)...)
+	}
+	if len(out) != 1 || out[0].Message != text || out[0].Partial {
+		t.Errorf("reassembled %+v", out)
+	}
+
+	if short, _ := ChainMessage(MessageInfo{Message: "SHORT"}, 40); len(short) != 1 || short[0].Message != "SHORT" {
+		t.Errorf("short message changed: %+v", short)
+	}
+	if _, err := ChainMessage(MessageInfo{Message: strings.Repeat("1", 50), PayloadType: PayloadTypeNumeric}, 40); err == nil {
+		t.Error("long numeric message chained")
+	}
+}
+
+func TestReassemblerTimeout(t *testing.T) {
+	r := NewReassembler(10 * time.Second)
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	if out := r.Add(DecodedMessage{Address: 5, Message: "PLAIN"}, start); len(out) != 1 || out[0].Message != "PLAIN" {
+		t.Errorf("unchained message: %+v", out)
+	}
+	if out := r.Add(DecodedMessage{Address: 7, Message: "[1/3] ONE "}, start); len(out) != 0 {
+		t.Errorf("first part delivered early: %+v", out)
+	}
+	r.Add(DecodedMessage{Address: 7, Message: "[3/3] THREE"}, start.Add(5*time.Second))
+
+	if out := r.Expire(start.Add(10 * time.Second)); len(out) != 0 {
+		t.Errorf("expired too soon: %+v", out)
+	}
+	out := r.Expire(start.Add(16 * time.Second))
+	if len(out) != 1 || out[0].Message != "ONE ...THREE" || !out[0].Partial {
+		t.Errorf("expired chain: %+v", out)
+	}
+
+	// A new chain for the same address displaces an unfinished one
+	r.Add(DecodedMessage{Address: 9, Message: "[1/2] OLD "}, start)
+	out = r.Add(DecodedMessage{Address: 9, Message: "[1/2] NEW "}, start)
+	if len(out) != 1 || out[0].Message != "OLD ..." {
+		t.Errorf("displaced chain: %+v", out)
+	}
+	if out := r.Flush(); len(out) != 1 || out[0].Message != "NEW ..." {
+		t.Errorf("Flush: %+v", out)
+	}
+}
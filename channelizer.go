@@ -0,0 +1,240 @@
+package pocsag
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+	"sync"
+)
+
+const (
+	// channelFilterCutoff is the channel filter's cutoff in Hz: 4.5 kHz
+	// deviation plus the data's sidebands and some tuning error, keeping
+	// out the nearer tone of a channel 12.5 kHz away, which would
+	// otherwise pull the AFC.
+	channelFilterCutoff = 7000
+	// channelFilterTaps is the length of the channel filter.
+	channelFilterTaps = 127
+	// channelFilterRate is the rate each channel is averaged down to before
+	// the channel filter, which then decimates it to about iqAudioRate.
+	channelFilterRate = 2 * iqAudioRate
+)
+
+// Channelizer decodes several paging channels at once from one wideband
+// IQ stream, such as an SDR tuned between them. Each channel is mixed down
+// to baseband, averaged and low-pass filtered down to about 48 kHz, and
+// decoded by an IQDecoder of its own, the channels in parallel.
+type Channelizer struct {
+	channels []*channel
+}
+
+// channel is one frequency a Channelizer decodes.
+type channel struct {
+	frequencyHz int64
+
+	// Mixer and averaging decimator. mix holds the mixer's rotation
+	// through one average, scaled by 1/avgLen, so that each average is a
+	// fixed-size dot product turned by rot only once.
+	mix    []complex64
+	rot    complex128
+	stepN  complex128 // rotation over one average
+	avgLen int
+	sum    complex64
+	count  int
+
+	// Channel filter and its decimator, on real and imaginary parts apart
+	taps           []float32
+	histRe, histIm []float32 // the last len(taps) inputs, twice over for a contiguous window
+	pos            int
+	decim          int
+	skip           int
+
+	out     []complex64
+	decoder *IQDecoder
+}
+
+// NewChannelizer creates a Channelizer for IQ samples at sampleRate from
+// a receiver tuned to centerHz, decoding each of frequencies at baudRate
+// with opts. Each frequency must lie far enough inside the sampled band
+// for a whole channel to fit.
+func NewChannelizer(sampleRate int, centerHz int64, frequencies []int64, baudRate int, opts DecodeOptions) (*Channelizer, error) {
+	if len(frequencies) == 0 {
+		return nil, fmt.Errorf("no channels given")
+	}
+	avgLen := max(sampleRate/channelFilterRate, 1)
+	filterRate := sampleRate / avgLen
+	decim := max(filterRate/iqAudioRate, 1)
+	taps := lowPassTaps(channelFilterTaps, channelFilterCutoff/float64(filterRate))
+
+	c := &Channelizer{}
+	seen := make(map[int64]bool)
+	for _, f := range frequencies {
+		offset := f - centerHz
+		if math.Abs(float64(offset))+channelFilterCutoff > float64(sampleRate)/2 {
+			return nil, fmt.Errorf("%.4f MHz is outside the %.3f MHz received around %.4f MHz", float64(f)/1e6, float64(sampleRate)/1e6, float64(centerHz)/1e6)
+		}
+		if seen[f] {
+			return nil, fmt.Errorf("%.4f MHz given twice", float64(f)/1e6)
+		}
+		seen[f] = true
+		w := -2 * math.Pi * float64(offset) / float64(sampleRate)
+		mix := make([]complex64, avgLen)
+		for k := range mix {
+			mix[k] = complex64(cmplx.Rect(1/float64(avgLen), w*float64(k)))
+		}
+		c.channels = append(c.channels, &channel{
+			frequencyHz: f,
+			mix:         mix,
+			rot:         1,
+			stepN:       cmplx.Rect(1, w*float64(avgLen)),
+			avgLen:      avgLen,
+			taps:        taps,
+			histRe:      make([]float32, 2*len(taps)),
+			histIm:      make([]float32, 2*len(taps)),
+			decim:       decim,
+			decoder:     NewIQDecoder(filterRate/decim, baudRate, opts),
+		})
+	}
+	return c, nil
+}
+
+// lowPassTaps designs a Hamming-windowed sinc low-pass filter with unity
+// gain at DC. cutoff is a fraction of the sample rate.
+func lowPassTaps(n int, cutoff float64) []float32 {
+	taps := make([]float32, n)
+	var sum float64
+	for i := range taps {
+		x := float64(i) - float64(n-1)/2
+		h := 2 * cutoff
+		if x != 0 {
+			h = math.Sin(2*math.Pi*cutoff*x) / (math.Pi * x)
+		}
+		h *= 0.54 - 0.46*math.Cos(2*math.Pi*float64(i)/float64(n-1))
+		taps[i] = float32(h)
+		sum += h
+	}
+	for i := range taps {
+		taps[i] /= float32(sum)
+	}
+	return taps
+}
+
+// Frequencies returns the channels' frequencies in Hz, in the order given.
+func (c *Channelizer) Frequencies() []int64 {
+	frequencies := make([]int64, len(c.channels))
+	for i, ch := range c.channels {
+		frequencies[i] = ch.frequencyHz
+	}
+	return frequencies
+}
+
+// Write decodes iq on every channel and returns the messages completed by
+// it, with FrequencyHz set to their channel's.
+func (c *Channelizer) Write(iq []complex64) []DecodedMessage {
+	found := make([][]DecodedMessage, len(c.channels))
+	var wg sync.WaitGroup
+	for i, ch := range c.channels {
+		wg.Add(1)
+		go func(i int, ch *channel) {
+			defer wg.Done()
+			found[i] = ch.decoder.Write(ch.filter(iq))
+		}(i, ch)
+	}
+	wg.Wait()
+	return c.label(found)
+}
+
+// Flush ends the stream and returns any message still in progress on any
+// channel.
+func (c *Channelizer) Flush() []DecodedMessage {
+	found := make([][]DecodedMessage, len(c.channels))
+	for i, ch := range c.channels {
+		found[i] = ch.decoder.Flush()
+	}
+	return c.label(found)
+}
+
+func (c *Channelizer) label(found [][]DecodedMessage) []DecodedMessage {
+	var messages []DecodedMessage
+	for i, msgs := range found {
+		for _, msg := range msgs {
+			msg.FrequencyHz = c.channels[i].frequencyHz
+			messages = append(messages, msg)
+		}
+	}
+	return messages
+}
+
+// filter mixes the channel in iq down to baseband and decimates it to the
+// decoder's rate. The work is in mixSum and dot, whose loops over
+// fixed-size blocks keep several sums going at once so that they pipeline.
+func (ch *channel) filter(iq []complex64) []complex64 {
+	ch.out = ch.out[:0]
+	n := len(ch.taps)
+	for len(iq) > 0 {
+		k := min(len(iq), ch.avgLen-ch.count)
+		ch.sum += mixSum(iq[:k], ch.mix[ch.count:ch.count+k])
+		ch.count += k
+		iq = iq[k:]
+		if ch.count < ch.avgLen {
+			break
+		}
+		x := ch.sum * complex64(ch.rot)
+		ch.rot *= ch.stepN
+		ch.sum, ch.count = 0, 0
+
+		ch.histRe[ch.pos], ch.histRe[ch.pos+n] = real(x), real(x)
+		ch.histIm[ch.pos], ch.histIm[ch.pos+n] = imag(x), imag(x)
+		if ch.pos++; ch.pos == n {
+			ch.pos = 0
+		}
+		if ch.skip++; ch.skip < ch.decim {
+			continue
+		}
+		ch.skip = 0
+		re := dot(ch.histRe[ch.pos:ch.pos+n], ch.taps)
+		im := dot(ch.histIm[ch.pos:ch.pos+n], ch.taps)
+		ch.out = append(ch.out, complex(re, im))
+	}
+	// Keep the mixer's rounding errors from growing
+	ch.rot /= complex(cmplx.Abs(ch.rot), 0)
+	return ch.out
+}
+
+// mixSum returns the sum of x[i]*m[i]. m must be at least as long as x.
+func mixSum(x, m []complex64) complex64 {
+	m = m[:len(x)]
+	var re0, im0, re1, im1 float32
+	i := 0
+	for ; i+1 < len(x); i += 2 {
+		a, b := x[i], m[i]
+		re0 += real(a)*real(b) - imag(a)*imag(b)
+		im0 += real(a)*imag(b) + imag(a)*real(b)
+		a, b = x[i+1], m[i+1]
+		re1 += real(a)*real(b) - imag(a)*imag(b)
+		im1 += real(a)*imag(b) + imag(a)*real(b)
+	}
+	if i < len(x) {
+		a, b := x[i], m[i]
+		re0 += real(a)*real(b) - imag(a)*imag(b)
+		im0 += real(a)*imag(b) + imag(a)*real(b)
+	}
+	return complex(re0+re1, im0+im1)
+}
+
+// dot returns the dot product of a and b, which must be as long as a.
+func dot(a, b []float32) float32 {
+	b = b[:len(a)]
+	var s0, s1, s2, s3 float32
+	i := 0
+	for ; i+3 < len(a); i += 4 {
+		s0 += a[i] * b[i]
+		s1 += a[i+1] * b[i+1]
+		s2 += a[i+2] * b[i+2]
+		s3 += a[i+3] * b[i+3]
+	}
+	for ; i < len(a); i++ {
+		s0 += a[i] * b[i]
+	}
+	return s0 + s1 + s2 + s3
+}
@@ -0,0 +1,42 @@
+package pocsag
+
+// Channel is the messages sent as one burst on one RF frequency.
+type Channel struct {
+	FrequencyHz int64
+	BaudRate    int
+	Messages    []MessageInfo
+}
+
+// ChannelPlan assigns messages to RF channels, so a multi-channel paging
+// simulator or a transmitter that hops between frequencies can be driven
+// from one list of messages. Each channel goes out as its own burst.
+type ChannelPlan struct {
+	Channels []Channel
+}
+
+// Add appends msg to the channel at frequencyHz and baudRate, starting a
+// new channel after the others if there is none yet, and returns the
+// channel's index.
+func (p *ChannelPlan) Add(frequencyHz int64, baudRate int, msg MessageInfo) int {
+	for i, ch := range p.Channels {
+		if ch.FrequencyHz == frequencyHz && ch.BaudRate == baudRate {
+			p.Channels[i].Messages = append(p.Channels[i].Messages, msg)
+			return i
+		}
+	}
+	p.Channels = append(p.Channels, Channel{FrequencyHz: frequencyHz, BaudRate: baudRate, Messages: []MessageInfo{msg}})
+	return len(p.Channels) - 1
+}
+
+// Describe lays out each channel's burst as Encoder.Describe does, with
+// the channel's frequency filled in. opts apply to every channel; the
+// baud rate is the channel's own.
+func (p *ChannelPlan) Describe(opts ...Option) []TransmissionDescription {
+	descs := make([]TransmissionDescription, len(p.Channels))
+	for i, ch := range p.Channels {
+		e := NewEncoder(append(opts[:len(opts):len(opts)], WithBaudRate(ch.BaudRate))...)
+		descs[i] = e.Describe(ch.Messages)
+		descs[i].FrequencyHz = ch.FrequencyHz
+	}
+	return descs
+}
@@ -0,0 +1,36 @@
+package pocsag
+
+import "testing"
+
+func TestChannelPlan(t *testing.T) {
+	var plan ChannelPlan
+	for _, m := range []struct {
+		freq int64
+		baud int
+		msg  MessageInfo
+		want int
+	}{
+		{439987500, BaudRate1200, MessageInfo{Address: 1, Message: "A", Function: FuncAlphanumeric}, 0},
+		{466075000, BaudRate512, MessageInfo{Address: 2, Message: "B", Function: FuncAlphanumeric}, 1},
+		{439987500, BaudRate1200, MessageInfo{Address: 3, Message: "C", Function: FuncAlphanumeric}, 0},
+		{439987500, BaudRate2400, MessageInfo{Address: 4, Message: "D", Function: FuncAlphanumeric}, 2},
+	} {
+		if got := plan.Add(m.freq, m.baud, m.msg); got != m.want {
+			t.Errorf("%q went to channel %d, want %d", m.msg.Message, got, m.want)
+		}
+	}
+
+	descs := plan.Describe(WithPreambleBits(1024))
+	if len(descs) != 3 {
+		t.Fatalf("%d descriptions", len(descs))
+	}
+	for i, d := range descs {
+		ch := plan.Channels[i]
+		if d.FrequencyHz != ch.FrequencyHz || d.BaudRate != ch.BaudRate || d.PreambleBits != 1024 || len(d.Messages) != len(ch.Messages) {
+			t.Errorf("channel %d: %+v", i, d)
+		}
+	}
+	if descs[0].Messages[1].Message != "C" {
+		t.Errorf("channel 0 messages: %+v", descs[0].Messages)
+	}
+}
@@ -0,0 +1,107 @@
+package pocsag
+
+import (
+	"math"
+	"math/cmplx"
+	"time"
+)
+
+// ChannelSimulator applies the impairments of a radio path to complex
+// baseband, such as GenerateIQ produces, so decoders can be tested against
+// what a receiver in the field hears instead of a perfect signal.
+type ChannelSimulator struct {
+	// SampleRate is the rate of the IQ given to Apply, in Hz.
+	SampleRate int
+	// Simulcast, if set, replaces the signal with the sum of these
+	// copies of it, one per transmitter a receiver hears in the overlap
+	// of a simulcast system.
+	Simulcast []SimulcastPath
+	// FrequencyOffset, in Hz, is how far off the signal is received: the
+	// tuning error of a cheap oscillator, or a Doppler shift of v/λ.
+	FrequencyOffset float64
+	// Drift changes the offset steadily, in Hz per second, as an
+	// oscillator warms up or a vehicle passes.
+	Drift float64
+	// WobbleAmplitude and WobbleRate add to the offset a swing of
+	// ±WobbleAmplitude Hz, WobbleRate times a second, as a TCXO's
+	// compensation hunts around its setpoint.
+	WobbleAmplitude float64
+	WobbleRate      float64
+}
+
+// SimulcastPath is one transmitter of a simulcast system as a receiver
+// hears it. Where copies of a page arrive a sizable fraction of a bit
+// apart at similar strength, their sum has bits smeared into each other
+// and, where the carriers cancel, deep fades: the overlap distortion that
+// launch delays (WithLaunchDelay) are tuned to keep out of the service
+// area.
+type SimulcastPath struct {
+	// Delay is how much later this copy arrives, from launch delay and
+	// path length (about 3.3 µs per km); it need not be a whole number
+	// of samples.
+	Delay time.Duration
+	// Amplitude scales the copy: 1 is as strong as the input, 0.5 is
+	// 6 dB down.
+	Amplitude float64
+	// Phase is the carrier phase of the copy relative to the input, in
+	// radians; copies in antiphase cancel where their bits agree.
+	Phase float64
+}
+
+// Apply returns iq as the receiver hears it: the simulcast copies summed,
+// then shifted in frequency. The result is longer than iq by the longest
+// simulcast delay, so that no copy is cut short.
+func (c ChannelSimulator) Apply(iq []complex64) []complex64 {
+	out := append([]complex64(nil), iq...)
+	if len(c.Simulcast) > 0 {
+		var longest float64
+		for _, p := range c.Simulcast {
+			longest = math.Max(longest, c.samples(p.Delay))
+		}
+		out = make([]complex64, len(iq)+int(math.Ceil(longest)))
+		for _, p := range c.Simulcast {
+			addDelayed(out, iq, c.samples(p.Delay), complex64(cmplx.Rect(p.Amplitude, p.Phase)))
+		}
+	}
+	c.shiftFrequency(out)
+	return out
+}
+
+// shiftFrequency mixes iq with the offset, drift, and wobble of c, in
+// place, keeping the phase continuous as the frequency moves.
+func (c ChannelSimulator) shiftFrequency(iq []complex64) {
+	if c.FrequencyOffset == 0 && c.Drift == 0 && c.WobbleAmplitude == 0 {
+		return
+	}
+	rate := float64(c.SampleRate)
+	var phase float64
+	for i := range iq {
+		t := float64(i) / rate
+		f := c.FrequencyOffset + c.Drift*t + c.WobbleAmplitude*math.Sin(2*math.Pi*c.WobbleRate*t)
+		iq[i] *= complex64(cmplx.Rect(1, phase))
+		phase = math.Mod(phase+2*math.Pi*f/rate, 2*math.Pi)
+	}
+}
+
+// samples converts d into samples at c.SampleRate.
+func (c ChannelSimulator) samples(d time.Duration) float64 {
+	return math.Max(0, d.Seconds()*float64(c.SampleRate))
+}
+
+// addDelayed adds in, delayed by delay samples and scaled by gain, to out,
+// interpolating linearly between samples for fractional delays.
+func addDelayed(out, in []complex64, delay float64, gain complex64) {
+	whole := int(delay)
+	frac := float32(delay - float64(whole))
+	for i := range out {
+		j := i - whole
+		var s complex64
+		if j >= 0 && j < len(in) {
+			s = in[j] * complex(1-frac, 0)
+		}
+		if j-1 >= 0 && j-1 < len(in) {
+			s += in[j-1] * complex(frac, 0)
+		}
+		out[i] += s * gain
+	}
+}
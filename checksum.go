@@ -0,0 +1,120 @@
+package pocsag
+
+import (
+	"fmt"
+	"hash/crc32"
+	"strconv"
+)
+
+// Checksum selects the checksum sealed inside an encrypted page with the
+// message, which tells a decoder that the key was right and the page came
+// through intact. It follows the message after a NUL, in hex.
+type Checksum uint8
+
+const (
+	// ChecksumCRC32 is CRC-32 (IEEE), 9 characters; pages without a
+	// PayloadHeader always use it
+	ChecksumCRC32 Checksum = iota
+	// ChecksumCRC16 is CRC-16/CCITT-FALSE, 5 characters
+	ChecksumCRC16
+	// ChecksumCRC32C is CRC-32C (Castagnoli), 9 characters
+	ChecksumCRC32C
+)
+
+// There is no way to leave the checksum out: the AES ciphers are CTR,
+// which is not authenticated, so the checksum is all that tells a wrong
+// key or a damaged page.
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// ParseChecksum parses "crc32", "crc16", or "crc32c".
+func ParseChecksum(name string) (Checksum, error) {
+	for c := ChecksumCRC32; c <= ChecksumCRC32C; c++ {
+		if c.String() == name {
+			return c, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown checksum %q: use crc32, crc16, or crc32c", name)
+}
+
+func (c Checksum) String() string {
+	switch c {
+	case ChecksumCRC32:
+		return "crc32"
+	case ChecksumCRC16:
+		return "crc16"
+	case ChecksumCRC32C:
+		return "crc32c"
+	default:
+		return fmt.Sprintf("Checksum(%d)", uint8(c))
+	}
+}
+
+// digits returns the number of hex digits of the checksum.
+func (c Checksum) digits() int {
+	switch c {
+	case ChecksumCRC16:
+		return 4
+	default:
+		return 8
+	}
+}
+
+func (c Checksum) sum(message string) uint32 {
+	switch c {
+	case ChecksumCRC16:
+		return uint32(crc16CCITT([]byte(message)))
+	case ChecksumCRC32C:
+		return crc32.Checksum([]byte(message), castagnoli)
+	default:
+		return crc32.ChecksumIEEE([]byte(message))
+	}
+}
+
+// seal appends the checksum of message to it.
+func (c Checksum) seal(message string) string {
+	return fmt.Sprintf("%s\x00%0*x", message, c.digits(), c.sum(message))
+}
+
+// open checks and removes the checksum of a decrypted page.
+func (c Checksum) open(decrypted string) (string, error) {
+	n := c.digits()
+	if len(decrypted) < n+1 {
+		return "", fmt.Errorf("decrypted message too short for CRC verification")
+	}
+
+	// Extract CRC and message
+	crcPos := len(decrypted) - n - 1
+	if decrypted[crcPos] != '\x00' {
+		return "", fmt.Errorf("invalid CRC separator")
+	}
+	message := decrypted[:crcPos]
+	crcStr := decrypted[crcPos+1:]
+
+	expectedCRC := c.sum(message)
+	actualCRC, err := strconv.ParseUint(crcStr, 16, 32)
+	if err != nil {
+		return "", fmt.Errorf("invalid CRC format: %v", err)
+	}
+	if uint64(expectedCRC) != actualCRC {
+		return "", fmt.Errorf("CRC verification failed: expected %0*x, got %0*x", n, expectedCRC, n, actualCRC)
+	}
+	return message, nil
+}
+
+// crc16CCITT is CRC-16/CCITT-FALSE: polynomial 0x1021, initial value
+// 0xFFFF, no reflection.
+func crc16CCITT(data []byte) uint16 {
+	crc := uint16(0xFFFF)
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,12 @@
+// Command pocsag-diff is the same as "pocsag diff".
+package main
+
+import (
+	"os"
+
+	"github.com/sqpp/pocsag-golang/v2/internal/cli"
+)
+
+func main() {
+	cli.Diff.Run("pocsag-diff", os.Args[1:])
+}
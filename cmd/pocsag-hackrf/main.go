@@ -0,0 +1,12 @@
+// Command pocsag-hackrf is kept for compatibility; it is the same as "pocsag hackrf".
+package main
+
+import (
+	"os"
+
+	"github.com/sqpp/pocsag-golang/v2/internal/cli"
+)
+
+func main() {
+	cli.HackRF.Run("pocsag-hackrf", os.Args[1:])
+}
@@ -0,0 +1,12 @@
+// Command pocsag-replay is the same as "pocsag replay".
+package main
+
+import (
+	"os"
+
+	"github.com/sqpp/pocsag-golang/v2/internal/cli"
+)
+
+func main() {
+	cli.Replay.Run("pocsag-replay", os.Args[1:])
+}
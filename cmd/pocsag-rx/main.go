@@ -0,0 +1,12 @@
+// Command pocsag-rx is kept for compatibility; it is the same as "pocsag monitor".
+package main
+
+import (
+	"os"
+
+	"github.com/sqpp/pocsag-golang/v2/internal/cli"
+)
+
+func main() {
+	cli.Monitor.Run("pocsag-rx", os.Args[1:])
+}
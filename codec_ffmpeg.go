@@ -0,0 +1,37 @@
+//go:build ffmpeg
+// +build ffmpeg
+
+package pocsag
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+)
+
+// encodeLossy transcodes wavData by piping it through ffmpeg.
+func encodeLossy(wavData []byte, format AudioFormat) ([]byte, error) {
+	args := []string{"-hide_banner", "-loglevel", "error", "-f", "wav", "-i", "pipe:0"}
+	switch format {
+	case AudioMP3:
+		args = append(args, "-c:a", "libmp3lame", "-b:a", "64k", "-f", "mp3")
+	case AudioOpus:
+		// Opus only runs at 48 kHz internally; force it so low-rate input is
+		// resampled by ffmpeg rather than rejected.
+		args = append(args, "-c:a", "libopus", "-b:a", "32k", "-ar", "48000", "-f", "ogg")
+	}
+	args = append(args, "pipe:1")
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("ffmpeg", args...)
+	cmd.Stdin = bytes.NewReader(wavData)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := bytes.TrimSpace(stderr.Bytes()); len(msg) > 0 {
+			return nil, fmt.Errorf("ffmpeg %s encode failed: %v: %s", format, err, msg)
+		}
+		return nil, fmt.Errorf("ffmpeg %s encode failed: %v", format, err)
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,13 @@
+//go:build !ffmpeg
+// +build !ffmpeg
+
+package pocsag
+
+import (
+	"fmt"
+)
+
+// encodeLossy is a stub for builds without the ffmpeg tag
+func encodeLossy(wavData []byte, format AudioFormat) ([]byte, error) {
+	return nil, fmt.Errorf("%s output requires building with -tags ffmpeg", format)
+}
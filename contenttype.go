@@ -0,0 +1,119 @@
+package pocsag
+
+// typeConfidence is how much more plausible one decode of a message must
+// be than the other for DecodeOptions.DetectType to be sure of it.
+const typeConfidence = 0.1
+
+// guessPayloadType decides whether message codewords carry numeric or
+// alphanumeric content by decoding them both ways, as PDW does, and
+// scoring the characters each gives. It returns the function's type when
+// the two score the same, and sure is false when they are within
+// typeConfidence of each other.
+func guessPayloadType(codewords []uint32, corrupt []bool, function uint8) (payloadType string, sure bool) {
+	bits, bad := codewordBits(codewords, corrupt)
+	numeric, alpha := numericScore(bits, bad), alphaScore(bits, bad)
+	switch {
+	case numeric > alpha:
+		payloadType = PayloadTypeNumeric
+	case alpha > numeric:
+		payloadType = PayloadTypeAlpha
+	case function == FuncNumeric:
+		return PayloadTypeNumeric, false
+	default:
+		return PayloadTypeAlpha, false
+	}
+	return payloadType, numeric-alpha >= typeConfidence || alpha-numeric >= typeConfidence
+}
+
+// numericScore is the share of the BCD digits in bits that numeric pages
+// carry: digits, spaces, and hyphens. The spare code and the urgency and
+// bracket symbols are rare on air but come up in text read as digits.
+// Zero bits at the end are left out: they pad text, while numeric pages
+// are padded with spaces.
+func numericScore(bits, bad []byte) float64 {
+	end := len(bits)
+	for end > 0 && bits[end-1] == 0 && bad[end-1] == 0 {
+		end--
+	}
+	var n, good float64
+	for i := 0; i+3 < end; i += 4 {
+		if anySet(bad[i : i+4]) {
+			continue
+		}
+		nibble := BitReverse4(bits[i]<<3 | bits[i+1]<<2 | bits[i+2]<<1 | bits[i+3])
+		n++
+		if ch := bcdToChar(nibble); ch >= '0' && ch <= '9' || ch == ' ' || ch == '-' {
+			good++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return good / n
+}
+
+// alphaScore is the share of the 7-bit characters in bits that text pages
+// carry, up to a NUL, ETX, EOT, or ETB followed only by fill. Letters,
+// digits, spaces, line breaks, and common punctuation count fully, other
+// printable characters (national letters on some networks) half, and
+// control characters not at all.
+func alphaScore(bits, bad []byte) float64 {
+	var n, good float64
+	for i := 0; i+6 < len(bits); i += 7 {
+		if anySet(bad[i : i+7]) {
+			continue
+		}
+		charBits := byte(0)
+		for j := 0; j < 7; j++ {
+			charBits = (charBits << 1) | bits[i+j]
+		}
+		char := BitReverse8(charBits << 1)
+		if (char == 0x00 || char == ETX || char == EOT || char == ETB) && onlyFill(bits[i+7:]) {
+			break
+		}
+		n++
+		switch {
+		case char >= 'A' && char <= 'Z', char >= 'a' && char <= 'z', char >= '0' && char <= '9':
+			good++
+		case char == ' ', char == '\r', char == '\n':
+			good++
+		case char >= 0x20 && char <= 0x7E:
+			if isCommonPunct(char) {
+				good++
+			} else {
+				good += 0.5
+			}
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return good / n
+}
+
+// onlyFill reports whether bits, after the end of a text, hold nothing but
+// the fill an encoder pads the last codeword with: zeros, or a character
+// repeated (see WithAlphaFill).
+func onlyFill(bits []byte) bool {
+	if len(bits) < 7 {
+		return true
+	}
+	var fill byte
+	for j := 0; j < 7; j++ {
+		fill |= bits[j] << j
+	}
+	for i, b := range bits {
+		if b != fill>>(i%7)&1 {
+			return false
+		}
+	}
+	return true
+}
+
+func isCommonPunct(char byte) bool {
+	switch char {
+	case '.', ',', ':', ';', '-', '/', '(', ')', '\'', '"', '!', '?', '#', '@', '&', '+', '=', '*', '%':
+		return true
+	}
+	return false
+}
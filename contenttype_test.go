@@ -0,0 +1,35 @@
+package pocsag
+
+import "testing"
+
+func TestDetectType(t *testing.T) {
+	// Each page sent on the function of the other type
+	pages := []MessageInfo{
+		{Address: 8, Function: FuncAlphanumeric, PayloadType: PayloadTypeNumeric, Message: "07700 900123"},
+		{Address: 16, Function: FuncNumeric, PayloadType: PayloadTypeAlpha, Message: "FIRE AT 12 HIGH ST, RESPOND"},
+		{Address: 24, Function: FuncNumeric, PayloadType: PayloadTypeAlpha, Message: "Hi"},
+	}
+	wav := ConvertToAudioWithBaudRate(CreatePOCSAGBurstWithBaudRate(pages, BaudRate1200), BaudRate1200)
+
+	decoded, err := DecodeFromAudioWithOptions(wav, BaudRate1200, DecodeOptions{DetectType: true})
+	if err != nil || len(decoded) != 3 {
+		t.Fatalf("got %+v, err %v", decoded, err)
+	}
+	if msg := decoded[0]; !msg.IsNumeric || msg.Message != pages[0].Message || msg.Alternative != "" {
+		t.Errorf("numeric page: %+v", msg)
+	}
+	if msg := decoded[1]; msg.IsNumeric || msg.Message != pages[1].Message || msg.Alternative != "" {
+		t.Errorf("text page: %+v", msg)
+	}
+	// Two letters read as well as digits, so the function decides and the
+	// text is offered as well
+	if msg := decoded[2]; !msg.IsNumeric || msg.Alternative != "Hi" {
+		t.Errorf("short page: %+v", msg)
+	}
+
+	// An explicit type wins
+	decoded, _ = DecodeFromAudioWithOptions(wav, BaudRate1200, DecodeOptions{DetectType: true, PayloadTypes: map[uint32]string{8: PayloadTypeAlpha}})
+	if decoded[0].IsNumeric || decoded[0].Alternative != "" {
+		t.Errorf("override: %+v", decoded[0])
+	}
+}
@@ -0,0 +1,133 @@
+-- Wireshark dissector for the POCSAG packets of pocsag decode/monitor
+-- --format pcapng (LINKTYPE_USER0). The layout is documented on
+-- pocsag.PcapWriter; all fields are big-endian.
+--
+--   wireshark -X lua_script:contrib/pocsag.lua pages.pcapng
+--
+-- or copy this file to Wireshark's personal plugins folder.
+
+local pocsag = Proto("pocsag", "POCSAG paging message")
+
+local f = pocsag.fields
+f.version = ProtoField.uint8("pocsag.version", "Version")
+f.flags = ProtoField.uint8("pocsag.flags", "Flags", base.HEX)
+f.partial = ProtoField.bool("pocsag.flags.partial", "Partial", 8, nil, 0x01)
+f.numeric = ProtoField.bool("pocsag.flags.numeric", "Numeric", 8, nil, 0x02)
+f.verified = ProtoField.bool("pocsag.flags.verified", "Verified", 8, nil, 0x04)
+f.func = ProtoField.uint8("pocsag.function", "Function")
+f.address = ProtoField.uint32("pocsag.address", "Address (RIC)")
+f.baud = ProtoField.uint16("pocsag.baud", "Baud rate")
+f.count = ProtoField.uint16("pocsag.codewords", "Codewords")
+f.frequency = ProtoField.uint64("pocsag.frequency", "Frequency (Hz)")
+f.codeword = ProtoField.uint32("pocsag.codeword", "Codeword", base.HEX)
+f.cw_type = ProtoField.string("pocsag.codeword.type", "Type")
+f.cw_address = ProtoField.uint32("pocsag.codeword.address", "Address bits", base.DEC, nil, 0x7FFFE000)
+f.cw_function = ProtoField.uint32("pocsag.codeword.function", "Function", base.DEC, nil, 0x00001800)
+f.cw_data = ProtoField.uint32("pocsag.codeword.data", "Message bits", base.HEX, nil, 0x7FFFF800)
+f.cw_bch = ProtoField.bool("pocsag.codeword.bch_ok", "BCH and parity OK")
+f.message = ProtoField.string("pocsag.message", "Message")
+
+local HEADER_LEN = 20
+local SYNC = 0x7CD215D8
+local IDLE = 0x7A89C197
+
+-- bitsOf returns the 32 bits of x, most significant first, without
+-- relying on a bit library, which differs between Wireshark's Lua versions
+local function bitsOf(x)
+	local bits = {}
+	for i = 32, 1, -1 do
+		bits[i] = x % 2
+		x = math.floor(x / 2)
+	end
+	return bits
+end
+
+-- bchOK checks a codeword's BCH(31,21) remainder against the generator
+-- 0x769 and its even parity, as pocsag.DoesWordPassBCH does
+local function bchOK(cw)
+	local bits = bitsOf(cw)
+	local ones = 0
+	for i = 1, 32 do
+		ones = ones + bits[i]
+	end
+	if ones % 2 ~= 0 then
+		return false
+	end
+	local gen = bitsOf(0x769)
+	for i = 1, 21 do
+		if bits[i] == 1 then
+			for j = 0, 10 do
+				bits[i + j] = (bits[i + j] + gen[22 + j]) % 2
+			end
+		end
+	end
+	for i = 22, 31 do
+		if bits[i] ~= 0 then
+			return false
+		end
+	end
+	return true
+end
+
+function pocsag.dissector(tvb, pinfo, tree)
+	if tvb:len() < HEADER_LEN or tvb(0, 1):uint() ~= 1 then
+		return 0
+	end
+	pinfo.cols.protocol = "POCSAG"
+
+	local n = tvb(10, 2):uint()
+	local subtree = tree:add(pocsag, tvb(), "POCSAG")
+	subtree:add(f.version, tvb(0, 1))
+	local flags = subtree:add(f.flags, tvb(1, 1))
+	flags:add(f.partial, tvb(1, 1))
+	flags:add(f.numeric, tvb(1, 1))
+	flags:add(f.verified, tvb(1, 1))
+	subtree:add(f.func, tvb(2, 1))
+	subtree:add(f.address, tvb(4, 4))
+	subtree:add(f.baud, tvb(8, 2))
+	subtree:add(f.count, tvb(10, 2))
+	subtree:add(f.frequency, tvb(12, 8))
+
+	local textOff = HEADER_LEN + 4 * n
+	if tvb:len() < textOff then
+		subtree:add_expert_info(PI_MALFORMED, PI_ERROR, "Packet shorter than its codewords")
+		return tvb:len()
+	end
+	for i = 0, n - 1 do
+		local r = tvb(HEADER_LEN + 4 * i, 4)
+		local cw = r:uint()
+		local item = subtree:add(f.codeword, r)
+		local kind
+		if cw == SYNC then
+			kind = "Sync"
+		elseif cw == IDLE then
+			kind = "Idle"
+		elseif cw >= 0x80000000 then
+			kind = "Message"
+			item:add(f.cw_data, r)
+		else
+			kind = "Address"
+			item:add(f.cw_address, r)
+			item:add(f.cw_function, r)
+		end
+		item:add(f.cw_type, kind)
+		local ok = bchOK(cw)
+		item:add(f.cw_bch, ok)
+		item:append_text(" (" .. kind .. (ok and "" or ", BCH error") .. ")")
+		if not ok then
+			item:add_expert_info(PI_CHECKSUM, PI_WARN, "Codeword fails BCH or parity check")
+		end
+	end
+
+	local text = ""
+	if tvb:len() > textOff then
+		local r = tvb(textOff)
+		text = r:string(ENC_UTF_8)
+		subtree:add(f.message, r, text)
+	end
+	pinfo.cols.info = string.format("RIC %d, function %d: %s", tvb(4, 4):uint(), tvb(2, 1):uint(), text)
+	return tvb:len()
+end
+
+local encaps = wtap_encaps or wtap
+DissectorTable.get("wtap_encap"):add(encaps.USER0, pocsag)
@@ -1,12 +1,13 @@
 package pocsag
 
 import (
-	"bytes"
 	"encoding/binary"
 	"fmt"
 	"io"
 	"math"
+	mathbits "math/bits"
 	"strings"
+	"time"
 )
 
 // DecodedMessage represents a decoded POCSAG message
@@ -15,6 +16,51 @@ type DecodedMessage struct {
 	Function  uint8
 	Message   string
 	IsNumeric bool
+	// Partial is set when some of the message's codewords failed the BCH
+	// check. Their characters are replaced with DecodeOptions.Placeholder,
+	// and corrupted codewords at the end of the message are dropped. It is
+	// also set when the input ends in the middle of a batch before
+	// anything marks the end of the message, as it may have been cut short.
+	Partial bool
+	// BaudRate is the rate the message was received at. It is set by the
+	// audio and IQ decoders and zero for raw bitstreams.
+	BaudRate int
+	// FrequencyHz is the channel the message was received on. It is set
+	// by the Channelizer and zero otherwise.
+	FrequencyHz int64
+	// FrequencyOffsetHz is how far above the tuned frequency the IQ
+	// decoders' AFC found the transmission, in Hz, for setting a dongle's
+	// ppm correction.
+	FrequencyOffsetHz int
+	// RSSI and SNR, from the IQ decoders, measure the transmission the
+	// message came in: its power in dB relative to full scale, and the
+	// SNR of the demodulated signal in dB, as DecodeStats.SNR.
+	RSSI float64
+	SNR  float64
+	// SNRValid is set when RSSI and SNR were measured, as 0 dB is a
+	// real reading.
+	SNRValid bool
+	// Position is where the receiver was, set by the StampPosition
+	// post-processor.
+	Position *Position
+	// Verified is set when the message was sent with EncryptionSignOnly
+	// and its tag checked out against a key of DecodeOptions; the tag is
+	// then removed from Message. A page whose tag fails keeps it.
+	Verified bool
+	// Codewords, with DecodeOptions.IncludeRaw, are the codewords as
+	// received: the address codeword followed by the message codewords the
+	// text was decoded from, without idle codewords. Corrupted codewords
+	// are included unrepaired, so BCH and parity can be checked again.
+	// Those of interleaved messages are given in order and repaired, and
+	// without the flag codewords.
+	Codewords []uint32
+	// Alias and Tags are set by post-processors (see PostProcessor): the
+	// name of the pager the message is for, and labels for its content.
+	Alias string
+	Tags  []string
+	// Alternative, with DecodeOptions.DetectType, is the message decoded
+	// as the other type when its content did not make clear which it is.
+	Alternative string
 }
 
 // DecodeFromAudio decodes POCSAG from WAV audio data
@@ -23,53 +69,305 @@ func DecodeFromAudio(wavData []byte) ([]DecodedMessage, error) {
 	return DecodeFromAudioWithBaudRate(wavData, BaudRate1200)
 }
 
+// DecodeOptions controls optional stages of the audio decode pipeline.
+// The zero value enables all pre-processing, which is what off-air
+// recordings need; synthetic input decodes the same either way.
+type DecodeOptions struct {
+	// DisableDCBlock skips the high-pass filter that removes DC offset and
+	// slow drift before the zero-threshold slicer.
+	DisableDCBlock bool
+	// DisableAGC skips level normalization of weak or uneven recordings.
+	DisableAGC bool
+	// DisableAFC stops the IQ decoders correcting the carrier's frequency
+	// offset; see IQDecoder.
+	DisableAFC bool
+	// Encryption, when set, decrypts each decoded message. Messages that
+	// fail decryption are returned unchanged (they might not be encrypted).
+	Encryption EncryptionConfig
+	// NumericKey decrypts numeric messages sent with EncryptionFF1 to
+	// their address. Nothing marks such pages, so every numeric message
+	// of MinFF1Digits digits or more is decrypted: set it only where all
+	// numeric pages are encrypted.
+	NumericKey []byte
+	// Keyring decrypts messages with a PayloadHeader, with the key and
+	// cipher the header names. It is tried before Encryption.
+	Keyring Keyring
+	// Keys decrypts messages with a PayloadHeader with the keys each pager
+	// holds when the message is decoded. It is tried before Keyring.
+	Keys *KeyStore
+	// KeyProvider is asked for the key a message's PayloadHeader or
+	// signature tag names, after Keys and before Keyring.
+	KeyProvider KeyProvider
+	// Placeholder is shown for characters carried by codewords that fail
+	// the BCH check (default '?').
+	Placeholder rune
+	// TrimTrailingSpaces removes every trailing space from numeric
+	// messages. By default at most four are removed, the most the encoder
+	// adds to fill the final codeword, so spaces the sender typed before
+	// them are kept. Leading zeros are never removed.
+	TrimTrailingSpaces bool
+	// SyncWord and IdleCodeword replace FrameSyncWord and IdleCodeword
+	// for networks that use non-standard ones; zero keeps the standard
+	// value. See WithSyncWord.
+	SyncWord     uint32
+	IdleCodeword uint32
+	// IncludeRaw fills in DecodedMessage.Codewords, for archiving the
+	// received code stream next to the text.
+	IncludeRaw bool
+	// Squelch decodes only the transmissions DetectTransmissions finds,
+	// each on its own, instead of slicing the whole recording. Long noisy
+	// recordings decode much faster, and each transmission gets its own
+	// clock phase and polarity.
+	Squelch bool
+	// ForceNumeric and ForceAlpha decode every message as numeric or as
+	// alphanumeric, for networks that send numeric pages on functions
+	// other than 0 or text on function 0. ForceNumeric wins if both are
+	// set.
+	ForceNumeric bool
+	ForceAlpha   bool
+	// PayloadTypes overrides the content type of messages to particular
+	// addresses, PayloadTypeNumeric or PayloadTypeAlpha, ahead of
+	// ForceNumeric and ForceAlpha. See AddressBook.PayloadTypes.
+	PayloadTypes map[uint32]string
+	// DetectType decides from their content whether messages the options
+	// above leave to their function are numeric or alphanumeric, by
+	// decoding them both ways and keeping the more plausible text. When
+	// the two are close, the other is given as DecodedMessage.Alternative.
+	DetectType bool
+	// Terminator, usually ETX or EOT, is the character the sender ends
+	// alphanumeric messages with (see WithTerminator). Messages are then
+	// read up to it, keeping the line breaks and tabs before it, and a
+	// message without it is marked Partial, as it was cut short. By
+	// default a message ends at the first control character.
+	Terminator byte
+	// Polarity restricts the decoder to one signal polarity instead of
+	// trying both, for receivers known to deliver one: it halves the work
+	// and avoids false decodes of the inverted signal.
+	Polarity Polarity
+	// Threshold is the slicer's decision level in the 16-bit sample range,
+	// measured after DC removal and AGC (which brings the signal to about
+	// +/-16000). Zero slices at the signal's midpoint; move it towards
+	// one level for receivers whose discriminator output is asymmetric.
+	Threshold float32
+	// ClockTolerancePPM also tries bit clocks this far either side of the
+	// nominal baud rate, in parts per million, for transmitters whose
+	// clock is off by more than the clock tracking follows. Only
+	// DecodeFromAudio and its variants use it.
+	ClockTolerancePPM float64
+	// GapTimeout rides out transmitters that drop carrier briefly in the
+	// middle of a transmission. Normally losing sync ends the message in
+	// progress and the codewords that continue it after the dropout are
+	// thrown away, as they come without an address. With GapTimeout, the
+	// message is held open while the decoder hunts for the preamble and
+	// sync again, and the codewords after a sync found within GapTimeout
+	// are added to it. The batches lost in the dropout, counted from its
+	// length, show as Placeholder characters and the message is marked
+	// Partial. With Squelch, transmissions this close together
+	// are decoded as one. Raw bitstreams have no timing and ignore it.
+	GapTimeout time.Duration
+}
+
+// Polarity is the sense of the demodulated audio: which level carries bit 1.
+type Polarity int
+
+const (
+	// PolarityAuto tries both polarities and keeps the one that decodes.
+	PolarityAuto Polarity = iota
+	// PolarityNormal takes bit 1 as the negative level, as the Encoder
+	// sends it.
+	PolarityNormal
+	// PolarityInverted takes bit 1 as the positive level.
+	PolarityInverted
+)
+
+// allows reports whether slicing with bit 1 on the negative level (normal)
+// or the positive level fits p.
+func (p Polarity) allows(normal bool) bool {
+	return p == PolarityAuto || (p == PolarityNormal) == normal
+}
+
+// DefaultPlaceholder stands in for characters lost to corrupted codewords.
+const DefaultPlaceholder = '?'
+
+// maxCorruptRun is how many consecutive corrupted codewords a message may
+// contain before it is ended; longer runs mean the signal has gone.
+const maxCorruptRun = 4
+
 // DecodeFromAudioWithDecryption decodes POCSAG from WAV audio data with decryption
 func DecodeFromAudioWithDecryption(wavData []byte, baudRate int, encryption EncryptionConfig) ([]DecodedMessage, error) {
-	// First decode the audio normally
-	messages, err := DecodeFromAudioWithBaudRate(wavData, baudRate)
+	return DecodeFromAudioWithOptions(wavData, baudRate, DecodeOptions{Encryption: encryption})
+}
+
+// DecodeFromAudioWithBaudRate decodes POCSAG from WAV audio data with specified baud rate
+func DecodeFromAudioWithBaudRate(wavData []byte, baudRate int) ([]DecodedMessage, error) {
+	return DecodeFromAudioWithOptions(wavData, baudRate, DecodeOptions{})
+}
+
+// DecodeFromAudioWithOptions decodes POCSAG from WAV audio data, applying the
+// pre-processing and decryption selected in opts.
+func DecodeFromAudioWithOptions(wavData []byte, baudRate int, opts DecodeOptions) ([]DecodedMessage, error) {
+	return decodeAudio(wavData, baudRate, opts, nil)
+}
+
+func decodeAudio(wavData []byte, baudRate int, opts DecodeOptions, stats *DecodeStats) ([]DecodedMessage, error) {
+	messages, err := demodulateAudio(wavData, baudRate, opts, stats)
 	if err != nil {
 		return nil, err
 	}
+	return finishMessages(messages, baudRate, opts), nil
+}
 
-	// Decrypt messages if encryption is configured
-	if encryption.Method != EncryptionNone {
-		for i := range messages {
-			decryptedMessage, err := DecryptMessage(messages[i].Message, encryption)
-			if err != nil {
-				// If decryption fails, keep the original message (might not be encrypted)
-				continue
+// finishMessages labels demodulated messages with their rate and decrypts
+// them if opts ask for it.
+func finishMessages(messages []DecodedMessage, baudRate int, opts DecodeOptions) []DecodedMessage {
+	for i := range messages {
+		messages[i].BaudRate = baudRate
+	}
+
+	for i := range messages {
+		opts.open(&messages[i])
+	}
+	return messages
+}
+
+// open verifies the tag of msg if it was signed, and otherwise decrypts it.
+func (opts DecodeOptions) open(msg *DecodedMessage) {
+	if msg.IsNumeric {
+		if opts.NumericKey != nil {
+			if message, err := decryptNumeric(msg.Message, opts.NumericKey, addressTweak(msg.Address)); err == nil {
+				msg.Message = message
 			}
-			messages[i].Message = decryptedMessage
 		}
+		return
 	}
+	if message, ok := opts.verify(msg.Address, msg.Message); ok {
+		msg.Message, msg.Verified = message, true
+		return
+	}
+	msg.Message = opts.decrypt(msg.Address, msg.Message)
+}
 
-	return messages, nil
+// keyrings returns the keys of opts that may open message, to address:
+// those of Keys, the one KeyProvider has under the ID message names, and
+// Keyring, in that order.
+func (opts DecodeOptions) keyrings(address uint32, message string) []Keyring {
+	var rings []Keyring
+	if opts.Keys != nil {
+		rings = append(rings, opts.Keys.Keyring(address, time.Now()))
+	}
+	if ring := opts.providedKeyring(message); ring != nil {
+		rings = append(rings, ring)
+	}
+	if opts.Keyring != nil {
+		rings = append(rings, opts.Keyring)
+	}
+	return rings
 }
 
-// DecodeFromAudioWithBaudRate decodes POCSAG from WAV audio data with specified baud rate
-func DecodeFromAudioWithBaudRate(wavData []byte, baudRate int) ([]DecodedMessage, error) {
+// verify checks the tag of a signed message, to address, against the
+// keyrings and Encryption key of opts.
+func (opts DecodeOptions) verify(address uint32, message string) (string, bool) {
+	if !strings.Contains(message, " #") {
+		return "", false
+	}
+	rings := opts.keyrings(address, message)
+	if opts.Encryption.Method != EncryptionNone && len(opts.Encryption.Key) > 0 {
+		rings = append(rings, Keyring{0: opts.Encryption.Key})
+	}
+	for _, ring := range rings {
+		if verified, err := VerifyWithKeyring(message, ring); err == nil {
+			return verified, true
+		}
+	}
+	return "", false
+}
+
+// decrypt decrypts message, to address, with the keyrings or Encryption
+// of opts. If none does, it returns message unchanged (it might not be
+// encrypted).
+func (opts DecodeOptions) decrypt(address uint32, message string) string {
+	for _, ring := range opts.keyrings(address, message) {
+		if decrypted, err := DecryptWithKeyring(message, ring); err == nil {
+			return decrypted
+		}
+	}
+	if opts.Encryption.Method != EncryptionNone {
+		if decrypted, err := DecryptMessage(message, opts.Encryption); err == nil {
+			return decrypted
+		}
+	}
+	return message
+}
 
-	// Find data chunk
-	// Standard WAV has "data" chunk followed by 4-byte size, then actual samples
-	dataOffset := bytes.Index(wavData, []byte("data"))
-	startIdx := 44
-	if dataOffset != -1 {
-		startIdx = dataOffset + 8 // "data" (4) + size (4)
+// demodulateAudio demodulates a WAV file, or with opts.Squelch each
+// transmission found in it on its own. stats, if not nil, is filled in.
+func demodulateAudio(wavData []byte, baudRate int, opts DecodeOptions, stats *DecodeStats) ([]DecodedMessage, error) {
+	samples, sampleRate, err := audioBaseband(wavData, opts)
+	if err != nil {
+		return nil, err
+	}
+	if !opts.Squelch {
+		return demodulateSamples(samples, sampleRate, baudRate, opts, stats)
 	}
 
-	// Read sample rate from WAV header (bytes 24-27)
-	var sampleRate uint32 = 48000 // default
-	if len(wavData) > 28 {
-		sampleRate = binary.LittleEndian.Uint32(wavData[24:28])
+	_, found, segStats, err := demodulateTransmissions(samples, sampleRate, baudRate, opts)
+	if err != nil {
+		return nil, err
+	}
+	messages := make([]DecodedMessage, 0)
+	for i := range found {
+		messages = append(messages, found[i]...)
+		if stats != nil {
+			stats.merge(segStats[i])
+		}
+	}
+	return messages, nil
+}
+
+// demodulateTransmissions finds the transmissions in samples and
+// demodulates each on its own, joining those less than opts.GapTimeout
+// apart.
+func demodulateTransmissions(samples []float32, sampleRate, baudRate int, opts DecodeOptions) ([]span, [][]DecodedMessage, []DecodeStats, error) {
+	spans := joinSpans(findTransmissions(samples, float64(sampleRate)/float64(baudRate)), int(opts.GapTimeout.Seconds()*float64(sampleRate)))
+	messages := make([][]DecodedMessage, len(spans))
+	stats := make([]DecodeStats, len(spans))
+	for i, sp := range spans {
+		found, err := demodulateSamples(samples[sp.start:sp.end], sampleRate, baudRate, opts, &stats[i])
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		messages[i] = found
+	}
+	return spans, messages, stats, nil
+}
+
+// audioBaseband returns the samples of a WAV file at SampleRate, with DC
+// removed and the level normalized unless opts disable it.
+func audioBaseband(wavData []byte, opts DecodeOptions) ([]float32, int, error) {
+	pcm, sampleRate, err := normalizedWAVSamples(wavData)
+	if err != nil {
+		return nil, 0, err
 	}
 
 	// Convert audio samples to slice
-	samples := make([]float32, 0)
-	for i := startIdx; i < len(wavData)-1; i += 2 {
-		sample := float32(int16(binary.LittleEndian.Uint16(wavData[i:])))
-		samples = append(samples, sample)
+	samples := make([]float32, len(pcm))
+	for i, sample := range pcm {
+		samples[i] = float32(sample)
 	}
 
+	if !opts.DisableDCBlock {
+		removeDC(samples, sampleRate)
+	}
+	if !opts.DisableAGC {
+		normalizeLevel(samples, sampleRate)
+	}
+	return samples, sampleRate, nil
+}
+
+// demodulateSamples tries several basebands, polarities, and clock phases
+// and keeps the attempt that decodes best. stats, if not nil, is filled in
+// for that attempt.
+func demodulateSamples(samples []float32, sampleRate, baudRate int, opts DecodeOptions, stats *DecodeStats) ([]DecodedMessage, error) {
 	// Demodulate: calculate samples per bit based on baud rate
 	samplesPerBit := float64(sampleRate) / float64(baudRate)
 
@@ -105,295 +403,713 @@ func DecodeFromAudioWithBaudRate(wavData []byte, baudRate int) ([]DecodedMessage
 	}
 
 	var bestMessages []DecodedMessage
+	bestScore := 0
+	var bestBaseband integrated
+	var bestOffset, bestSamplesPerBit float64
+	var bestInvert, bestTrack bool
+
+	// Bit clocks to try: the nominal one, and with a clock tolerance the
+	// ones either side of it
+	clocks := []float64{samplesPerBit}
+	if opts.ClockTolerancePPM > 0 {
+		tolerance := opts.ClockTolerancePPM / 1e6
+		clocks = append(clocks, samplesPerBit*(1-tolerance), samplesPerBit*(1+tolerance))
+	}
 
 	// We test different basebands based on recording quality
 	// 0: Raw samples (perfect for synthetic)
 	// 1: Global Average DC (best for most cases)
 	// 2: Dynamic LPF Baseband (for heavy DC drift)
+search:
 	for strat := 0; strat < 3; strat++ {
-		var activeBaseband []float32
+		var activeBaseband integrated
 		if strat == 0 {
-			activeBaseband = samples
+			activeBaseband = integrate(samples)
 		} else if strat == 1 {
-			activeBaseband = basebandGlobal
+			activeBaseband = integrate(basebandGlobal)
 		} else {
-			activeBaseband = basebandDynamic
+			activeBaseband = integrate(basebandDynamic)
 		}
 
-		// Test both polarities
-		for polarity := 0; polarity < 2; polarity++ {
-			// Higher number of phases for better initial alignment
-			phases := 40
-
-			for phase := 0; phase < phases; phase++ {
-				bits := make([]byte, 0)
-				offset := (float64(phase) * samplesPerBit) / float64(phases)
-
-				currentIndex := offset
-
-				// DPLL Tracking parameters
-				// nudgeFactor := 0.01 // Very low nudge for stability
-
-				for currentIndex+samplesPerBit <= float64(len(activeBaseband)) {
-					// Integration window
-					var bitSum float32 = 0
-					window := 0.7
-					winOffset := samplesPerBit * (1.0 - window) / 2.0
-					startS := currentIndex + winOffset
-					endS := startS + samplesPerBit*window
-
-					iStart := int(math.Round(startS))
-					iEnd := int(math.Round(endS))
-
-					for j := iStart; j < iEnd && j < len(activeBaseband); j++ {
-						bitSum += activeBaseband[j]
-					}
+		for _, clock := range clocks {
+			// Test both polarities, unless opts fix one
+			for polarity := 0; polarity < 2; polarity++ {
+				if !opts.Polarity.allows(polarity == 1) {
+					continue
+				}
+				// Higher number of phases for better initial alignment
+				phases := 40
 
-					bitVal := byte(0)
-					if (polarity == 0 && bitSum > 0) || (polarity == 1 && bitSum < 0) {
-						bitVal = 1
-					}
-					bits = append(bits, bitVal)
+				for phase := 0; phase < phases; phase++ {
+					offset := (float64(phase) * clock) / float64(phases)
 
 					// DPLL: Only use for strategy 1 and 2 (DC tracked signals)
-					if strat > 0 {
-						searchLen := samplesPerBit * 0.4
-						searchStart := currentIndex + samplesPerBit - searchLen/2
-
-						iSearchStart := int(math.Round(searchStart))
-						iSearchEnd := int(math.Round(searchStart + searchLen))
-
-						for j := iSearchStart; j < iSearchEnd && j < len(activeBaseband)-1; j++ {
-							s1 := activeBaseband[j]
-							s2 := activeBaseband[j+1]
-							if (s1 > 0 && s2 <= 0) || (s1 <= 0 && s2 > 0) {
-								t := -s1 / (s2 - s1)
-								actualBoundary := float64(j) + float64(t)
-								expectedBoundary := currentIndex + samplesPerBit
-								errorOffset := actualBoundary - expectedBoundary
-
-								// Highly conservative nudge
-								currentIndex += errorOffset * 0.005
-								break
-							}
+					bits := sliceBits(activeBaseband, clock, offset, opts.Threshold, polarity == 1, strat > 0, nil)
+
+					messages, err := decodeBitstream(bits, "", opts, baudRate)
+					score, intact := decodeScore(messages)
+					if err == nil && score > bestScore {
+						bestMessages, bestScore = messages, score
+						bestBaseband, bestOffset, bestInvert, bestTrack = activeBaseband, offset, polarity == 1, strat > 0
+						bestSamplesPerBit = clock
+
+						// Strategy 0 is raw/perfect. If it finds anything intact, it's almost certainly the correct one.
+						if strat == 0 && intact {
+							break search
 						}
 					}
-
-					currentIndex += samplesPerBit
-				}
-
-				messages, err := DecodeFromBitstream(bits)
-				if err == nil && len(messages) > len(bestMessages) {
-					bestMessages = messages
-
-					// Strategy 0 is raw/perfect. If it finds anything, it's almost certainly the correct one.
-					if strat == 0 && len(bestMessages) > 0 {
-						return bestMessages, nil
-					}
 				}
 			}
 		}
 	}
 
+	if stats != nil && bestBaseband.samples != nil {
+		// Slice the winning attempt again, measuring as it goes
+		bits := sliceBits(bestBaseband, bestSamplesPerBit, bestOffset, opts.Threshold, bestInvert, bestTrack, stats)
+		// Drift was measured against the clock that won; make it relative
+		// to the nominal one
+		stats.ClockDriftPPM += (samplesPerBit/bestSamplesPerBit - 1) * 1e6
+		d := newBitstreamDecoder("", func(DecodedMessage) {})
+		d.setOptions(opts, baudRate)
+		d.write(bits)
+		d.close()
+		stats.Batches, stats.Resyncs, stats.BitSlips = d.batches, d.resyncs, d.slips
+	}
 	return bestMessages, nil
 }
 
-// DecodeFromBitstream decodes POCSAG from a stream of 0/1 bits
-func DecodeFromBitstream(bits []byte) ([]DecodedMessage, error) {
-	messages := make([]DecodedMessage, 0)
-
-	// Scan for sync word bit-by-bit
-	var shiftReg uint32
-	syncIdx := -1
-	for i := 0; i < len(bits); i++ {
-		shiftReg = (shiftReg << 1) | uint32(bits[i])
-		if shiftReg == FrameSyncWord {
-			syncIdx = i + 1
-			break
-		}
-	}
+const (
+	// sliceFracBits is how many fraction bits integrate keeps of each
+	// sample, and sliceLimit the largest sample it holds: the int16 range
+	// the audio comes in. A window of up to 4096 such samples sums in an
+	// int32, and a 512 baud bit at SampleRate is under 100.
+	sliceFracBits = 4
+	sliceLimit    = 1 << 15
+	// sliceBlock is how many samples integrate quantizes at a time.
+	sliceBlock = 256
+)
 
-	if syncIdx == -1 {
-		return nil, fmt.Errorf("sync word not found")
-	}
+// integrated is a baseband prepared for sliceBits: its samples, for
+// finding zero crossings, and their running sum in fixed point, so that
+// integrating a bit is one subtraction however many clock phases are
+// tried. The running sum wraps around, but the difference of two is
+// exact as long as the window's own sum fits in an int32.
+type integrated struct {
+	samples []float32
+	sums    []int32 // sums[i] is the sum of samples[:i]
+}
 
-	// Helper to read 32 bits from current position
-	readWord := func(pos int) (uint32, bool) {
-		if pos+32 > len(bits) {
-			return 0, false
+// integrate quantizes samples and sums them, a fixed-size block at a time.
+func integrate(samples []float32) integrated {
+	sums := make([]int32, len(samples)+1)
+	var q [sliceBlock]int32
+	var acc int32
+	for start := 0; start < len(samples); start += sliceBlock {
+		block := samples[start:min(start+sliceBlock, len(samples))]
+		for i, s := range block {
+			q[i] = int32(math.Round(float64(max(min(s, sliceLimit), -sliceLimit)) * (1 << sliceFracBits)))
 		}
-		var w uint32
-		for i := 0; i < 32; i++ {
-			w = (w << 1) | uint32(bits[pos+i])
+		out := sums[start+1 : start+1+len(block)]
+		for i := range out {
+			acc += q[i]
+			out[i] = acc
 		}
-		return w, true
 	}
+	return integrated{samples: samples, sums: sums}
+}
 
-	idx := syncIdx
-	var currentAddress uint32
-	var currentFunction uint8
-	messageCodewords := make([]uint32, 0)
-	batchPos := 0
+// sliceBits turns baseband into bits by integrating the middle of each bit
+// period, starting offset samples in and comparing it with threshold.
+// invert swaps the polarity, and track
+// lets a DPLL follow the transitions. When stats is not nil it also
+// receives the clock drift and eye opening.
+func sliceBits(in integrated, samplesPerBit, offset float64, threshold float32, invert, track bool, stats *DecodeStats) []byte {
+	baseband := in.samples
+	bits := make([]byte, 0)
+	var m *sliceMeasurement
+	if stats != nil {
+		m = &sliceMeasurement{}
+	}
 
-	for {
-		cw, ok := readWord(idx)
-		if !ok {
-			break
+	currentIndex := offset
+	for currentIndex+samplesPerBit <= float64(len(baseband)) {
+		// Integration window. Positions are never negative, so adding a
+		// half and truncating rounds them as math.Round would.
+		var bitSum float32 = 0
+		window := 0.7
+		winOffset := samplesPerBit * (1.0 - window) / 2.0
+		startS := currentIndex + winOffset
+		endS := startS + samplesPerBit*window
+
+		iStart := int(startS + 0.5)
+		iEnd := int(endS + 0.5)
+
+		if end := min(iEnd, len(baseband)); iStart < end {
+			bitSum = float32(in.sums[end]-in.sums[iStart]) / (1 << sliceFracBits)
 		}
 
-		// Every codeword must pass BCH/Parity check, EXCEPT for Sync/Idle constants
-		if cw != FrameSyncWord && cw != IdleCodeword {
-			if !DoesWordPassBCH(cw) {
-				// Log the failure for debugging
-				// fmt.Printf("[BitDecode] Parity error at batch bit offset %d: 0x%08X\n", idx, cw)
-				break
-			}
+		bitVal := byte(0)
+		level := bitSum - threshold*float32(max(iEnd-iStart, 0))
+		if (!invert && level > 0) || (invert && level < 0) {
+			bitVal = 1
 		}
-
-		idx += 32
-
-		if cw == FrameSyncWord {
-			batchPos = 0
-			continue
+		bits = append(bits, bitVal)
+		if m != nil {
+			m.levels = append(m.levels, bitSum/float32(max(iEnd-iStart, 1)))
 		}
 
-		if cw == IdleCodeword {
-			batchPos++
-			continue
+		if track || m != nil {
+			searchLen := samplesPerBit * 0.4
+			searchStart := currentIndex + samplesPerBit - searchLen/2
+
+			iSearchStart := int(searchStart + 0.5)
+			iSearchEnd := int(searchStart + searchLen + 0.5)
+
+			for j := iSearchStart; j < iSearchEnd && j < len(baseband)-1; j++ {
+				s1 := baseband[j]
+				s2 := baseband[j+1]
+				if (s1 > 0 && s2 <= 0) || (s1 <= 0 && s2 > 0) {
+					t := -s1 / (s2 - s1)
+					actualBoundary := float64(j) + float64(t)
+					expectedBoundary := currentIndex + samplesPerBit
+					errorOffset := actualBoundary - expectedBoundary
+
+					if m != nil {
+						// Relative to the nominal clock, whatever the DPLL did
+						k := len(bits) - 1
+						m.addCrossing(k, actualBoundary-(offset+float64(k+1)*samplesPerBit))
+					}
+					if track {
+						// Highly conservative nudge
+						currentIndex += errorOffset * 0.005
+					}
+					break
+				}
+			}
 		}
 
-		isAddress := (cw & (1 << 31)) == 0
-		if isAddress {
-			if len(messageCodewords) > 0 && currentAddress != 0 {
-				msg := decodeMessage(messageCodewords, currentFunction)
-				messages = append(messages, DecodedMessage{Address: currentAddress, Function: currentFunction, Message: msg, IsNumeric: currentFunction == FuncNumeric})
-			}
-			messageCodewords = make([]uint32, 0)
+		currentIndex += samplesPerBit
+	}
 
-			data := (cw >> 11) & 0x1FFFFF
-			currentFunction = uint8(data & 0x3)
-			baseAddress := ((data >> 2) & 0x7FFFF)
-			frameIndex := uint32(batchPos / 2)
-			currentAddress = (baseAddress << 3) | frameIndex
-			currentAddress &= 0x1FFFFF
-		} else {
-			if currentAddress != 0 {
-				messageCodewords = append(messageCodewords, cw)
-			}
+	if m != nil {
+		m.finish(samplesPerBit, stats)
+	}
+	return bits
+}
+
+// decodeScore ranks the result of one demodulation attempt: more messages
+// is better, and at equal counts fewer partial ones. intact reports that
+// no message is partial.
+func decodeScore(messages []DecodedMessage) (score int, intact bool) {
+	intact = true
+	for _, msg := range messages {
+		score += 2 * CodewordsPerBatch
+		if msg.Partial {
+			score--
+			intact = false
 		}
-		batchPos++
 	}
+	return score, intact
+}
 
-	if len(messageCodewords) > 0 && currentAddress != 0 {
-		msg := decodeMessage(messageCodewords, currentFunction)
-		messages = append(messages, DecodedMessage{Address: currentAddress, Function: currentFunction, Message: msg, IsNumeric: currentFunction == FuncNumeric})
+// normalizedWAVSamples parses a WAV file and resamples it to SampleRate so the
+// demodulators always work with the same number of samples per bit. It
+// fails on a sample rate ValidateSampleRate rejects.
+func normalizedWAVSamples(wavData []byte) ([]int16, int, error) {
+	samples, sampleRate := ParseWAVSamples(wavData)
+	if sampleRate <= 0 {
+		return samples, SampleRate, nil
+	}
+	if err := ValidateSampleRate(sampleRate); err != nil {
+		return nil, 0, err
 	}
+	if sampleRate != SampleRate {
+		samples = Resample(samples, sampleRate, SampleRate)
+	}
+	return samples, SampleRate, nil
+}
 
-	return messages, nil
+// Frame sync tolerance. A sync word is accepted with up to syncMaxErrors
+// flipped bits, and the sync expected after each batch is also searched up
+// to syncMaxSlip bits either side of its nominal position so a slipped or
+// inserted bit does not lose the rest of the transmission.
+const (
+	syncMaxErrors = 2
+	syncMaxSlip   = 2
+)
+
+// isSyncWord reports whether w is within syncMaxErrors bits of sync.
+func isSyncWord(w, sync uint32) bool {
+	return mathbits.OnesCount32(w^sync) <= syncMaxErrors
 }
 
-// DecodeFromBinary decodes POCSAG from raw binary data
-func DecodeFromBinary(data []byte) ([]DecodedMessage, error) {
-	return decodeFromBinary(data, "")
+// isCutSync reports whether stream, shorter than a codeword, starts like
+// the sync word. Fewer than 8 bits are too few to tell.
+func isCutSync(stream []byte, sync uint32) bool {
+	if len(stream) < 8 || len(stream) >= 32 {
+		return false
+	}
+	var w uint32
+	for _, b := range stream {
+		w = (w << 1) | uint32(b&1)
+	}
+	return mathbits.OnesCount32(w^(sync>>(32-len(stream)))) <= 1
 }
 
-// DecodeFromBinaryWithPayloadType decodes raw POCSAG bytes using an explicit
-// payload type instead of inferring numeric/alpha from the function bits.
-func DecodeFromBinaryWithPayloadType(data []byte, payloadType string) ([]DecodedMessage, error) {
-	return decodeFromBinary(data, normalizePayloadType(payloadType))
+// readBitsWord reads 32 bits MSB first starting at pos.
+func readBitsWord(stream []byte, pos int) (uint32, bool) {
+	if pos < 0 || pos+32 > len(stream) {
+		return 0, false
+	}
+	var w uint32
+	for i := 0; i < 32; i++ {
+		w = (w << 1) | uint32(stream[pos+i]&1)
+	}
+	return w, true
 }
 
-func decodeFromBinary(data []byte, payloadType string) ([]DecodedMessage, error) {
-	messages := make([]DecodedMessage, 0)
+// findSync slides a 32-bit window over stream from start and returns the
+// index of the first bit after a tolerated sync word, or -1.
+func findSync(stream []byte, start int) int {
+	if start < 0 {
+		start = 0
+	}
+	var shiftReg uint32
+	for i := start; i < len(stream); i++ {
+		shiftReg = (shiftReg << 1) | uint32(stream[i]&1)
+		if i-start >= 31 && isSyncWord(shiftReg, FrameSyncWord) {
+			return i + 1
+		}
+	}
+	return -1
+}
 
-	// Find first frame sync word
-	syncIdx := -1
-	for i := 0; i < len(data)-3; i++ {
-		word := binary.BigEndian.Uint32(data[i:])
-		if word == FrameSyncWord {
-			syncIdx = i
-			break
+// nextBatchSync checks for the sync word that should follow a batch ending at
+// pos, allowing for bit slip. It returns the index after the sync or -1.
+func nextBatchSync(stream []byte, pos int, sync uint32) int {
+	for slip := 0; slip <= syncMaxSlip; slip++ {
+		for _, p := range []int{pos + slip, pos - slip} {
+			if w, ok := readBitsWord(stream, p); ok && isSyncWord(w, sync) {
+				return p + 32
+			}
+			if slip == 0 {
+				break
+			}
 		}
 	}
+	return -1
+}
 
-	if syncIdx == -1 {
-		return nil, fmt.Errorf("frame sync word not found")
+// bytesToBits expands bytes to one bit per byte, MSB first.
+func bytesToBits(data []byte) []byte {
+	stream := make([]byte, 0, len(data)*8)
+	for _, b := range data {
+		for i := 7; i >= 0; i-- {
+			stream = append(stream, (b>>uint(i))&1)
+		}
 	}
+	return stream
+}
 
-	// Start reading codewords after sync
-	idx := syncIdx + 4
+// DecodeFromBitstream decodes POCSAG from a stream of 0/1 bits
+func DecodeFromBitstream(bits []byte) ([]DecodedMessage, error) {
+	return decodeBitstream(bits, "", DecodeOptions{}, 0)
+}
 
-	var currentAddress uint32
-	var currentFunction uint8
-	messageCodewords := make([]uint32, 0)
+// decodeBitstream walks the stream batch by batch. Codewords failing the
+// BCH check inside a message become placeholder characters; a run of more
+// than maxCorruptRun of them ends the message. When the sync
+// word after a batch cannot be found the pending message is flushed and the
+// stream is searched for the next sync, so a lost batch only costs the
+// messages inside it; with opts.GapTimeout the pending message waits for
+// the next sync instead. baudRate, zero for raw bitstreams, converts the
+// timeout into bits.
+func decodeBitstream(stream []byte, payloadType string, opts DecodeOptions, baudRate int) ([]DecodedMessage, error) {
+	messages := make([]DecodedMessage, 0)
+	d := newBitstreamDecoder(payloadType, func(msg DecodedMessage) {
+		messages = append(messages, msg)
+	})
+	d.setOptions(opts, baudRate)
+	d.write(stream)
+	d.close()
+
+	if !d.everSynced {
+		return nil, fmt.Errorf("sync word not found")
+	}
+	return messages, nil
+}
 
-	// Keep track of our position within the 16-codeword batch
-	// Each batch has 8 frames, each frame has 2 codewords
-	batchPos := 0
+// bitstreamDecoder is the incremental form of decodeBitstream: bits are
+// written as they are demodulated and each message is passed to emit as
+// soon as it is complete.
+type bitstreamDecoder struct {
+	payloadType  string
+	payloadTypes map[uint32]string // by address, ahead of payloadType
+	emit         func(DecodedMessage)
+
+	buf []byte // bits not yet consumed, plus a few already consumed ones
+	pos int    // next unconsumed bit in buf
+
+	synced     bool
+	everSynced bool
+	gapBits    int    // how long a message is held open after losing sync
+	inGap      bool   // sync was lost with a message held open
+	bridged    bool   // the current message was held open across a gap
+	gapIdle    bool   // the message was followed by idle when sync was lost
+	gapLen     int    // its codewords when sync was lost
+	shiftReg   uint32 // sync hunting window
+	hunted     int    // bits shifted into shiftReg since hunting began
+	slot       int    // codeword position within the batch, 16 = expecting sync
+
+	currentAddress   uint32
+	currentFunction  uint8
+	addressWord      uint32
+	messageCodewords []uint32
+	corrupt          []bool // parallel to messageCodewords
+	corruptRun       int
+	idleAfter        bool     // an idle codeword came after the current message
+	truncated        bool     // the input ended before the current message did
+	interleaveMagic  bool     // the first interleave flag codeword came
+	interleaved      []uint32 // words of an interleaved message so far
+	interleaveLeft   int      // words of it still to come
+	interleaveDepth  int
+	placeholder      rune
+	trimSpaces       bool
+	syncWord         uint32
+	idleWord         uint32
+	includeRaw       bool
+	detectType       bool
+	terminator       byte
+
+	batches, resyncs, slips int // for DecodeStats
+}
 
-	for idx+3 < len(data) {
-		cw := binary.BigEndian.Uint32(data[idx:])
-		idx += 4
+func newBitstreamDecoder(payloadType string, emit func(DecodedMessage)) *bitstreamDecoder {
+	return &bitstreamDecoder{
+		payloadType: payloadType,
+		emit:        emit,
+		placeholder: DefaultPlaceholder,
+		syncWord:    FrameSyncWord,
+		idleWord:    IdleCodeword,
+	}
+}
 
-		// Check if it's a sync word (start of new batch)
-		if cw == FrameSyncWord {
-			batchPos = 0 // Reset batch position
-			// Continue to next batch without breaking message collection
-			continue
+func (d *bitstreamDecoder) write(bits []byte) {
+	d.buf = append(d.buf, bits...)
+	d.process(false)
+	d.compact()
+}
+
+// close decodes whatever is left and flushes the pending message.
+func (d *bitstreamDecoder) close() {
+	d.process(true)
+	// Still in sync means the input stopped inside a batch, perhaps in the
+	// middle of the current message unless idle codewords followed it
+	d.truncated = (d.synced || d.inGap) && !d.idleAfter
+	d.inGap = false
+	d.flush()
+}
+
+func (d *bitstreamDecoder) process(final bool) {
+	for {
+		if !d.synced {
+			for d.pos < len(d.buf) && !d.synced {
+				d.shiftReg = (d.shiftReg << 1) | uint32(d.buf[d.pos]&1)
+				d.pos++
+				d.hunted++
+				if d.hunted >= 32 && isSyncWord(d.shiftReg, d.syncWord) {
+					d.batches++
+					if d.everSynced {
+						d.resyncs++
+					}
+					d.synced, d.everSynced = true, true
+					d.slot = 0
+					if d.inGap {
+						// Carry on with the held message, standing in
+						// corrupt codewords for the batches the dropout took
+						// so that its characters stay aligned
+						d.inGap, d.bridged = false, true
+						lost := (d.hunted - 32 + BatchBits/2) / BatchBits * CodewordsPerBatch
+						for i := 0; i < lost; i++ {
+							d.messageCodewords = append(d.messageCodewords, 0)
+							d.corrupt = append(d.corrupt, true)
+						}
+					}
+				} else if d.inGap && d.hunted > d.gapBits+32 {
+					// Too long a gap: the held message ends where sync was lost
+					d.inGap = false
+					d.truncated = !d.idleAfter
+					d.flush()
+					d.currentAddress = 0
+				}
+			}
+			if !d.synced {
+				return
+			}
 		}
 
-		if cw == IdleCodeword {
-			// Skip idle codewords - they're just padding between or within messages
-			// Don't finalize the message here, as it may continue in the next batch
-			batchPos++
+		if d.slot < CodewordsPerBatch {
+			cw, ok := readBitsWord(d.buf, d.pos)
+			if !ok {
+				return
+			}
+			d.pos += 32
+			d.codeword(cw, d.slot)
+			d.slot++
 			continue
 		}
 
-		// Check if it's an address codeword (bit 31 = 0)
-		isAddress := (cw & (1 << 31)) == 0
-
-		if isAddress {
-			// If we have a pending message, process it first
-			if len(messageCodewords) > 0 && currentAddress != 0 {
-				msg, isNumeric := decodeMessageWithPayloadType(messageCodewords, currentFunction, payloadType)
-				messages = append(messages, DecodedMessage{Address: currentAddress, Function: currentFunction, Message: msg, IsNumeric: isNumeric})
+		// The slip search looks up to syncMaxSlip bits past the sync word
+		if len(d.buf)-d.pos < 32+syncMaxSlip && !final {
+			return
+		}
+		if final && isCutSync(d.buf[d.pos:], d.syncWord) {
+			// The input ends partway into the next sync word; staying in
+			// sync tells close the message may go on in the next batch
+			return
+		}
+		next := nextBatchSync(d.buf, d.pos, d.syncWord)
+		if next == -1 {
+			// Lost sync: end the current message, or hold it open for
+			// the rest of it after a dropout, and resynchronize
+			if d.gapBits > 0 && d.currentAddress != 0 {
+				if !d.bridged {
+					d.gapIdle, d.gapLen = d.idleAfter, len(d.messageCodewords)
+				}
+				d.inGap = true
+			} else {
+				d.flush()
+				d.currentAddress = 0
 			}
-			messageCodewords = make([]uint32, 0) // Reset for new address
+			d.synced = false
+			d.shiftReg, d.hunted = 0, 0
+			continue
+		}
+		d.batches++
+		if next != d.pos+32 {
+			d.slips++
+		}
+		d.pos = next
+		d.slot = 0
+	}
+}
 
-			// Decode the new address
-			// Bits 30-13 contain the 18 most significant bits of the 21-bit address
-			// The 3 least significant bits are determined by the frame in the batch (0-7)
-			data := (cw >> 11) & 0x1FFFFF
-			currentFunction = uint8(data & 0x3)
+func (d *bitstreamDecoder) codeword(cw uint32, slot int) {
+	if d.interleaveLeft > 0 {
+		// Interleaved words fail BCH until they are put back in order
+		d.interleaved = append(d.interleaved, cw)
+		d.interleaveLeft--
+		d.idleAfter = false
+		if d.interleaveLeft == 0 {
+			d.deinterleave()
+		}
+		return
+	}
+	if cw == d.idleWord {
+		// Idle padding may sit between the codewords of one message
+		d.idleAfter = true
+		return
+	}
+	if !DoesWordPassBCH(cw) {
+		if d.currentAddress == 0 || d.startInterleave(cw) {
+			return
+		}
+		d.corruptRun++
+		if d.corruptRun > maxCorruptRun && !d.awaitingFEC() {
+			d.flush()
+			d.currentAddress = 0
+			return
+		}
+		d.messageCodewords = append(d.messageCodewords, cw)
+		d.corrupt = append(d.corrupt, true)
+		d.idleAfter = false
+		return
+	}
+	d.corruptRun = 0
+
+	isAddress := (cw & (1 << 31)) == 0
+	if isAddress {
+		d.flush()
+
+		// Bits 30-13 carry the upper 18 bits of the 21-bit address; the low
+		// 3 bits are the frame (0-7) the codeword sits in.
+		data := (cw >> 11) & 0x1FFFFF
+		d.currentFunction = uint8(data & 0x3)
+		baseAddress := (data >> 2) & 0x7FFFF
+		d.currentAddress = ((baseAddress << 3) | uint32(slot/CodewordsPerFrame)) & 0x1FFFFF
+		d.addressWord = cw
+	} else if d.currentAddress != 0 {
+		if d.startInterleave(cw) {
+			return
+		}
+		d.messageCodewords = append(d.messageCodewords, cw)
+		d.corrupt = append(d.corrupt, false)
+		d.idleAfter = false
+	}
+}
 
-			// Extract the 18-bit base address from the codeword
-			baseAddress := ((data >> 2) & 0x7FFFF)
+// startInterleave reports whether cw, possibly with bad bits, is a flag
+// codeword of an interleaved message, and after the second starts
+// collecting its words. A first flag codeword not followed by a valid
+// second one is taken as text after all.
+func (d *bitstreamDecoder) startInterleave(cw uint32) bool {
+	if len(d.messageCodewords) > 0 {
+		return false
+	}
+	fixed, ok := CorrectCodeword(cw)
+	if !d.interleaveMagic {
+		d.interleaveMagic = ok && isInterleaveMagic(fixed)
+		return d.interleaveMagic
+	}
+	d.interleaveMagic = false
+	if depth, count, valid := parseInterleaveFlag(fixed); ok && valid {
+		d.interleaveDepth, d.interleaveLeft = depth, count
+		return true
+	}
+	d.messageCodewords = append(d.messageCodewords, dataCodeword(interleaveMagic))
+	d.corrupt = append(d.corrupt, false)
+	return false
+}
 
-			// Calculate the frame index (0-7) from the batch position (0-15)
-			frameIndex := uint32(batchPos / 2)
+// deinterleave adds the codewords of an interleaved message to the
+// message, as far as they arrived.
+func (d *bitstreamDecoder) deinterleave() {
+	cws, bad := deinterleaveMessage(d.interleaved, d.interleaveLeft, d.interleaveDepth)
+	d.messageCodewords = append(d.messageCodewords, cws...)
+	d.corrupt = append(d.corrupt, bad...)
+	d.interleaved, d.interleaveLeft = nil, 0
+}
 
-			// Combine them: 18 bits shifted left by 3, OR'ed with the 3-bit frame index
-			currentAddress = (baseAddress << 3) | frameIndex
+// setOptions applies the framing and message formatting options of opts,
+// and GapTimeout at baudRate if that is known.
+func (d *bitstreamDecoder) setOptions(opts DecodeOptions, baudRate int) {
+	if opts.Placeholder != 0 {
+		d.placeholder = opts.Placeholder
+	}
+	d.trimSpaces = opts.TrimTrailingSpaces
+	if opts.SyncWord != 0 {
+		d.syncWord = opts.SyncWord
+	}
+	if opts.IdleCodeword != 0 {
+		d.idleWord = opts.IdleCodeword
+	}
+	d.includeRaw = opts.IncludeRaw
+	switch {
+	case opts.ForceNumeric:
+		d.payloadType = PayloadTypeNumeric
+	case opts.ForceAlpha:
+		d.payloadType = PayloadTypeAlpha
+	}
+	d.payloadTypes = opts.PayloadTypes
+	d.detectType = opts.DetectType
+	d.terminator = opts.Terminator
+	d.gapBits = int(opts.GapTimeout.Seconds() * float64(baudRate))
+}
 
-			// Note: Standard POCSAG (e.g. PDW) often ignores the 22nd bit (MSB)
-			// we mask keep only 21 bits
-			currentAddress &= 0x1FFFFF
+// typeOf returns the payload type to decode the current message as, or ""
+// to go by its function.
+func (d *bitstreamDecoder) typeOf() string {
+	if t := normalizePayloadType(d.payloadTypes[d.currentAddress]); t != "" {
+		return t
+	}
+	return d.payloadType
+}
 
-		} else { // Is Message
-			if currentAddress != 0 { // Only collect message parts if we have an address
-				messageCodewords = append(messageCodewords, cw)
+func (d *bitstreamDecoder) flush() {
+	if d.interleaveLeft > 0 {
+		d.deinterleave()
+	}
+	if d.interleaveMagic {
+		d.messageCodewords = append(d.messageCodewords, dataCodeword(interleaveMagic))
+		d.corrupt = append(d.corrupt, false)
+		d.interleaveMagic = false
+	}
+	// Corrupted codewords at the end may belong to the next address as
+	// much as to this message, so they are dropped rather than shown.
+	n := len(d.messageCodewords)
+	for n > 0 && d.corrupt[n-1] {
+		n--
+	}
+	partial := n < len(d.messageCodewords) || d.truncated
+	// Held across a dropout, a message lost what was sent during it,
+	// unless it had ended with idle codewords and nothing more came
+	partial = partial || d.bridged && (!d.gapIdle || len(d.messageCodewords) > d.gapLen)
+	for _, bad := range d.corrupt[:n] {
+		partial = partial || bad
+	}
+
+	if n > 0 && d.currentAddress != 0 {
+		payloadType, alternative := d.typeOf(), ""
+		if payloadType == "" && d.detectType {
+			var sure bool
+			if payloadType, sure = guessPayloadType(d.messageCodewords[:n], d.corrupt[:n], d.currentFunction); !sure {
+				other := PayloadTypeNumeric
+				if payloadType == PayloadTypeNumeric {
+					other = PayloadTypeAlpha
+				}
+				alternative, _ = decodeCodewords(d.messageCodewords[:n], d.corrupt[:n], d.currentFunction, other, d.placeholder, d.trimSpaces)
 			}
 		}
-
-		batchPos++
+		msg, isNumeric := decodeCodewords(d.messageCodewords[:n], d.corrupt[:n], d.currentFunction, payloadType, d.placeholder, d.trimSpaces)
+		if !isNumeric && d.terminator != 0 {
+			var ended bool
+			bits, bad := codewordBits(d.messageCodewords[:n], d.corrupt[:n])
+			// Tone-only pages, all zeros, have no text to end
+			if msg, ended = decodeAlphaToTerminator(bits, bad, d.placeholder, d.terminator); !ended && anySet(bits) {
+				partial = true
+			}
+		}
+		if !isNumeric && IsFEC(msg) {
+			if text, err := DecodeFEC(msg, d.placeholder); err == nil {
+				msg, partial, alternative = text, false, ""
+			}
+		}
+		decoded := DecodedMessage{Address: d.currentAddress, Function: d.currentFunction, Message: msg, IsNumeric: isNumeric, Partial: partial, Alternative: alternative}
+		if d.includeRaw {
+			decoded.Codewords = append([]uint32{d.addressWord}, d.messageCodewords[:n]...)
+		}
+		d.emit(decoded)
 	}
+	d.messageCodewords = nil
+	d.corrupt = nil
+	d.corruptRun = 0
+	d.idleAfter, d.truncated = false, false
+	d.bridged = false
+}
 
-	// Process any leftover message at the end
-	if len(messageCodewords) > 0 && currentAddress != 0 {
-		msg, isNumeric := decodeMessageWithPayloadType(messageCodewords, currentFunction, payloadType)
-		messages = append(messages, DecodedMessage{Address: currentAddress, Function: currentFunction, Message: msg, IsNumeric: isNumeric})
+// awaitingFEC reports whether the current message is an FEC page that has
+// not yet had all its codewords, which rides out fades longer than
+// maxCorruptRun.
+func (d *bitstreamDecoder) awaitingFEC() bool {
+	msg, isNumeric := decodeCodewords(d.messageCodewords, d.corrupt, d.currentFunction, d.typeOf(), d.placeholder, false)
+	return !isNumeric && len(d.messageCodewords) < fecCodewords(msg)
+}
+
+// compact drops consumed bits, keeping syncMaxSlip of them for the slip
+// search behind the next expected sync word.
+func (d *bitstreamDecoder) compact() {
+	if drop := d.pos - syncMaxSlip; drop > 4096 {
+		d.buf = append(d.buf[:0], d.buf[drop:]...)
+		d.pos -= drop
 	}
+}
 
+// DecodeFromBinary decodes POCSAG from raw binary data
+func DecodeFromBinary(data []byte) ([]DecodedMessage, error) {
+	return decodeFromBinary(data, "")
+}
+
+// DecodeFromBinaryWithPayloadType decodes raw POCSAG bytes using an explicit
+// payload type instead of inferring numeric/alpha from the function bits.
+func DecodeFromBinaryWithPayloadType(data []byte, payloadType string) ([]DecodedMessage, error) {
+	return decodeFromBinary(data, normalizePayloadType(payloadType))
+}
+
+func decodeFromBinary(data []byte, payloadType string) ([]DecodedMessage, error) {
+	messages, err := decodeBitstream(bytesToBits(data), payloadType, DecodeOptions{}, 0)
+	if err != nil {
+		return nil, fmt.Errorf("frame sync word not found")
+	}
 	return messages, nil
 }
 
@@ -404,22 +1120,49 @@ func decodeMessage(codewords []uint32, function uint8) string {
 }
 
 func decodeMessageWithPayloadType(codewords []uint32, function uint8, payloadType string) (string, bool) {
-	var bits []byte
-	for _, cw := range codewords {
+	return decodeCodewords(codewords, nil, function, payloadType, DefaultPlaceholder, false)
+}
+
+// decodeCodewords decodes message codewords, replacing every character
+// with a bit from a codeword marked in corrupt with placeholder. trimSpaces
+// is DecodeOptions.TrimTrailingSpaces.
+func decodeCodewords(codewords []uint32, corrupt []bool, function uint8, payloadType string, placeholder rune, trimSpaces bool) (string, bool) {
+	bits, bad := codewordBits(codewords, corrupt)
+	isNumeric := payloadType == PayloadTypeNumeric || (payloadType == "" && function == FuncNumeric)
+	if isNumeric {
+		return decodeNumericFromBits(bits, bad, placeholder, trimSpaces), true
+	}
+	return decodeAlphaFromBits(bits, bad, placeholder), false
+}
+
+// codewordBits returns the 20 data bits of each message codeword, MSB
+// first, and whether each came from a corrupted codeword.
+func codewordBits(codewords []uint32, corrupt []bool) (bits, bad []byte) {
+	for i, cw := range codewords {
 		// Extract the 20-bit data portion (bits 11-30)
 		data := (cw >> 11) & 0xFFFFF
+		isBad := byte(0)
+		if corrupt != nil && corrupt[i] {
+			isBad = 1
+		}
 
 		// Convert to bits (MSB first)
 		for i := 19; i >= 0; i-- {
 			bits = append(bits, byte((data>>i)&1))
+			bad = append(bad, isBad)
 		}
 	}
+	return bits, bad
+}
 
-	isNumeric := payloadType == PayloadTypeNumeric || (payloadType == "" && function == FuncNumeric)
-	if isNumeric {
-		return decodeNumericFromBits(bits), true
+// anySet reports whether any of flags is non-zero.
+func anySet(flags []byte) bool {
+	for _, f := range flags {
+		if f != 0 {
+			return true
+		}
 	}
-	return decodeAlphaFromBits(bits), false
+	return false
 }
 
 func normalizePayloadType(payloadType string) string {
@@ -433,56 +1176,50 @@ func normalizePayloadType(payloadType string) string {
 	}
 }
 
-// decodeNumericFromBits decodes BCD numeric message from bitstream
-func decodeNumericFromBits(bits []byte) string {
+// decodeNumericFromBits decodes BCD numeric message from bitstream. The
+// digits are returned exactly as sent, apart from trailing spaces: up to
+// four may be the encoder's fill and are always removed, and trimAll
+// removes the rest too.
+func decodeNumericFromBits(bits, bad []byte, placeholder rune, trimAll bool) string {
 	result := make([]rune, 0)
 	for i := 0; i+3 < len(bits); i += 4 {
+		if anySet(bad[i : i+4]) {
+			result = append(result, placeholder)
+			continue
+		}
 		nibble := byte(0)
 		for j := 0; j < 4; j++ {
 			nibble = (nibble << 1) | bits[i+j]
 		}
 		nibble = BitReverse4(nibble)
 
-		char := bcdToChar(nibble)
-		if char != 0 {
-			result = append(result, char)
-		}
+		result = append(result, bcdToChar(nibble))
 	}
-	msg := string(result)
-	// Trim trailing spaces
-	for len(msg) > 0 && msg[len(msg)-1] == ' ' {
-		msg = msg[:len(msg)-1]
+	// A codeword holds five digits, so at most four are fill
+	keep := max(len(result)-4, 0)
+	if trimAll {
+		keep = 0
 	}
-	return msg
+	for len(result) > keep && result[len(result)-1] == ' ' {
+		result = result[:len(result)-1]
+	}
+	return string(result)
 }
 
 // bcdToChar converts BCD nibble to character
 func bcdToChar(nibble byte) rune {
-	switch nibble {
-	case 0, 1, 2, 3, 4, 5, 6, 7, 8, 9:
-		return rune('0' + nibble)
-	case 0xA:
-		return '*'
-	case 0xB:
-		return 'U'
-	case 0xC:
-		return ' '
-	case 0xD:
-		return '-'
-	case 0xE:
-		return ']'
-	case 0xF:
-		return '['
-	default:
-		return 0
-	}
+	return rune(NumericAlphabet[nibble&0xF])
 }
 
 // decodeAlphaFromBits decodes a 7-bit ASCII bitstream
-func decodeAlphaFromBits(bits []byte) string {
+func decodeAlphaFromBits(bits, bad []byte, placeholder rune) string {
 	result := make([]byte, 0)
 	// Process all available 7-bit groups
 	for i := 0; i <= len(bits)-7; i += 7 {
+		if anySet(bad[i : i+7]) {
+			result = append(result, string(placeholder)...)
+			continue
+		}
 		charBits := byte(0)
 		for j := 0; j < 7; j++ {
 			charBits = (charBits << 1) | bits[i+j]
@@ -508,23 +1245,64 @@ func decodeAlphaFromBits(bits []byte) string {
 	return string(result)
 }
 
+// decodeAlphaToTerminator decodes a 7-bit ASCII bitstream up to
+// terminator, keeping line breaks and tabs and dropping other control
+// characters, and reports whether the terminator came.
+func decodeAlphaToTerminator(bits, bad []byte, placeholder rune, terminator byte) (string, bool) {
+	var result []byte
+	for i := 0; i <= len(bits)-7; i += 7 {
+		if anySet(bad[i : i+7]) {
+			result = append(result, string(placeholder)...)
+			continue
+		}
+		charBits := byte(0)
+		for j := 0; j < 7; j++ {
+			charBits = (charBits << 1) | bits[i+j]
+		}
+		switch char := BitReverse8(charBits << 1); {
+		case char == terminator:
+			return string(result), true
+		case char >= 0x20 && char <= 0x7E, char == '\r', char == '\n', char == '\t':
+			result = append(result, char)
+		}
+	}
+	return string(result), false
+}
+
 // FormatMessage formats a decoded message for display
 func (m *DecodedMessage) String() string {
 	msgType := "ALPHA"
 	if m.IsNumeric {
 		msgType = "NUMERIC"
 	}
-	return fmt.Sprintf("Address: %7d  Function: %d  %-7s  Message: %s",
-		m.Address, m.Function, msgType, m.Message)
+	partial := ""
+	if m.Partial {
+		partial = "  [PARTIAL]"
+	}
+	if m.Verified {
+		partial += "  [VERIFIED]"
+	}
+	alias := ""
+	if m.Alias != "" {
+		alias = " (" + m.Alias + ")"
+	}
+	tags := ""
+	if len(m.Tags) > 0 {
+		tags = "  Tags: " + strings.Join(m.Tags, ", ")
+	}
+	alternative := ""
+	if m.Alternative != "" {
+		alternative = "  Or: " + m.Alternative
+	}
+	return fmt.Sprintf("Address: %7d%s  Function: %d  %-7s  Message: %s%s%s%s",
+		m.Address, alias, m.Function, msgType, m.Message, partial, tags, alternative)
 }
 
-// DecodeReader reads and decodes POCSAG from an io.Reader (WAV file)
+// DecodeReader decodes POCSAG from a WAV stream at 1200 baud. The stream is
+// parsed and demodulated incrementally (see StreamDecoder), so it need not
+// fit in memory.
 func DecodeReader(r io.Reader) ([]DecodedMessage, error) {
-	data, err := io.ReadAll(r)
-	if err != nil {
-		return nil, err
-	}
-	return DecodeFromAudio(data)
+	return DecodeReaderWithOptions(r, BaudRate1200, DecodeOptions{})
 }
 
 // DecodeFromLiveStreamWithDecryption decodes POCSAG from continuous audio stream
@@ -533,28 +1311,14 @@ func DecodeReader(r io.Reader) ([]DecodedMessage, error) {
 func DecodeFromLiveStreamWithDecryption(wavData []byte, baudRate int, encryption EncryptionConfig) ([]DecodedMessage, error) {
 	fmt.Printf("[LiveDecode] Starting decode: WAV size=%d bytes, baudRate=%d\n", len(wavData), baudRate)
 
-	// Find data chunk
-	dataOffset := bytes.Index(wavData, []byte("data"))
-	startIdx := 44
-	if dataOffset != -1 {
-		startIdx = dataOffset + 8 // "data" + 4 bytes length
-	}
-
 	// Convert audio samples to bits
-	samples := make([]int16, 0)
-	for i := startIdx; i < len(wavData)-1; i += 2 {
-		sample := int16(binary.LittleEndian.Uint16(wavData[i:]))
-		samples = append(samples, sample)
+	samples, sampleRate, err := normalizedWAVSamples(wavData)
+	if err != nil {
+		return nil, err
 	}
 
 	fmt.Printf("[LiveDecode] Extracted %d audio samples\n", len(samples))
 
-	// Read sample rate from WAV header (bytes 24-27)
-	var sampleRate uint32 = 48000 // default
-	if len(wavData) > 28 {
-		sampleRate = binary.LittleEndian.Uint32(wavData[24:28])
-	}
-
 	// Demodulate: calculate samples per bit based on baud rate
 	samplesPerBit := float64(sampleRate) / float64(baudRate)
 	bits := make([]byte, 0)
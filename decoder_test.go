@@ -0,0 +1,568 @@
+package pocsag
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDecodeWithDCOffsetAndLowLevel(t *testing.T) {
+	packet := CreatePOCSAGPacketWithBaudRate(123456, "OFF AIR", FuncAlphanumeric, BaudRate512)
+	samples, rate := ParseWAVSamples(ConvertToAudioWithBaudRate(packet, BaudRate512))
+
+	// Attenuate to a weak signal riding on a drifting DC offset larger than
+	// the signal itself, as seen on discriminator taps of cheap receivers.
+	for i, s := range samples {
+		drift := 400 + 200*math.Sin(2*math.Pi*0.2*float64(i)/float64(rate))
+		samples[i] = int16(float64(s)/100 + drift)
+	}
+	wavData := createWAVFile(samples)
+
+	decoded, err := DecodeFromAudioWithBaudRate(wavData, BaudRate512)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if len(decoded) != 1 || decoded[0].Message != "OFF AIR" {
+		t.Errorf("got %v, want one message %q", decoded, "OFF AIR")
+	}
+
+	// Clean synthetic audio must decode identically with pre-processing off.
+	clean := ConvertToAudioWithBaudRate(packet, BaudRate512)
+	decoded, err = DecodeFromAudioWithOptions(clean, BaudRate512, DecodeOptions{DisableDCBlock: true, DisableAGC: true})
+	if err != nil || len(decoded) != 1 || decoded[0].Message != "OFF AIR" {
+		t.Errorf("decode without pre-processing: got %v, err %v", decoded, err)
+	}
+}
+
+func TestDecodeFloat32WAV(t *testing.T) {
+	packet := CreatePOCSAGPacketWithBaudRate(123456, "FLOAT WAV", FuncAlphanumeric, BaudRate2400)
+	wavData := ConvertToAudioWithOptions(packet, BaudRate2400, WithWAVFormat(WAVFloat32), WithSampleRate(44100))
+
+	if format := binary.LittleEndian.Uint16(wavData[20:]); format != wavFormatFloat {
+		t.Fatalf("format code: got %d, want %d", format, wavFormatFloat)
+	}
+
+	decoded, err := DecodeFromAudioWithBaudRate(wavData, BaudRate2400)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if len(decoded) != 1 || decoded[0].Message != "FLOAT WAV" {
+		t.Errorf("got %v, want one message %q", decoded, "FLOAT WAV")
+	}
+}
+
+func TestDecodeBitstreamSyncToleranceAndResync(t *testing.T) {
+	var messages []MessageInfo
+	for i := 0; i < 16; i++ {
+		messages = append(messages, MessageInfo{Address: uint32(200000 + i*3), Message: fmt.Sprintf("PAGE %02d", i), Function: FuncAlphanumeric})
+	}
+	desc := DescribeTransmission(messages, BaudRate1200)
+	if len(desc.Batches) < 4 {
+		t.Fatalf("need at least 4 batches, got %d", len(desc.Batches))
+	}
+
+	// Batches each message's codewords occupy
+	spans := make([]map[int]bool, len(messages))
+	for i := range spans {
+		spans[i] = map[int]bool{}
+	}
+	for _, b := range desc.Batches {
+		for _, f := range b.Frames {
+			for _, cw := range f.Codewords {
+				if cw.Message >= 0 {
+					spans[cw.Message][b.Index] = true
+				}
+			}
+		}
+	}
+
+	stream := bytesToBits(CreatePOCSAGBurst(messages))
+	batchStart := func(b int) int { return PreambleLength + b*17*32 }
+	flip := func(pos int) { stream[pos] ^= 1 }
+
+	// Two errored bits in the first sync word
+	flip(batchStart(0) + 3)
+	flip(batchStart(0) + 20)
+	// Batch 1's sync is destroyed, so batch 1 is lost
+	for i := 0; i < 12; i++ {
+		flip(batchStart(1) + i*2)
+	}
+	// A dropped bit late in batch 2 shifts batch 3's sync by one
+	drop := batchStart(3) - 16
+	stream = append(stream[:drop], stream[drop+1:]...)
+
+	decoded, err := DecodeFromBitstream(stream)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	got := map[string]uint32{}
+	for _, m := range decoded {
+		got[m.Message] = m.Address
+	}
+	for i, msg := range messages {
+		switch {
+		case spans[i][1]:
+			if _, ok := got[msg.Message]; ok {
+				t.Errorf("message %q from the lost batch was decoded", msg.Message)
+			}
+		case spans[i][0] && len(spans[i]) == 1, spans[i][3] && len(spans[i]) == 1:
+			if got[msg.Message] != msg.Address {
+				t.Errorf("message %q (address %d) not recovered: %v", msg.Message, msg.Address, decoded)
+			}
+		}
+	}
+}
+
+func TestDecodeTruncatedInput(t *testing.T) {
+	var messages []MessageInfo
+	for i := 0; i < 12; i++ {
+		messages = append(messages, MessageInfo{Address: uint32(300000 + i*5), Message: fmt.Sprintf("TRUNCATED PAGE NUMBER %02d", i), Function: FuncAlphanumeric})
+	}
+	want := make(map[uint32]string)
+	for _, m := range messages {
+		want[m.Address] = m.Message
+	}
+	bits := bytesToBits(CreatePOCSAGBurst(messages))
+	batchBits := (CodewordsPerBatch + 1) * 32
+
+	rng := rand.New(rand.NewSource(2912))
+	for n := 0; n < 300; n++ {
+		cut := PreambleLength + 32 + rng.Intn(len(bits)-PreambleLength-32)
+		if (cut-PreambleLength)%batchBits < 8 {
+			// Cut where a transmission may as well have ended, or too
+			// early in a sync word to tell
+			continue
+		}
+		decoded, err := decodeBitstream(bits[:cut], "", DecodeOptions{}, 0)
+		if err != nil {
+			t.Fatalf("cut at bit %d: %v", cut, err)
+		}
+		for i, msg := range decoded {
+			if !msg.Partial && msg.Message != want[msg.Address] {
+				t.Errorf("cut at bit %d: %q not marked partial", cut, msg.Message)
+			}
+			if i < len(decoded)-1 && msg.Partial {
+				t.Errorf("cut at bit %d: %q before the cut marked partial", cut, msg.Message)
+			}
+		}
+	}
+
+	// Trailing codewords of a batch cut short still decode
+	decoded, _ := decodeBitstream(bits[:PreambleLength+32+6*32+5], "", DecodeOptions{}, 0)
+	if len(decoded) == 0 || decoded[0].Address != messages[0].Address {
+		t.Errorf("partial batch: %+v", decoded)
+	}
+}
+
+func TestDecodeGapTimeout(t *testing.T) {
+	long := strings.Repeat("CARRIER DROPPED IN THE MIDDLE OF THIS PAGE. ", 4)
+	bits := bytesToBits(CreatePOCSAGBurst([]MessageInfo{
+		{Address: 8, Message: long, Function: FuncAlphanumeric},
+		{Address: 16, Message: "NEXT PAGE", Function: FuncAlphanumeric},
+	}))
+
+	// The second batch is lost to noise
+	rng := rand.New(rand.NewSource(2937))
+	dropped := append([]byte(nil), bits[:PreambleLength+BatchBits]...)
+	for i := 0; i < BatchBits; i++ {
+		dropped = append(dropped, byte(rng.Intn(2)))
+	}
+	dropped = append(dropped, bits[PreambleLength+2*BatchBits:]...)
+
+	for _, tc := range []struct {
+		timeout  time.Duration
+		stitched bool
+	}{
+		{0, false},
+		{100 * time.Millisecond, false},
+		{time.Second, true},
+	} {
+		decoded, err := decodeBitstream(dropped, "", DecodeOptions{GapTimeout: tc.timeout}, BaudRate1200)
+		if err != nil || len(decoded) != 2 {
+			t.Fatalf("timeout %v: got %+v, err %v", tc.timeout, decoded, err)
+		}
+		first := decoded[0]
+		// Twenty characters of alpha fit in seven codewords
+		head := long[:(CodewordsPerBatch-1)*20/7]
+		if first.Address != 8 || !strings.HasPrefix(first.Message, head) {
+			t.Errorf("timeout %v: first message %q", tc.timeout, first.Message)
+		}
+		// Stitched, the lost batch shows as placeholders and the rest of
+		// the message stays aligned after it
+		if stitched := len(first.Message) == len(long) && strings.HasSuffix(long, first.Message[len(head)+50:]); stitched != tc.stitched {
+			t.Errorf("timeout %v: stitched %v, message %q", tc.timeout, stitched, first.Message)
+		}
+		if tc.timeout > 0 && !first.Partial {
+			t.Errorf("timeout %v: message held across the gap not partial", tc.timeout)
+		}
+		if decoded[1].Address != 16 || decoded[1].Message != "NEXT PAGE" || decoded[1].Partial {
+			t.Errorf("timeout %v: second message %+v", tc.timeout, decoded[1])
+		}
+	}
+
+	// Squelch joins transmissions closer than the timeout
+	spans := []span{{0, 100, true}, {150, 300, false}, {1000, 1200, true}}
+	if got := joinSpans(spans, 60); !reflect.DeepEqual(got, []span{{0, 300, true}, {1000, 1200, true}}) {
+		t.Errorf("joined %v", got)
+	}
+}
+
+func TestDecodePartialMessagePlaceholders(t *testing.T) {
+	msgs := []MessageInfo{
+		{Address: 8, Message: "ABCDEFGHIJKL", Function: FuncAlphanumeric},
+		{Address: 17, Message: "0123456789", Function: FuncNumeric},
+	}
+	packet := CreatePOCSAGBurst(msgs)
+	batches := LayoutBatches(msgs)
+
+	// Corrupt three bits of a message codeword
+	corrupt := func(batch, slot int) {
+		pos := PreambleLength/8 + batch*BatchBits/8 + 4 + slot*4
+		packet[pos] ^= 0x07
+	}
+	corrupt(0, 2) // second codeword of address 8's message
+	for b, batch := range batches {
+		for slot, cw := range batch.Codewords() {
+			if cw == EncodeAddress(17, FuncNumeric) {
+				corrupt(b, slot+1) // first codeword of address 17's message
+			}
+		}
+	}
+
+	decoded, err := DecodeFromBinary(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 2 {
+		t.Fatalf("decoded %d messages: %v", len(decoded), decoded)
+	}
+	// Codeword 2 carries bits 20-39, i.e. parts of characters 2-5
+	if decoded[0].Message != "AB????GHIJKL" || !decoded[0].Partial {
+		t.Errorf("alpha: %q partial=%v", decoded[0].Message, decoded[0].Partial)
+	}
+	// Five digits per codeword
+	if decoded[1].Message != "?????56789" || !decoded[1].Partial {
+		t.Errorf("numeric: %q partial=%v", decoded[1].Message, decoded[1].Partial)
+	}
+
+	// A corrupted final codeword is dropped but still marks the message
+	corrupt(0, 5)
+	decoded, _ = DecodeFromBinary(packet)
+	if decoded[0].Message != "AB????GHIJK" || !decoded[0].Partial {
+		t.Errorf("trailing corruption: %q partial=%v", decoded[0].Message, decoded[0].Partial)
+	}
+
+	// The placeholder is configurable and intact messages are not partial
+	decoded, _ = decodeBitstream(bytesToBits(packet), "", DecodeOptions{Placeholder: '_'}, 0)
+	if decoded[0].Message != "AB____GHIJK" {
+		t.Errorf("custom placeholder: %q", decoded[0].Message)
+	}
+	clean, _ := DecodeFromBinary(CreatePOCSAGBurst(msgs))
+	if clean[0].Partial || clean[1].Partial {
+		t.Errorf("clean decode flagged partial: %+v", clean)
+	}
+}
+
+func TestNumericPagesKeepLeadingZerosAndSpaces(t *testing.T) {
+	decode := func(message string, opts DecodeOptions) string {
+		packet := CreatePOCSAGPacket(1234567, message, FuncNumeric)
+		decoded, err := decodeBitstream(bytesToBits(packet), "", opts, 0)
+		if err != nil || len(decoded) != 1 {
+			t.Fatalf("decode of %q: got %v, err %v", message, decoded, err)
+		}
+		return decoded[0].Message
+	}
+
+	// Phone numbers and codes come back exactly as sent
+	for _, message := range []string{"0", "0007", "00000", "000000", "0044 20 7946 0000", "0123456789"} {
+		if got := decode(message, DecodeOptions{}); got != message {
+			t.Errorf("round trip of %q: got %q", message, got)
+		}
+	}
+
+	// Only the four spaces of fill are removed by default
+	if got := decode("12345      ", DecodeOptions{}); got != "12345      " {
+		t.Errorf("default: got %q", got)
+	}
+	if got := decode("12345      ", DecodeOptions{TrimTrailingSpaces: true}); got != "12345" {
+		t.Errorf("TrimTrailingSpaces: got %q", got)
+	}
+
+	// The option reaches the audio decoders too
+	wavData := ConvertToAudio(CreatePOCSAGPacket(1234567, "0007       ", FuncNumeric))
+	for _, tt := range []struct {
+		opts DecodeOptions
+		want string
+	}{
+		{DecodeOptions{}, "0007       "},
+		{DecodeOptions{TrimTrailingSpaces: true}, "0007"},
+	} {
+		decoded, err := DecodeFromAudioWithOptions(wavData, BaudRate1200, tt.opts)
+		if err != nil || len(decoded) != 1 || decoded[0].Message != tt.want {
+			t.Errorf("audio with %+v: got %v, err %v", tt.opts, decoded, err)
+		}
+		decoded, err = DecodeReaderWithOptions(bytes.NewReader(wavData), BaudRate1200, tt.opts)
+		if err != nil || len(decoded) != 1 || decoded[0].Message != tt.want {
+			t.Errorf("stream with %+v: got %v, err %v", tt.opts, decoded, err)
+		}
+	}
+}
+
+func TestDecodeStats(t *testing.T) {
+	msgs := []MessageInfo{{Address: 123456, Message: "LONG ENOUGH TO SPAN A SECOND BATCH OF CODEWORDS", Function: FuncAlphanumeric}}
+	wavData := NewEncoder().EncodeWAV(msgs)
+
+	decoded, stats, err := DecodeFromAudioWithStats(wavData, BaudRate1200, DecodeOptions{})
+	if err != nil || len(decoded) != 1 {
+		t.Fatalf("got %v, err %v", decoded, err)
+	}
+	if stats.Batches != 2 || stats.Resyncs != 0 || stats.BitSlips != 0 || math.Abs(stats.ClockDriftPPM) > 5 || stats.EyeOpening < 0.9 || stats.SNR < 25 {
+		t.Errorf("clean recording: %+v", stats)
+	}
+
+	// Claiming a higher sample rate plays the recording 208 ppm fast
+	fast := append([]byte(nil), wavData...)
+	binary.LittleEndian.PutUint32(fast[24:], SampleRate+10)
+	_, stats, _ = DecodeFromAudioWithStats(fast, BaudRate1200, DecodeOptions{})
+	if want := 1e6 * 10 / SampleRate; math.Abs(stats.ClockDriftPPM-want) > 10 {
+		t.Errorf("drift %.1f ppm, want %.1f", stats.ClockDriftPPM, want)
+	}
+
+	// A second transmission after a gap is a resync
+	twice := NewEncoder().EncodeTransmissions([][]MessageInfo{msgs, msgs}, time.Second)
+	if _, stats, _ = DecodeFromAudioWithStats(twice, BaudRate1200, DecodeOptions{}); stats.Resyncs != 1 || stats.Batches != 4 {
+		t.Errorf("two transmissions: %+v", stats)
+	}
+}
+
+func TestDecodeMultiRate(t *testing.T) {
+	// Traffic at all three rates on one channel, with pauses between
+	var pcm []int16
+	pause := make([]int16, SampleRate/2)
+	for _, tt := range []struct {
+		baud    int
+		message string
+	}{
+		{BaudRate1200, "AT 1200"},
+		{BaudRate512, "AT 512"},
+		{BaudRate2400, "AT 2400"},
+		{BaudRate1200, "1200 AGAIN"},
+		{BaudRate1200, "1200 AGAIN"}, // a repeat is not a duplicate
+	} {
+		wavData := NewEncoder(WithBaudRate(tt.baud)).EncodeWAV([]MessageInfo{{Address: 123456, Message: tt.message, Function: FuncAlphanumeric}})
+		samples, _ := ParseWAVSamples(wavData)
+		pcm = append(append(pcm, samples...), pause...)
+	}
+
+	decoded, err := DecodeFromAudioMultiRate(SamplesToWAV(pcm, SampleRate, WAVPCM16), DecodeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, msg := range decoded {
+		got = append(got, fmt.Sprintf("%d:%s", msg.BaudRate, msg.Message))
+	}
+	if want := "[1200:AT 1200 512:AT 512 2400:AT 2400 1200:1200 AGAIN 1200:1200 AGAIN]"; fmt.Sprint(got) != want {
+		t.Errorf("got %v, want %s", got, want)
+	}
+
+	if _, err := DecodeFromAudioMultiRate([]byte("junk"), DecodeOptions{}); err == nil {
+		t.Error("decoded a non-WAV input")
+	}
+
+	// A header claiming 1 Hz would take gigabytes to resample
+	bogus := SamplesToWAV(make([]int16, 48000), 1, WAVPCM16)
+	if _, err := DecodeFromAudio(bogus); err == nil {
+		t.Error("decoded a WAV at 1 Hz")
+	}
+	if _, err := DecodeFromAudioMultiRate(bogus, DecodeOptions{}); err == nil {
+		t.Error("decoded a WAV at 1 Hz at every rate")
+	}
+}
+
+func BenchmarkDecodeFromAudio(b *testing.B) {
+	for _, baud := range []int{BaudRate512, BaudRate1200, BaudRate2400} {
+		b.Run(fmt.Sprint(baud), func(b *testing.B) {
+			packet := CreatePOCSAGPacketWithBaudRate(123456, "THE QUICK BROWN FOX JUMPS OVER THE LAZY DOG", FuncAlphanumeric, baud)
+			wav := ConvertToAudioWithBaudRate(packet, baud)
+			b.SetBytes(int64(len(wav)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := DecodeFromAudioWithBaudRate(wav, baud); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeIncludeRaw(t *testing.T) {
+	msg := MessageInfo{Address: 123456, Message: "RAW CODEWORDS", Function: FuncAlphanumeric}
+	want := messageCodewords(msg)
+
+	// Corrupt the second message codeword; it must come back unrepaired
+	packet := CreatePOCSAGBurstWithBaudRate([]MessageInfo{msg}, BaudRate1200)
+	packet[PreambleLength/8+4+4*2+3] ^= 0x10
+	want[2] ^= 0x10
+
+	wav := ConvertToAudioWithBaudRate(packet, BaudRate1200)
+	decoded, err := DecodeFromAudioWithOptions(wav, BaudRate1200, DecodeOptions{IncludeRaw: true})
+	if err != nil || len(decoded) != 1 || !decoded[0].Partial {
+		t.Fatalf("got %+v, err %v", decoded, err)
+	}
+	if fmt.Sprint(decoded[0].Codewords) != fmt.Sprint(want) {
+		t.Errorf("codewords %08X, want %08X", decoded[0].Codewords, want)
+	}
+	if DoesWordPassBCH(decoded[0].Codewords[2]) {
+		t.Error("corrupted codeword passes BCH")
+	}
+
+	decoded, _ = DecodeFromAudioWithOptions(wav, BaudRate1200, DecodeOptions{})
+	if decoded[0].Codewords != nil {
+		t.Error("codewords returned without IncludeRaw")
+	}
+}
+
+func TestDecodeForcedPayloadType(t *testing.T) {
+	// Numeric pages on function 2 and text on function 0, as some
+	// networks send them
+	packet := CreatePOCSAGBurstWithBaudRate([]MessageInfo{
+		{Address: 8, Function: 2, PayloadType: PayloadTypeNumeric, Message: "0123"},
+		{Address: 16, Function: 0, PayloadType: PayloadTypeAlpha, Message: "TEXT"},
+	}, BaudRate1200)
+	wav := ConvertToAudioWithBaudRate(packet, BaudRate1200)
+
+	byAddress := map[uint32]string{8: PayloadTypeNumeric, 16: PayloadTypeAlpha}
+	for _, tc := range []struct {
+		opts    DecodeOptions
+		numeric bool // address 8 is decoded as numeric
+		both    bool // and address 16 still as text
+	}{
+		{DecodeOptions{ForceNumeric: true}, true, false},
+		{DecodeOptions{ForceAlpha: true}, false, false},
+		{DecodeOptions{PayloadTypes: byAddress}, true, true},
+		{DecodeOptions{ForceAlpha: true, PayloadTypes: map[uint32]string{8: PayloadTypeNumeric}}, true, true},
+	} {
+		decoded, err := DecodeFromAudioWithOptions(wav, BaudRate1200, tc.opts)
+		if err != nil || len(decoded) != 2 {
+			t.Fatalf("got %+v, err %v", decoded, err)
+		}
+		if (decoded[0].Message == "0123") != tc.numeric || decoded[0].IsNumeric != tc.numeric {
+			t.Errorf("%+v: address 8 %+v", tc.opts, decoded[0])
+		}
+		if (decoded[1].Message == "TEXT") != (tc.both || !tc.numeric) {
+			t.Errorf("%+v: address 16 %+v", tc.opts, decoded[1])
+		}
+	}
+}
+
+func TestSquelch(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	static := func(seconds int) []int16 {
+		out := make([]int16, seconds*SampleRate)
+		var level float64
+		for i := range out {
+			level = 0.7*level + 0.3*rng.NormFloat64()*12000
+			out[i] = int16(max(-32000, min(32000, level)))
+		}
+		return out
+	}
+	page := func(address uint32, text string) []int16 {
+		samples, _ := ParseWAVSamples(ConvertToAudioWithBaudRate(CreatePOCSAGPacketWithBaudRate(address, text, FuncAlphanumeric, BaudRate1200), BaudRate1200))
+		return samples
+	}
+
+	// Two pages in fifteen seconds of static
+	recording := static(5)
+	recording = append(recording, page(123456, "FIRST PAGE")...)
+	recording = append(recording, static(7)...)
+	recording = append(recording, page(8, "SECOND PAGE")...)
+	recording = append(recording, static(3)...)
+	wav := SamplesToWAV(recording, SampleRate, WAVPCM16)
+
+	segments, err := DetectTransmissions(wav, BaudRate1200)
+	if err != nil || len(segments) != 2 {
+		t.Fatalf("got %v, err %v", segments, err)
+	}
+	for i, start := range []float64{5, 12.9} {
+		if s := segments[i]; math.Abs(s.Start.Seconds()-start) > 0.1 || s.End-s.Start > 1200*time.Millisecond || !s.Preamble {
+			t.Errorf("segment %d: %+v", i, s)
+		}
+	}
+
+	decoded, err := DecodeFromAudioWithOptions(wav, BaudRate1200, DecodeOptions{Squelch: true})
+	if err != nil || len(decoded) != 2 || decoded[0].Message != "FIRST PAGE" || decoded[1].Message != "SECOND PAGE" {
+		t.Errorf("got %+v, err %v", decoded, err)
+	}
+
+	transmissions, err := DecodeTransmissions(wav, BaudRate1200, DecodeOptions{})
+	if err != nil || len(transmissions) != 2 {
+		t.Fatalf("got %+v, err %v", transmissions, err)
+	}
+	for i, want := range []string{"FIRST PAGE", "SECOND PAGE"} {
+		tr := transmissions[i]
+		if tr.Segment != segments[i] || tr.BaudRate != BaudRate1200 || len(tr.Messages) != 1 || tr.Messages[0].Message != want || tr.Stats.Batches == 0 {
+			t.Errorf("transmission %d: %+v", i, tr)
+		}
+	}
+
+	if segments, _ := DetectTransmissions(SamplesToWAV(static(5), SampleRate, WAVPCM16), BaudRate1200); len(segments) != 0 {
+		t.Errorf("static alone: %v", segments)
+	}
+}
+
+func TestDecoderInstances(t *testing.T) {
+	msgs := []MessageInfo{{Address: 123456, Message: "TWO DECODERS", Function: FuncAlphanumeric}}
+	normal := NewEncoder().EncodeWAV(msgs)
+	inverted := NewEncoder(WithSymbols(SymbolLow, SymbolHigh)).EncodeWAV(msgs)
+
+	// Decoders fixed to opposite polarities, used at the same time
+	decoders := []*Decoder{
+		NewDecoder(BaudRate1200, DecodeOptions{Polarity: PolarityNormal}),
+		NewDecoder(BaudRate1200, DecodeOptions{Polarity: PolarityInverted}),
+	}
+	var wg sync.WaitGroup
+	for _, d := range decoders {
+		for _, wav := range [][]byte{normal, inverted, normal} {
+			wg.Add(1)
+			go func(d *Decoder, wav []byte) {
+				defer wg.Done()
+				if _, err := d.Decode(wav); err != nil {
+					t.Error(err)
+				}
+			}(d, wav)
+		}
+	}
+	wg.Wait()
+
+	if s := decoders[0].Stats(); s.Recordings != 3 || s.Messages != 2 {
+		t.Errorf("normal polarity decoder: %+v", s)
+	}
+	if s := decoders[1].Stats(); s.Recordings != 3 || s.Messages != 1 {
+		t.Errorf("inverted polarity decoder: %+v", s)
+	}
+
+	// A threshold past the signal's level reads every bit the same
+	decoded, _ := NewDecoder(BaudRate1200, DecodeOptions{Threshold: 20000}).Decode(normal)
+	if len(decoded) != 0 {
+		t.Errorf("threshold above the signal: got %v", decoded)
+	}
+
+	// A transmitter 3% fast needs the clock tolerance
+	fast := append([]byte(nil), normal...)
+	binary.LittleEndian.PutUint32(fast[24:], SampleRate*103/100)
+	if decoded, _ := NewDecoder(BaudRate1200, DecodeOptions{}).Decode(fast); len(decoded) != 0 {
+		t.Errorf("3%% fast without clock tolerance: got %v", decoded)
+	}
+	decoded, _ = NewDecoder(BaudRate1200, DecodeOptions{ClockTolerancePPM: 30000}).Decode(fast)
+	if len(decoded) != 1 || decoded[0].Message != "TWO DECODERS" {
+		t.Errorf("with clock tolerance: got %v", decoded)
+	}
+}
@@ -0,0 +1,177 @@
+package pocsag
+
+import (
+	"math"
+	"sort"
+)
+
+// DecodeStats describes how cleanly a recording demodulated, to quantify
+// recording quality and tune a receiver chain. It is measured on the
+// demodulation attempt the decoder kept.
+type DecodeStats struct {
+	// Batches is the number of batch sync words found.
+	Batches int `json:"batches"`
+	// Resyncs counts how often sync was found again after being lost,
+	// which includes the start of every later transmission.
+	Resyncs int `json:"resyncs"`
+	// BitSlips counts batch sync words found off their expected position,
+	// each a bit the clock recovery gained or lost.
+	BitSlips int `json:"bit_slips"`
+	// ClockDriftPPM estimates how far the sender's bit rate is from the
+	// nominal baud rate, in parts per million; positive is fast.
+	ClockDriftPPM float64 `json:"clock_drift_ppm"`
+	// EyeOpening is the average eye opening from 0 (closed) to 1 (a clean
+	// square wave): the gap between the 1 and 0 levels, less one standard
+	// deviation of each, relative to the distance between their means.
+	EyeOpening float64 `json:"eye_opening"`
+	// SNR is the signal-to-noise ratio of the demodulated signal in dB:
+	// half the distance between the 1 and 0 levels against the noise on
+	// them, up to MaxSNR. Audio carries no signal strength, which FM
+	// demodulation removes; the IQ decoders report it per message as
+	// DecodedMessage.RSSI.
+	SNR float64 `json:"snr_db"`
+}
+
+// MaxSNR is the highest SNR reported, in dB, that of noiseless synthetic
+// signals.
+const MaxSNR = 60.0
+
+// snrDB converts the power of a signal and of the noise on it to an SNR
+// in dB, at most MaxSNR.
+func snrDB(signal, noise float64) float64 {
+	if noise <= 0 || signal/noise > math.Pow(10, MaxSNR/10) {
+		return MaxSNR
+	}
+	return 10 * math.Log10(signal/noise)
+}
+
+// DecodeFromAudioWithStats is DecodeFromAudioWithOptions that also reports
+// demodulator diagnostics.
+func DecodeFromAudioWithStats(wavData []byte, baudRate int, opts DecodeOptions) ([]DecodedMessage, DecodeStats, error) {
+	var stats DecodeStats
+	messages, err := decodeAudio(wavData, baudRate, opts, &stats)
+	return messages, stats, err
+}
+
+// merge adds the stats of a later transmission decoded on its own, with
+// drift, eye opening, and SNR averaged by batches.
+func (s *DecodeStats) merge(o DecodeStats) {
+	if total := s.Batches + o.Batches; total > 0 {
+		w := float64(o.Batches) / float64(total)
+		s.ClockDriftPPM += (o.ClockDriftPPM - s.ClockDriftPPM) * w
+		s.EyeOpening += (o.EyeOpening - s.EyeOpening) * w
+		s.SNR += (o.SNR - s.SNR) * w
+	}
+	if s.Batches > 0 && o.Batches > 0 {
+		s.Resyncs++ // finding the later transmission
+	}
+	s.Batches += o.Batches
+	s.Resyncs += o.Resyncs
+	s.BitSlips += o.BitSlips
+}
+
+// sliceMeasurement collects what sliceBits sees of each bit.
+type sliceMeasurement struct {
+	levels    []float32 // mean sample value of each bit
+	crossings []bitCrossing
+}
+
+// bitCrossing is a transition at the end of bit, phase samples from where
+// the nominal bit clock puts it.
+type bitCrossing struct {
+	bit   int
+	phase float64
+}
+
+func (m *sliceMeasurement) addCrossing(bit int, phase float64) {
+	m.crossings = append(m.crossings, bitCrossing{bit, phase})
+}
+
+// finish fills in stats from the bits that carry signal: those at least
+// half as strong as the strongest tenth, which leaves out noise between
+// transmissions.
+func (m *sliceMeasurement) finish(samplesPerBit float64, stats *DecodeStats) {
+	if len(m.levels) == 0 {
+		return
+	}
+	strength := make([]float64, len(m.levels))
+	for i, v := range m.levels {
+		strength[i] = math.Abs(float64(v))
+	}
+	sorted := append([]float64(nil), strength...)
+	sort.Float64s(sorted)
+	threshold := sorted[len(sorted)*9/10] / 2
+	signal := func(bit int) bool {
+		return bit < len(strength) && strength[bit] >= threshold && threshold > 0
+	}
+
+	var ones, zeros []float64
+	for i, v := range m.levels {
+		if !signal(i) {
+			continue
+		}
+		if v > 0 {
+			ones = append(ones, float64(v))
+		} else {
+			zeros = append(zeros, float64(v))
+		}
+	}
+	if len(ones) > 0 && len(zeros) > 0 {
+		mean1, sd1 := meanStdDev(ones)
+		mean0, sd0 := meanStdDev(zeros)
+		eye := ((mean1 - sd1) - (mean0 + sd0)) / (mean1 - mean0)
+		stats.EyeOpening = math.Max(0, math.Min(1, eye))
+		stats.SNR = snrDB((mean1-mean0)*(mean1-mean0)/4, (sd1*sd1+sd0*sd0)/2)
+	}
+
+	// The phase of the transitions drifts linearly when the sender's clock
+	// is off. Each transmission starts at its own phase, so the slope is
+	// fitted with every run of signal centred on its own mean.
+	var sxy, sxx float64
+	var run []bitCrossing
+	fit := func() {
+		if len(run) < 2 {
+			run = run[:0]
+			return
+		}
+		var mx, my float64
+		for _, c := range run {
+			mx += float64(c.bit)
+			my += c.phase
+		}
+		mx /= float64(len(run))
+		my /= float64(len(run))
+		for _, c := range run {
+			dx := float64(c.bit) - mx
+			sxy += dx * (c.phase - my)
+			sxx += dx * dx
+		}
+		run = run[:0]
+	}
+	for _, c := range m.crossings {
+		if !signal(c.bit) || !signal(c.bit+1) {
+			continue
+		}
+		// A long stretch without signal ends the run
+		if len(run) > 0 && c.bit-run[len(run)-1].bit > CodewordBits {
+			fit()
+		}
+		run = append(run, c)
+	}
+	fit()
+	if sxx > 0 {
+		// A fast sender's transitions come progressively early
+		stats.ClockDriftPPM = -sxy / sxx / samplesPerBit * 1e6
+	}
+}
+
+func meanStdDev(values []float64) (mean, sd float64) {
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	for _, v := range values {
+		sd += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(sd / float64(len(values)))
+}
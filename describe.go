@@ -0,0 +1,158 @@
+package pocsag
+
+import (
+	"fmt"
+)
+
+// Codeword kinds reported by DescribeTransmission
+const (
+	CodewordTypeAddress = "address"
+	CodewordTypeMessage = "message"
+	CodewordTypeIdle    = "idle"
+	// CodewordTypePadding is a repeated address codeword in a slot no
+	// message uses, with PadLastAddress.
+	CodewordTypePadding = "padding"
+)
+
+// TransmissionDescription is a JSON-serializable view of everything the
+// encoder will put on the air for a set of messages: the batch/frame layout
+// with the meaning of every codeword, and timing for the whole transmission
+// and for each message. FrequencyHz is set for the channels of a
+// ChannelPlan.
+type TransmissionDescription struct {
+	FrequencyHz  int64                `json:"frequency_hz,omitempty"`
+	BaudRate     int                  `json:"baud"`
+	PreambleBits int                  `json:"preamble_bits"`
+	TotalBits    int                  `json:"total_bits"`
+	DurationSec  float64              `json:"duration_s"`
+	Batches      []BatchDescription   `json:"batches"`
+	Messages     []MessageDescription `json:"messages"`
+}
+
+// BatchDescription describes one batch: a frame sync word followed by 8 frames.
+type BatchDescription struct {
+	Index    int                `json:"index"`
+	SyncWord string             `json:"sync_word"`
+	Frames   []FrameDescription `json:"frames"`
+}
+
+// FrameDescription describes one frame (2 codewords) within a batch.
+type FrameDescription struct {
+	Index     int                   `json:"index"`
+	Codewords []CodewordDescription `json:"codewords"`
+}
+
+// CodewordDescription describes a single 32-bit codeword and its meaning.
+// Message is the index into TransmissionDescription.Messages, or -1 for idle.
+type CodewordDescription struct {
+	Value    uint32 `json:"value"`
+	Hex      string `json:"hex"`
+	Type     string `json:"type"`
+	Message  int    `json:"message"`
+	Address  uint32 `json:"address,omitempty"`
+	Function uint8  `json:"function,omitempty"`
+}
+
+// MessageDescription summarizes where a message lands and how long it takes
+// to transmit. AirtimeSec counts only the message's own codewords, not the
+// shared preamble, sync words, or idle padding.
+type MessageDescription struct {
+	Index       int     `json:"index"`
+	Address     uint32  `json:"address"`
+	Function    uint8   `json:"function"`
+	PayloadType string  `json:"payload_type"`
+	Message     string  `json:"message"`
+	Batch       int     `json:"batch"`
+	Frame       int     `json:"frame"`
+	Codewords   int     `json:"codewords"`
+	AirtimeSec  float64 `json:"airtime_s"`
+}
+
+// DescribeTransmission lays out messages exactly as CreatePOCSAGBurstWithBaudRate
+// would and returns the resulting structure, for capacity planning and UIs
+// that visualize what will be transmitted.
+func DescribeTransmission(messages []MessageInfo, baudRate int) TransmissionDescription {
+	return NewEncoder(WithBaudRate(baudRate)).Describe(messages)
+}
+
+// Describe returns the layout of the burst CreateBurst would produce for
+// messages. If they cannot be encrypted, it describes a burst with none.
+func (e *Encoder) Describe(messages []MessageInfo) TransmissionDescription {
+	messages, err := e.encrypt(messages)
+	if err != nil {
+		messages = nil
+	}
+	batches, owners := e.layout(messages)
+	return e.describe(e.transliterate(messages), batches, owners)
+}
+
+// describe describes batches laid out by layout for messages, as
+// transliterated.
+func (e *Encoder) describe(messages []MessageInfo, batches [][]uint32, owners [][]int) TransmissionDescription {
+	baudRate := e.baudRate
+	desc := TransmissionDescription{
+		BaudRate:     baudRate,
+		PreambleBits: e.preambleBits,
+		Batches:      make([]BatchDescription, len(batches)),
+		Messages:     make([]MessageDescription, len(messages)),
+	}
+
+	for i, msg := range messages {
+		desc.Messages[i] = MessageDescription{
+			Index:       i,
+			Address:     msg.Address,
+			Function:    msg.Function,
+			PayloadType: messagePayloadType(msg),
+			Message:     msg.Message,
+			Batch:       -1,
+			Frame:       -1,
+		}
+	}
+
+	for b, batch := range batches {
+		bd := BatchDescription{
+			Index:    b,
+			SyncWord: fmt.Sprintf("0x%08X", e.syncWord),
+			Frames:   make([]FrameDescription, len(batch)/CodewordsPerFrame),
+		}
+		for f := range bd.Frames {
+			bd.Frames[f].Index = f
+		}
+
+		for slot, cw := range batch {
+			owner := owners[b][slot]
+			cd := CodewordDescription{
+				Value:   cw,
+				Hex:     fmt.Sprintf("0x%08X", cw),
+				Type:    CodewordTypeIdle,
+				Message: owner,
+			}
+			if owner == -1 && cw != e.idleWord {
+				cd.Type = CodewordTypePadding
+			}
+			if owner >= 0 {
+				md := &desc.Messages[owner]
+				cd.Address = md.Address
+				cd.Function = md.Function
+				if md.Batch == -1 {
+					cd.Type = CodewordTypeAddress
+					md.Batch = b
+					md.Frame = slot / CodewordsPerFrame
+				} else {
+					cd.Type = CodewordTypeMessage
+				}
+				md.Codewords++
+			}
+			bd.Frames[slot/CodewordsPerFrame].Codewords = append(bd.Frames[slot/CodewordsPerFrame].Codewords, cd)
+		}
+		desc.Batches[b] = bd
+	}
+
+	for i := range desc.Messages {
+		desc.Messages[i].AirtimeSec = float64(desc.Messages[i].Codewords*CodewordBits) / float64(baudRate)
+	}
+
+	desc.TotalBits = transmissionBits(e.preambleBits, batches)
+	desc.DurationSec = float64(desc.TotalBits) / float64(baudRate)
+	return desc
+}
@@ -0,0 +1,279 @@
+package pocsag
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// Codeword kinds reported by CheckCodeword.
+const (
+	KindSync    = "sync"
+	KindIdle    = "idle"
+	KindAddress = "address"
+	KindMessage = "message"
+)
+
+// ReadBatches returns the batches a transmission carries, for comparing
+// it with another codeword by codeword. data is a WAV recording,
+// demodulated at baudRate, or a bitstream: text of 0s and 1s (whitespace
+// is ignored) or bytes sent MSB first, as CreatePOCSAGBurst returns. A
+// batch the input ends inside is left out.
+func ReadBatches(data []byte, baudRate int) ([]Batch, error) {
+	var stream []byte
+	switch {
+	case len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WAVE":
+		var err error
+		if stream, err = cleanestBits(data, baudRate); err != nil {
+			return nil, err
+		}
+	case isBitText(data):
+		for _, c := range data {
+			if c == '0' || c == '1' {
+				stream = append(stream, c-'0')
+			}
+		}
+	default:
+		stream = bytesToBits(data)
+	}
+	if findSync(stream, 0) < 0 {
+		// Some transmitters take the bits the other way up
+		for i := range stream {
+			stream[i] ^= 1
+		}
+	}
+
+	batches := splitBatches(stream)
+	if len(batches) == 0 {
+		return nil, fmt.Errorf("sync word not found")
+	}
+	return batches, nil
+}
+
+// cleanestBits demodulates a WAV recording with the polarity and clock
+// phase that give the most codewords BCH can make sense of. Unlike the
+// decoder it does not need a message to come out, as the transmission
+// being debugged may not produce one.
+func cleanestBits(wavData []byte, baudRate int) ([]byte, error) {
+	samples, sampleRate, err := audioBaseband(wavData, DecodeOptions{})
+	if err != nil {
+		return nil, err
+	}
+	samplesPerBit := float64(sampleRate) / float64(baudRate)
+	baseband := integrate(samples)
+	const phases = 40
+
+	var best []byte
+	bestScore := -1
+	for _, track := range []bool{false, true} {
+		for _, invert := range []bool{false, true} {
+			for phase := 0; phase < phases; phase++ {
+				offset := float64(phase) * samplesPerBit / phases
+				stream := sliceBits(baseband, samplesPerBit, offset, 0, invert, track, nil)
+				score := 0
+				for _, b := range splitBatches(stream) {
+					for _, cw := range b.Codewords() {
+						if _, ok := CorrectCodeword(cw); ok {
+							score++
+						}
+					}
+				}
+				if score > bestScore {
+					best, bestScore = stream, score
+				}
+			}
+		}
+	}
+	return best, nil
+}
+
+// isBitText reports whether data is 0s and 1s separated by whitespace.
+func isBitText(data []byte) bool {
+	digits := 0
+	for _, c := range data {
+		switch c {
+		case '0', '1':
+			digits++
+		case ' ', '\t', '\r', '\n':
+		default:
+			return false
+		}
+	}
+	return digits > 0
+}
+
+// splitBatches cuts stream into batches the way the decoder walks it,
+// following the sync word through bit slips and hunting for it again when
+// it is lost.
+func splitBatches(stream []byte) []Batch {
+	batches := make([]Batch, 0)
+	pos := findSync(stream, 0)
+	for pos >= 0 {
+		sync, _ := readBitsWord(stream, pos-32)
+		b := Batch{Sync: sync}
+		for slot := 0; slot < CodewordsPerBatch; slot++ {
+			cw, ok := readBitsWord(stream, pos+slot*CodewordBits)
+			if !ok {
+				return batches
+			}
+			b.Frames[slot/CodewordsPerFrame][slot%CodewordsPerFrame] = cw
+		}
+		batches = append(batches, b)
+
+		end := pos + CodewordsPerBatch*CodewordBits
+		if pos = nextBatchSync(stream, end, FrameSyncWord); pos < 0 {
+			pos = findSync(stream, end)
+		}
+	}
+	return batches
+}
+
+// CodewordCheck is what a received codeword turns out to be once BCH
+// error correction has been tried on it.
+type CodewordCheck struct {
+	Codeword  uint32
+	Kind      string // KindSync, KindIdle, KindAddress, or KindMessage
+	Corrected uint32 // Codeword with the errors fixed, if they could be
+	// Errors is the number of bits wrong: fixed by CorrectCodeword, or
+	// for the sync word, different from FrameSyncWord. -1 means more than
+	// CorrectCodeword can fix.
+	Errors int
+	// RIC and Function are filled in for address codewords
+	RIC      uint32
+	Function uint8
+}
+
+// CheckCodeword classifies cw, received in the given slot of a batch
+// (0-15, or -1 for the sync word), and counts its bit errors.
+func CheckCodeword(cw uint32, slot int) CodewordCheck {
+	c := CodewordCheck{Codeword: cw, Corrected: cw}
+	if slot < 0 {
+		c.Kind = KindSync
+		c.Errors = bits.OnesCount32(cw ^ FrameSyncWord)
+		return c
+	}
+	fixed, ok := CorrectCodeword(cw)
+	if ok {
+		c.Corrected = fixed
+		c.Errors = bits.OnesCount32(cw ^ fixed)
+	} else {
+		c.Errors = -1
+	}
+	switch {
+	case c.Corrected == IdleCodeword:
+		c.Kind = KindIdle
+	case c.Corrected&0x80000000 != 0:
+		c.Kind = KindMessage
+	default:
+		c.Kind = KindAddress
+		a := ParseAddressCodeword(c.Corrected, slot/CodewordsPerFrame)
+		c.RIC, c.Function = a.RIC, a.Function
+	}
+	return c
+}
+
+// String gives the codeword, what it is, and its errors, e.g.
+// "0x0001E8A5 address 1234/3 (1 bit wrong)".
+func (c CodewordCheck) String() string {
+	s := fmt.Sprintf("0x%08X %s", c.Codeword, c.Kind)
+	if c.Kind == KindAddress {
+		s += fmt.Sprintf(" %d/%d", c.RIC, c.Function)
+	}
+	switch {
+	case c.Errors < 0:
+		s += " (uncorrectable)"
+	case c.Errors == 1:
+		s += " (1 bit wrong)"
+	case c.Errors > 1:
+		s += fmt.Sprintf(" (%d bits wrong)", c.Errors)
+	}
+	return s
+}
+
+// CodewordDiff is one position in two transmissions lined up batch by
+// batch.
+type CodewordDiff struct {
+	Batch       int
+	Slot        int            // 0-15 in transmission order, or -1 for the sync word
+	Left, Right *CodewordCheck // nil when that transmission has no such batch
+	// Fields names the parts of the codeword that differ: "flag",
+	// "address" and "function" or "data", "bch", and "parity", or
+	// "sync" for the sync word
+	Fields []string
+}
+
+// Differs reports whether the two codewords are not the same.
+func (d CodewordDiff) Differs() bool {
+	return d.Left == nil || d.Right == nil || d.Left.Codeword != d.Right.Codeword
+}
+
+// DiffBatches lines up two transmissions from their first sync words and
+// compares them codeword by codeword, sync words included. Preambles are
+// not compared, so transmissions with different preamble lengths line up.
+func DiffBatches(left, right []Batch) []CodewordDiff {
+	n := len(left)
+	if len(right) > n {
+		n = len(right)
+	}
+	diffs := make([]CodewordDiff, 0, n*(1+CodewordsPerBatch))
+	for i := 0; i < n; i++ {
+		for slot := -1; slot < CodewordsPerBatch; slot++ {
+			d := CodewordDiff{Batch: i, Slot: slot}
+			if i < len(left) {
+				c := CheckCodeword(batchWord(left[i], slot), slot)
+				d.Left = &c
+			}
+			if i < len(right) {
+				c := CheckCodeword(batchWord(right[i], slot), slot)
+				d.Right = &c
+			}
+			if d.Left != nil && d.Right != nil {
+				d.Fields = diffFields(d.Left, d.Right, slot)
+			}
+			diffs = append(diffs, d)
+		}
+	}
+	return diffs
+}
+
+// batchWord returns the codeword in slot, or the sync word for slot -1.
+func batchWord(b Batch, slot int) uint32 {
+	if slot < 0 {
+		return b.Sync
+	}
+	return b.Frames[slot/CodewordsPerFrame][slot%CodewordsPerFrame]
+}
+
+// diffFields names the fields in which l and r differ. The data bits are
+// split into address and function unless either is a message codeword.
+func diffFields(l, r *CodewordCheck, slot int) []string {
+	x := l.Codeword ^ r.Codeword
+	if x == 0 {
+		return nil
+	}
+	if slot < 0 {
+		return []string{"sync"}
+	}
+	fields := make([]string, 0, 4)
+	if x&0x80000000 != 0 {
+		fields = append(fields, "flag")
+	}
+	if l.Kind == KindMessage || r.Kind == KindMessage {
+		if x&0x7FFFF800 != 0 {
+			fields = append(fields, "data")
+		}
+	} else {
+		if x&0x7FFFE000 != 0 {
+			fields = append(fields, "address")
+		}
+		if x&0x1800 != 0 {
+			fields = append(fields, "function")
+		}
+	}
+	if x&0x7FE != 0 {
+		fields = append(fields, "bch")
+	}
+	if x&1 != 0 {
+		fields = append(fields, "parity")
+	}
+	return fields
+}
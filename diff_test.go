@@ -0,0 +1,66 @@
+package pocsag
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDiffBatches(t *testing.T) {
+	pages := []MessageInfo{{Address: 1234, Function: 3, Message: "HELLO WORLD", PayloadType: PayloadTypeAlpha}}
+	burst := CreatePOCSAGBurstWithBaudRate(pages, BaudRate1200)
+
+	// The same transmission as audio, as packed bytes, and as 0/1 text
+	fromWAV, err := ReadBatches(ConvertToAudioWithBaudRate(burst, BaudRate1200), BaudRate1200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromBytes, _ := ReadBatches(burst, BaudRate1200)
+	var text strings.Builder
+	for _, b := range bytesToBits(burst) {
+		text.WriteByte('0' + b)
+		text.WriteByte('\n')
+	}
+	fromText, _ := ReadBatches([]byte(text.String()), BaudRate1200)
+	for _, other := range [][]Batch{fromBytes, fromText} {
+		for _, d := range DiffBatches(fromWAV, other) {
+			if d.Differs() {
+				t.Fatalf("identical transmissions differ at %+v", d)
+			}
+		}
+	}
+
+	// A bit flipped in the address codeword, with a shorter preamble and
+	// the bits the other way up
+	flipped := append([]byte(nil), burst[PreambleLength/8/2:]...)
+	slot := FrameForAddress(1234) * CodewordsPerFrame
+	flipped[(PreambleLength/2+CodewordBits+slot*CodewordBits)/8+3] ^= 0x01
+	for i := range flipped {
+		flipped[i] ^= 0xFF
+	}
+	other, err := ReadBatches(flipped, BaudRate1200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var differ []CodewordDiff
+	for _, d := range DiffBatches(fromWAV, other) {
+		if d.Differs() {
+			differ = append(differ, d)
+		}
+	}
+	if len(differ) != 1 {
+		t.Fatalf("got %d differences, want 1: %+v", len(differ), differ)
+	}
+	d := differ[0]
+	if d.Batch != 0 || d.Slot != slot || d.Right.Errors != 1 || d.Right.Kind != KindAddress || d.Right.RIC != 1234 ||
+		strings.Join(d.Fields, ",") != "parity" {
+		t.Errorf("difference %+v, right %+v", d, *d.Right)
+	}
+	if s := d.Right.String(); !strings.HasSuffix(s, "address 1234/3 (1 bit wrong)") {
+		t.Errorf("String() = %q", s)
+	}
+
+	if _, err := ReadBatches(bytes.Repeat([]byte{0xAA}, 100), BaudRate1200); err == nil {
+		t.Error("preamble alone gave batches")
+	}
+}
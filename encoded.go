@@ -0,0 +1,87 @@
+package pocsag
+
+import (
+	"bytes"
+	"time"
+)
+
+// EncodedBurst is the result of encoding pages once, which can then be
+// rendered as audio, IQ samples, or bits, or described, without encoding
+// them again. Encoder.Encode returns it.
+type EncodedBurst struct {
+	encoder  Encoder
+	messages []MessageInfo // as transliterated
+	batches  [][]uint32
+	owners   [][]int
+	data     []byte
+}
+
+// IQOptions selects how EncodedBurst.IQ modulates. Zero values take the
+// defaults.
+type IQOptions struct {
+	SampleRate int     // samples per second; default 2 MHz, as pocsag-hackrf sends
+	Deviation  float64 // Hz; default DefaultDeviation
+	Invert     bool    // bit 1 on the upper tone, for networks using that sense
+}
+
+// DefaultIQSampleRate is the IQ sample rate IQOptions defaults to.
+const DefaultIQSampleRate = 2000000
+
+// Encode encodes messages as CreateBurst does, encrypted first if the
+// Encoder has WithEncryption, and keeps the result for rendering in several
+// ways. A numeric message to be encrypted fails with a
+// *NumericEncryptionError.
+func (e *Encoder) Encode(messages []MessageInfo) (*EncodedBurst, error) {
+	messages, err := e.encrypt(messages)
+	if err != nil {
+		return nil, err
+	}
+	b := &EncodedBurst{encoder: *e, messages: e.transliterate(messages)}
+	b.batches, b.owners = e.layout(messages)
+	var buf bytes.Buffer
+	buf.Write(bytes.Repeat([]byte{0xAA}, e.preambleBits/8))
+	if err := e.writeLayout(&buf, b.batches, b.owners); err != nil {
+		return nil, err
+	}
+	b.data = buf.Bytes()
+	return b, nil
+}
+
+// Bytes returns the burst as CreateBurst does: preamble and batches,
+// eight bits to a byte, MSB first.
+func (b *EncodedBurst) Bytes() []byte {
+	return append([]byte(nil), b.data...)
+}
+
+// Bits returns the burst one bit per byte, 0 or 1, as
+// DecodeFromBitstream takes it.
+func (b *EncodedBurst) Bits() []byte {
+	return bytesToBits(b.data)
+}
+
+// WAV renders the burst as a WAV file in the Encoder's audio settings.
+func (b *EncodedBurst) WAV() []byte {
+	return b.encoder.ConvertToAudio(b.data)
+}
+
+// IQ renders the burst as complex baseband FSK, as GenerateIQ does.
+func (b *EncodedBurst) IQ(opts IQOptions) []complex64 {
+	if opts.SampleRate <= 0 {
+		opts.SampleRate = DefaultIQSampleRate
+	}
+	if opts.Deviation == 0 {
+		opts.Deviation = DefaultDeviation
+	}
+	return GenerateIQ(b.data, b.encoder.baudRate, opts.SampleRate, opts.Deviation, opts.Invert)
+}
+
+// Describe returns the burst's layout, as Encoder.Describe does.
+func (b *EncodedBurst) Describe() TransmissionDescription {
+	return b.encoder.describe(b.messages, b.batches, b.owners)
+}
+
+// Duration returns the airtime of the burst, preamble included. The WAV
+// file is longer by the Encoder's launch delay.
+func (b *EncodedBurst) Duration() time.Duration {
+	return bitsDuration(len(b.data)*8, b.encoder.baudRate)
+}
@@ -0,0 +1,123 @@
+package pocsag
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEncode(t *testing.T) {
+	messages := []MessageInfo{
+		{Address: 123456, Function: 3, Message: "Grüße", PayloadType: PayloadTypeAlpha},
+		{Address: 8, Function: 0, Message: "0123", PayloadType: PayloadTypeNumeric},
+	}
+	e := NewEncoder(WithBaudRate(BaudRate512), WithSampleRate(22050), WithTerminator(ETX))
+	burst, err := e.Encode(messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(burst.Bytes(), e.CreateBurst(messages)) {
+		t.Error("Bytes differs from CreateBurst")
+	}
+	if !bytes.Equal(burst.WAV(), e.EncodeWAV(messages)) {
+		t.Error("WAV differs from EncodeWAV")
+	}
+	if !reflect.DeepEqual(burst.Describe(), e.Describe(messages)) {
+		t.Error("Describe differs from Encoder.Describe")
+	}
+	if got, err := DecodeFromBitstream(burst.Bits()); err != nil || len(got) != 2 || got[0].Message != "Grusse" {
+		t.Errorf("Bits decoded to %+v, %v", got, err)
+	}
+	if got, want := burst.Duration(), bitsDuration(len(burst.Bytes())*8, BaudRate512); got != want {
+		t.Errorf("Duration = %v, want %v", got, want)
+	}
+	iq := burst.IQ(IQOptions{SampleRate: 48000})
+	if !reflect.DeepEqual(iq, GenerateIQ(burst.Bytes(), BaudRate512, 48000, DefaultDeviation, false)) {
+		t.Error("IQ differs from GenerateIQ")
+	}
+}
+
+func TestEncodeWithEncryption(t *testing.T) {
+	key := KeyFromPassword("secret", 32)
+	messages := []MessageInfo{
+		{Address: 123456, Function: 3, Message: "SECRET", PayloadType: PayloadTypeAlpha},
+		{Address: 8, Function: 3, Message: "CLEAR", PayloadType: PayloadTypeAlpha},
+	}
+	e := NewEncoder(WithEncryption(EncryptionConfig{Method: EncryptionAES256, Key: key}, EncryptionConfig{}))
+	burst, err := e.Encode(messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := DecodeFromAudioWithOptions(burst.WAV(), BaudRate1200, DecodeOptions{Encryption: EncryptionConfig{Method: EncryptionAES256, Key: key}})
+	if err != nil || len(got) != 2 || got[0].Message != "SECRET" || got[1].Message != "CLEAR" {
+		t.Errorf("decoded %+v, %v", got, err)
+	}
+
+	// A numeric page, by payload type or by function, is refused
+	var numeric *NumericEncryptionError
+	for _, msg := range []MessageInfo{
+		{Address: 8, Function: 3, Message: "123", PayloadType: PayloadTypeNumeric},
+		{Address: 16, Function: FuncNumeric, Message: "123"},
+	} {
+		_, err := NewEncoder(WithEncryption(EncryptionConfig{Method: EncryptionAES256, Key: key})).Encode([]MessageInfo{msg})
+		if !errors.As(err, &numeric) || numeric.Address != msg.Address {
+			t.Errorf("%+v: got %v, want a NumericEncryptionError", msg, err)
+		}
+	}
+	if _, err := CreatePOCSAGPacketWithEncryption(16, "123", FuncNumeric, BaudRate1200, EncryptionConfig{Method: EncryptionAES256, Key: key}); !errors.As(err, &numeric) {
+		t.Errorf("CreatePOCSAGPacketWithEncryption: got %v, want a NumericEncryptionError", err)
+	}
+}
+
+func TestEncoderNeverSendsCleartext(t *testing.T) {
+	config := EncryptionConfig{Method: EncryptionAES256, Key: KeyFromPassword("secret", 32)}
+	msg := MessageInfo{Address: 123456, Function: 3, Message: "TOP SECRET", PayloadType: PayloadTypeAlpha}
+	messages := []MessageInfo{msg}
+	e := NewEncoder(WithEncryption(config))
+	continuous := NewEncoder(WithEncryption(config), WithContinuousMode(true))
+
+	budget, _, err := e.EncodeWithBudget(messages, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := e.Encode(messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputs := map[string][]byte{
+		"Encode":                          encoded.WAV(),
+		"CreateBurst":                     e.ConvertToAudio(e.CreateBurst(messages)),
+		"EncodeWAV":                       e.EncodeWAV(messages),
+		"EncodeWithBudget":                e.ConvertToAudio(budget),
+		"EncodeTransmissions":             e.EncodeTransmissions([][]MessageInfo{messages, messages}, time.Second),
+		"EncodeTransmissions, continuous": continuous.EncodeTransmissions([][]MessageInfo{messages, messages}, time.Second),
+		"EncodeTimeline":                  e.EncodeTimeline([]TimedMessage{{MessageInfo: msg}, {Offset: 2 * time.Second, MessageInfo: msg}}),
+	}
+	for name, wav := range outputs {
+		clear, err := DecodeFromAudio(wav)
+		if err != nil || len(clear) == 0 {
+			t.Errorf("%s: decoded %+v, %v", name, clear, err)
+		}
+		for _, got := range clear {
+			if strings.Contains(got.Message, "SECRET") {
+				t.Errorf("%s sent %q in the clear", name, got.Message)
+			}
+		}
+		decrypted, err := DecodeFromAudioWithOptions(wav, BaudRate1200, DecodeOptions{Encryption: config})
+		if err != nil || len(decrypted) != len(clear) || decrypted[0].Message != msg.Message {
+			t.Errorf("%s: decrypted %+v, %v", name, decrypted, err)
+		}
+	}
+	for _, desc := range e.Describe(messages).Messages {
+		if strings.Contains(desc.Message, "SECRET") {
+			t.Errorf("Describe shows %q in the clear", desc.Message)
+		}
+	}
+	if got, want := e.EstimateDuration(messages), encoded.Duration(); got != want {
+		t.Errorf("EstimateDuration = %v, want %v as encrypted", got, want)
+	}
+}
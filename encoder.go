@@ -2,8 +2,10 @@ package pocsag
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
 	"strings"
+	"unicode/utf8"
 )
 
 const (
@@ -20,6 +22,13 @@ const (
 
 	PayloadTypeAlpha   = "alpha"
 	PayloadTypeNumeric = "numeric"
+
+	// Terminators some pagers expect at the end of alphanumeric
+	// messages; see WithTerminator and DecodeOptions.Terminator. ETB is
+	// also sent as fill; see WithAlphaFill.
+	ETX = 0x03
+	EOT = 0x04
+	ETB = 0x17
 )
 
 // BitReverse8 reverses bits in a byte - exact port from pocsag.c
@@ -95,25 +104,39 @@ func NumericBCDEncoder(message string) []byte {
 	return encoded
 }
 
+// NumericAlphabet is the numeric (BCD) character set indexed by 4-bit value,
+// before the per-nibble bit reversal applied on air. 0xA is a spare code
+// conventionally displayed as '*', 0xB is the urgency marker 'U'. The encoder
+// and decoder both use this table so every symbol round-trips.
+const NumericAlphabet = "0123456789*U -]["
+
 func numericCharToNibble(ch byte) byte {
-	switch {
-	case ch >= '0' && ch <= '9':
-		return ch - '0'
-	case ch == '*':
-		return 0xA
-	case ch == 'U' || ch == 'u':
+	switch ch {
+	case 'u':
 		return 0xB // Urgency
-	case ch == ' ':
-		return 0xC // Space
-	case ch == '-':
-		return 0xD // Hyphen
-	case ch == ']':
-		return 0xE // Right bracket
-	case ch == '[':
-		return 0xF // Left bracket
-	default:
-		return 0xC // Default to space for invalid chars
+	case ')':
+		return 0xE // Right bracket, as shown by some decoders
+	case '(':
+		return 0xF // Left bracket, as shown by some decoders
+	}
+	if i := strings.IndexByte(NumericAlphabet, ch); i >= 0 {
+		return byte(i)
+	}
+	return 0xC // Default to space for invalid chars
+}
+
+// numericSubstitutions lists the characters of message that
+// numericCharToNibble sends as spaces.
+func numericSubstitutions(message string) []Substitution {
+	var subs []Substitution
+	for i := 0; i < len(message); {
+		r, size := utf8.DecodeRuneInString(message[i:])
+		if size > 1 || (r != ' ' && numericCharToNibble(message[i]) == 0xC) {
+			subs = append(subs, Substitution{i, r, strings.Repeat(" ", size)})
+		}
+		i += size
 	}
+	return subs
 }
 
 func splitNumericMessageIntoFrames(message string) []uint32 {
@@ -178,6 +201,11 @@ func Ascii7BitEncoder(message string) []byte {
 	if curr > 0 {
 		encoded = append(encoded, curr)
 	}
+	// A last character whose high bits are zero, such as ETX, leaves them
+	// out above; send them as zeros so the character arrives whole.
+	for len(encoded) < (length*7+7)/8 {
+		encoded = append(encoded, 0)
+	}
 
 	return encoded
 }
@@ -274,35 +302,97 @@ func CreatePOCSAGBurst(messages []MessageInfo) []byte {
 	return CreatePOCSAGBurstWithBaudRate(messages, BaudRate1200)
 }
 
-// CreatePOCSAGPacketWithEncryption creates a complete POCSAG packet with encryption
+// CreatePOCSAGPacketWithEncryption creates a complete POCSAG packet with
+// encryption. It is Encode with WithEncryption, for existing callers.
 func CreatePOCSAGPacketWithEncryption(address uint32, message string, function uint8, baudRate int, encryption EncryptionConfig) ([]byte, error) {
-	// Encrypt message if encryption is configured
-	encryptedMessage, err := EncryptMessage(message, encryption)
+	return CreatePOCSAGBurstWithEncryption([]MessageInfo{{Address: address, Message: message, Function: function}}, baudRate, encryption)
+}
+
+// CreatePOCSAGBurstWithEncryption creates a POCSAG packet with multiple
+// messages, encrypted as EncryptMessages does: with one config for all of
+// them, or one per message. It is Encode with WithEncryption, for existing
+// callers.
+func CreatePOCSAGBurstWithEncryption(messages []MessageInfo, baudRate int, encryption ...EncryptionConfig) ([]byte, error) {
+	burst, err := NewEncoder(WithBaudRate(baudRate), WithEncryption(encryption...)).Encode(messages)
 	if err != nil {
-		return nil, fmt.Errorf("failed to encrypt message: %v", err)
+		return nil, err
 	}
+	return burst.Bytes(), nil
+}
 
-	return CreatePOCSAGPacketWithBaudRate(address, encryptedMessage, function, baudRate), nil
+// NumericEncryptionError reports a numeric page given to be encrypted or
+// signed other than with EncryptionFF1. The ciphertext is not BCD, so no
+// pager could display it.
+type NumericEncryptionError struct {
+	Index   int // of the message in the burst
+	Address uint32
 }
 
-// CreatePOCSAGBurstWithEncryption creates a POCSAG packet with multiple messages and encryption
-func CreatePOCSAGBurstWithEncryption(messages []MessageInfo, baudRate int, encryption EncryptionConfig) ([]byte, error) {
-	// Encrypt all messages
-	encryptedMessages := make([]MessageInfo, len(messages))
+func (e *NumericEncryptionError) Error() string {
+	return fmt.Sprintf("message %d to %d: numeric pages cannot be encrypted or signed, as the result is not digits; use EncryptionFF1", e.Index, e.Address)
+}
+
+// EncryptMessages returns messages with their text encrypted, for sending
+// in one burst: with configs[0] for all of them if there is one config,
+// otherwise with configs[i] for message i. EncryptionNone leaves a message
+// clear. Numeric messages, by payload type or else by function, can only
+// be encrypted with EncryptionFF1, which uses the address as the tweak
+// unless the config has one, and which is for numeric messages alone;
+// others fail with a *NumericEncryptionError.
+//
+// CTR mode must never reuse an IV under the same key, so a fixed IV is
+// offset by the message's index in the burst, in its upper 64 bits: the
+// first message uses it as given, and the keystreams of the others cannot
+// overlap. Random and deterministic IVs are drawn per message anyway.
+func EncryptMessages(messages []MessageInfo, configs ...EncryptionConfig) ([]MessageInfo, error) {
+	if len(configs) != 1 && len(configs) != len(messages) {
+		return nil, fmt.Errorf("%d encryption configs for %d messages; give one, or one per message", len(configs), len(messages))
+	}
+	encrypted := make([]MessageInfo, len(messages))
 	for i, msg := range messages {
-		encryptedMessage, err := EncryptMessage(msg.Message, encryption)
+		config := configs[0]
+		if len(configs) > 1 {
+			config = configs[i]
+		}
+		encrypted[i] = msg
+		if config.Method == EncryptionNone {
+			continue
+		}
+		numeric := messagePayloadType(msg) == PayloadTypeNumeric
+		if numeric != (config.Method == EncryptionFF1) {
+			if numeric {
+				return nil, &NumericEncryptionError{Index: i, Address: msg.Address}
+			}
+			return nil, fmt.Errorf("message %d: EncryptionFF1 is for numeric pages", i)
+		}
+		if config.Method == EncryptionFF1 && len(config.Tweak) == 0 {
+			config.Tweak = addressTweak(msg.Address)
+		}
+		if len(config.IV) > 0 {
+			config.IV = burstIV(config.IV, i)
+		}
+		text, err := EncryptMessage(msg.Message, config)
 		if err != nil {
 			return nil, fmt.Errorf("failed to encrypt message %d: %v", i, err)
 		}
-		encryptedMessages[i] = MessageInfo{
-			Address:     msg.Address,
-			Message:     encryptedMessage,
-			Function:    msg.Function,
-			PayloadType: msg.PayloadType,
-		}
+		encrypted[i].Message = text
 	}
+	return encrypted, nil
+}
+
+// addressTweak is the FF1 tweak for pages to address.
+func addressTweak(address uint32) []byte {
+	return binary.BigEndian.AppendUint32(nil, address)
+}
 
-	return CreatePOCSAGBurstWithBaudRate(encryptedMessages, baudRate), nil
+// burstIV returns iv with index added to its upper 64 bits.
+func burstIV(iv []byte, index int) []byte {
+	offset := make([]byte, len(iv))
+	copy(offset, iv)
+	if len(offset) >= 8 {
+		binary.BigEndian.PutUint64(offset, binary.BigEndian.Uint64(offset)+uint64(index))
+	}
+	return offset
 }
 
 func messagePayloadType(msg MessageInfo) string {
@@ -323,41 +413,76 @@ func messagePayloadType(msg MessageInfo) string {
 // Per ITU-R M.584-2: the 21-bit address (RIC/capcode) has 18 bits in the codeword; the 3 LSBs
 // (address % 8) determine which of the 8 frames the address must appear in. Each frame has 2 codeword slots.
 func CreatePOCSAGBurstWithBaudRate(messages []MessageInfo, baudRate int) []byte {
-	preamble := make([]byte, PreambleLength/8)
-	for i := range preamble {
-		preamble[i] = 0xAA
+	return NewEncoder(WithBaudRate(baudRate)).CreateBurst(messages)
+}
+
+// messageCodewords returns the address codeword followed by the message
+// codewords for a single message.
+func messageCodewords(msg MessageInfo) []uint32 {
+	addressCW := EncodeAddress(msg.Address, msg.Function)
+	var messageCWs []uint32
+	if messagePayloadType(msg) == PayloadTypeNumeric {
+		messageCWs = splitNumericMessageIntoFrames(msg.Message)
+	} else {
+		encodedMessage := Ascii7BitEncoder(defaultTransliterator.ToASCII(msg.Message))
+		messageCWs = SplitMessageIntoFrames(encodedMessage)
 	}
+	return append([]uint32{addressCW}, messageCWs...)
+}
 
+// fillAlpha sets the data bits after the first chars characters of an
+// alphanumeric message to fill repeated, 7 bits to a character in the order
+// they are sent, in place of zeros, and recomputes the check bits.
+func fillAlpha(codewords []uint32, chars int, fill byte) {
+	for bit := chars * 7; bit < len(codewords)*20; bit++ {
+		if fill>>((bit-chars*7)%7)&1 == 1 {
+			codewords[bit/20] |= 1 << (30 - bit%20)
+		}
+	}
+	for i := chars * 7 / 20; i < len(codewords); i++ {
+		codewords[i] = CalculateEvenParity(CalculateBCH(codewords[i]))
+	}
+}
+
+// layoutBatches places each message's codewords into 16-slot batches with
+// correct frame placement (ITU-R M.584-2), interleaving them in blocks of
+// depth if it is above 1 and filling the end of alphanumeric messages with
+// fill if it is not 0. The second return value mirrors the batch layout
+// and records which message owns each slot (-1 for idle).
+func layoutBatches(messages []MessageInfo, depth int, fill byte) ([][]uint32, [][]int) {
 	// Build codewords per message with correct frame placement (ITU-R M.584-2)
 	// Batch has 16 slots (8 frames × 2 codewords). Frame f uses slots 2*f, 2*f+1.
 	// Each message starts at slot 2*(address%8) in the first batch.
 	var batches [][]uint32 // batches[batchIndex][0..15], grow as needed
+	var owners [][]int
 
 	ensureBatch := func(batchIdx int) {
 		for len(batches) <= batchIdx {
-			batches = append(batches, make([]uint32, 16))
-			for i := range batches[len(batches)-1] {
-				batches[len(batches)-1][i] = IdleCodeword
+			batch := make([]uint32, CodewordsPerBatch)
+			owner := make([]int, CodewordsPerBatch)
+			for i := range batch {
+				batch[i] = IdleCodeword
+				owner[i] = -1
 			}
+			batches = append(batches, batch)
+			owners = append(owners, owner)
 		}
 	}
 
 	lastBatchIdx := 0
 	lastSlotIdx := -1
 
-	for _, msg := range messages {
-		addressCW := EncodeAddress(msg.Address, msg.Function)
-		var messageCWs []uint32
-		if messagePayloadType(msg) == PayloadTypeNumeric {
-			messageCWs = splitNumericMessageIntoFrames(msg.Message)
-		} else {
-			encodedMessage := Ascii7BitEncoder(msg.Message)
-			messageCWs = SplitMessageIntoFrames(encodedMessage)
+	for msgIdx, msg := range messages {
+		allCWs := messageCodewords(msg)
+		if fill != 0 && msg.Message != "" && messagePayloadType(msg) == PayloadTypeAlpha {
+			fillAlpha(allCWs[1:], len(defaultTransliterator.ToASCII(msg.Message)), fill)
+		}
+		if depth > 1 {
+			allCWs = interleaveMessage(allCWs, depth)
 		}
-		allCWs := append([]uint32{addressCW}, messageCWs...)
 
-		f := int(msg.Address % 8) // target frame 0..7
-		startSlot := 2 * f
+		f := FrameForAddress(msg.Address) // target frame 0..7
+		startSlot := CodewordsPerFrame * f
 
 		// Find first batch where we can start at frame f
 		batchIdx := lastBatchIdx
@@ -368,16 +493,16 @@ func CreatePOCSAGBurstWithBaudRate(messages []MessageInfo, baudRate int) []byte
 		slotIdx := startSlot
 		for _, cw := range allCWs {
 			ensureBatch(batchIdx)
-			// Move to next batch if we current one is full OR we hit the start of next batch
-			// Actually, messages just continue into the next word.
-			// But wait: if we are in the middle of a batch and we have more words, we just increment slotIdx.
+			// Messages simply continue into the next codeword slot, spilling
+			// into the following batch when this one is full.
 			batches[batchIdx][slotIdx] = cw
+			owners[batchIdx][slotIdx] = msgIdx
 
 			lastBatchIdx = batchIdx
 			lastSlotIdx = slotIdx
 
 			slotIdx++
-			if slotIdx >= 16 {
+			if slotIdx >= CodewordsPerBatch {
 				slotIdx = 0
 				batchIdx++
 			}
@@ -388,15 +513,7 @@ func CreatePOCSAGBurstWithBaudRate(messages []MessageInfo, baudRate int) []byte
 		ensureBatch(0)
 	}
 
-	var buf bytes.Buffer
-	buf.Write(preamble)
-	for _, batch := range batches {
-		writeUint32BE(&buf, FrameSyncWord)
-		for _, cw := range batch {
-			writeUint32BE(&buf, cw)
-		}
-	}
-	return buf.Bytes()
+	return batches, owners
 }
 
 func writeUint32BE(buf *bytes.Buffer, val uint32) {
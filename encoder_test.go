@@ -1,8 +1,19 @@
 package pocsag
 
 import (
+	"bytes"
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestPOCSAGEncoding(t *testing.T) {
@@ -97,6 +108,100 @@ func TestNumericMessagesDoNotTransmitTerminator(t *testing.T) {
 	}
 }
 
+func TestTerminator(t *testing.T) {
+	// The last character of a message 161 bits long, and an ETX whose
+	// last four bits start a codeword of their own
+	pages := []MessageInfo{
+		{Address: 8, Function: FuncAlphanumeric, Message: strings.Repeat("A", 22) + "!"},
+		{Address: 16, Function: FuncAlphanumeric, Message: "HELLO WORLD"},
+		{Address: 24, Function: FuncNumeric, Message: "0123"},
+		{Address: 32, Function: FuncTone1},
+	}
+	decode := func(packet []byte, terminator byte) []DecodedMessage {
+		decoded, err := decodeBitstream(bytesToBits(packet), "", DecodeOptions{Terminator: terminator}, 0)
+		if err != nil || len(decoded) != len(pages) {
+			t.Fatalf("got %+v, err %v", decoded, err)
+		}
+		return decoded
+	}
+
+	for _, terminator := range []byte{ETX, EOT} {
+		packet := NewEncoder(WithTerminator(terminator)).CreateBurst(pages)
+		for _, decoded := range [][]DecodedMessage{decode(packet, 0), decode(packet, terminator)} {
+			for i, msg := range decoded {
+				if msg.Message != pages[i].Message || msg.Partial {
+					t.Errorf("terminator %#x: got %+v, want %q", terminator, msg, pages[i].Message)
+				}
+			}
+		}
+	}
+
+	// Without the terminator the text is all there but may be cut short
+	decoded := decode(CreatePOCSAGBurst(pages), ETX)
+	if decoded[1].Message != "HELLO WORLD" || !decoded[1].Partial || decoded[2].Partial {
+		t.Errorf("unterminated: %+v", decoded)
+	}
+}
+
+func TestAlphaFill(t *testing.T) {
+	pages := []MessageInfo{
+		{Address: 8, Function: FuncAlphanumeric, Message: "HELLO"},
+		{Address: 16, Function: FuncAlphanumeric, Message: strings.Repeat("FILL ", 9)},
+		{Address: 24, Function: FuncNumeric, Message: "0123"},
+		{Address: 32, Function: FuncTone1},
+	}
+	byMessage := func(batches [][]uint32, owners [][]int) [][]uint32 {
+		codewords := make([][]uint32, len(pages))
+		for b := range batches {
+			for slot, owner := range owners[b] {
+				if owner != -1 {
+					codewords[owner] = append(codewords[owner], batches[b][slot])
+				}
+			}
+		}
+		return codewords
+	}
+	plain := byMessage(NewEncoder().layout(pages))
+
+	for _, fill := range []byte{0, ETX, ETB} {
+		for _, terminator := range []byte{0, ETX, EOT} {
+			e := NewEncoder(WithAlphaFill(fill), WithTerminator(terminator), WithSelfVerify())
+			codewords := byMessage(e.layout(pages))
+
+			// Everything after the text and terminator is fill, and the
+			// numeric and tone-only pages are as they were
+			for i, page := range pages[:2] {
+				text := page.Message
+				if terminator != 0 {
+					text += string(rune(terminator))
+				}
+				bits, _ := codewordBits(codewords[i][1:], nil)
+				for bit := len(text) * 7; bit < len(bits); bit++ {
+					if bits[bit] != fill>>((bit-len(text)*7)%7)&1 {
+						t.Fatalf("fill %#x, terminator %#x, %q: bit %d is %d", fill, terminator, page.Message, bit, bits[bit])
+					}
+				}
+			}
+			if fmt.Sprint(codewords[2:]) != fmt.Sprint(plain[2:]) {
+				t.Errorf("fill %#x, terminator %#x: changed %08X, want %08X", fill, terminator, codewords[2:], plain[2:])
+			}
+
+			packet := e.CreateBurst(pages)
+			for _, decodeTerminator := range []byte{0, terminator} {
+				decoded, err := decodeBitstream(bytesToBits(packet), "", DecodeOptions{Terminator: decodeTerminator}, 0)
+				if err != nil || len(decoded) != len(pages) {
+					t.Fatalf("got %+v, err %v", decoded, err)
+				}
+				for i, msg := range decoded {
+					if msg.Message != pages[i].Message || msg.Partial {
+						t.Errorf("fill %#x, terminator %#x: got %+v, want %q", fill, terminator, msg, pages[i].Message)
+					}
+				}
+			}
+		}
+	}
+}
+
 func TestPayloadTypeIndependentFromFunctionBits(t *testing.T) {
 	packet := CreatePOCSAGPacketWithPayloadType(1234567, "123124242", 1, PayloadTypeNumeric)
 	decoded, err := DecodeFromBinaryWithPayloadType(packet, PayloadTypeNumeric)
@@ -126,3 +231,1019 @@ func TestExample(t *testing.T) {
 
 	t.Log("✅ Generated example.wav")
 }
+
+func TestDescribeTransmissionMatchesPacket(t *testing.T) {
+	messages := []MessageInfo{
+		{Address: 111111, Message: "FIRST MESSAGE THAT SPANS SEVERAL CODEWORDS", Function: FuncAlphanumeric},
+		{Address: 222222, Message: "0123456789", Function: FuncNumeric},
+	}
+	packet := CreatePOCSAGBurstWithBaudRate(messages, BaudRate1200)
+	desc := DescribeTransmission(messages, BaudRate1200)
+
+	if desc.TotalBits != len(packet)*8 {
+		t.Fatalf("total bits: got %d, want %d", desc.TotalBits, len(packet)*8)
+	}
+
+	idx := PreambleLength / 8
+	for _, batch := range desc.Batches {
+		idx += 4 // sync word
+		for _, frame := range batch.Frames {
+			for _, cw := range frame.Codewords {
+				got := uint32(packet[idx])<<24 | uint32(packet[idx+1])<<16 | uint32(packet[idx+2])<<8 | uint32(packet[idx+3])
+				if got != cw.Value {
+					t.Fatalf("codeword at byte %d: described 0x%08X, packet has 0x%08X", idx, cw.Value, got)
+				}
+				idx += 4
+			}
+		}
+	}
+
+	for i, md := range desc.Messages {
+		if md.Frame != int(messages[i].Address%8) {
+			t.Errorf("message %d: frame %d, want %d", i, md.Frame, messages[i].Address%8)
+		}
+		if md.Codewords != len(messageCodewords(messages[i])) {
+			t.Errorf("message %d: %d codewords, want %d", i, md.Codewords, len(messageCodewords(messages[i])))
+		}
+	}
+}
+
+func TestNumericAlphabetRoundTrip(t *testing.T) {
+	roundTrip := func(message string) string {
+		packet := CreatePOCSAGPacket(1234567, message, FuncNumeric)
+		decoded, err := DecodeFromBinary(packet)
+		if err != nil || len(decoded) != 1 {
+			t.Fatalf("DecodeFromBinary(%q): got %v, err %v", message, decoded, err)
+		}
+		return decoded[0].Message
+	}
+
+	// Every symbol at every nibble position within a codeword, followed by a
+	// non-space so trailing padding trimming does not interfere.
+	for nibble := 0; nibble < 16; nibble++ {
+		for pos := 0; pos < 5; pos++ {
+			message := strings.Repeat("0", pos) + string(NumericAlphabet[nibble]) + "9"
+			if got := roundTrip(message); got != message {
+				t.Errorf("round trip of %q: got %q", message, got)
+			}
+		}
+	}
+
+	// Every ordered pair of symbols.
+	for a := 0; a < 16; a++ {
+		for b := 0; b < 16; b++ {
+			message := string([]byte{NumericAlphabet[a], NumericAlphabet[b], '0'})
+			if got := roundTrip(message); got != message {
+				t.Errorf("round trip of %q: got %q", message, got)
+			}
+		}
+	}
+
+	// Aliases normalize to the canonical table entries.
+	if got := roundTrip("u(1)"); got != "U[1]" {
+		t.Errorf("alias round trip: got %q, want %q", got, "U[1]")
+	}
+}
+
+func TestNumericBCDEncoderMatchesFrames(t *testing.T) {
+	for _, message := range []string{"1", "12345", "123456", "U-[ ]*"} {
+		encoded := NumericBCDEncoder(message)
+		cws := splitNumericMessageIntoFrames(message)
+		for i, cw := range cws {
+			data := (cw >> 11) & 0xFFFFF
+			var want uint32
+			for n := 0; n < 5; n++ {
+				nibbleIdx := i*5 + n
+				b := encoded[nibbleIdx/2]
+				if nibbleIdx%2 == 0 {
+					b >>= 4
+				}
+				want = want<<4 | uint32(b&0xF)
+			}
+			if data != want {
+				t.Errorf("%q codeword %d: frame data 0x%05X, NumericBCDEncoder 0x%05X", message, i, data, want)
+			}
+		}
+	}
+}
+
+func TestEstimateDurationMatchesAudio(t *testing.T) {
+	messages := []MessageInfo{
+		{Address: 8, Message: "SHORT", Function: FuncAlphanumeric},
+		{Address: 1234567, Message: strings.Repeat("LONG MESSAGE ", 10), Function: FuncAlphanumeric},
+	}
+	for _, baud := range []int{BaudRate512, BaudRate1200, BaudRate2400} {
+		packet := CreatePOCSAGBurstWithBaudRate(messages, baud)
+		want := time.Duration(len(packet)*8) * time.Second / time.Duration(baud)
+		if got := EstimateDuration(messages, baud); got != want {
+			t.Errorf("%d baud: EstimateDuration = %v, want %v", baud, got, want)
+		}
+	}
+
+	// One short message fits in a single batch: 576 + 17*32 bits.
+	if got, want := EstimateAirtime(messages[0], BaudRate1200), 1120*time.Second/1200; got != want {
+		t.Errorf("EstimateAirtime = %v, want %v", got, want)
+	}
+}
+
+func TestGenerateTestPattern(t *testing.T) {
+	bitAt := func(data []byte, i int) byte { return (data[i/8] >> (7 - uint(i%8))) & 1 }
+
+	preamble, err := GenerateTestPattern(TestPatternPreamble, time.Second, BaudRate1200)
+	if err != nil || len(preamble) != 150 {
+		t.Fatalf("preamble: got %d bytes, err %v; want 150", len(preamble), err)
+	}
+
+	ber, err := GenerateTestPattern(TestPatternBER, 2*time.Second, BaudRate512)
+	if err != nil {
+		t.Fatalf("ber: %v", err)
+	}
+	ones := 0
+	for i := 0; i < 511; i++ {
+		if bitAt(ber, i) != bitAt(ber, i+511) {
+			t.Fatalf("PRBS-9 does not repeat with period 511 at bit %d", i)
+		}
+		ones += int(bitAt(ber, i))
+	}
+	if ones != 256 {
+		t.Errorf("PRBS-9 period has %d ones, want 256", ones)
+	}
+
+	idle, err := GenerateTestPattern(TestPatternIdle, time.Second, BaudRate1200)
+	if err != nil {
+		t.Fatalf("idle: %v", err)
+	}
+	if decoded, err := DecodeFromBinary(idle); err != nil || len(decoded) != 0 {
+		t.Errorf("idle pattern decoded to %v (err %v), want no messages", decoded, err)
+	}
+
+	if _, err := GenerateTestPattern("sweep", time.Second, BaudRate1200); err == nil {
+		t.Error("unknown pattern kind accepted")
+	}
+}
+
+func TestEncoderInstancesAreIndependent(t *testing.T) {
+	messages := []MessageInfo{{Address: 123456, Message: "HELLO", Function: 3}}
+
+	def := NewEncoder()
+	long := NewEncoder(WithPreambleBits(1001), WithSymbols(SymbolLow, SymbolHigh))
+
+	// Run both concurrently; neither may observe the other's settings.
+	done := make(chan []byte)
+	go func() { done <- long.EncodeWAV(messages) }()
+	defWAV := def.EncodeWAV(messages)
+	longWAV := <-done
+
+	if string(defWAV) != string(ConvertToAudio(CreatePOCSAGBurst(messages))) {
+		t.Error("default Encoder output differs from package functions")
+	}
+
+	burst := long.CreateBurst(messages)
+	if preamble := len(burst) - len(def.CreateBurst(messages)); preamble != (1008-PreambleLength)/8 {
+		t.Errorf("long preamble added %d bytes", preamble)
+	}
+	if got, want := long.Describe(messages).PreambleBits, 1008; got != want {
+		t.Errorf("PreambleBits = %d, want %d", got, want)
+	}
+	if got, want := long.EstimateDuration(messages), time.Duration(len(burst)*8)*time.Second/BaudRate1200; got != want {
+		t.Errorf("EstimateDuration = %v, want %v", got, want)
+	}
+
+	// Inverted symbols: decoder tries both polarities, so it still decodes.
+	decoded, err := DecodeFromAudio(longWAV)
+	if err != nil || len(decoded) != 1 || decoded[0].Message != "HELLO" {
+		t.Errorf("inverted long-preamble audio decoded to %v (err %v)", decoded, err)
+	}
+}
+
+func TestTimePageRoundTrip(t *testing.T) {
+	when := time.Date(2026, 3, 7, 18, 45, 9, 0, time.UTC)
+	page := NewTimePage(1234567, when)
+	if page.Message != "184509 070326" {
+		t.Fatalf("NewTimePage message = %q", page.Message)
+	}
+
+	decoded, err := DecodeFromBinary(CreatePOCSAGBurst([]MessageInfo{page}))
+	if err != nil || len(decoded) != 1 || !decoded[0].IsNumeric {
+		t.Fatalf("decode: got %v, err %v", decoded, err)
+	}
+	got, err := ParseTimePage(decoded[0].Message, time.UTC)
+	if err != nil || !got.Equal(when) {
+		t.Errorf("ParseTimePage(%q) = %v, %v; want %v", decoded[0].Message, got, err, when)
+	}
+
+	for msg, want := range map[string]time.Time{
+		"1845 070326":       time.Date(2026, 3, 7, 18, 45, 0, 0, time.UTC),
+		"18-45-09 07-03-26": when,
+		"1845070326":        time.Date(2026, 3, 7, 18, 45, 0, 0, time.UTC),
+	} {
+		if got, err := ParseTimePage(msg, nil); err != nil || !got.Equal(want) {
+			t.Errorf("ParseTimePage(%q) = %v, %v; want %v", msg, got, err, want)
+		}
+	}
+	if got, err := ParseTimePage("0930", time.UTC); err != nil || got.Hour() != 9 || got.Minute() != 30 || got.Year() < 2000 {
+		t.Errorf("time-only page: got %v, err %v", got, err)
+	}
+	if _, err := ParseTimePage("12345", time.UTC); err == nil {
+		t.Error("garbage accepted as time page")
+	}
+}
+
+func TestFrameMath(t *testing.T) {
+	if CodewordsPerBatch != 16 || BatchBits != 544 {
+		t.Fatalf("CodewordsPerBatch = %d, BatchBits = %d", CodewordsPerBatch, BatchBits)
+	}
+	if got := FrameForAddress(123459); got != 3 {
+		t.Errorf("FrameForAddress(123459) = %d, want 3", got)
+	}
+	if got, want := BatchDuration(BaudRate512), 544*time.Second/512; got != want {
+		t.Errorf("BatchDuration(512) = %v, want %v", got, want)
+	}
+	if got, want := PreambleDuration(BaudRate1200), 480*time.Millisecond; got != want {
+		t.Errorf("PreambleDuration(1200) = %v, want %v", got, want)
+	}
+
+	messages := []MessageInfo{{Address: 123459, Message: "FRAME THREE", Function: FuncAlphanumeric}}
+	batches := LayoutBatches(messages)
+	packet := CreatePOCSAGBurst(messages)
+	idx := PreambleLength / 8
+	for _, b := range batches {
+		if b.Sync != FrameSyncWord {
+			t.Fatalf("batch sync = 0x%08X", b.Sync)
+		}
+		idx += 4
+		for _, cw := range b.Codewords() {
+			if got := binary.BigEndian.Uint32(packet[idx:]); got != cw {
+				t.Fatalf("codeword at byte %d: layout 0x%08X, packet 0x%08X", idx, cw, got)
+			}
+			idx += 4
+		}
+	}
+	if batches[0].Frames[3][0] != EncodeAddress(123459, FuncAlphanumeric) {
+		t.Error("address codeword not in frame 3")
+	}
+	if batches[0].Frames[2][1] != IdleCodeword {
+		t.Error("frame 2 not idle")
+	}
+}
+
+func TestDeterministicEncryption(t *testing.T) {
+	config := EncryptionConfig{
+		Method:        EncryptionAES256,
+		Key:           KeyFromPassword("secret", 32),
+		Deterministic: true,
+	}
+	a, err := EncryptMessage("HELLO", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := EncryptMessage("HELLO", config)
+	c, _ := EncryptMessage("HELLO!", config)
+	if a != b {
+		t.Errorf("deterministic encryption differs between runs: %q vs %q", a, b)
+	}
+	if a == c {
+		t.Error("different messages encrypted identically")
+	}
+	// The IV is a MAC with a key of its own, not with the AES key
+	data, _ := decodePayload(a)
+	mac := hmac.New(sha256.New, config.Key)
+	mac.Write([]byte(ChecksumCRC32.seal("HELLO")))
+	if bytes.Equal(data[:aes.BlockSize], mac.Sum(nil)[:aes.BlockSize]) {
+		t.Error("synthetic IV is keyed with the AES key")
+	}
+	if got, err := DecryptMessage(a, EncryptionConfig{Method: EncryptionAES256, Key: config.Key}); err != nil || got != "HELLO" {
+		t.Errorf("DecryptMessage = %q, %v", got, err)
+	}
+
+	config.Deterministic = false
+	r1, _ := EncryptMessage("HELLO", config)
+	r2, _ := EncryptMessage("HELLO", config)
+	if r1 == r2 {
+		t.Error("random-IV encryption repeated its output")
+	}
+}
+
+func TestEncryptMessages(t *testing.T) {
+	key := KeyFromPassword("secret", 32)
+	iv := bytes.Repeat([]byte{0xff}, 16)
+	messages := []MessageInfo{
+		{Address: 8, Message: "FIRST", PayloadType: PayloadTypeAlpha},
+		{Address: 16, Message: "FIRST", PayloadType: PayloadTypeAlpha},
+		{Address: 24, Message: "CLEAR", PayloadType: PayloadTypeAlpha},
+	}
+
+	// One config for all: a fixed IV is not reused
+	shared := EncryptionConfig{Method: EncryptionAES256, Key: key, IV: iv}
+	all, err := EncryptMessages(messages[:2], shared)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if all[0].Message == all[1].Message {
+		t.Error("two messages encrypted with the same IV")
+	}
+	if single, _ := EncryptMessage("FIRST", shared); all[0].Message != single {
+		t.Error("the first message does not use the IV as given")
+	}
+	for i, msg := range all {
+		if got, err := DecryptMessage(msg.Message, shared); err != nil || got != "FIRST" {
+			t.Errorf("message %d decrypts to %q, %v", i, got, err)
+		}
+	}
+
+	// One config per message
+	configs := []EncryptionConfig{shared, {Method: EncryptionAES128, Key: key[:16], Header: true, KeyID: 3}, {}}
+	each, err := EncryptMessages(messages, configs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h, ok := ParsePayloadHeader(each[1].Message); !ok || h.KeyID != 3 || each[2] != messages[2] {
+		t.Errorf("per-message configs not applied: %+v", each)
+	}
+	if got, err := DecryptWithKeyring(each[1].Message, Keyring{3: key[:16]}); err != nil || got != "FIRST" {
+		t.Errorf("message 1 decrypts to %q, %v", got, err)
+	}
+	if burst, err := CreatePOCSAGBurstWithEncryption(messages, BaudRate1200, configs...); err != nil || len(burst) == 0 {
+		t.Errorf("CreatePOCSAGBurstWithEncryption: %v", err)
+	}
+
+	if _, err := EncryptMessages(messages, shared, shared); err == nil {
+		t.Error("two configs accepted for three messages")
+	}
+	numeric := []MessageInfo{{Address: 8, Message: "123", PayloadType: PayloadTypeNumeric}}
+	if _, err := EncryptMessages(numeric, shared); err == nil {
+		t.Error("numeric message encrypted")
+	}
+}
+
+func TestFF1(t *testing.T) {
+	// NIST SP 800-38G samples 1, 2, and 4
+	key := []byte{0x2B, 0x7E, 0x15, 0x16, 0x28, 0xAE, 0xD2, 0xA6, 0xAB, 0xF7, 0x15, 0x88, 0x09, 0xCF, 0x4F, 0x3C}
+	key192 := append(append([]byte(nil), key...), 0xEF, 0x43, 0x59, 0xD8, 0xD5, 0x80, 0xAA, 0x4F)
+	for _, tc := range []struct {
+		key        []byte
+		tweak      string
+		plain, enc string
+	}{
+		{key, "", "0123456789", "2433477484"},
+		{key, "9876543210", "0123456789", "6124200773"},
+		{key192, "", "0123456789", "2830668132"},
+	} {
+		got, err := encryptNumeric(tc.plain, tc.key, []byte(tc.tweak))
+		if err != nil || got != tc.enc {
+			t.Errorf("FF1(%s, tweak %q) = %q, %v, want %s", tc.plain, tc.tweak, got, err, tc.enc)
+		}
+		if back, err := decryptNumeric(got, tc.key, []byte(tc.tweak)); err != nil || back != tc.plain {
+			t.Errorf("decrypting %s = %q, %v", got, back, err)
+		}
+	}
+
+	// A numeric page keeps its length and its other characters
+	config := EncryptionConfig{Method: EncryptionFF1, Key: KeyFromPassword("secret", 32)}
+	messages := []MessageInfo{{Address: 8, Message: "555-0123 U", PayloadType: PayloadTypeNumeric}}
+	encrypted, err := EncryptMessages(messages, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent := encrypted[0].Message
+	if len(sent) != 10 || sent[3] != '-' || sent[8:] != " U" || sent == messages[0].Message {
+		t.Errorf("sent %q", sent)
+	}
+	if other, _ := EncryptMessages([]MessageInfo{{Address: 16, Message: "555-0123 U", PayloadType: PayloadTypeNumeric}}, config); other[0].Message == sent {
+		t.Error("the address does not change the ciphertext")
+	}
+	wav := ConvertToAudio(CreatePOCSAGBurst(encrypted))
+	got, err := DecodeFromAudioWithOptions(wav, BaudRate1200, DecodeOptions{NumericKey: config.Key})
+	if err != nil || len(got) != 1 || strings.TrimRight(got[0].Message, " ") != "555-0123 U" {
+		t.Errorf("decoded %+v, %v", got, err)
+	}
+
+	if _, err := EncryptMessages([]MessageInfo{{Address: 8, Message: "12345", PayloadType: PayloadTypeNumeric}}, config); err == nil {
+		t.Error("5 digits encrypted")
+	}
+	if _, err := EncryptMessages([]MessageInfo{{Address: 8, Message: "PIN 123456", PayloadType: PayloadTypeAlpha}}, config); err == nil {
+		t.Error("FF1 applied to an alpha page")
+	}
+}
+
+func TestChecksums(t *testing.T) {
+	if got := crc16CCITT([]byte("123456789")); got != 0x29B1 {
+		t.Errorf("CRC-16/CCITT-FALSE check value %04x", got)
+	}
+	if got := ChecksumCRC32C.sum("123456789"); got != 0xE3069283 {
+		t.Errorf("CRC-32C check value %08x", got)
+	}
+
+	ring := Keyring{7: KeyFromPassword("seven", 32), 8: KeyFromPassword("eight", 32)}
+	sizes := make(map[Checksum]int)
+	for c := ChecksumCRC32; c <= ChecksumCRC32C; c++ {
+		if parsed, err := ParseChecksum(c.String()); err != nil || parsed != c {
+			t.Errorf("ParseChecksum(%q) = %v, %v", c, parsed, err)
+		}
+		config := EncryptionConfig{Method: EncryptionAES256, Key: ring[7], Header: true, KeyID: 7, Checksum: c}
+		sent, err := EncryptMessage("HELLO", config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h, ok := ParsePayloadHeader(sent); !ok || h.Checksum() != c {
+			t.Errorf("%s: header %+v, %v", c, h, ok)
+		}
+		if got, err := DecryptWithKeyring(sent, ring); err != nil || got != "HELLO" {
+			t.Errorf("%s: DecryptWithKeyring = %q, %v", c, got, err)
+		}
+		if got, err := DecryptMessage(sent, EncryptionConfig{Method: EncryptionAES256, Key: ring[7]}); err != nil || got != "HELLO" {
+			t.Errorf("%s: DecryptMessage = %q, %v", c, got, err)
+		}
+		data, _ := decodePayload(sent)
+		sizes[c] = len(data)
+	}
+	if sizes[ChecksumCRC32]-sizes[ChecksumCRC16] != 4 {
+		t.Errorf("payload sizes %v", sizes)
+	}
+
+	// AES-CTR is not authenticated, so there is no checksum to leave out:
+	// a header naming another is not a header, and the page does not open
+	if _, err := ParseChecksum("none"); err == nil {
+		t.Error(`ParseChecksum accepted "none"`)
+	}
+	if _, err := EncryptMessage("HELLO", EncryptionConfig{Method: EncryptionAES256, Key: ring[7], Header: true, KeyID: 7, Checksum: ChecksumCRC32C + 1}); err == nil {
+		t.Error("unknown checksum accepted")
+	}
+	header := PayloadHeader{Cipher: EncryptionAES256, KeyID: 7, Flags: uint8(ChecksumCRC32C + 1)}.marshal()
+	unchecked, _ := encryptAES("HELLO", ring[7], 32, nil, false, header)
+	if got, err := DecryptWithKeyring(unchecked, ring); err == nil {
+		t.Errorf("unchecked page decrypted to %q", got)
+	}
+	if _, err := EncryptMessage("HELLO", EncryptionConfig{Method: EncryptionAES256, Key: ring[7], Checksum: ChecksumCRC16}); err == nil {
+		t.Error("CRC-16 accepted without a header to name it")
+	}
+}
+
+func TestPayloadHeader(t *testing.T) {
+	ring, err := LoadKeyring([]byte(`{"7": "seven", "200": "other"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent, err := EncryptMessage("HELLO", EncryptionConfig{Method: EncryptionAES128, Key: ring[7], Header: true, KeyID: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h, ok := ParsePayloadHeader(sent); !ok || h != (PayloadHeader{Cipher: EncryptionAES128, KeyID: 7}) || !strings.HasPrefix(sent, "xZoBAgcA") {
+		t.Errorf("header %+v, %v in %q", h, ok, sent)
+	}
+	if got, err := DecryptWithKeyring(sent, ring); err != nil || got != "HELLO" {
+		t.Errorf("DecryptWithKeyring = %q, %v", got, err)
+	}
+	// The header picks the cipher for a key given out of band too
+	if got, err := DecryptMessage(sent, EncryptionConfig{Method: EncryptionAES256, Key: KeyFromPassword("seven", 32)}); err != nil || got != "HELLO" {
+		t.Errorf("DecryptMessage = %q, %v", got, err)
+	}
+	if _, err := DecryptWithKeyring(sent, Keyring{200: ring[200]}); err == nil {
+		t.Error("decrypted without the key")
+	}
+
+	legacy, _ := EncryptMessage("HELLO", EncryptionConfig{Method: EncryptionAES256, Key: ring[7]})
+	if _, ok := ParsePayloadHeader(legacy); ok {
+		t.Error("header found in a page without one")
+	}
+	opts := DecodeOptions{Keyring: ring, Encryption: EncryptionConfig{Method: EncryptionAES256, Key: ring[7]}}
+	if opts.decrypt(8, sent) != "HELLO" || opts.decrypt(8, legacy) != "HELLO" || opts.decrypt(8, "PLAIN TEXT") != "PLAIN TEXT" {
+		t.Error("DecodeOptions with a keyring and a key")
+	}
+
+	if _, err := LoadKeyring([]byte(`{"256": "x"}`)); err == nil {
+		t.Error("key ID 256 accepted")
+	}
+}
+
+func TestSignOnly(t *testing.T) {
+	key := KeyFromPassword("dispatch", 32)
+	signed, err := EncryptMessage("CALL DISPATCH", EncryptionConfig{Method: EncryptionSignOnly, Key: key, Header: true, KeyID: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(signed, "CALL DISPATCH #7:") || len(signed) != len("CALL DISPATCH #7:")+11 {
+		t.Errorf("signed page %q", signed)
+	}
+	if got, err := DecryptMessage(signed+"\x00\x03", EncryptionConfig{Method: EncryptionSignOnly, Key: key}); err != nil || got != "CALL DISPATCH" {
+		t.Errorf("VerifyMessage = %q, %v", got, err)
+	}
+	tampered := strings.Replace(signed, "CALL", "CAL1", 1)
+	if _, err := VerifyMessage(tampered, key); err == nil {
+		t.Error("tampered page verified")
+	}
+	if _, err := VerifyMessage("CALL #1", key); err == nil {
+		t.Error("unsigned page verified")
+	}
+
+	// Decoding checks the tag and strips it, leaving tampered pages as sent
+	wav := ConvertToAudio(CreatePOCSAGBurst([]MessageInfo{
+		{Address: 123456, Message: signed, Function: 3, PayloadType: PayloadTypeAlpha},
+		{Address: 8, Message: tampered, Function: 3, PayloadType: PayloadTypeAlpha},
+	}))
+	for _, opts := range []DecodeOptions{
+		{Encryption: EncryptionConfig{Method: EncryptionAES256, Key: key}},
+		{Keyring: Keyring{7: key}},
+	} {
+		msgs, err := DecodeFromAudioWithOptions(wav, BaudRate1200, opts)
+		if err != nil || len(msgs) != 2 {
+			t.Fatalf("decoded %v, %v", msgs, err)
+		}
+		if !msgs[0].Verified || msgs[0].Message != "CALL DISPATCH" || !strings.Contains(msgs[0].String(), "[VERIFIED]") {
+			t.Errorf("signed page decoded as %+v", msgs[0])
+		}
+		if msgs[1].Verified || msgs[1].Message != tampered {
+			t.Errorf("tampered page decoded as %+v", msgs[1])
+		}
+	}
+}
+
+func TestKeyProviders(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keyring.json")
+	if err := os.WriteFile(path, []byte(`{"7": "seven", "123456": {"5": "ward-5"}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_KEY_7", "seven")
+	want := KeyFromPassword("seven", 32)
+	for _, spec := range []string{"file:" + path, "env:TEST_KEY_"} {
+		provider, err := OpenKeyProvider(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key, err := provider.Key(7); err != nil || !bytes.Equal(key, want) {
+			t.Errorf("%s: key 7 = %x, %v", spec, key, err)
+		}
+		if _, err := provider.Key(5); err == nil {
+			t.Errorf("%s: found a key it does not have", spec)
+		}
+	}
+	if _, err := (PKCS11{Module: "libsofthsm2.so"}).Key(7); err == nil {
+		t.Error("PKCS#11 stub returned a key")
+	}
+	for _, bad := range []string{"file:", "vault:secret/pocsag", "secret"} {
+		if _, err := OpenKeyProvider(bad); err == nil {
+			t.Errorf("key source %q accepted", bad)
+		}
+	}
+
+	// Decoders ask the provider for the key a page names
+	opts := DecodeOptions{KeyProvider: KeyringFile(path)}
+	encrypted, _ := EncryptMessage("HELLO", EncryptionConfig{Method: EncryptionAES128, Key: want, Header: true, KeyID: 7})
+	signed, _ := EncryptMessage("HELLO", EncryptionConfig{Method: EncryptionSignOnly, Key: want, Header: true, KeyID: 7})
+	if opts.decrypt(8, encrypted) != "HELLO" {
+		t.Error("encrypted page not decrypted with the provider's key")
+	}
+	if got, ok := opts.verify(8, signed); !ok || got != "HELLO" {
+		t.Errorf("signed page: %q, %v", got, ok)
+	}
+	if other, _ := EncryptMessage("HELLO", EncryptionConfig{Method: EncryptionAES256, Key: want, Header: true, KeyID: 9}); opts.decrypt(8, other) != other {
+		t.Error("page under a key the provider lacks changed")
+	}
+}
+
+func TestKeyStoreRotation(t *testing.T) {
+	now := time.Now() // decoders check keys against the clock
+	store := NewKeyStore()
+	if _, err := store.Encryption(123456); err == nil {
+		t.Error("encryption without a key")
+	}
+	store.Rotate(AnyAddress, 1, KeyFromPassword("fleet-2025", 32), 0, now)
+	store.Rotate(123456, 5, KeyFromPassword("ward-5", 16), 0, now)
+
+	encrypt := func(address uint32) string {
+		config, err := store.Encryption(address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		page, err := EncryptMessage("HELLO", config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return page
+	}
+	old := encrypt(8)
+	if h, _ := ParsePayloadHeader(old); h.KeyID != 1 || h.Cipher != EncryptionAES256 {
+		t.Errorf("fleet page header %+v", h)
+	}
+	if h, _ := ParsePayloadHeader(encrypt(123456)); h.KeyID != 5 || h.Cipher != EncryptionAES128 {
+		t.Errorf("pager's own key not used")
+	}
+
+	// Pages under the old key decrypt until the grace period ends
+	store.Rotate(AnyAddress, 2, KeyFromPassword("fleet-2026", 32), time.Hour, now)
+	current := encrypt(8)
+	if h, _ := ParsePayloadHeader(current); h.KeyID != 2 {
+		t.Errorf("rotated key not used: %+v", h)
+	}
+	opts := DecodeOptions{Keys: store}
+	if opts.decrypt(8, old) != "HELLO" || opts.decrypt(8, current) != "HELLO" {
+		t.Error("in-flight page lost at rotation")
+	}
+	ring := store.Keyring(8, now.Add(2*time.Hour))
+	if _, ok := ring[1]; ok || len(ring) != 1 {
+		t.Errorf("keyring after grace %v", ring.sortedIDs())
+	}
+	if ring := store.Keyring(123456, now); len(ring) != 3 {
+		t.Errorf("pager keyring %v, want its own key and the fleet's", ring.sortedIDs())
+	}
+	store.Retire(AnyAddress, 1)
+	if opts.decrypt(8, old) == "HELLO" {
+		t.Error("retired key still decrypts")
+	}
+
+	// A key filed under another ID is found by falling back
+	misfiled, _ := EncryptMessage("HELLO", EncryptionConfig{Method: EncryptionAES256, Key: KeyFromPassword("fleet-2026", 32), Header: true, KeyID: 9})
+	if got, err := DecryptWithKeyring(misfiled, store.Keyring(8, now)); err != nil || got != "HELLO" {
+		t.Errorf("fallback = %q, %v", got, err)
+	}
+
+	loaded, err := LoadKeyStore([]byte(`{"1": "fleet-2025", "123456": {"5": "ward-5"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ring := loaded.Keyring(123456, now); len(ring) != 2 || string(ring[5]) != string(KeyFromPassword("ward-5", 32)) {
+		t.Errorf("loaded keyring %v", ring.sortedIDs())
+	}
+	if ring := loaded.Keyring(8, now); len(ring) != 1 {
+		t.Errorf("loaded keyring of another pager %v", ring.sortedIDs())
+	}
+	for _, bad := range []string{`{"pager": {"1": "x"}}`, `{"2097152": {"1": "x"}}`, `{"8": {"300": "x"}}`, `{"1": ""}`} {
+		if _, err := LoadKeyStore([]byte(bad)); err == nil {
+			t.Errorf("%s accepted", bad)
+		}
+	}
+}
+
+func TestExplainAddressCodeword(t *testing.T) {
+	a, err := ExplainAddressCodeword(1234567, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.RIC != 1234567 || a.Frame != 7 || a.AddressBits != 1234567>>3 || a.Function != 2 {
+		t.Errorf("fields = %+v", a)
+	}
+	if a.MessageFlag || !a.BCHValid || !a.ParityValid {
+		t.Errorf("flags = %+v", a)
+	}
+	// Reassemble the codeword from its fields
+	cw := a.AddressBits<<13 | uint32(a.Function)<<11 | uint32(a.BCH)<<1 | uint32(a.Parity)
+	if cw != a.Codeword || cw != EncodeAddress(1234567, 2) {
+		t.Errorf("fields reassemble to 0x%08X, codeword 0x%08X", cw, a.Codeword)
+	}
+
+	broken := ParseAddressCodeword(a.Codeword^(1<<5), 7)
+	if broken.BCHValid || broken.ParityValid {
+		t.Errorf("corrupted codeword reported valid: %s", broken)
+	}
+
+	if _, err := ExplainAddressCodeword(MaxAddress+1, 0); err == nil {
+		t.Error("22-bit address accepted")
+	}
+}
+
+func TestCustomSyncAndIdleWords(t *testing.T) {
+	const sync, idle = 0x5A3C96E1, 0x3E6E2C4B
+	messages := []MessageInfo{{Address: 123456, Message: "PRIVATE NET", Function: FuncAlphanumeric}}
+	enc := NewEncoder(WithSyncWord(sync), WithIdleCodeword(idle))
+
+	burst := enc.CreateBurst(messages)
+	var syncs, idles int
+	for i := PreambleLength / 8; i+4 <= len(burst); i += 4 {
+		switch binary.BigEndian.Uint32(burst[i:]) {
+		case sync:
+			syncs++
+		case idle:
+			idles++
+		case FrameSyncWord, IdleCodeword:
+			t.Fatalf("standard word at byte %d", i)
+		}
+	}
+	if syncs != 1 || idles == 0 {
+		t.Errorf("%d sync words, %d idle codewords", syncs, idles)
+	}
+	desc := enc.Describe(messages)
+	if desc.Batches[0].SyncWord != "0x5A3C96E1" || desc.Batches[0].Frames[7].Codewords[1].Value != idle {
+		t.Errorf("Describe: %+v", desc.Batches[0])
+	}
+
+	// A standard receiver ignores the network; a configured one decodes it.
+	wavData := enc.EncodeWAV(messages)
+	if decoded, _ := DecodeFromAudio(wavData); len(decoded) != 0 {
+		t.Errorf("standard decoder found %v", decoded)
+	}
+	opts := DecodeOptions{SyncWord: sync, IdleCodeword: idle}
+	decoded, err := DecodeFromAudioWithOptions(wavData, BaudRate1200, opts)
+	if err != nil || len(decoded) != 1 || decoded[0].Message != "PRIVATE NET" {
+		t.Errorf("configured decoder: got %v, err %v", decoded, err)
+	}
+	samples, rate := ParseWAVSamples(wavData)
+	stream := NewStreamDecoder(rate, BaudRate1200, opts)
+	floats := make([]float32, len(samples))
+	for i, s := range samples {
+		floats[i] = float32(s)
+	}
+	if got := append(stream.Write(floats), stream.Flush()...); len(got) != 1 || got[0].Message != "PRIVATE NET" {
+		t.Errorf("stream decoder: got %v", got)
+	}
+}
+
+func TestEncodeTransmissionsModes(t *testing.T) {
+	transmissions := [][]MessageInfo{
+		{{Address: 123456, Message: "FIRST", Function: FuncAlphanumeric}},
+		{{Address: 654320, Message: "SECOND", Function: FuncAlphanumeric}},
+	}
+	const gap = time.Second
+
+	check := func(name string, wavData []byte, want time.Duration) {
+		t.Helper()
+		if got := WAVDuration(wavData); got < want-time.Millisecond || got > want+time.Millisecond {
+			t.Errorf("%s: duration %v, want %v", name, got, want)
+		}
+		decoded, err := DecodeFromAudio(wavData)
+		if err != nil || len(decoded) != 2 || decoded[0].Message != "FIRST" || decoded[1].Message != "SECOND" {
+			t.Errorf("%s: decoded %v, err %v", name, decoded, err)
+		}
+	}
+
+	// Battery saver: two full bursts with silence between them
+	enc := NewEncoder()
+	bursts := enc.EstimateDuration(transmissions[0]) + enc.EstimateDuration(transmissions[1])
+	check("battery saver", enc.EncodeTransmissions(transmissions, gap), bursts+gap)
+
+	// Continuous: one preamble, the gap filled with whole idle batches
+	cont := NewEncoder(WithContinuousMode(true))
+	idleBatches := (BaudRate1200 + BatchBits - 1) / BatchBits
+	want := bursts - PreambleDuration(BaudRate1200) + time.Duration(idleBatches*BatchBits)*time.Second/BaudRate1200
+	check("continuous", cont.EncodeTransmissions(transmissions, gap), want)
+
+	packet, err := cont.continuousBurst(transmissions, gap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := bytes.Count(packet, bytes.Repeat([]byte{0xAA}, PreambleLength/8)); n != 1 {
+		t.Errorf("continuous burst has %d preambles", n)
+	}
+}
+
+func TestBCHTables(t *testing.T) {
+	// The tables agree with long division for every data word, and the
+	// syndrome for words with errors too
+	for data := uint32(0); data < 1<<NumDataBits; data++ {
+		x := data << 11
+		if got, want := CalculateBCH(x), calculateBCHBitwise(x); got != want {
+			t.Fatalf("data 0x%06X: 0x%08X, want 0x%08X", data, got, want)
+		}
+		cw := x ^ data*0x9E3779B1&0x7FF
+		if got, want := bchSyndrome(cw), bchSyndromeBitwise(cw); got != want {
+			t.Fatalf("0x%08X: syndrome 0x%03X, want 0x%03X", cw, got, want)
+		}
+	}
+}
+
+var benchCodewords = func() []uint32 {
+	cws := make([]uint32, 1024)
+	for i := range cws {
+		cws[i] = EncodeAddress(uint32(i)*8+1000, FuncAlphanumeric) ^ uint32(i%5)<<7
+	}
+	return cws
+}()
+
+// bchSink keeps the compiler from dropping calls whose results go unused.
+var bchSink uint32
+
+func BenchmarkCalculateBCH(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		bchSink ^= CalculateBCH(uint32(i) << 11)
+	}
+}
+
+func BenchmarkCalculateBCHBitwise(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		bchSink ^= calculateBCHBitwise(uint32(i) << 11)
+	}
+}
+
+func BenchmarkDoesWordPassBCH(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		DoesWordPassBCH(benchCodewords[i%len(benchCodewords)])
+	}
+}
+
+func BenchmarkBCHSyndrome(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		bchSink ^= bchSyndrome(benchCodewords[i%len(benchCodewords)])
+	}
+}
+
+func BenchmarkBCHSyndromeBitwise(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		bchSink ^= bchSyndromeBitwise(benchCodewords[i%len(benchCodewords)])
+	}
+}
+
+func BenchmarkCorrectCodeword(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		CorrectCodeword(benchCodewords[i%len(benchCodewords)])
+	}
+}
+
+func BenchmarkEncodeAddress(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		EncodeAddress(uint32(i)&0x1FFFFF, FuncAlphanumeric)
+	}
+}
+
+func BenchmarkSplitMessageIntoFrames(b *testing.B) {
+	encoded := Ascii7BitEncoder(strings.Repeat("THE QUICK BROWN FOX JUMPS OVER THE LAZY DOG ", 2))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		SplitMessageIntoFrames(encoded)
+	}
+}
+
+func BenchmarkConvertToAudio(b *testing.B) {
+	packet := CreatePOCSAGPacket(123456, "THE QUICK BROWN FOX JUMPS OVER THE LAZY DOG", FuncAlphanumeric)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		ConvertToAudio(packet)
+	}
+}
+
+func TestEncodeWithBudget(t *testing.T) {
+	var queue []MessageInfo
+	for i := 0; i < 12; i++ {
+		queue = append(queue, MessageInfo{Address: uint32(1000 + 8*i + i%8), Message: strings.Repeat("QUEUED PAGE ", i%3+1), Function: FuncAlphanumeric})
+	}
+	budget := EstimateDuration(queue[:5], BaudRate1200)
+
+	packet, rest, err := EncodeWithBudget(queue, BaudRate1200, budget)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent := len(queue) - len(rest)
+	if sent < 5 || sent == len(queue) {
+		t.Fatalf("sent %d of %d", sent, len(queue))
+	}
+	if EstimateDuration(queue[:sent+1], BaudRate1200) <= budget {
+		t.Errorf("message %d would also have fit", sent)
+	}
+	if rest[0] != queue[sent] {
+		t.Errorf("leftovers start with %+v, want %+v", rest[0], queue[sent])
+	}
+	decoded, err := DecodeFromBinary(packet)
+	if err != nil || len(decoded) != sent {
+		t.Errorf("decoded %d messages, err %v, want %d", len(decoded), err, sent)
+	}
+	if d := bitsDuration(len(packet)*8, BaudRate1200); d > budget {
+		t.Errorf("burst lasts %v, budget %v", d, budget)
+	}
+
+	if _, rest, err := EncodeWithBudget(queue, BaudRate1200, 100*time.Millisecond); err == nil || len(rest) != len(queue) {
+		t.Errorf("a budget shorter than the preamble gave err %v, %d left", err, len(rest))
+	}
+}
+
+func TestLaunchDelay(t *testing.T) {
+	messages := []MessageInfo{{Address: 123456, Message: "SIMULCAST", Function: FuncAlphanumeric}}
+	for _, tt := range []struct {
+		baud, rate int
+		delay      time.Duration
+		samples    int
+	}{
+		{BaudRate512, SampleRate, 1250 * time.Microsecond, 60},
+		{BaudRate1200, 22050, 10 * time.Millisecond, 221}, // 220.5 rounds up
+		{BaudRate2400, 8000, 62 * time.Microsecond, 0},    // under half a sample
+	} {
+		plain := NewEncoder(WithBaudRate(tt.baud), WithSampleRate(tt.rate))
+		delayed := NewEncoder(WithBaudRate(tt.baud), WithSampleRate(tt.rate), WithLaunchDelay(tt.delay))
+		if got, want := delayed.LaunchDelay(), time.Duration(tt.samples)*time.Second/time.Duration(tt.rate); got != want {
+			t.Errorf("%d Hz: LaunchDelay = %v, want %v", tt.rate, got, want)
+		}
+
+		// The delayed audio is the same samples, shifted
+		a, _ := ParseWAVSamples(plain.EncodeWAV(messages))
+		b, _ := ParseWAVSamples(delayed.EncodeWAV(messages))
+		if len(b) != len(a)+tt.samples {
+			t.Fatalf("%d Hz: %d samples, want %d", tt.rate, len(b), len(a)+tt.samples)
+		}
+		for i, s := range b {
+			if (i < tt.samples && s != 0) || (i >= tt.samples && s != a[i-tt.samples]) {
+				t.Fatalf("%d Hz: sample %d differs", tt.rate, i)
+			}
+		}
+	}
+
+	// At SampleRate bit k starts exactly at sample delay + round(k * rate / baud)
+	delayed := NewEncoder(WithBaudRate(BaudRate512), WithLaunchDelay(time.Millisecond))
+	samples, _ := ParseWAVSamples(delayed.EncodeWAV(messages))
+	for k := 1; k < PreambleLength; k++ {
+		edge := 48 + int(math.Round(float64(k)*SampleRate/BaudRate512))
+		if samples[edge] == samples[edge-1] {
+			t.Fatalf("no edge at the start of preamble bit %d", k)
+		}
+	}
+	if decoded, err := DecodeFromAudioWithBaudRate(delayed.EncodeWAV(messages), BaudRate512); err != nil || len(decoded) != 1 {
+		t.Errorf("got %v, err %v", decoded, err)
+	}
+}
+
+func TestPadding(t *testing.T) {
+	// Address 1234 sits in frame 2, and its 5 codewords end in frame 4
+	messages := []MessageInfo{{Address: 1234, Message: "HELLO WORLD", Function: FuncAlphanumeric}}
+	address := EncodeAddress(1234, FuncAlphanumeric)
+	preambleBytes := PreambleLength / 8
+
+	short := NewEncoder(WithPaddingStrategy(PadToFrame))
+	burst := short.CreateBurst(messages)
+	if want := preambleBytes + 4 + 10*4; len(burst) != want {
+		t.Fatalf("PadToFrame burst is %d bytes, want %d", len(burst), want)
+	}
+	if got, want := short.EstimateDuration(messages), bitsDuration(PreambleLength+11*CodewordBits, BaudRate1200); got != want {
+		t.Errorf("EstimateDuration = %v, want %v", got, want)
+	}
+	desc := short.Describe(messages)
+	if len(desc.Batches[0].Frames) != 5 || desc.TotalBits != PreambleLength+11*CodewordBits {
+		t.Errorf("described %d frames and %d bits", len(desc.Batches[0].Frames), desc.TotalBits)
+	}
+	if decoded, err := DecodeFromAudio(short.EncodeWAV(messages)); err != nil || len(decoded) != 1 || decoded[0].Message != "HELLO WORLD" {
+		t.Errorf("PadToFrame: got %v, err %v", decoded, err)
+	}
+
+	// Repeated addresses fill the slots after the message, idle the ones before
+	repeat := NewEncoder(WithPaddingCodeword(PadLastAddress))
+	burst = repeat.CreateBurst(messages)
+	for slot := 0; slot < CodewordsPerBatch; slot++ {
+		cw := binary.BigEndian.Uint32(burst[preambleBytes+4+slot*4:])
+		switch {
+		case slot < 4 && cw != IdleCodeword:
+			t.Errorf("slot %d: %08X, want idle", slot, cw)
+		case slot > 8 && cw != address:
+			t.Errorf("slot %d: %08X, want the address %08X", slot, cw, address)
+		}
+	}
+	if cd := repeat.Describe(messages).Batches[0].Frames[7].Codewords[1]; cd.Type != CodewordTypePadding || cd.Value != address {
+		t.Errorf("described last slot as %+v", cd)
+	}
+	if decoded, err := DecodeFromAudio(repeat.EncodeWAV(messages)); err != nil || len(decoded) != 1 || decoded[0].Message != "HELLO WORLD" {
+		t.Errorf("PadLastAddress: got %v, err %v", decoded, err)
+	}
+
+	// In continuous mode only the last transmission is cut short
+	both := NewEncoder(WithPaddingStrategy(PadToFrame), WithContinuousMode(true))
+	decoded, err := DecodeFromAudio(both.EncodeTransmissions([][]MessageInfo{messages, messages}, time.Second))
+	if err != nil || len(decoded) != 2 {
+		t.Errorf("continuous: got %v, err %v", decoded, err)
+	}
+}
+
+func TestVerifyCodeword(t *testing.T) {
+	for _, cw := range []uint32{IdleCodeword, FrameSyncWord, EncodeAddress(123456, FuncAlphanumeric)} {
+		if err := VerifyCodeword(cw); err != nil {
+			t.Errorf("%v", err)
+		}
+		// Every single bit error is caught
+		for bit := 0; bit < 32; bit++ {
+			if VerifyCodeword(cw^1<<bit) == nil {
+				t.Errorf("0x%08X with bit %d flipped passes", cw, bit)
+			}
+		}
+	}
+
+	// The independent check agrees with the encoder's BCH and parity
+	for data := uint32(0); data < 1<<NumDataBits; data += 997 {
+		cw := CalculateEvenParity(CalculateBCH(data << 11))
+		if err := VerifyCodeword(cw); err != nil {
+			t.Fatalf("data 0x%06X: %v", data, err)
+		}
+	}
+
+	// Every payload and padding passes with self-verification on
+	messages := []MessageInfo{
+		{Address: 1234, Message: "HELLO WORLD, Grüße", Function: FuncAlphanumeric},
+		{Address: 5678, Message: "0123456789*U -[]", PayloadType: PayloadTypeNumeric},
+		{Address: 8, Function: FuncTone1, PayloadType: PayloadTypeNumeric},
+	}
+	for _, opts := range [][]Option{
+		{WithSelfVerify()},
+		{WithSelfVerify(), WithPaddingCodeword(PadLastAddress), WithPaddingStrategy(PadToFrame)},
+		{WithSelfVerify(), WithIdleCodeword(0x12345678)},
+	} {
+		if _, err := NewEncoder(opts...).Encode(messages); err != nil {
+			t.Errorf("%d options: %v", len(opts), err)
+		}
+	}
+
+	// A corrupted codeword is caught and reported, not sent
+	e := NewEncoder(WithSelfVerify())
+	batches, owners := e.layout(messages)
+	batches[0][3] ^= 1 << 12
+	var buf bytes.Buffer
+	err := e.writeLayout(&buf, batches, owners)
+	var verr *SelfVerifyError
+	if !errors.As(err, &verr) || verr.Batch != 0 || verr.Slot != 3 || verr.Codeword != batches[0][3] {
+		t.Errorf("corrupted codeword: got %v", err)
+	}
+	if err := NewEncoder().writeLayout(&buf, batches, owners); err != nil {
+		t.Errorf("checked without WithSelfVerify: %v", err)
+	}
+}
@@ -3,11 +3,11 @@ package pocsag
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
 	"fmt"
-	"hash/crc32"
 	"io"
 	"strings"
 )
@@ -22,13 +22,41 @@ const (
 	EncryptionAES256
 	// EncryptionAES128 - AES-128 encryption with Base64 encoding
 	EncryptionAES128
+	// EncryptionSignOnly - no secrecy: an HMAC-SHA256 tag is appended to
+	// the plain text, so receivers with the key can tell it is unaltered
+	EncryptionSignOnly
+	// EncryptionFF1 - the digits of a numeric page are encrypted to other
+	// digits with FF1, so the page stays numeric; see MinFF1Digits
+	EncryptionFF1
 )
 
 // EncryptionConfig holds encryption settings
 type EncryptionConfig struct {
 	Method EncryptionMethod
 	Key    []byte
-	IV     []byte // Initialization Vector (optional, will be generated if not provided)
+	IV     []byte // Initialization Vector (optional, will be generated if not provided); sent with the page, so decryption ignores it
+
+	// Deterministic derives the IV from the key and message (a synthetic
+	// IV, as in AES-SIV) instead of drawing a random one, so the same
+	// message and key always produce the same ciphertext. This is meant
+	// for golden-file tests and caching only: on air it reveals when the
+	// same message is sent twice, which random IVs hide. Ignored when IV
+	// is set.
+	Deterministic bool
+
+	// Header starts the encrypted page with a PayloadHeader naming Method
+	// and KeyID, so decoders can pick the cipher and the key from a
+	// Keyring. Decoders older than the header cannot read such pages.
+	Header bool
+	KeyID  uint8
+
+	// Checksum selects the checksum sealed with the message. Others than
+	// the default ChecksumCRC32 need Header, which names it to decoders.
+	Checksum Checksum
+
+	// Tweak varies EncryptionFF1 output without a new key. EncryptMessages
+	// and the decoders use the page's address when it is empty.
+	Tweak []byte
 }
 
 // EncryptMessage encrypts a message using the specified method
@@ -36,73 +64,88 @@ func EncryptMessage(message string, config EncryptionConfig) (string, error) {
 	if config.Method == EncryptionNone {
 		return message, nil
 	}
+	if config.Method == EncryptionSignOnly {
+		return signMessage(message, config.Key, config.Header, config.KeyID), nil
+	}
+	if config.Method == EncryptionFF1 {
+		return encryptNumeric(message, config.Key, config.Tweak)
+	}
 
-	// Add CRC32 checksum for integrity verification
-	crc := crc32.ChecksumIEEE([]byte(message))
-	messageWithCRC := fmt.Sprintf("%s\x00%08x", message, crc)
+	// Add a checksum for integrity verification
+	if config.Checksum != ChecksumCRC32 && !config.Header {
+		return "", fmt.Errorf("checksum %s needs a payload header to name it", config.Checksum)
+	}
+	if config.Checksum > ChecksumCRC32C {
+		return "", fmt.Errorf("unsupported checksum: %d", config.Checksum)
+	}
+	messageWithCRC := config.Checksum.seal(message)
 
+	var header []byte
+	if config.Header {
+		header = PayloadHeader{Cipher: config.Method, KeyID: config.KeyID, Flags: uint8(config.Checksum)}.marshal()
+	}
 	switch config.Method {
 	case EncryptionAES256:
-		return encryptAES(messageWithCRC, config.Key, 32, config.IV)
+		return encryptAES(messageWithCRC, config.Key, 32, config.IV, config.Deterministic, header)
 	case EncryptionAES128:
-		return encryptAES(messageWithCRC, config.Key, 16, config.IV)
+		return encryptAES(messageWithCRC, config.Key, 16, config.IV, config.Deterministic, header)
 	default:
 		return "", fmt.Errorf("unsupported encryption method: %d", config.Method)
 	}
 }
 
-// DecryptMessage decrypts a message using the specified method
+// DecryptMessage decrypts a message using the specified method. A message
+// with a PayloadHeader is decrypted with the cipher the header names. With
+// EncryptionSignOnly it verifies the message's tag instead. EncryptionFF1
+// cannot tell a wrong key, and only fails on pages with too few digits.
 func DecryptMessage(encryptedMessage string, config EncryptionConfig) (string, error) {
 	if config.Method == EncryptionNone {
 		return encryptedMessage, nil
 	}
+	if config.Method == EncryptionSignOnly {
+		return VerifyMessage(encryptedMessage, config.Key)
+	}
+	if config.Method == EncryptionFF1 {
+		return decryptNumeric(encryptedMessage, config.Key, config.Tweak)
+	}
 
+	data, err := decodePayload(encryptedMessage)
+	if err != nil {
+		return "", err
+	}
+	if h, ok := parsePayloadHeader(data); ok {
+		if message, err := decryptPayload(data[payloadHeaderLen:], h.Cipher, config.Key, h.Checksum()); err == nil {
+			return message, nil
+		}
+		// Not a header after all, but the start of an IV
+	}
+	return decryptPayload(data, config.Method, config.Key, ChecksumCRC32)
+}
+
+// decryptPayload decrypts the bytes of an encrypted page and checks the
+// checksum of the result.
+func decryptPayload(data []byte, method EncryptionMethod, key []byte, sum Checksum) (string, error) {
 	var decrypted string
 	var err error
 
-	switch config.Method {
+	switch method {
 	case EncryptionAES256:
-		decrypted, err = decryptAES(encryptedMessage, config.Key, 32, config.IV)
+		decrypted, err = decryptAES(data, key, 32)
 	case EncryptionAES128:
-		decrypted, err = decryptAES(encryptedMessage, config.Key, 16, config.IV)
+		decrypted, err = decryptAES(data, key, 16)
 	default:
-		return "", fmt.Errorf("unsupported encryption method: %d", config.Method)
+		return "", fmt.Errorf("unsupported encryption method: %d", method)
 	}
 
 	if err != nil {
 		return "", err
 	}
 
-	// Verify CRC32 checksum
-	if len(decrypted) < 9 {
-		return "", fmt.Errorf("decrypted message too short for CRC verification")
-	}
-
-	// Extract CRC and message
-	crcPos := len(decrypted) - 9
-	if decrypted[crcPos] != '\x00' {
-		return "", fmt.Errorf("invalid CRC separator")
-	}
-
-	message := decrypted[:crcPos]
-	crcStr := decrypted[crcPos+1:]
-
-	// Verify CRC
-	expectedCRC := crc32.ChecksumIEEE([]byte(message))
-	var actualCRC uint32
-	if _, err := fmt.Sscanf(crcStr, "%08x", &actualCRC); err != nil {
-		return "", fmt.Errorf("invalid CRC format: %v", err)
-	}
-
-	if expectedCRC != actualCRC {
-		return "", fmt.Errorf("CRC verification failed: expected %08x, got %08x", expectedCRC, actualCRC)
-	}
-
-	return message, nil
+	return sum.open(decrypted)
 }
 
-// encryptAES encrypts data using AES with Base64 encoding
-func encryptAES(data string, key []byte, keySize int, iv []byte) (string, error) {
+// encryptAES encrypts data using AES with Base64 encoding, after header
+func encryptAES(data string, key []byte, keySize int, iv []byte, deterministic bool, header []byte) (string, error) {
 	// Ensure key is the correct size
 	if len(key) != keySize {
 		// Hash the key to get the correct size
@@ -111,7 +154,13 @@ func encryptAES(data string, key []byte, keySize int, iv []byte) (string, error)
 	}
 
 	// Generate IV if not provided
-	if len(iv) == 0 {
+	if len(iv) == 0 && deterministic {
+		// Synthetic IV: a MAC of the plaintext, so only identical
+		// messages share an IV
+		mac := hmac.New(sha256.New, syntheticIVKey(key))
+		mac.Write([]byte(data))
+		iv = mac.Sum(nil)[:aes.BlockSize]
+	} else if len(iv) == 0 {
 		iv = make([]byte, aes.BlockSize)
 		if _, err := io.ReadFull(rand.Reader, iv); err != nil {
 			return "", fmt.Errorf("failed to generate IV: %v", err)
@@ -131,17 +180,26 @@ func encryptAES(data string, key []byte, keySize int, iv []byte) (string, error)
 	ciphertext := make([]byte, len(data))
 	stream.XORKeyStream(ciphertext, []byte(data))
 
-	// Prepend IV to ciphertext
-	result := make([]byte, len(iv)+len(ciphertext))
-	copy(result, iv)
-	copy(result[len(iv):], ciphertext)
+	// Prepend header and IV to ciphertext
+	result := make([]byte, 0, len(header)+len(iv)+len(ciphertext))
+	result = append(result, header...)
+	result = append(result, iv...)
+	result = append(result, ciphertext...)
 
 	// Base64 encode
 	return base64.StdEncoding.EncodeToString(result), nil
 }
 
-// decryptAES decrypts Base64 encoded AES data
-func decryptAES(encryptedData string, key []byte, keySize int, iv []byte) (string, error) {
+// syntheticIVKey derives the key the synthetic IV is a MAC with from the
+// AES key, so that no key is used with two primitives.
+func syntheticIVKey(key []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("pocsag synthetic IV"))
+	return mac.Sum(nil)
+}
+
+// decodePayload decodes the Base64 text of an encrypted page.
+func decodePayload(encryptedData string) ([]byte, error) {
 	// POCSAG decoding often strips trailing '=' padding or appends NUL/ETX/Space.
 	// Clean the string and repair Base64 padding.
 	cleanedStr := strings.TrimRight(encryptedData, "\x00\x03\x04\r\n ")
@@ -149,12 +207,15 @@ func decryptAES(encryptedData string, key []byte, keySize int, iv []byte) (strin
 		cleanedStr += strings.Repeat("=", 4-padding)
 	}
 
-	// Decode Base64
 	data, err := base64.StdEncoding.DecodeString(cleanedStr)
 	if err != nil {
-		return "", fmt.Errorf("failed to decode base64: %v", err)
+		return nil, fmt.Errorf("failed to decode base64: %v", err)
 	}
+	return data, nil
+}
 
+// decryptAES decrypts AES data, IV first unless iv is given
+func decryptAES(data []byte, key []byte, keySize int) (string, error) {
 	// Ensure key is the correct size
 	if len(key) != keySize {
 		// Hash the key to get the correct size
@@ -162,14 +223,12 @@ func decryptAES(encryptedData string, key []byte, keySize int, iv []byte) (strin
 		key = hash[:keySize]
 	}
 
-	// Extract IV if not provided
-	if len(iv) == 0 {
-		if len(data) < aes.BlockSize {
-			return "", fmt.Errorf("encrypted data too short")
-		}
-		iv = data[:aes.BlockSize]
-		data = data[aes.BlockSize:]
+	// The IV leads the ciphertext
+	if len(data) < aes.BlockSize {
+		return "", fmt.Errorf("encrypted data too short")
 	}
+	iv := data[:aes.BlockSize]
+	data = data[aes.BlockSize:]
 
 	// Create cipher
 	block, err := aes.NewCipher(key)
@@ -0,0 +1,194 @@
+package pocsag
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// FEC pages protect critical messages against fades that wipe out more
+// codewords than BCH(31,21) can repair. The text is Reed-Solomon coded
+// over GF(256) and sent as Base64 behind a short header:
+//
+//	~F <parity> <length> <Base64 of the coded bytes>
+//
+// parity is one Base64 digit giving the parity bytes per block and length
+// two digits giving the text's length in bytes. Text longer than one block
+// is split into several blocks whose bytes are interleaved, so a burst of
+// lost codewords is spread over all of them. The decoder knows which
+// characters came from corrupted codewords, since they are shown as the
+// placeholder, which is not a Base64 character, and repairs them as
+// erasures: each block survives losing as many bytes as it has parity
+// bytes. The header itself, in the first two codewords, must arrive.
+const (
+	fecHeader    = "~F"
+	fecMaxParity = 63   // one Base64 digit
+	fecMaxLength = 4095 // two Base64 digits
+	rsBlockSize  = 255  // the longest Reed-Solomon code over GF(256)
+)
+
+var fecEncoding = base64.RawStdEncoding
+
+const fecDigits = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+// EncodeFEC wraps text in Reed-Solomon forward error correction with parity
+// bytes per block, 1 to 63, for an alphanumeric page. Each parity byte
+// costs about 1.4 characters of airtime and lets a block lose one more
+// byte; 16 parity bytes ride out about 5 lost codewords. DecodeFEC, which
+// the decoder applies to every page that carries the header, reverses it.
+func EncodeFEC(text string, parity int) (string, error) {
+	if parity < 1 || parity > fecMaxParity {
+		return "", fmt.Errorf("FEC parity must be 1 to %d bytes, not %d", fecMaxParity, parity)
+	}
+	if len(text) > fecMaxLength {
+		return "", fmt.Errorf("message of %d bytes is too long for FEC (at most %d)", len(text), fecMaxLength)
+	}
+
+	blocks := fecBlocks([]byte(text), parity)
+	for i, data := range blocks {
+		blocks[i] = rsEncode(data, parity)
+	}
+
+	var b strings.Builder
+	b.WriteString(fecHeader)
+	b.WriteByte(fecDigits[parity])
+	b.WriteByte(fecDigits[len(text)>>6])
+	b.WriteByte(fecDigits[len(text)&63])
+	b.WriteString(fecEncoding.EncodeToString(interleave(blocks)))
+	return b.String(), nil
+}
+
+// IsFEC reports whether a received message carries the FEC header.
+func IsFEC(message string) bool {
+	return strings.HasPrefix(message, fecHeader) && len(message) >= len(fecHeader)+3
+}
+
+// DecodeFEC recovers the text of a page wrapped by EncodeFEC. Characters
+// equal to placeholder, or missing from the end, are treated as lost.
+func DecodeFEC(message string, placeholder rune) (string, error) {
+	if !IsFEC(message) {
+		return "", fmt.Errorf("not an FEC page")
+	}
+	parity, length, total, ok := parseFECHeader(message)
+	if !ok {
+		return "", fmt.Errorf("FEC header is damaged")
+	}
+
+	// Base64 characters that did not arrive are erased along with every
+	// byte they carry a bit of
+	chars := []rune(message[len(fecHeader)+3:])
+	coded := make([]byte, (total*8+5)/6)
+	erased := make([]bool, total)
+	for i := range coded {
+		if i < len(chars) && chars[i] != placeholder && strings.ContainsRune(fecDigits, chars[i]) {
+			coded[i] = byte(chars[i])
+			continue
+		}
+		coded[i] = 'A'
+		for bit := i * 6; bit < i*6+6 && bit/8 < total; bit += 2 {
+			erased[bit/8] = true
+		}
+	}
+	data, err := fecEncoding.DecodeString(string(coded))
+	if err != nil {
+		return "", fmt.Errorf("FEC payload: %v", err)
+	}
+
+	blocks, blockErased := deinterleave(data[:total], erased, length, parity)
+	var text []byte
+	for i, block := range blocks {
+		var positions []int
+		for j, e := range blockErased[i] {
+			if e {
+				positions = append(positions, j)
+			}
+		}
+		fixed, err := rsCorrect(block, parity, positions)
+		if err != nil {
+			return "", fmt.Errorf("FEC block %d: %v", i, err)
+		}
+		blocks[i] = fixed
+	}
+	// Undo the striping of the text over the blocks
+	for i := 0; i < length; i++ {
+		text = append(text, blocks[i%len(blocks)][i/len(blocks)])
+	}
+	return string(text), nil
+}
+
+// parseFECHeader returns the parity bytes per block, the text length and
+// the length of the coded bytes an FEC page's header gives.
+func parseFECHeader(message string) (parity, length, total int, ok bool) {
+	header := message[len(fecHeader) : len(fecHeader)+3]
+	parity = strings.IndexByte(fecDigits, header[0])
+	hi, lo := strings.IndexByte(fecDigits, header[1]), strings.IndexByte(fecDigits, header[2])
+	if parity < 1 || hi < 0 || lo < 0 {
+		return 0, 0, 0, false
+	}
+	length = hi<<6 | lo
+	total = length + len(fecBlocks(make([]byte, length), parity))*parity
+	return parity, length, total, true
+}
+
+// fecCodewords returns how many message codewords the FEC page a received
+// message starts is sent in, or 0 if it is not one.
+func fecCodewords(message string) int {
+	if !IsFEC(message) {
+		return 0
+	}
+	_, _, total, ok := parseFECHeader(message)
+	if !ok {
+		return 0
+	}
+	chars := len(fecHeader) + 3 + (total*8+5)/6
+	return (chars*7 + 19) / 20
+}
+
+// fecBlocks stripes data over as few blocks as hold it with parity bytes
+// each: byte i goes to block i mod n.
+func fecBlocks(data []byte, parity int) [][]byte {
+	room := rsBlockSize - parity
+	n := max((len(data)+room-1)/room, 1)
+	blocks := make([][]byte, n)
+	for i, c := range data {
+		blocks[i%n] = append(blocks[i%n], c)
+	}
+	return blocks
+}
+
+// interleave sends the first byte of every block, then the second, and so
+// on, so neighbouring bytes on air belong to different blocks.
+func interleave(blocks [][]byte) []byte {
+	var out []byte
+	for j := 0; ; j++ {
+		sent := false
+		for _, block := range blocks {
+			if j < len(block) {
+				out = append(out, block[j])
+				sent = true
+			}
+		}
+		if !sent {
+			return out
+		}
+	}
+}
+
+// deinterleave reverses interleave for the blocks of a text of length
+// bytes, carrying the erasure flags along.
+func deinterleave(data []byte, erased []bool, length, parity int) ([][]byte, [][]bool) {
+	sizes := fecBlocks(make([]byte, length), parity)
+	blocks := make([][]byte, len(sizes))
+	flags := make([][]bool, len(sizes))
+	k := 0
+	for j := 0; k < len(data); j++ {
+		for i := range blocks {
+			if j < len(sizes[i])+parity {
+				blocks[i] = append(blocks[i], data[k])
+				flags[i] = append(flags[i], erased[k])
+				k++
+			}
+		}
+	}
+	return blocks, flags
+}
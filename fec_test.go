@@ -0,0 +1,75 @@
+package pocsag
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFEC(t *testing.T) {
+	const text = "EVACUATE BUILDING 4 NOW - GAS LEAK"
+	page, err := EncodeFEC(text, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packet := CreatePOCSAGPacket(8, page, FuncAlphanumeric)
+
+	// Address 8 sits in slot 0, so message codeword k is overall codeword k+1
+	corrupt := func(k int) {
+		n := k + 1
+		pos := PreambleLength/8 + n/16*BatchBits/8 + 4 + n%16*4
+		packet[pos] ^= 0x07
+	}
+	decoded, err := DecodeFromBinary(packet)
+	if err != nil || len(decoded) != 1 || decoded[0].Message != text {
+		t.Fatalf("clean page: %v, %v", decoded, err)
+	}
+
+	// A fade of six codewords is longer than plain pages survive
+	for k := 3; k < 9; k++ {
+		corrupt(k)
+	}
+	decoded, err = DecodeFromBinary(packet)
+	if err != nil || len(decoded) != 1 {
+		t.Fatalf("faded page: %v, %v", decoded, err)
+	}
+	if decoded[0].Message != text || decoded[0].Partial {
+		t.Errorf("faded page: %q partial=%v", decoded[0].Message, decoded[0].Partial)
+	}
+
+	// Beyond the parity the page is shown as received
+	for k := 9; k < 14; k++ {
+		corrupt(k)
+	}
+	decoded, _ = DecodeFromBinary(packet)
+	if len(decoded) != 1 || !decoded[0].Partial || !IsFEC(decoded[0].Message) {
+		t.Errorf("lost page: %v", decoded)
+	}
+
+	// Long text is split over interleaved blocks, and unmarked errors are
+	// corrected too
+	long := strings.Repeat("0123456789 ", 40)
+	page, err = EncodeFEC(long, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := []rune(page)
+	for _, i := range []int{5, 6, 7, 8, 9, 10} {
+		received[i] = '?'
+	}
+	received[40] = rune(fecDigits[(strings.IndexRune(fecDigits, received[40])+1)%64])
+	received[90] = rune(fecDigits[(strings.IndexRune(fecDigits, received[90])+9)%64])
+	received = received[:len(received)-3]
+	if got, err := DecodeFEC(string(received), '?'); err != nil || got != long {
+		t.Errorf("long page: %q, %v", got, err)
+	}
+
+	if _, err := EncodeFEC(text, 0); err == nil {
+		t.Error("no parity accepted")
+	}
+	if _, err := EncodeFEC(strings.Repeat("x", 5000), 8); err == nil {
+		t.Error("overlong text accepted")
+	}
+	if _, err := DecodeFEC("~F??A", '?'); err == nil {
+		t.Error("damaged header accepted")
+	}
+}
@@ -0,0 +1,171 @@
+package pocsag
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+)
+
+// Numeric pages carry only the 16 characters of BCD, so the Base64 of the
+// AES modes cannot be sent on them. EncryptionFF1 encrypts their digits
+// with FF1 (NIST SP 800-38G) instead, a format-preserving cipher: the
+// digits are replaced with as many other digits and everything else stays
+// in place, so the page is as long, and takes as much airtime, as before.
+//
+// The price is that nothing is added to the page: there is no IV, so a
+// message sent twice to the same pager encrypts the same, and no CRC, so a
+// decoder cannot tell a wrong key or a clear page from an encrypted one.
+// The page's address is the tweak, so the same message to two pagers does
+// encrypt differently.
+
+// MinFF1Digits is the fewest digits FF1 encrypts: SP 800-38G asks for at
+// least a million possible values.
+const MinFF1Digits = 6
+
+const ff1Rounds = 10
+
+// encryptNumeric encrypts the digits of message with FF1, in place.
+func encryptNumeric(message string, key, tweak []byte) (string, error) {
+	return ff1Digits(message, key, tweak, true)
+}
+
+// decryptNumeric reverses encryptNumeric.
+func decryptNumeric(message string, key, tweak []byte) (string, error) {
+	return ff1Digits(message, key, tweak, false)
+}
+
+func ff1Digits(message string, key, tweak []byte, encrypt bool) (string, error) {
+	page := []byte(message)
+	var digits []byte
+	for _, c := range page {
+		if c >= '0' && c <= '9' {
+			digits = append(digits, c-'0')
+		}
+	}
+	if len(digits) < MinFF1Digits {
+		return "", fmt.Errorf("FF1 needs at least %d digits, the page has %d", MinFF1Digits, len(digits))
+	}
+
+	switch len(key) {
+	case 16, 24, 32:
+	default:
+		hash := sha256.Sum256(key)
+		key = hash[:]
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", fmt.Errorf("failed to create cipher: %v", err)
+	}
+	digits = ff1(block, tweak, digits, encrypt)
+
+	n := 0
+	for i, c := range page {
+		if c >= '0' && c <= '9' {
+			page[i] = '0' + digits[n]
+			n++
+		}
+	}
+	return string(page), nil
+}
+
+// ff1 runs FF1 with radix 10 over digits, most significant first,
+// following algorithms 7 and 8 of SP 800-38G.
+func ff1(block cipher.Block, tweak, digits []byte, encrypt bool) []byte {
+	n := len(digits)
+	u, v := n/2, n-n/2
+	a, b := append([]byte(nil), digits[:u]...), append([]byte(nil), digits[u:]...)
+
+	// Bytes to hold a number of v digits, and of the round function output
+	byteLen := (new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(v)), nil).BitLen() + 7) / 8
+	outLen := 4*((byteLen+3)/4) + 4
+
+	p := make([]byte, aes.BlockSize, aes.BlockSize+len(tweak)+byteLen+16)
+	p[0], p[1], p[2], p[5], p[6] = 1, 2, 1, 10, ff1Rounds
+	p[7] = byte(u)
+	binary.BigEndian.PutUint32(p[8:], uint32(n))
+	binary.BigEndian.PutUint32(p[12:], uint32(len(tweak)))
+
+	moduli := [2]*big.Int{
+		new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(u)), nil),
+		new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(v)), nil),
+	}
+	for r := 0; r < ff1Rounds; r++ {
+		i := r
+		if !encrypt {
+			i = ff1Rounds - 1 - r
+		}
+		// Q = T || 0s to a block boundary || i || NUM(B), with B the half
+		// the round leaves alone: A when decrypting
+		half := b
+		if !encrypt {
+			half = a
+		}
+		q := append(p[:aes.BlockSize], tweak...)
+		q = append(q, make([]byte, mod(-len(tweak)-byteLen-1, aes.BlockSize))...)
+		q = append(q, byte(i))
+		q = append(q, numBytes(half, byteLen)...)
+
+		y := new(big.Int).SetBytes(ff1Round(block, q, outLen))
+		m := moduli[i%2]
+		if encrypt {
+			c := y.Add(y, digitsNum(a))
+			a, b = b, numDigits(c.Mod(c, m), len(a))
+		} else {
+			c := y.Sub(digitsNum(b), y)
+			a, b = numDigits(c.Mod(c, m), len(b)), a
+		}
+	}
+	return append(a, b...)
+}
+
+// ff1Round is the PRF of FF1, a CBC-MAC over pq, extended to outLen bytes.
+func ff1Round(block cipher.Block, pq []byte, outLen int) []byte {
+	r := make([]byte, aes.BlockSize)
+	for off := 0; off < len(pq); off += aes.BlockSize {
+		for j := range r {
+			r[j] ^= pq[off+j]
+		}
+		block.Encrypt(r, r)
+	}
+	s := append([]byte(nil), r...)
+	for j := 1; len(s) < outLen; j++ {
+		x := make([]byte, aes.BlockSize)
+		binary.BigEndian.PutUint64(x[8:], uint64(j))
+		for k := range x {
+			x[k] ^= r[k]
+		}
+		block.Encrypt(x, x)
+		s = append(s, x...)
+	}
+	return s[:outLen]
+}
+
+func digitsNum(digits []byte) *big.Int {
+	x, ten := new(big.Int), big.NewInt(10)
+	for _, d := range digits {
+		x.Mul(x, ten).Add(x, big.NewInt(int64(d)))
+	}
+	return x
+}
+
+func numDigits(x *big.Int, n int) []byte {
+	digits := make([]byte, n)
+	x, ten, d := new(big.Int).Set(x), big.NewInt(10), new(big.Int)
+	for i := n - 1; i >= 0; i-- {
+		x.DivMod(x, ten, d)
+		digits[i] = byte(d.Int64())
+	}
+	return digits
+}
+
+// numBytes returns the number digits stands for in n bytes, big-endian.
+func numBytes(digits []byte, n int) []byte {
+	return digitsNum(digits).FillBytes(make([]byte, n))
+}
+
+func mod(x, m int) int {
+	return ((x % m) + m) % m
+}
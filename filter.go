@@ -0,0 +1,86 @@
+package pocsag
+
+import (
+	"math"
+)
+
+const (
+	// dcBlockCutoff is the -3 dB corner of the DC blocking filter in Hz. It
+	// must sit far below 512 baud so long runs of identical bits (up to a
+	// full codeword) keep their sign after filtering.
+	dcBlockCutoff = 1.0
+
+	// agcTarget is the peak level normalized audio is scaled to.
+	agcTarget = 16000.0
+
+	// agcRelease is the time constant in seconds over which the AGC envelope
+	// decays after a loud passage, letting a weak transmission that follows
+	// a strong one be brought back up.
+	agcRelease = 0.5
+)
+
+// removeDC subtracts the mean and then applies a first-order high-pass
+// (DC blocker) in place, taking out both static offset and slow drift from
+// off-air recordings.
+func removeDC(samples []float32, sampleRate int) {
+	if len(samples) == 0 {
+		return
+	}
+
+	var sum float64
+	for _, s := range samples {
+		sum += float64(s)
+	}
+	mean := float32(sum / float64(len(samples)))
+
+	r := float32(1.0 - 2.0*math.Pi*dcBlockCutoff/float64(sampleRate))
+	var prevIn, prevOut float32
+	for i, s := range samples {
+		x := s - mean
+		y := x - prevIn + r*prevOut
+		prevIn = x
+		prevOut = y
+		samples[i] = y
+	}
+}
+
+// normalizeLevel applies a peak-following AGC in place: the envelope attacks
+// instantly and releases slowly, and each sample is scaled so the envelope
+// sits at agcTarget. Near-silent input is left alone so noise is not
+// amplified into full-scale garbage.
+func normalizeLevel(samples []float32, sampleRate int) {
+	const noiseFloor = 1.0
+
+	release := float32(math.Exp(-1.0 / (agcRelease * float64(sampleRate))))
+	var envelope float32
+	for _, s := range samples {
+		if a := float32(math.Abs(float64(s))); a > envelope {
+			envelope = a
+		}
+	}
+	if envelope < noiseFloor {
+		return
+	}
+
+	// Seed the envelope from the first few milliseconds so the start of the
+	// recording is not scaled by an empty envelope.
+	envelope = 0
+	seed := min(len(samples), sampleRate/100)
+	for _, s := range samples[:seed] {
+		if a := float32(math.Abs(float64(s))); a > envelope {
+			envelope = a
+		}
+	}
+
+	for i, s := range samples {
+		a := float32(math.Abs(float64(s)))
+		if a > envelope {
+			envelope = a
+		} else {
+			envelope *= release
+		}
+		if envelope > noiseFloor {
+			samples[i] = s * agcTarget / envelope
+		}
+	}
+}
@@ -0,0 +1,336 @@
+package pocsag
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+)
+
+// flacBlockSize is the number of samples per FLAC frame. 4096 is the
+// reference encoder's default and has a short block-size code.
+const flacBlockSize = 4096
+
+// EncodeFLAC encodes 16-bit mono samples as a FLAC file. Each block is
+// stored with whichever of a constant, fixed-predictor (orders 0-4), or
+// verbatim subframe is smallest; baseband POCSAG is mostly flat runs, so
+// the fixed predictors shrink it to a small fraction of the WAV size.
+func EncodeFLAC(samples []int16, sampleRate int) []byte {
+	var out bytes.Buffer
+	out.WriteString("fLaC")
+
+	// STREAMINFO, the only metadata block
+	var info bitWriter
+	info.writeBits(flacBlockSize, 16) // min block size
+	info.writeBits(flacBlockSize, 16) // max block size
+	info.writeBits(0, 24)             // min frame size (unknown)
+	info.writeBits(0, 24)             // max frame size (unknown)
+	info.writeBits(uint64(sampleRate), 20)
+	info.writeBits(0, 3)  // channels - 1
+	info.writeBits(15, 5) // bits per sample - 1
+	info.writeBits(uint64(len(samples)), 36)
+	sum := md5.New()
+	binary.Write(sum, binary.LittleEndian, samples)
+	out.Write([]byte{0x80, 0, 0, 34}) // last block, type 0, length 34
+	out.Write(info.bytes())
+	out.Write(sum.Sum(nil))
+
+	for frame, start := 0, 0; start < len(samples); frame, start = frame+1, start+flacBlockSize {
+		end := start + flacBlockSize
+		if end > len(samples) {
+			end = len(samples)
+		}
+		out.Write(encodeFLACFrame(samples[start:end], frame, sampleRate))
+	}
+	return out.Bytes()
+}
+
+func encodeFLACFrame(block []int16, frameNumber int, sampleRate int) []byte {
+	var w bitWriter
+
+	blockCode, blockExtra := uint64(12), 0 // 12 = 4096 samples
+	if len(block) != flacBlockSize {
+		blockCode, blockExtra = 7, 16 // 16-bit (size - 1) at end of header
+	}
+	rateCode, rateExtra, rateValue := flacSampleRateCode(sampleRate)
+
+	w.writeBits(0x3FFE, 14) // sync
+	w.writeBits(0, 1)       // reserved
+	w.writeBits(0, 1)       // fixed block size stream
+	w.writeBits(blockCode, 4)
+	w.writeBits(rateCode, 4)
+	w.writeBits(0, 4) // mono
+	w.writeBits(4, 3) // 16 bits per sample
+	w.writeBits(0, 1) // reserved
+	w.writeUTF8(uint64(frameNumber))
+	if blockExtra > 0 {
+		w.writeBits(uint64(len(block)-1), blockExtra)
+	}
+	if rateExtra > 0 {
+		w.writeBits(rateValue, rateExtra)
+	}
+	w.writeBits(uint64(crc8(w.bytes())), 8)
+
+	writeFLACSubframe(&w, block)
+
+	w.align()
+	w.writeBits(uint64(crc16(w.bytes())), 16)
+	return w.bytes()
+}
+
+// flacSampleRateCode returns the frame header code for sampleRate and, for
+// rates without a dedicated code, the size and value of the trailing field.
+func flacSampleRateCode(sampleRate int) (code uint64, extraBits int, extra uint64) {
+	switch sampleRate {
+	case 88200:
+		return 1, 0, 0
+	case 176400:
+		return 2, 0, 0
+	case 192000:
+		return 3, 0, 0
+	case 8000:
+		return 4, 0, 0
+	case 16000:
+		return 5, 0, 0
+	case 22050:
+		return 6, 0, 0
+	case 24000:
+		return 7, 0, 0
+	case 32000:
+		return 8, 0, 0
+	case 44100:
+		return 9, 0, 0
+	case 48000:
+		return 10, 0, 0
+	case 96000:
+		return 11, 0, 0
+	}
+	if sampleRate%1000 == 0 && sampleRate/1000 < 256 {
+		return 12, 8, uint64(sampleRate / 1000)
+	}
+	if sampleRate < 65536 {
+		return 13, 16, uint64(sampleRate)
+	}
+	return 0, 0, 0 // taken from STREAMINFO
+}
+
+func writeFLACSubframe(w *bitWriter, block []int16) {
+	constant := true
+	for _, s := range block {
+		if s != block[0] {
+			constant = false
+			break
+		}
+	}
+	if constant {
+		w.writeBits(0, 8) // padding bit, type 000000, no wasted bits
+		w.writeBits(uint64(uint16(block[0])), 16)
+		return
+	}
+
+	bestOrder, bestBits := -1, 16*len(block)
+	var bestResidual []int32
+	var bestPartitions []int
+	for order := 0; order <= 4 && order < len(block); order++ {
+		residual := fixedResidual(block, order)
+		partitions, bits := bestRicePartitions(residual, len(block), order)
+		bits += 16*order + 2 + 4
+		if partitions != nil && bits < bestBits {
+			bestOrder, bestBits, bestResidual, bestPartitions = order, bits, residual, partitions
+		}
+	}
+
+	if bestOrder < 0 {
+		w.writeBits(1<<1, 8) // verbatim
+		for _, s := range block {
+			w.writeBits(uint64(uint16(s)), 16)
+		}
+		return
+	}
+
+	w.writeBits(uint64(0x08|bestOrder)<<1, 8) // fixed predictor of bestOrder
+	for _, s := range block[:bestOrder] {
+		w.writeBits(uint64(uint16(s)), 16)
+	}
+	w.writeBits(0, 2) // Rice coding, 4-bit parameters
+	partitionOrder := 0
+	for 1<<uint(partitionOrder) < len(bestPartitions) {
+		partitionOrder++
+	}
+	w.writeBits(uint64(partitionOrder), 4)
+
+	pos := 0
+	for p, k := range bestPartitions {
+		n := len(block) >> uint(partitionOrder)
+		if p == 0 {
+			n -= bestOrder
+		}
+		w.writeBits(uint64(k), 4)
+		for _, r := range bestResidual[pos : pos+n] {
+			u := uint32(r<<1) ^ uint32(r>>31)
+			w.writeUnary(u >> uint(k))
+			w.writeBits(uint64(u)&(1<<uint(k)-1), k)
+		}
+		pos += n
+	}
+}
+
+// fixedResidual returns the prediction error of the FLAC fixed predictor of
+// the given order for samples[order:].
+func fixedResidual(block []int16, order int) []int32 {
+	residual := make([]int32, 0, len(block)-order)
+	for i := order; i < len(block); i++ {
+		s := func(k int) int32 { return int32(block[i-k]) }
+		var r int32
+		switch order {
+		case 0:
+			r = s(0)
+		case 1:
+			r = s(0) - s(1)
+		case 2:
+			r = s(0) - 2*s(1) + s(2)
+		case 3:
+			r = s(0) - 3*s(1) + 3*s(2) - s(3)
+		case 4:
+			r = s(0) - 4*s(1) + 6*s(2) - 4*s(3) + s(4)
+		}
+		residual = append(residual, r)
+	}
+	return residual
+}
+
+// flacMaxPartitionOrder caps the Rice partition search. Baseband POCSAG
+// residuals are zero except at bit transitions, so small partitions let
+// the quiet stretches between transitions cost one bit per sample.
+const flacMaxPartitionOrder = 8
+
+// bestRicePartitions picks the Rice partition order and per-partition
+// parameters that code residual in the fewest bits. It returns the
+// parameters (one per partition) and the bit count including the partition
+// order and parameter fields, or nil if no partitioning fits the block.
+func bestRicePartitions(residual []int32, blockSize, predictorOrder int) ([]int, int) {
+	var best []int
+	bestBits := -1
+	for order := 0; order <= flacMaxPartitionOrder; order++ {
+		count := 1 << uint(order)
+		if blockSize%count != 0 || blockSize/count <= predictorOrder {
+			break
+		}
+		params := make([]int, count)
+		bits := 4
+		pos := 0
+		for p := range params {
+			n := blockSize / count
+			if p == 0 {
+				n -= predictorOrder
+			}
+			k, kBits := bestRiceParameter(residual[pos : pos+n])
+			params[p] = k
+			bits += 4 + kBits
+			pos += n
+		}
+		if bestBits < 0 || bits < bestBits {
+			best, bestBits = params, bits
+		}
+	}
+	return best, bestBits
+}
+
+// bestRiceParameter returns the Rice parameter that codes residual in the
+// fewest bits, and that bit count.
+func bestRiceParameter(residual []int32) (int, int) {
+	bestK, bestBits := 0, -1
+	for k := 0; k < 15; k++ {
+		bits := 0
+		for _, r := range residual {
+			u := uint32(r<<1) ^ uint32(r>>31)
+			bits += int(u>>uint(k)) + 1 + k
+		}
+		if bestBits < 0 || bits < bestBits {
+			bestK, bestBits = k, bits
+		}
+	}
+	return bestK, bestBits
+}
+
+// bitWriter accumulates an MSB-first bit stream.
+type bitWriter struct {
+	buf   []byte
+	nbits int
+}
+
+func (w *bitWriter) writeBits(v uint64, n int) {
+	for i := n - 1; i >= 0; i-- {
+		if w.nbits%8 == 0 {
+			w.buf = append(w.buf, 0)
+		}
+		if (v>>uint(i))&1 != 0 {
+			w.buf[len(w.buf)-1] |= 0x80 >> uint(w.nbits%8)
+		}
+		w.nbits++
+	}
+}
+
+// writeUnary writes q zero bits followed by a one bit.
+func (w *bitWriter) writeUnary(q uint32) {
+	for ; q > 0; q-- {
+		w.writeBits(0, 1)
+	}
+	w.writeBits(1, 1)
+}
+
+// writeUTF8 writes v in FLAC's extended UTF-8 coding for frame numbers.
+func (w *bitWriter) writeUTF8(v uint64) {
+	if v < 0x80 {
+		w.writeBits(v, 8)
+		return
+	}
+	n := 2
+	for v >= 1<<uint(5*n+1) {
+		n++
+	}
+	lead := uint64(0xFF00>>uint(n)) & 0xFF
+	w.writeBits(lead|v>>uint(6*(n-1)), 8)
+	for i := n - 2; i >= 0; i-- {
+		w.writeBits(0x80|(v>>uint(6*i))&0x3F, 8)
+	}
+}
+
+func (w *bitWriter) align() {
+	if pad := (8 - w.nbits%8) % 8; pad > 0 {
+		w.writeBits(0, pad)
+	}
+}
+
+func (w *bitWriter) bytes() []byte {
+	return w.buf
+}
+
+func crc8(data []byte) byte {
+	var crc byte
+	for _, b := range data {
+		crc ^= b
+		for i := 0; i < 8; i++ {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ 0x07
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+func crc16(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x8005
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,154 @@
+package pocsag
+
+import (
+	"testing"
+)
+
+// flacBitReader reads the subset of FLAC that EncodeFLAC writes.
+type flacBitReader struct {
+	data []byte
+	pos  int // bit position
+}
+
+func (r *flacBitReader) bits(n int) uint64 {
+	var v uint64
+	for i := 0; i < n; i++ {
+		v = v<<1 | uint64(r.data[r.pos/8]>>(7-uint(r.pos%8))&1)
+		r.pos++
+	}
+	return v
+}
+
+func (r *flacBitReader) signed16() int16 { return int16(uint16(r.bits(16))) }
+
+func decodeTestFLAC(t *testing.T, data []byte) ([]int16, int) {
+	if string(data[:4]) != "fLaC" {
+		t.Fatalf("missing fLaC marker")
+	}
+	// STREAMINFO: skip block sizes and frame sizes
+	r := &flacBitReader{data: data, pos: (4 + 4 + 10) * 8}
+	sampleRate := int(r.bits(20))
+	r.bits(3 + 5)
+	total := int(r.bits(36))
+	r.pos = (4 + 4 + 34) * 8
+
+	var out []int16
+	for len(out) < total {
+		frameStart := r.pos / 8
+		if r.bits(14) != 0x3FFE {
+			t.Fatalf("bad frame sync at byte %d", frameStart)
+		}
+		r.bits(2)
+		blockCode := r.bits(4)
+		rateCode := r.bits(4)
+		r.bits(4 + 3 + 1)
+		if lead := r.bits(8); lead >= 0xC0 {
+			for lead&0x20 != 0 {
+				r.bits(8)
+				lead <<= 1
+			}
+			r.bits(8)
+		}
+		size := flacBlockSize
+		if blockCode == 7 {
+			size = int(r.bits(16)) + 1
+		}
+		switch rateCode {
+		case 12:
+			r.bits(8)
+		case 13:
+			r.bits(16)
+		}
+		if got := byte(r.bits(8)); got != crc8(data[frameStart:r.pos/8-1]) {
+			t.Fatalf("frame header CRC mismatch at byte %d", frameStart)
+		}
+
+		r.bits(1)
+		kind := int(r.bits(6))
+		r.bits(1)
+		switch {
+		case kind == 0:
+			v := r.signed16()
+			for i := 0; i < size; i++ {
+				out = append(out, v)
+			}
+		case kind == 1:
+			for i := 0; i < size; i++ {
+				out = append(out, r.signed16())
+			}
+		case kind&0x38 == 0x08:
+			order := kind & 7
+			block := make([]int32, 0, size)
+			for i := 0; i < order; i++ {
+				block = append(block, int32(r.signed16()))
+			}
+			r.bits(2)
+			partitionOrder := int(r.bits(4))
+			for p := 0; p < 1<<uint(partitionOrder); p++ {
+				n := size >> uint(partitionOrder)
+				if p == 0 {
+					n -= order
+				}
+				k := int(r.bits(4))
+				for i := 0; i < n; i++ {
+					q := 0
+					for r.bits(1) == 0 {
+						q++
+					}
+					u := uint32(q)<<uint(k) | uint32(r.bits(k))
+					res := int32(u>>1) ^ -int32(u&1)
+					j := len(block)
+					s := func(d int) int32 { return block[j-d] }
+					pred := [5]func() int32{
+						func() int32 { return 0 },
+						func() int32 { return s(1) },
+						func() int32 { return 2*s(1) - s(2) },
+						func() int32 { return 3*s(1) - 3*s(2) + s(3) },
+						func() int32 { return 4*s(1) - 6*s(2) + 4*s(3) - s(4) },
+					}[order]()
+					block = append(block, pred+res)
+				}
+			}
+			for _, v := range block {
+				out = append(out, int16(v))
+			}
+		default:
+			t.Fatalf("unexpected subframe type %d", kind)
+		}
+
+		r.pos = (r.pos + 7) / 8 * 8
+		want := crc16(data[frameStart : r.pos/8])
+		if got := uint16(r.bits(16)); got != want {
+			t.Fatalf("frame CRC mismatch at byte %d", frameStart)
+		}
+	}
+	return out, sampleRate
+}
+
+func TestEncodeFLACLossless(t *testing.T) {
+	packet := CreatePOCSAGPacket(123456, "FLAC ROUND TRIP", FuncAlphanumeric)
+	for _, rate := range []int{48000, 8000, 11025} {
+		wavData := ConvertToAudioWithSampleRate(packet, BaudRate1200, rate)
+		samples, _ := ParseWAVSamples(wavData)
+		// Silence exercises constant subframes
+		samples = append(samples, make([]int16, 5000)...)
+
+		flacData := EncodeFLAC(samples, rate)
+		got, gotRate := decodeTestFLAC(t, flacData)
+		if gotRate != rate || len(got) != len(samples) {
+			t.Fatalf("rate %d: got %d samples at %d Hz, want %d", rate, len(got), gotRate, len(samples))
+		}
+		for i := range samples {
+			if got[i] != samples[i] {
+				t.Fatalf("rate %d: sample %d = %d, want %d", rate, i, got[i], samples[i])
+			}
+		}
+		if rate == SampleRate && len(flacData) > len(wavData)/2 {
+			t.Errorf("FLAC is %d bytes for a %d byte WAV", len(flacData), len(wavData))
+		}
+	}
+
+	if f, err := ParseAudioFormat("OGG"); err != nil || f != AudioOpus || f.FileExtension() != ".ogg" {
+		t.Errorf("ParseAudioFormat(OGG) = %v, %v", f, err)
+	}
+}
@@ -0,0 +1,293 @@
+package pocsag
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ForwardFormat selects how a Forwarder frames each message.
+type ForwardFormat string
+
+const (
+	// ForwardJSON writes one WebhookPayload JSON object per line.
+	ForwardJSON ForwardFormat = "json"
+	// ForwardKISS writes each message as an APRS packet in an AX.25 UI
+	// frame wrapped in KISS, for a TNC or a KISS-over-TCP soundmodem.
+	ForwardKISS ForwardFormat = "kiss"
+	// ForwardAPRSIS logs in to an APRS-IS server and sends each message as
+	// a TNC2-format APRS packet.
+	ForwardAPRSIS ForwardFormat = "aprs-is"
+)
+
+// DefaultAPRSDestination is the AX.25 destination used for APRS packets.
+// APZ is the block reserved for experimental software.
+const DefaultAPRSDestination = "APZPOC"
+
+// KISS framing bytes
+const (
+	kissFEND  = 0xC0
+	kissFESC  = 0xDB
+	kissTFEND = 0xDC
+	kissTFESC = 0xDD
+)
+
+// ForwarderConfig configures a Forwarder.
+type ForwarderConfig struct {
+	Format ForwardFormat // default ForwardJSON
+	Filter MessageFilter
+
+	// Callsign (with optional -SSID) is the APRS source, required for the
+	// KISS and APRS-IS formats. Passcode is the APRS-IS login passcode.
+	Callsign    string
+	Passcode    string
+	Destination string   // AX.25 destination (default DefaultAPRSDestination)
+	Path        []string // digipeater path for KISS, e.g. WIDE1-1
+
+	// Objects places each page on the map as an APRS object named after
+	// its RIC at Latitude/Longitude, or where the message was received
+	// when it has a Position. Without it pages are sent as status reports.
+	Objects   bool
+	Latitude  float64
+	Longitude float64
+	Symbol    string // two-character APRS symbol (default "/$", phone)
+}
+
+// Forwarder writes decoded messages to a serial port, TCP socket, or any
+// io.Writer, framed for TNC, IGate, or line-oriented consumers.
+type Forwarder struct {
+	config ForwarderConfig
+	w      io.Writer
+	mu     sync.Mutex
+}
+
+// NewForwarder creates a Forwarder writing to w. For ForwardAPRSIS the
+// login line is sent immediately.
+func NewForwarder(w io.Writer, config ForwarderConfig) (*Forwarder, error) {
+	if config.Format == "" {
+		config.Format = ForwardJSON
+	}
+	if config.Destination == "" {
+		config.Destination = DefaultAPRSDestination
+	}
+	if config.Symbol == "" {
+		config.Symbol = "/$"
+	}
+
+	switch config.Format {
+	case ForwardJSON:
+	case ForwardKISS, ForwardAPRSIS:
+		if config.Callsign == "" {
+			return nil, fmt.Errorf("%s forwarding requires a callsign", config.Format)
+		}
+		if len(config.Symbol) != 2 {
+			return nil, fmt.Errorf("invalid APRS symbol %q", config.Symbol)
+		}
+	default:
+		return nil, fmt.Errorf("unknown forward format %q", config.Format)
+	}
+
+	if config.Format == ForwardAPRSIS {
+		passcode := config.Passcode
+		if passcode == "" {
+			passcode = "-1" // receive-only login
+		}
+		login := fmt.Sprintf("user %s pass %s vers pocsag-golang %s\r\n", config.Callsign, passcode, Version)
+		if _, err := io.WriteString(w, login); err != nil {
+			return nil, fmt.Errorf("APRS-IS login failed: %v", err)
+		}
+	}
+	return &Forwarder{config: config, w: w}, nil
+}
+
+// DialForwarder opens target and returns a Forwarder writing to it, along
+// with the connection so the caller can close it. A target of the form
+// tcp://host:port is dialed; anything else is opened as a serial device
+// (line speed is left as configured by the OS, e.g. with stty).
+func DialForwarder(target string, config ForwarderConfig) (*Forwarder, io.Closer, error) {
+	var conn io.ReadWriteCloser
+	if addr, ok := strings.CutPrefix(target, "tcp://"); ok {
+		c, err := net.DialTimeout("tcp", addr, 10*time.Second)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to connect to %s: %v", addr, err)
+		}
+		conn = c
+		if config.Format == ForwardAPRSIS {
+			// Discard the server banner and keepalives
+			go io.Copy(io.Discard, c)
+		}
+	} else {
+		f, err := os.OpenFile(target, os.O_RDWR, 0)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open %s: %v", target, err)
+		}
+		conn = f
+	}
+
+	fwd, err := NewForwarder(conn, config)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return fwd, conn, nil
+}
+
+// Send forwards msg if it passes the filter.
+func (f *Forwarder) Send(msg DecodedMessage) error {
+	if !f.config.Filter.Match(msg) {
+		return nil
+	}
+
+	var out []byte
+	switch f.config.Format {
+	case ForwardJSON:
+		body, err := json.Marshal(newWebhookPayload(msg))
+		if err != nil {
+			return fmt.Errorf("failed to encode message: %v", err)
+		}
+		out = append(body, '\n')
+	case ForwardKISS:
+		frame, err := EncodeAX25UI(f.config.Destination, f.config.Callsign, f.config.Path, []byte(f.aprsInfo(msg, time.Now())))
+		if err != nil {
+			return err
+		}
+		out = KISSFrame(0, frame)
+	case ForwardAPRSIS:
+		out = []byte(fmt.Sprintf("%s>%s,TCPIP*:%s\r\n", f.config.Callsign, f.config.Destination, f.aprsInfo(msg, time.Now())))
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, err := f.w.Write(out); err != nil {
+		return fmt.Errorf("failed to forward message: %v", err)
+	}
+	return nil
+}
+
+// aprsInfo builds the APRS information field for msg: an object at the
+// configured position or the message's own, or a status report.
+func (f *Forwarder) aprsInfo(msg DecodedMessage, now time.Time) string {
+	text := aprsText(msg.Message)
+	if !f.config.Objects {
+		return truncate(fmt.Sprintf(">POCSAG %d: %s", msg.Address, text), 62)
+	}
+
+	lat, lon := aprsPosition(f.config.Latitude, f.config.Longitude)
+	if msg.Position != nil {
+		lat, lon = aprsPosition(msg.Position.Latitude, msg.Position.Longitude)
+	}
+	return fmt.Sprintf(";%-9s*%sz%s%c%s%c%s",
+		fmt.Sprintf("P%d", msg.Address),
+		now.UTC().Format("021504"),
+		lat, f.config.Symbol[0], lon, f.config.Symbol[1],
+		truncate(text, 43))
+}
+
+// aprsText replaces characters APRS does not allow in free text.
+func aprsText(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7E || r == '|' || r == '~' {
+			return ' '
+		}
+		return r
+	}, s)
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+// aprsPosition formats decimal degrees as APRS DDMM.mmN / DDDMM.mmE.
+func aprsPosition(lat, lon float64) (string, string) {
+	format := func(v float64, degDigits int, pos, neg byte) string {
+		hemi := pos
+		if v < 0 {
+			hemi, v = neg, -v
+		}
+		deg := math.Floor(v)
+		min := (v - deg) * 60
+		if math.Round(min*100) >= 6000 {
+			deg, min = deg+1, 0
+		}
+		return fmt.Sprintf("%0*d%05.2f%c", degDigits, int(deg), min, hemi)
+	}
+	return format(lat, 2, 'N', 'S'), format(lon, 3, 'E', 'W')
+}
+
+// KISSFrame wraps an AX.25 frame in a KISS data frame for the given TNC
+// port, escaping FEND and FESC bytes.
+func KISSFrame(port int, frame []byte) []byte {
+	out := []byte{kissFEND, byte(port&0x0F) << 4}
+	for _, b := range frame {
+		switch b {
+		case kissFEND:
+			out = append(out, kissFESC, kissTFEND)
+		case kissFESC:
+			out = append(out, kissFESC, kissTFESC)
+		default:
+			out = append(out, b)
+		}
+	}
+	return append(out, kissFEND)
+}
+
+// EncodeAX25UI builds an AX.25 UI frame (without FCS, which the TNC adds)
+// from dest, src, and digipeater path callsigns in CALL or CALL-SSID form.
+func EncodeAX25UI(dest, src string, path []string, info []byte) ([]byte, error) {
+	if len(path) > 8 {
+		return nil, fmt.Errorf("AX.25 path has %d digipeaters, at most 8 allowed", len(path))
+	}
+	calls := append([]string{dest, src}, path...)
+	frame := make([]byte, 0, 7*len(calls)+2+len(info))
+	for i, call := range calls {
+		addr, err := ax25Address(call)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			addr[6] |= 0x80 // command frame: C bit on destination
+		}
+		if i == len(calls)-1 {
+			addr[6] |= 0x01 // end of address field
+		}
+		frame = append(frame, addr[:]...)
+	}
+	frame = append(frame, 0x03, 0xF0) // UI, no layer 3
+	return append(frame, info...), nil
+}
+
+func ax25Address(call string) ([7]byte, error) {
+	var addr [7]byte
+	call = strings.ToUpper(strings.TrimSpace(call))
+	ssid := 0
+	if i := strings.IndexByte(call, '-'); i >= 0 {
+		if _, err := fmt.Sscanf(call[i+1:], "%d", &ssid); err != nil || ssid < 0 || ssid > 15 {
+			return addr, fmt.Errorf("invalid SSID in callsign %q", call)
+		}
+		call = call[:i]
+	}
+	if call == "" || len(call) > 6 {
+		return addr, fmt.Errorf("invalid callsign %q", call)
+	}
+	for i := 0; i < 6; i++ {
+		c := byte(' ')
+		if i < len(call) {
+			c = call[i]
+			if !(c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+				return addr, fmt.Errorf("invalid callsign %q", call)
+			}
+		}
+		addr[i] = c << 1
+	}
+	addr[6] = 0x60 | byte(ssid)<<1
+	return addr, nil
+}
@@ -0,0 +1,84 @@
+package pocsag
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKISSFrameEscaping(t *testing.T) {
+	got := KISSFrame(1, []byte{0x01, kissFEND, 0x02, kissFESC})
+	want := []byte{kissFEND, 0x10, 0x01, kissFESC, kissTFEND, 0x02, kissFESC, kissTFESC, kissFEND}
+	if !bytes.Equal(got, want) {
+		t.Errorf("KISSFrame = %x, want %x", got, want)
+	}
+}
+
+func TestEncodeAX25UI(t *testing.T) {
+	frame, err := EncodeAX25UI("APZPOC", "N0CALL-9", []string{"WIDE1-1"}, []byte(">hi"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		'A' << 1, 'P' << 1, 'Z' << 1, 'P' << 1, 'O' << 1, 'C' << 1, 0xE0,
+		'N' << 1, '0' << 1, 'C' << 1, 'A' << 1, 'L' << 1, 'L' << 1, 0x60 | 9<<1,
+		'W' << 1, 'I' << 1, 'D' << 1, 'E' << 1, '1' << 1, ' ' << 1, 0x60 | 1<<1 | 1,
+		0x03, 0xF0, '>', 'h', 'i',
+	}
+	if !bytes.Equal(frame, want) {
+		t.Errorf("frame\n got %x\nwant %x", frame, want)
+	}
+
+	for _, bad := range []string{"TOOLONGCALL", "N0CALL-16", "N0-CALL", ""} {
+		if _, err := EncodeAX25UI("APZPOC", bad, nil, nil); err == nil {
+			t.Errorf("callsign %q accepted", bad)
+		}
+	}
+}
+
+func TestForwarderFormats(t *testing.T) {
+	msg := DecodedMessage{Address: 123456, Function: 3, Message: "FIRE|STN 4\n"}
+
+	var buf bytes.Buffer
+	fwd, err := NewForwarder(&buf, ForwarderConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fwd.Send(msg)
+	var payload WebhookPayload
+	if err := json.Unmarshal(buf.Bytes(), &payload); err != nil || payload.Address != 123456 || !strings.HasSuffix(buf.String(), "\n") {
+		t.Errorf("json line %q: %v", buf.String(), err)
+	}
+
+	buf.Reset()
+	fwd, err = NewForwarder(&buf, ForwarderConfig{Format: ForwardAPRSIS, Callsign: "N0CALL", Passcode: "12345"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fwd.Send(msg)
+	want := "user N0CALL pass 12345 vers pocsag-golang " + Version + "\r\n" +
+		"N0CALL>APZPOC,TCPIP*:>POCSAG 123456: FIRE STN 4 \r\n"
+	if buf.String() != want {
+		t.Errorf("APRS-IS output\n got %q\nwant %q", buf.String(), want)
+	}
+
+	fwd.config.Objects = true
+	fwd.config.Latitude, fwd.config.Longitude = 47.4979, -19.0402
+	now := time.Date(2024, 3, 9, 14, 5, 0, 0, time.UTC)
+	if got, want := fwd.aprsInfo(msg, now), ";P123456  *091405z4729.87N/01902.41W$FIRE STN 4 "; got != want {
+		t.Errorf("object\n got %q\nwant %q", got, want)
+	}
+
+	buf.Reset()
+	fwd, _ = NewForwarder(&buf, ForwarderConfig{Format: ForwardKISS, Callsign: "N0CALL", Filter: MessageFilter{Addresses: []uint32{1}}})
+	fwd.Send(msg)
+	if buf.Len() != 0 {
+		t.Errorf("filtered message forwarded: %x", buf.Bytes())
+	}
+
+	if _, err := NewForwarder(&buf, ForwarderConfig{Format: ForwardKISS}); err == nil {
+		t.Error("KISS without callsign accepted")
+	}
+}
@@ -0,0 +1,72 @@
+package pocsag
+
+import (
+	"time"
+)
+
+// POCSAG frame structure (ITU-R M.584-2). A transmission is a preamble
+// followed by batches; each batch is a frame sync word and 8 frames of 2
+// codewords. A pager only listens to the frame given by the low 3 bits of
+// its address.
+const (
+	CodewordBits      = 32
+	FramesPerBatch    = 8
+	CodewordsPerFrame = 2
+	CodewordsPerBatch = FramesPerBatch * CodewordsPerFrame // 16
+	BatchBits         = (1 + CodewordsPerBatch) * CodewordBits
+)
+
+// Frame is the pair of codewords in one frame of a batch.
+type Frame [CodewordsPerFrame]uint32
+
+// Batch is one batch as transmitted: the sync word and its 8 frames.
+type Batch struct {
+	Sync   uint32
+	Frames [FramesPerBatch]Frame
+}
+
+// Codewords returns the batch's 16 codewords in transmission order,
+// excluding the sync word.
+func (b Batch) Codewords() []uint32 {
+	cws := make([]uint32, 0, CodewordsPerBatch)
+	for _, f := range b.Frames {
+		cws = append(cws, f[:]...)
+	}
+	return cws
+}
+
+// FrameForAddress returns the frame (0-7) in which an address codeword for
+// ric must be sent.
+func FrameForAddress(ric uint32) int {
+	return int(ric % FramesPerBatch)
+}
+
+// BatchDuration returns the airtime of one batch, sync word included.
+func BatchDuration(baudRate int) time.Duration {
+	return bitsDuration(BatchBits, baudRate)
+}
+
+// CodewordDuration returns the airtime of one codeword.
+func CodewordDuration(baudRate int) time.Duration {
+	return bitsDuration(CodewordBits, baudRate)
+}
+
+// PreambleDuration returns the airtime of the standard 576-bit preamble.
+func PreambleDuration(baudRate int) time.Duration {
+	return bitsDuration(PreambleLength, baudRate)
+}
+
+// LayoutBatches returns the batches CreatePOCSAGBurst would transmit for
+// messages, with every message placed in the frame its address requires
+// and unused slots filled with idle codewords.
+func LayoutBatches(messages []MessageInfo) []Batch {
+	layout, _ := layoutBatches(messages, 0, 0)
+	batches := make([]Batch, len(layout))
+	for i, cws := range layout {
+		batches[i].Sync = FrameSyncWord
+		for slot, cw := range cws {
+			batches[i].Frames[slot/CodewordsPerFrame][slot%CodewordsPerFrame] = cw
+		}
+	}
+	return batches
+}
@@ -0,0 +1,147 @@
+// Package gpsd is a client for gpsd, the GPS service daemon. It follows the
+// receiver's position as a pocsag.PositionSource, for stamping decoded
+// messages during drive tests.
+package gpsd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	pocsag "github.com/sqpp/pocsag-golang/v2"
+)
+
+// DefaultAddress is where gpsd listens by default.
+const DefaultAddress = "127.0.0.1:2947"
+
+// DefaultMaxAge is how long a fix is reported after the last one gpsd
+// sent, if MaxAge is not set.
+const DefaultMaxAge = 10 * time.Second
+
+// watch asks gpsd to stream reports as JSON.
+const watch = `?WATCH={"enable":true,"json":true}` + "\n"
+
+// tpv is the part of a gpsd time-position-velocity report used here.
+type tpv struct {
+	Class  string   `json:"class"`
+	Mode   int      `json:"mode"` // 0-1 no fix, 2 2D, 3 3D
+	Time   string   `json:"time"`
+	Lat    *float64 `json:"lat"`
+	Lon    *float64 `json:"lon"`
+	Alt    *float64 `json:"alt"`    // before gpsd 3.20
+	AltMSL *float64 `json:"altMSL"` // gpsd 3.20 and later
+}
+
+// Client is a connection to gpsd that keeps the latest fix.
+type Client struct {
+	// MaxAge is how long a fix stays current without another; zero means
+	// DefaultMaxAge.
+	MaxAge time.Duration
+
+	conn net.Conn
+
+	mu       sync.Mutex
+	fix      pocsag.Position
+	have     bool
+	received time.Time
+	err      error
+}
+
+// Dial connects to gpsd and starts following its reports.
+func Dial(address string) (*Client, error) {
+	conn, err := net.DialTimeout("tcp", address, 5*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to gpsd at %s: %v", address, err)
+	}
+	c, err := NewClient(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// NewClient follows gpsd over an established connection, e.g. one
+// tunnelled over SSH.
+func NewClient(conn net.Conn) (*Client, error) {
+	if _, err := conn.Write([]byte(watch)); err != nil {
+		return nil, fmt.Errorf("failed to start gpsd watch: %v", err)
+	}
+	c := &Client{conn: conn}
+	go c.read()
+	return c, nil
+}
+
+// read keeps the latest fix until the connection ends.
+func (c *Client) read() {
+	scanner := bufio.NewScanner(c.conn)
+	scanner.Buffer(make([]byte, 0, 4096), 1<<20) // SKY reports can be long
+	for scanner.Scan() {
+		var report tpv
+		if json.Unmarshal(scanner.Bytes(), &report) != nil || report.Class != "TPV" {
+			continue
+		}
+		c.mu.Lock()
+		c.have = report.Mode >= 2 && report.Lat != nil && report.Lon != nil
+		if c.have {
+			c.fix = position(report)
+			c.received = time.Now()
+		}
+		c.mu.Unlock()
+	}
+
+	c.mu.Lock()
+	c.have = false
+	c.err = scanner.Err()
+	if c.err == nil {
+		c.err = fmt.Errorf("gpsd closed the connection")
+	}
+	c.mu.Unlock()
+}
+
+func position(report tpv) pocsag.Position {
+	pos := pocsag.Position{Latitude: *report.Lat, Longitude: *report.Lon}
+	if report.Mode >= 3 {
+		if report.AltMSL != nil {
+			pos.Altitude = *report.AltMSL
+		} else if report.Alt != nil {
+			pos.Altitude = *report.Alt
+		}
+	}
+	if t, err := time.Parse(time.RFC3339Nano, report.Time); err == nil {
+		pos.Time = t.UTC()
+	} else {
+		pos.Time = time.Now().UTC()
+	}
+	return pos
+}
+
+// Position returns the latest fix, or false if gpsd has none, has sent
+// none for MaxAge, or the connection has ended.
+func (c *Client) Position() (pocsag.Position, bool) {
+	maxAge := c.MaxAge
+	if maxAge == 0 {
+		maxAge = DefaultMaxAge
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.have || time.Since(c.received) > maxAge {
+		return pocsag.Position{}, false
+	}
+	return c.fix, true
+}
+
+// Err returns why the connection ended, or nil while it is open.
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Close ends the connection.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,69 @@
+package gpsd
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestClientFollowsFix(t *testing.T) {
+	server, client := net.Pipe()
+	watched := make(chan string, 1)
+	fix := make(chan struct{})
+	go func() {
+		defer server.Close()
+		line, _ := bufio.NewReader(server).ReadString('\n')
+		watched <- line
+		server.Write([]byte(`{"class":"VERSION","release":"3.25"}` + "\n"))
+		server.Write([]byte(`{"class":"TPV","mode":1}` + "\n"))
+		<-fix
+		server.Write([]byte(`{"class":"SKY","satellites":[]}` + "\n"))
+		server.Write([]byte(`{"class":"TPV","mode":3,"time":"2026-10-15T09:30:00.000Z","lat":52.52,"lon":13.405,"altMSL":34.5}` + "\n"))
+		<-fix
+	}()
+
+	c, err := NewClient(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if line := <-watched; line != watch {
+		t.Errorf("sent %q, want %q", line, watch)
+	}
+
+	// No fix yet
+	time.Sleep(10 * time.Millisecond)
+	if _, ok := c.Position(); ok {
+		t.Error("position reported without a fix")
+	}
+
+	fix <- struct{}{}
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if pos, ok := c.Position(); ok {
+			if pos.Latitude != 52.52 || pos.Longitude != 13.405 || pos.Altitude != 34.5 || !pos.Time.Equal(time.Date(2026, 10, 15, 9, 30, 0, 0, time.UTC)) {
+				t.Errorf("got %+v", pos)
+			}
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if _, ok := c.Position(); !ok {
+		t.Fatal("no position after a 3D fix")
+	}
+
+	// A fix goes stale, and is lost with the connection
+	c.MaxAge = time.Nanosecond
+	if _, ok := c.Position(); ok {
+		t.Error("stale fix reported")
+	}
+	c.MaxAge = 0
+	close(fix)
+	for time.Now().Before(deadline) && c.Err() == nil {
+		time.Sleep(time.Millisecond)
+	}
+	if _, ok := c.Position(); ok || c.Err() == nil {
+		t.Errorf("after disconnect: position still reported, or no error (%v)", c.Err())
+	}
+}
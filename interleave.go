@@ -0,0 +1,112 @@
+package pocsag
+
+// A fade corrupts codewords in a row, and BCH(31,21) repairs at most two
+// bits of each. WithInterleaving sends a message's codewords in blocks of
+// depth with their bits interleaved: the first bit of every codeword in the
+// block, then the second, and so on. A fade of up to 2×depth bits then
+// leaves at most two bad bits in each codeword, which the decoder repairs
+// once it has put the block back in order.
+//
+// The message starts with two flag codewords, sent as they are, that tell
+// decoders the rest is interleaved and how. The first carries 0xF5F5F,
+// which reads "[*[*[" as a numeric message and starts with "/_" as an
+// alphanumeric one, so ordinary pages hardly ever begin with it. The
+// second carries:
+//
+//	bits 30-26  depth - 1
+//	bits 25-18  number of interleaved codewords
+//	bits 17-11  zero
+const (
+	interleaveMagic    = 0xF5F5F
+	MaxInterleaveDepth = 32
+	maxInterleaved     = 255 // codewords one flag can announce
+)
+
+// interleaveMessage returns the address and message codewords of a message
+// with the message codewords interleaved in blocks of depth behind the flag
+// codewords. Messages too long for the flag are returned unchanged.
+func interleaveMessage(cws []uint32, depth int) []uint32 {
+	message := cws[1:]
+	if len(message) == 0 || len(message) > maxInterleaved {
+		return cws
+	}
+	out := []uint32{cws[0], dataCodeword(interleaveMagic), dataCodeword(uint32(depth-1)<<15 | uint32(len(message))<<7)}
+	for start := 0; start < len(message); start += depth {
+		out = append(out, interleaveBlock(message[start:min(start+depth, len(message))])...)
+	}
+	return out
+}
+
+// dataCodeword returns the message codeword carrying 20 data bits.
+func dataCodeword(data uint32) uint32 {
+	return CalculateEvenParity(CalculateBCH(1<<31 | data<<11))
+}
+
+// isInterleaveMagic reports whether cw is the first flag codeword.
+func isInterleaveMagic(cw uint32) bool {
+	return cw == dataCodeword(interleaveMagic)
+}
+
+// parseInterleaveFlag reports whether cw is a valid second flag codeword
+// and what it announces.
+func parseInterleaveFlag(cw uint32) (depth, count int, ok bool) {
+	data := cw >> 11 & 0xFFFFF
+	depth, count = int(data>>15)+1, int(data>>7&0xFF)
+	if cw&(1<<31) == 0 || data&0x7F != 0 || depth < 2 || count == 0 {
+		return 0, 0, false
+	}
+	return depth, count, true
+}
+
+// interleaveBlock sends bit j of codeword i of block as bit j×n+i of the
+// block's bits on air, n being the block's length.
+func interleaveBlock(block []uint32) []uint32 {
+	n := len(block)
+	out := make([]uint32, n)
+	for i, cw := range block {
+		for j := 0; j < CodewordBits; j++ {
+			if cw&(1<<(31-j)) != 0 {
+				p := j*n + i
+				out[p/32] |= 1 << (31 - p%32)
+			}
+		}
+	}
+	return out
+}
+
+// deinterleaveBlock undoes interleaveBlock.
+func deinterleaveBlock(block []uint32) []uint32 {
+	n := len(block)
+	out := make([]uint32, n)
+	for i := range out {
+		for j := 0; j < CodewordBits; j++ {
+			p := j*n + i
+			if block[p/32]&(1<<(31-p%32)) != 0 {
+				out[i] |= 1 << (31 - j)
+			}
+		}
+	}
+	return out
+}
+
+// deinterleaveMessage restores the codewords of an interleaved message
+// from the words received after its flag, of which lost are missing from
+// the end, and repairs up to two bits in each. bad marks the codewords
+// that could not be repaired or had bits in the missing words.
+func deinterleaveMessage(received []uint32, lost, depth int) (cws []uint32, bad []bool) {
+	words := append(received[:len(received):len(received)], make([]uint32, lost)...)
+	missing := make([]uint32, len(words))
+	for i := len(received); i < len(words); i++ {
+		missing[i] = 0xFFFFFFFF
+	}
+	for start := 0; start < len(words); start += depth {
+		end := min(start+depth, len(words))
+		gaps := deinterleaveBlock(missing[start:end])
+		for i, cw := range deinterleaveBlock(words[start:end]) {
+			fixed, ok := CorrectCodeword(cw)
+			cws = append(cws, fixed)
+			bad = append(bad, !ok || gaps[i] != 0 || fixed&(1<<31) == 0)
+		}
+	}
+	return cws, bad
+}
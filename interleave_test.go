@@ -0,0 +1,70 @@
+package pocsag
+
+import "testing"
+
+func TestCorrectCodeword(t *testing.T) {
+	if len(bchErrors) != 32+32*31/2 {
+		t.Fatalf("%d distinct error syndromes, want one per error", len(bchErrors))
+	}
+	cw := EncodeAddress(1234560, FuncAlphanumeric)
+	for i := 0; i < 32; i++ {
+		for j := i; j < 32; j++ {
+			if got, ok := CorrectCodeword(cw ^ 1<<i ^ 1<<j); !ok || got != cw {
+				t.Fatalf("bits %d and %d: got 0x%08X, %v", i, j, got, ok)
+			}
+		}
+	}
+	if _, ok := CorrectCodeword(cw ^ 0x700); ok {
+		t.Error("three bad bits repaired")
+	}
+}
+
+func TestInterleaving(t *testing.T) {
+	msgs := []MessageInfo{{Address: 8, Message: "FADE TEST ON THE LONG SIMULCAST PATH", Function: FuncAlphanumeric}}
+	enc := NewEncoder(WithInterleaving(8))
+	clean := enc.CreateBurst(msgs)
+	batches, _ := enc.layout(msgs)
+	depth, count, ok := parseInterleaveFlag(batches[0][2])
+	if !isInterleaveMagic(batches[0][1]) || !ok || depth != 8 || count != len(messageCodewords(msgs[0]))-1 {
+		t.Fatalf("flag codewords 0x%08X 0x%08X", batches[0][1], batches[0][2])
+	}
+
+	// Sixteen bits in a row, across the third and fourth codewords sent
+	// after the flag
+	fade := func(packet []byte) []byte {
+		faded := append([]byte(nil), packet...)
+		pos := PreambleLength/8 + 4 + 5*4 + 2
+		faded[pos] ^= 0xFF
+		faded[pos+1] ^= 0xFF
+		return faded
+	}
+	plain, _ := DecodeFromBinary(fade(CreatePOCSAGBurst(msgs)))
+	if len(plain) != 1 || !plain[0].Partial {
+		t.Fatalf("the fade does not hurt a plain page: %v", plain)
+	}
+
+	packet := fade(clean)
+	// Bad bits in the flag are repaired too
+	packet[PreambleLength/8+4+4] ^= 0x10
+	packet[PreambleLength/8+4+8] ^= 0x01
+	decoded, err := DecodeFromBinary(packet)
+	if err != nil || len(decoded) != 1 {
+		t.Fatalf("got %v, err %v", decoded, err)
+	}
+	if decoded[0].Message != msgs[0].Message || decoded[0].Partial {
+		t.Errorf("interleaved: %q partial=%v", decoded[0].Message, decoded[0].Partial)
+	}
+
+	// An ordinary page that starts like the flag is still text
+	decoded, _ = DecodeFromBinary(CreatePOCSAGPacket(16, "[*[*[ 123", FuncNumeric))
+	if len(decoded) != 1 || decoded[0].Message != "[*[*[ 123" {
+		t.Errorf("page starting with the magic: %v", decoded)
+	}
+
+	// A page cut short loses only the codewords of the blocks it hits
+	short := clean[:PreambleLength/8+4+(3+8)*4]
+	decoded, _ = DecodeFromBinary(short)
+	if len(decoded) != 1 || decoded[0].Message != msgs[0].Message[:22] || !decoded[0].Partial {
+		t.Errorf("cut short: %v", decoded)
+	}
+}
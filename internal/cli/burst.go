@@ -0,0 +1,726 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	pocsag "github.com/sqpp/pocsag-golang/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// burstMessage is one entry of the input file, whichever format it is in.
+type burstMessage struct {
+	Address     uint32 `json:"address" yaml:"address"`
+	Message     string `json:"message" yaml:"message"`
+	Function    uint8  `json:"function" yaml:"function"`
+	PayloadType string `json:"payload_type" yaml:"payload_type"`
+	Baud        int    `json:"baud,omitempty" yaml:"baud,omitempty"`
+	// Frequency tags the entry with an RF channel; see planChannels.
+	Frequency frequencyField `json:"frequency,omitempty" yaml:"frequency,omitempty"`
+	// Encrypt sends the entry encrypted, with the key KeyID names or the
+	// --key password; see encryptEntries.
+	Encrypt bool        `json:"encrypt,omitempty" yaml:"encrypt,omitempty"`
+	KeyID   *keyIDField `json:"key_id,omitempty" yaml:"key_id,omitempty"`
+}
+
+// keyIDField is a key ID given as a number or as a string such as "7".
+type keyIDField uint8
+
+func (k *keyIDField) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		s = string(data)
+	}
+	return k.parse(s)
+}
+
+func (k *keyIDField) UnmarshalYAML(node *yaml.Node) error {
+	return k.parse(node.Value)
+}
+
+func (k *keyIDField) parse(s string) error {
+	id, err := strconv.ParseUint(strings.TrimSpace(s), 10, 8)
+	if err != nil {
+		return fmt.Errorf("key_id must be 0 to 255, not %s", s)
+	}
+	*k = keyIDField(id)
+	return nil
+}
+
+// frequencyField is a frequency given as a number of Hz or as a string
+// parseFrequency accepts, such as "439.9875M".
+type frequencyField int64
+
+func (f *frequencyField) UnmarshalJSON(data []byte) error {
+	var hz int64
+	if err := json.Unmarshal(data, &hz); err == nil {
+		*f = frequencyField(hz)
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("frequency must be a number of Hz or a string such as \"439.9875M\"")
+	}
+	return f.parse(s)
+}
+
+func (f *frequencyField) UnmarshalYAML(node *yaml.Node) error {
+	return f.parse(node.Value)
+}
+
+func (f *frequencyField) parse(s string) error {
+	hz, err := parseFrequency(s)
+	*f = frequencyField(hz)
+	return err
+}
+
+func burstCommand(fs *flag.FlagSet) func() {
+	input := fs.String("input", "", "Input file with messages (JSON, YAML, or CSV), or - for stdin")
+	fs.StringVar(input, "i", "", "Input file with messages - short form")
+
+	jsonInput := fs.String("json", "", "JSON input file with message array (same as --input)")
+	fs.StringVar(jsonInput, "j", "", "JSON input file - short form")
+
+	inputFormat := fs.String("input-format", "auto", "Input format: auto, json, yaml, or csv")
+
+	defaultType := fs.String("type", "", "Payload type for entries that do not set one: numeric or alpha")
+
+	baudRate := baudFlag(fs)
+
+	audio := addAudioFlags(fs, "burst.wav")
+
+	layout := addLayoutFlags(fs)
+
+	jsonOutput := fs.Bool("json-output", false, "Output result as JSON")
+	fs.BoolVar(jsonOutput, "jo", false, "Output result as JSON - short form")
+
+	describe := fs.Bool("describe", false, "Print the batch/frame/codeword layout as JSON")
+	dryRun := fs.Bool("dry-run", false, "Check the input and report the layout and airtime without writing any file")
+
+	policyFile := policyFlag(fs)
+	translitFile := translitFlag(fs, "JSON file of custom spellings for characters outside ASCII, e.g. {\"ü\": \"ue\"}")
+
+	maxDuration := fs.Duration("max-duration", 0, "Send only the messages, from the top, that fit into this much airtime (e.g. 5s)")
+	leftoverFile := fs.String("leftover", "", "With --max-duration, write the messages that did not fit to this JSON file")
+
+	key := fs.String("key", "", "Password to encrypt entries with \"encrypt\": true and no key_id")
+	fs.StringVar(key, "k", "", "Encryption password - short form")
+	keyring := fs.String("keyring", "", "JSON file of key IDs and passwords, as pocsag-decode takes, to encrypt entries that name a key_id")
+	keySource := keySourceFlag(fs)
+	checksumName := checksumFlag(fs)
+
+	validate := fs.Bool("validate", false, "Check JSON input against the burst-input schema and report the line and field of any error")
+
+	version := versionFlag(fs)
+
+	return func() {
+		// Handle version flag
+		printVersion(*version)
+
+		if *input == "" {
+			*input = *jsonInput
+		}
+		if *input == "" {
+			usageError(nil, "input file required",
+				"",
+				"Usage examples:",
+				"  pocsag-burst --json messages.json --output burst.wav",
+				"  pocsag-burst -j messages.json -o burst.wav",
+				"  pocsag-burst -j messages.json --baud 512 -o burst.wav",
+				"  pocsag-burst -j messages.json -b 2400 -o burst.wav",
+				"  pocsag-burst -j messages.json --json-output",
+				"  pocsag-burst -i messages.yaml -o burst.wav",
+				"  pocsag-burst -i pages.csv --type alpha -o burst.wav",
+				"  pocsag-burst -i pages.json --keyring keys.json -o burst.wav",
+				"",
+				"JSON format:",
+				"  [",
+				"    {\"address\": 123456, \"message\": \"FIRST MESSAGE\", \"function\": 3, \"payload_type\": \"alpha\"},",
+				"    {\"address\": 789012, \"message\": \"SECOND MESSAGE\", \"function\": 3, \"payload_type\": \"alpha\"},",
+				"    {\"address\": 345678, \"message\": \"0123456789\", \"function\": 1, \"payload_type\": \"numeric\"},",
+				"    {\"address\": 901234, \"message\": \"SECRET\", \"function\": 3, \"payload_type\": \"alpha\", \"encrypt\": true, \"key_id\": 1}",
+				"  ]",
+				"",
+				"YAML format:",
+				"  - address: 123456",
+				"    message: FIRST MESSAGE",
+				"    function: 3",
+				"    payload_type: alpha",
+				"",
+				"CSV format (header optional; payload_type column optional with --type):",
+				"  address,message,function,baud",
+				"  123456,FIRST MESSAGE,3,1200")
+		}
+
+		checkBaud(*baudRate)
+
+		if *maxDuration < 0 {
+			fail(exitUsage, "--max-duration must not be negative")
+		}
+		if *leftoverFile != "" && *maxDuration == 0 {
+			fail(exitUsage, "--leftover needs --max-duration")
+		}
+
+		audioFileFormat, audioOpts := audio.parse(fs, *baudRate)
+		output := audio.output
+
+		// Read input file
+		inputData, err := readInput(*input)
+		if err != nil {
+			fail(exitIO, "reading input file: %v", err)
+		}
+
+		format := strings.ToLower(*inputFormat)
+		if format == "auto" {
+			format = detectInputFormat(*input, inputData)
+		}
+		if *validate {
+			if format != "json" {
+				fail(exitUsage, "--validate checks JSON input, not %s", strings.ToUpper(format))
+			}
+			if err := pocsag.ValidateBurstJSON(inputData); err != nil {
+				fail(exitEncode, "%s: %v", *input, err)
+			}
+		}
+		burstMessages, err := parseMessages(inputData, format)
+		if err != nil {
+			fail(exitEncode, "parsing %s: %v", strings.ToUpper(format), err)
+		}
+
+		messages, warnings, substitutions := prepareMessages(*policyFile, *translitFile, toMessageInfo(burstMessages, *defaultType))
+		// Policies see the text before it is encrypted
+		encryptEntries(burstMessages, messages, *key, loadKeyring(*keyring), loadKeyProvider(*keySource), parseChecksum(*checksumName))
+
+		// Entries may name a frequency, and those on different ones go out as
+		// separate bursts, each written to its own file.
+		plan, indices, err := planChannels(burstMessages, messages)
+		if err != nil {
+			fail(exitEncode, "%v", err)
+		}
+		multi := len(plan.Channels) > 1
+		if multi && *output == "-" && !*dryRun {
+			fail(exitUsage, "input names %d frequencies and each is written to its own file; give --output a file name", len(plan.Channels))
+		}
+		if multi && *maxDuration > 0 {
+			fail(exitUsage, "--max-duration needs input on a single frequency")
+		}
+
+		channels := make([]burstChannel, len(plan.Channels))
+		for i, ch := range plan.Channels {
+			// A burst goes out at one baud rate. Entries may name it, in which
+			// case they must agree with --baud when that is given.
+			if ch.BaudRate == 0 {
+				ch.BaudRate = *baudRate
+			} else {
+				if isSet(fs, "baud", "b") && ch.BaudRate != *baudRate {
+					fail(exitUsage, "input requests %d baud but --baud is %d", ch.BaudRate, *baudRate)
+				}
+				checkBaud(ch.BaudRate)
+				audio.checkSampleRate(ch.BaudRate)
+			}
+
+			c := burstChannel{
+				Channel: ch,
+				encoder: pocsag.NewEncoder(append(layout.options(), pocsag.WithBaudRate(ch.BaudRate))...),
+				output:  *output,
+			}
+			if multi {
+				c.output = channelOutput(*output, ch.FrequencyHz)
+			}
+			for _, idx := range indices[i] {
+				c.warnings = append(c.warnings, warnings[idx])
+				c.replaced = append(c.replaced, substitutions[idx])
+			}
+
+			// Generate burst
+			var packet []byte
+			if *maxDuration > 0 {
+				packet, c.leftover, err = c.encoder.EncodeWithBudget(c.Messages, *maxDuration)
+				if err != nil {
+					fail(exitEncode, "%v", err)
+				}
+				c.Messages = c.Messages[:len(c.Messages)-len(c.leftover)]
+			} else {
+				packet = c.encoder.CreateBurst(c.Messages)
+			}
+			if *dryRun {
+				channels[i] = c
+				continue
+			}
+			if *leftoverFile != "" {
+				if err := writeLeftover(*leftoverFile, c.leftover, plan.Channels[i]); err != nil {
+					fail(exitIO, "writing leftover messages: %v", err)
+				}
+			}
+			c.wavData = pocsag.ConvertToAudioWithOptions(packet, c.BaudRate, audioOpts...)
+
+			c.audioData, err = pocsag.EncodeAudio(c.wavData, audioFileFormat)
+			if err != nil {
+				fail(exitEncode, "%v", err)
+			}
+
+			// Write to file
+			if err := writeOutput(c.output, c.audioData); err != nil {
+				fail(exitIO, "writing file: %v", err)
+			}
+			channels[i] = c
+		}
+
+		// When the WAV goes to stdout, machine-readable reports move to stderr
+		// and the human-readable summary is suppressed.
+		report := os.Stdout
+		if *output == "-" {
+			report = os.Stderr
+		}
+
+		// Output result
+		if *dryRun {
+			descs := make([]pocsag.TransmissionDescription, len(channels))
+			for i, c := range channels {
+				descs[i] = c.describe()
+			}
+			switch {
+			case !*describe && !*jsonOutput:
+				for _, c := range channels {
+					for i := range c.Messages {
+						warnSubstitutions(fmt.Sprintf("message %d: ", i+1), c.replaced[i])
+					}
+				}
+				printDryRun(descs)
+			case multi:
+				printJSON(os.Stdout, descs)
+			default:
+				printJSON(os.Stdout, descs[0])
+			}
+			return
+		}
+		switch {
+		case *describe && multi:
+			descs := make([]pocsag.TransmissionDescription, len(channels))
+			for i, c := range channels {
+				descs[i] = c.describe()
+			}
+			printJSON(report, descs)
+		case *describe:
+			printJSON(report, channels[0].describe())
+		case *jsonOutput && multi:
+			jsonChannels := make([]map[string]interface{}, len(channels))
+			count := 0
+			for i, c := range channels {
+				jsonChannels[i] = c.jsonResult()
+				count += len(c.Messages)
+			}
+			printJSON(report, map[string]interface{}{
+				"success":  true,
+				"format":   string(audioFileFormat),
+				"count":    count,
+				"channels": jsonChannels,
+			})
+		case *jsonOutput:
+			result := channels[0].jsonResult()
+			result["success"] = true
+			result["format"] = string(audioFileFormat)
+			if *maxDuration > 0 {
+				result["leftover"] = len(channels[0].leftover)
+			}
+			printJSON(report, result)
+		case *output != "-":
+			for _, c := range channels {
+				c.printSummary()
+			}
+			if leftover := channels[0].leftover; len(leftover) > 0 {
+				fmt.Printf("   %d messages did not fit into %v", len(leftover), *maxDuration)
+				if *leftoverFile != "" {
+					fmt.Printf("; saved to %s", *leftoverFile)
+				}
+				fmt.Println()
+			}
+		}
+	}
+}
+
+// toMessageInfo converts input entries, giving those without a payload
+// type defaultType.
+func toMessageInfo(entries []burstMessage, defaultType string) []pocsag.MessageInfo {
+	messages := make([]pocsag.MessageInfo, len(entries))
+	for i, bm := range entries {
+		if bm.PayloadType == "" {
+			bm.PayloadType = defaultType
+		}
+		payloadType := normalizePayloadType(bm.PayloadType)
+		if payloadType == "" {
+			fail(exitEncode, "Invalid payload_type for message %d. Supported types: numeric, alpha", i+1)
+		}
+		messages[i] = pocsag.MessageInfo{
+			Address:     bm.Address,
+			Message:     bm.Message,
+			Function:    bm.Function,
+			PayloadType: payloadType,
+		}
+	}
+	return messages
+}
+
+// encryptEntries encrypts the messages of the entries marked encrypt, in
+// place. An entry naming a key_id is encrypted with that key, found by
+// address in store, then from provider, then derived from password, and
+// starts with a PayloadHeader naming it; others use password without a
+// header. 16-byte keys use AES-128 and others AES-256, and numeric entries
+// FF1, without a header. Entries with a header are sealed with checksum.
+func encryptEntries(entries []burstMessage, messages []pocsag.MessageInfo, password string, store *pocsag.KeyStore, provider pocsag.KeyProvider, checksum pocsag.Checksum) {
+	configs := make([]pocsag.EncryptionConfig, len(entries))
+	for i, bm := range entries {
+		if !bm.Encrypt {
+			if bm.KeyID != nil {
+				fail(exitEncode, "message %d: key_id needs \"encrypt\": true", i+1)
+			}
+			continue
+		}
+
+		config := pocsag.EncryptionConfig{Method: pocsag.EncryptionAES256}
+		// Numeric pages stay numeric with FF1, which has no header, so
+		// key_id only picks the key for them
+		numeric := messages[i].PayloadType == pocsag.PayloadTypeNumeric
+		if numeric {
+			config.Method = pocsag.EncryptionFF1
+		}
+		if bm.KeyID == nil {
+			if password == "" {
+				fail(exitUsage, "message %d is encrypted without a key_id; give --key", i+1)
+			}
+			config.Key = pocsag.KeyFromPassword(password, 32)
+		} else {
+			id := uint8(*bm.KeyID)
+			config.Header, config.KeyID = !numeric, id
+			if config.Header {
+				config.Checksum = checksum
+			}
+			if store != nil {
+				config.Key = store.Keyring(bm.Address, time.Now())[id]
+			}
+			if config.Key == nil && provider != nil {
+				config.Key, _ = provider.Key(id)
+			}
+			if config.Key == nil && password != "" {
+				config.Key = pocsag.KeyFromPassword(password, 32)
+			}
+			if config.Key == nil {
+				fail(exitUsage, "message %d: no key %d for address %d; give --keyring, --key-source, or --key", i+1, id, bm.Address)
+			}
+			if len(config.Key) == 16 && !numeric {
+				config.Method = pocsag.EncryptionAES128
+			}
+		}
+		configs[i] = config
+	}
+
+	encrypted, err := pocsag.EncryptMessages(messages, configs...)
+	if err != nil {
+		fail(exitEncode, "%v", err)
+	}
+	copy(messages, encrypted)
+}
+
+// burstChannel is the burst written for one channel of the input.
+type burstChannel struct {
+	pocsag.Channel
+	encoder   *pocsag.Encoder // layout and baud rate
+	output    string
+	warnings  [][]string              // per message, for the changes made before sending
+	replaced  [][]pocsag.Substitution // per message, the characters substituted
+	leftover  []pocsag.MessageInfo
+	wavData   []byte
+	audioData []byte
+}
+
+func (c burstChannel) describe() pocsag.TransmissionDescription {
+	desc := c.encoder.Describe(c.Messages)
+	desc.FrequencyHz = c.FrequencyHz
+	return desc
+}
+
+// jsonResult reports where each message went and how long it takes, as
+// sent rather than as given.
+func (c burstChannel) jsonResult() map[string]interface{} {
+	desc := c.describe()
+	jsonMessages := make([]map[string]interface{}, len(c.Messages))
+	for i, md := range desc.Messages {
+		jsonMessages[i] = map[string]interface{}{
+			"address":       md.Address,
+			"message":       md.Message,
+			"function":      md.Function,
+			"type":          displayPayloadType(md.PayloadType),
+			"batch":         md.Batch,
+			"frame":         md.Frame,
+			"codewords":     md.Codewords,
+			"airtime_s":     md.AirtimeSec,
+			"warnings":      c.warnings[i],
+			"substitutions": substitutionsJSON(c.replaced[i]),
+		}
+	}
+	result := map[string]interface{}{
+		"output":     c.output,
+		"messages":   jsonMessages,
+		"baud":       c.BaudRate,
+		"count":      len(c.Messages),
+		"size":       len(c.audioData),
+		"duration_s": pocsag.WAVDuration(c.wavData).Seconds(),
+	}
+	if c.FrequencyHz != 0 {
+		result["frequency_hz"] = c.FrequencyHz
+	}
+	return result
+}
+
+func (c burstChannel) printSummary() {
+	on := ""
+	if c.FrequencyHz != 0 {
+		on = fmt.Sprintf(" on %s MHz", strconv.FormatFloat(float64(c.FrequencyHz)/1e6, 'f', -1, 64))
+	}
+	fmt.Printf("✅ Generated burst with %d messages%s: %s (baud: %d)\n", len(c.Messages), on, c.output, c.BaudRate)
+	fmt.Printf("   Size: %d bytes, Duration: %.2f s\n", len(c.audioData), pocsag.WAVDuration(c.wavData).Seconds())
+	for i, msg := range c.Messages {
+		msgType := "ALPHA"
+		if displayPayloadType(msg.PayloadType) == "numeric" {
+			msgType = "NUMERIC"
+		}
+		fmt.Printf("   %d. Address: %d, Type: %s, Message: %s\n", i+1, msg.Address, msgType, msg.Message)
+		warnSubstitutions(fmt.Sprintf("message %d: ", i+1), c.replaced[i])
+	}
+}
+
+// planChannels groups messages, which correspond to entries, by the
+// frequency their entries name. A channel's baud rate is the one its
+// entries request, or 0 if none do. indices lists the entries of each
+// channel.
+func planChannels(entries []burstMessage, messages []pocsag.MessageInfo) (plan pocsag.ChannelPlan, indices [][]int, err error) {
+	byFrequency := make(map[int64][]burstMessage)
+	var order []int64
+	for i, m := range entries {
+		freq := int64(m.Frequency)
+		switch first := entries[0].Frequency; {
+		case freq == 0 && first != 0:
+			return plan, nil, fmt.Errorf("message %d names no frequency but message 1 does", i+1)
+		case freq != 0 && first == 0:
+			return plan, nil, fmt.Errorf("message %d names a frequency but message 1 does not", i+1)
+		}
+		if _, ok := byFrequency[freq]; !ok {
+			order = append(order, freq)
+		}
+		byFrequency[freq] = append(byFrequency[freq], m)
+	}
+	baud := make(map[int64]int, len(order))
+	for _, freq := range order {
+		if baud[freq], err = commonBaud(byFrequency[freq]); err != nil {
+			if len(order) > 1 {
+				err = fmt.Errorf("%s MHz: %v", strconv.FormatFloat(float64(freq)/1e6, 'f', -1, 64), err)
+			}
+			return plan, nil, err
+		}
+	}
+	for i, m := range entries {
+		freq := int64(m.Frequency)
+		ch := plan.Add(freq, baud[freq], messages[i])
+		if ch == len(indices) {
+			indices = append(indices, nil)
+		}
+		indices[ch] = append(indices[ch], i)
+	}
+	return plan, indices, nil
+}
+
+// channelOutput names the file for the channel at freq, e.g. burst.wav
+// becomes burst-439.9875M.wav.
+func channelOutput(output string, freq int64) string {
+	ext := filepath.Ext(output)
+	return fmt.Sprintf("%s-%sM%s", strings.TrimSuffix(output, ext), strconv.FormatFloat(float64(freq)/1e6, 'f', -1, 64), ext)
+}
+
+// writeLeftover saves messages as burst input JSON, so they can be sent
+// by the next run. An empty queue is written as [].
+func writeLeftover(path string, messages []pocsag.MessageInfo, ch pocsag.Channel) error {
+	entries := make([]burstMessage, len(messages))
+	for i, msg := range messages {
+		entries[i] = burstMessage{
+			Address:     msg.Address,
+			Message:     msg.Message,
+			Function:    msg.Function,
+			PayloadType: msg.PayloadType,
+			Baud:        ch.BaudRate,
+			Frequency:   frequencyField(ch.FrequencyHz),
+		}
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// detectInputFormat picks json, yaml, or csv from the file extension, or
+// from the content when the extension says nothing.
+func detectInputFormat(path string, data []byte) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return "json"
+	case ".yaml", ".yml":
+		return "yaml"
+	case ".csv":
+		return "csv"
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '{') {
+		return "json"
+	}
+	firstLine := string(trimmed)
+	if i := strings.IndexByte(firstLine, '\n'); i >= 0 {
+		firstLine = firstLine[:i]
+	}
+	if strings.HasPrefix(firstLine, "-") || strings.HasPrefix(firstLine, "#") || strings.Contains(firstLine, ": ") {
+		return "yaml"
+	}
+	return "csv"
+}
+
+func parseMessages(data []byte, format string) ([]burstMessage, error) {
+	var messages []burstMessage
+	switch format {
+	case "json":
+		if err := json.Unmarshal(data, &messages); err != nil {
+			return nil, err
+		}
+	case "yaml", "yml":
+		if err := yaml.Unmarshal(data, &messages); err != nil {
+			return nil, err
+		}
+	case "csv":
+		return parseCSVMessages(data)
+	default:
+		return nil, fmt.Errorf("unknown input format %q (supported: auto, json, yaml, csv)", format)
+	}
+	return messages, nil
+}
+
+// csvColumns is the column order used when a CSV file has no header row.
+var csvColumns = []string{"address", "message", "function", "baud", "payload_type", "frequency", "encrypt", "key_id"}
+
+// parseCSVMessages reads address,message,function,baud[,payload_type[,frequency[,encrypt[,key_id]]]]
+// rows. A first row naming the columns may reorder them or leave some out;
+// blank function, baud, frequency, encrypt, and key_id cells mean 0,
+// "use --baud", none, false, and none.
+func parseCSVMessages(data []byte) ([]burstMessage, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	columns := csvColumns
+	if len(rows) > 0 && strings.EqualFold(strings.TrimSpace(rows[0][0]), "address") {
+		columns = make([]string, len(rows[0]))
+		for i, name := range rows[0] {
+			columns[i] = strings.ToLower(strings.TrimSpace(name))
+			if columns[i] == "type" {
+				columns[i] = "payload_type"
+			}
+		}
+		rows = rows[1:]
+	}
+
+	messages := make([]burstMessage, 0, len(rows))
+	for n, row := range rows {
+		if len(row) > len(columns) {
+			return nil, fmt.Errorf("row %d: %d fields, expected at most %d", n+1, len(row), len(columns))
+		}
+		var m burstMessage
+		for i, value := range row {
+			value = strings.TrimSpace(value)
+			switch columns[i] {
+			case "address":
+				v, err := strconv.ParseUint(value, 10, 32)
+				if err != nil {
+					return nil, fmt.Errorf("row %d: invalid address %q", n+1, value)
+				}
+				m.Address = uint32(v)
+			case "message":
+				m.Message = value
+			case "function":
+				if value == "" {
+					continue
+				}
+				v, err := strconv.ParseUint(value, 10, 8)
+				if err != nil || v > 3 {
+					return nil, fmt.Errorf("row %d: invalid function %q", n+1, value)
+				}
+				m.Function = uint8(v)
+			case "baud":
+				if value == "" {
+					continue
+				}
+				v, err := strconv.Atoi(value)
+				if err != nil {
+					return nil, fmt.Errorf("row %d: invalid baud %q", n+1, value)
+				}
+				m.Baud = v
+			case "payload_type":
+				m.PayloadType = value
+			case "frequency":
+				if value == "" {
+					continue
+				}
+				if err := m.Frequency.parse(value); err != nil {
+					return nil, fmt.Errorf("row %d: %v", n+1, err)
+				}
+			case "encrypt":
+				if value == "" {
+					continue
+				}
+				v, err := strconv.ParseBool(value)
+				if err != nil {
+					return nil, fmt.Errorf("row %d: invalid encrypt %q", n+1, value)
+				}
+				m.Encrypt = v
+			case "key_id":
+				if value == "" {
+					continue
+				}
+				m.KeyID = new(keyIDField)
+				if err := m.KeyID.parse(value); err != nil {
+					return nil, fmt.Errorf("row %d: %v", n+1, err)
+				}
+			default:
+				return nil, fmt.Errorf("unknown CSV column %q", columns[i])
+			}
+		}
+		messages = append(messages, m)
+	}
+	return messages, nil
+}
+
+// commonBaud returns the baud rate requested by the entries that set one,
+// or 0 if none do.
+func commonBaud(messages []burstMessage) (int, error) {
+	baud := 0
+	for i, m := range messages {
+		if m.Baud == 0 {
+			continue
+		}
+		if baud != 0 && m.Baud != baud {
+			return 0, fmt.Errorf("message %d requests %d baud but earlier messages use %d; a burst has a single baud rate", i+1, m.Baud, baud)
+		}
+		baud = m.Baud
+	}
+	return baud, nil
+}
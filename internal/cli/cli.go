@@ -0,0 +1,170 @@
+// Package cli implements the pocsag command-line tools. Each tool is a
+// Command: the pocsag binary runs them as subcommands, and the older
+// single-purpose binaries (pocsag-decode, pocsag-burst, ...) run one
+// directly.
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	pocsag "github.com/sqpp/pocsag-golang/v2"
+)
+
+// Command is one pocsag subcommand.
+type Command struct {
+	Name    string // subcommand name, e.g. "decode"
+	Binary  string // standalone binary kept for compatibility, e.g. "pocsag-decode"; empty if there is none
+	Summary string // one line for help output and man pages
+
+	jsonFlag  string // flag that selects JSON output, for reporting flag errors
+	jsonLines bool   // JSON output is one object per line
+
+	// setup defines the command's flags on fs and returns the function
+	// that runs the command once fs has been parsed.
+	setup func(fs *flag.FlagSet) func()
+}
+
+var (
+	Encode   = &Command{Name: "encode", Binary: "pocsag", Summary: "Encode a page to a WAV (or FLAC/MP3/Opus) file", setup: encodeCommand, jsonFlag: "json"}
+	Burst    = &Command{Name: "burst", Binary: "pocsag-burst", Summary: "Encode several pages from a JSON, YAML, or CSV file into one transmission", setup: burstCommand, jsonFlag: "json-output"}
+	Decode   = &Command{Name: "decode", Binary: "pocsag-decode", Summary: "Decode pages from a WAV recording", setup: decodeCommand, jsonFlag: "json"}
+	Monitor  = &Command{Name: "monitor", Binary: "pocsag-rx", Summary: "Receive and decode pages live from an RTL-SDR over rtl_tcp or a sound card", setup: monitorCommand, jsonFlag: "json", jsonLines: true}
+	HackRF   = &Command{Name: "hackrf", Binary: "pocsag-hackrf", Summary: "Transmit a page with a HackRF via hackrf_transfer", setup: hackrfCommand, jsonFlag: "json"}
+	Replay   = &Command{Name: "replay", Binary: "pocsag-replay", Summary: "Re-encode archived pages into one recording with their original timing", setup: replayCommand, jsonFlag: "json"}
+	Diff     = &Command{Name: "diff", Binary: "pocsag-diff", Summary: "Compare two transmissions codeword by codeword, with BCH error checks", setup: diffCommand, jsonFlag: "json"}
+	SelfTest = &Command{Name: "selftest", Summary: "Encode test pages at every baud and polarity, decode them back, and report pass or fail", setup: selftestCommand, jsonFlag: "json"}
+)
+
+// Commands lists the subcommands in the order help shows them.
+var Commands = []*Command{Encode, Burst, Decode, Monitor, HackRF, Replay, Diff, SelfTest}
+
+// standalone reports whether the command also has a binary of its own
+// besides pocsag.
+func (c *Command) standalone() bool {
+	return c.Binary != "" && c.Binary != "pocsag"
+}
+
+// FlagSet returns the command's flags without running it, for generating
+// completions and man pages.
+func (c *Command) FlagSet(prog string) *flag.FlagSet {
+	fs := flag.NewFlagSet(prog, flag.ExitOnError)
+	c.setup(fs)
+	return fs
+}
+
+// Run parses args and runs the command. prog is the name shown in usage
+// messages, e.g. "pocsag decode" or "pocsag-decode".
+func (c *Command) Run(prog string, args []string) {
+	fs := flag.NewFlagSet(prog, flag.ContinueOnError)
+	run := c.setup(fs)
+	fs.Usage = func() {
+		out := fs.Output()
+		fmt.Fprintf(out, "%s\n\nUsage: %s [flags]\n\nFlags:\n", c.Summary, prog)
+		fs.PrintDefaults()
+	}
+
+	// Parse quietly so that a bad flag can be reported as JSON
+	fs.SetOutput(io.Discard)
+	err := fs.Parse(args)
+	fs.SetOutput(os.Stderr)
+	switch {
+	case err == flag.ErrHelp:
+		fs.Usage()
+		os.Exit(0)
+	case err != nil:
+		if jsonRequested(fs, c.jsonFlag, args) {
+			reportErrorsAsJSON(os.Stdout, c.jsonLines)
+			fail(exitUsage, "%v", err)
+		}
+		fmt.Fprintln(os.Stderr, err)
+		fs.Usage()
+		os.Exit(exitUsage)
+	}
+	out := fs.Lookup("output")
+	if f := fs.Lookup(c.jsonFlag); f != nil && f.Value.String() == "true" || c.jsonLines && out != nil && out.Value.String() == "ndjson" {
+		// Errors go where the JSON result would, which is stderr when the
+		// audio itself is written to stdout
+		w := io.Writer(os.Stdout)
+		if out != nil && out.Value.String() == "-" {
+			w = os.Stderr
+		}
+		reportErrorsAsJSON(w, c.jsonLines)
+	}
+	run()
+}
+
+// Main runs the pocsag binary. The first argument names the subcommand;
+// arguments that start with a flag go to encode, so command lines written
+// for the original pocsag encoder keep working.
+func Main(args []string) {
+	if len(args) == 0 {
+		usage()
+		os.Exit(exitUsage)
+	}
+
+	switch name := args[0]; name {
+	case "-h", "-help", "--help", "help":
+		if len(args) > 1 {
+			if c := lookup(args[1]); c != nil {
+				c.Run("pocsag "+c.Name, []string{"-h"})
+			}
+			fail(exitUsage, "unknown command %q", args[1])
+		}
+		usage()
+	case "completion":
+		if len(args) != 2 {
+			fmt.Fprintln(os.Stderr, "Usage: pocsag completion bash|zsh|fish")
+			os.Exit(exitUsage)
+		}
+		if err := WriteCompletion(os.Stdout, args[1]); err != nil {
+			fail(exitUsage, "%v", err)
+		}
+	case "man":
+		dir := "."
+		if len(args) > 1 {
+			dir = args[1]
+		}
+		if err := WriteManPages(dir); err != nil {
+			fail(exitIO, "%v", err)
+		}
+	default:
+		if strings.HasPrefix(name, "-") {
+			Encode.Run("pocsag", args)
+			return
+		}
+		c := lookup(name)
+		if c == nil {
+			fmt.Fprintf(os.Stderr, "Error: unknown command %q\n\n", name)
+			usage()
+			os.Exit(exitUsage)
+		}
+		c.Run("pocsag "+c.Name, args[1:])
+	}
+}
+
+func lookup(name string) *Command {
+	for _, c := range Commands {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "pocsag %s - POCSAG pager encoder and decoder\n\n", pocsag.Version)
+	fmt.Fprintln(os.Stderr, "Usage: pocsag <command> [flags]")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+	for _, c := range Commands {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", c.Name, c.Summary)
+	}
+	fmt.Fprintf(os.Stderr, "  %-12s %s\n", "completion", "Print a bash, zsh, or fish completion script")
+	fmt.Fprintf(os.Stderr, "  %-12s %s\n", "man", "Write man pages to a directory (default: current)")
+	fmt.Fprintf(os.Stderr, "  %-12s %s\n", "help", "Show help for a command")
+	fmt.Fprintln(os.Stderr, "\nRun 'pocsag <command> -h' for the flags of a command.")
+	fmt.Fprintln(os.Stderr, "Flags without a command run encode: pocsag -a 123456 -m \"HELLO\" --type alpha")
+}
@@ -0,0 +1,191 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pocsag "github.com/sqpp/pocsag-golang/v2"
+)
+
+func TestFlagInfosGroupsAliases(t *testing.T) {
+	var input *flagInfo
+	for _, f := range flagInfos(Decode.FlagSet("pocsag-decode")) {
+		if f.Names[0] == "input" {
+			f := f
+			input = &f
+		}
+	}
+	if input == nil {
+		t.Fatal("no --input flag")
+	}
+	if strings.Join(input.Spellings(), " ") != "--input -i" || input.IsBool {
+		t.Errorf("input flag %+v", *input)
+	}
+	if strings.Contains(input.Usage, "short form") {
+		t.Errorf("usage taken from the alias: %q", input.Usage)
+	}
+}
+
+func TestCompletionAndManPages(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		var b strings.Builder
+		if err := WriteCompletion(&b, shell); err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range []string{"monitor", "pocsag-decode", "webhook-match", "chain"} {
+			if !strings.Contains(b.String(), want) {
+				t.Errorf("%s completion lacks %q", shell, want)
+			}
+		}
+		if !strings.Contains(b.String(), "selftest") || strings.Contains(b.String(), "pocsag-selftest") {
+			t.Errorf("%s completion should offer selftest only as a subcommand", shell)
+		}
+	}
+	if err := WriteCompletion(&strings.Builder{}, "tcsh"); err == nil {
+		t.Error("unknown shell accepted")
+	}
+
+	dir := t.TempDir()
+	if err := WriteManPages(dir); err != nil {
+		t.Fatal(err)
+	}
+	page, err := os.ReadFile(filepath.Join(dir, "pocsag-burst.1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(page), `\fB\-\-input\-format\fR \fIvalue\fR`) || !strings.Contains(string(page), ".B pocsag\\-burst") {
+		t.Errorf("burst man page:\n%s", page)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "pocsag.1")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMessageJSONMatchesSchemas(t *testing.T) {
+	msg := messageJSON(pocsag.DecodedMessage{Address: 1234, Function: 3, Message: "HELLO", Partial: true, Codewords: []uint32{0x0789182E}})
+	stats := pocsag.DecodeStats{Batches: 2, ClockDriftPPM: -3.5, EyeOpening: 0.9}
+	decode, _ := json.Marshal(map[string]interface{}{"success": true, "messages": []interface{}{msg}, "baud": 1200, "stats": stats})
+	if err := pocsag.ValidateJSON(pocsag.SchemaDecodeOutput, decode); err != nil {
+		t.Errorf("decode output: %v", err)
+	}
+
+	msg["time"] = "2026-01-01T00:00:00Z"
+	msg["baud"] = 512
+	line, _ := json.Marshal(msg)
+	if err := pocsag.ValidateJSON(pocsag.SchemaMonitorMessage, line); err != nil {
+		t.Errorf("monitor line: %v", err)
+	}
+}
+
+func TestJSONRequested(t *testing.T) {
+	fs := Burst.FlagSet("pocsag-burst")
+	for _, tt := range []struct {
+		args []string
+		want bool
+	}{
+		{[]string{"--json-output", "--bogus"}, true},
+		{[]string{"-jo"}, true},
+		{[]string{"--json-output=false"}, false},
+		{[]string{"-j", "pages.json"}, false}, // burst's -j names the input
+		{[]string{"--", "--json-output"}, false},
+	} {
+		if got := jsonRequested(fs, Burst.jsonFlag, tt.args); got != tt.want {
+			t.Errorf("jsonRequested(%q) = %v, want %v", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestPrepareMessagesWarnings(t *testing.T) {
+	policy := filepath.Join(t.TempDir(), "policy.json")
+	if err := os.WriteFile(policy, []byte(`{"max_length": 10, "truncate": true}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	messages, warnings, substitutions := prepareMessages(policy, "", []pocsag.MessageInfo{
+		{Address: 8, Message: "Café Ω", PayloadType: pocsag.PayloadTypeAlpha},
+		{Address: 16, Message: "MUCH TOO LONG", PayloadType: pocsag.PayloadTypeAlpha},
+		{Address: 24, Message: "OK", PayloadType: pocsag.PayloadTypeAlpha},
+	})
+	want := [][]string{
+		{"1 character transliterated to ASCII", "1 character with no ASCII spelling sent as ?"},
+		{"truncated from 13 to 10 characters"},
+		{},
+	}
+	if !reflect.DeepEqual(warnings, want) {
+		t.Errorf("warnings %q, want %q", warnings, want)
+	}
+	wantSubs := [][]pocsag.Substitution{
+		{{Offset: 3, Original: 'é', Replacement: "e"}, {Offset: 6, Original: 'Ω', Replacement: "?"}},
+		nil,
+		nil,
+	}
+	if !reflect.DeepEqual(substitutions, wantSubs) {
+		t.Errorf("substitutions %v, want %v", substitutions, wantSubs)
+	}
+	if messages[0].Message != "Cafe ?" || messages[1].Message != "MUCH TOO L" {
+		t.Errorf("messages %q, %q", messages[0].Message, messages[1].Message)
+	}
+}
+
+func TestEncryptEntries(t *testing.T) {
+	for format, input := range map[string]string{
+		"json": `[{"address": 8, "message": "CLEAR"}, {"address": 16, "message": "SECRET", "encrypt": true, "key_id": "5"}, {"address": 24, "message": "ALSO SECRET", "encrypt": true}]`,
+		"yaml": "- {address: 8, message: CLEAR}\n- {address: 16, message: SECRET, encrypt: true, key_id: 5}\n- {address: 24, message: ALSO SECRET, encrypt: true}\n",
+		"csv":  "address,message,encrypt,key_id\n8,CLEAR,,\n16,SECRET,true,5\n24,ALSO SECRET,true,\n",
+	} {
+		entries, err := parseMessages([]byte(input), format)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		messages := toMessageInfo(entries, "alpha")
+		store := pocsag.NewKeyStore()
+		store.Add(16, 5, pocsag.KeyFromPassword("ward-5", 32))
+		encryptEntries(entries, messages, "shared", store, nil, pocsag.ChecksumCRC32)
+
+		if messages[0].Message != "CLEAR" {
+			t.Errorf("%s: clear entry sent as %q", format, messages[0].Message)
+		}
+		ring := pocsag.Keyring{5: pocsag.KeyFromPassword("ward-5", 32)}
+		if got, err := pocsag.DecryptWithKeyring(messages[1].Message, ring); err != nil || got != "SECRET" {
+			t.Errorf("%s: key_id entry decrypts to %q, %v", format, got, err)
+		}
+		shared := pocsag.EncryptionConfig{Method: pocsag.EncryptionAES256, Key: pocsag.KeyFromPassword("shared", 32)}
+		if got, err := pocsag.DecryptMessage(messages[2].Message, shared); err != nil || got != "ALSO SECRET" {
+			t.Errorf("%s: --key entry decrypts to %q, %v", format, got, err)
+		}
+	}
+}
+
+func TestWebhookQueueDoesNotBlock(t *testing.T) {
+	release := make(chan struct{})
+	var delivered atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		delivered.Add(1)
+	}))
+	defer server.Close()
+
+	webhook := pocsag.NewWebhook(pocsag.WebhookConfig{URL: server.URL, MaxRetries: 0})
+	q := startWebhookQueue(context.Background(), webhook, 2)
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		q.send(pocsag.DecodedMessage{Address: uint32(i), Message: "TEST"})
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("send waited %v for a stalled endpoint", elapsed)
+	}
+	close(release)
+	q.close()
+	// The delivery under way and a full queue go out; the rest are dropped
+	if n := delivered.Load(); n < 1 || n > 3 {
+		t.Errorf("delivered %d messages, want 1 to 3", n)
+	}
+}
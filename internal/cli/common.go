@@ -0,0 +1,368 @@
+package cli
+
+import (
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	pocsag "github.com/sqpp/pocsag-golang/v2"
+)
+
+// readInput reads path, or stdin when path is "-".
+func readInput(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
+
+// captureMessages decodes audio from a sound card, passing each message to
+// emit, until ctx is done or the recorder fails.
+func captureMessages(ctx context.Context, device string, baudRate int, opts pocsag.DecodeOptions, emit func(pocsag.DecodedMessage)) error {
+	capture, err := pocsag.OpenCapture(device, pocsag.SampleRate)
+	if err != nil {
+		return err
+	}
+	defer capture.Close()
+	go func() {
+		// Unblock the read loop on Ctrl-C
+		<-ctx.Done()
+		capture.Close()
+	}()
+
+	decoder := pocsag.NewStreamDecoder(capture.SampleRate(), baudRate, opts)
+	buf := make([]float32, 4096)
+	for {
+		n, err := capture.Read(buf)
+		for _, msg := range decoder.Write(buf[:n]) {
+			emit(msg)
+		}
+		if err != nil {
+			for _, msg := range decoder.Flush() {
+				emit(msg)
+			}
+			if ctx.Err() != nil || err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// webhookQueueSize is how many messages wait for the webhook while an
+// earlier delivery is still retrying.
+const webhookQueueSize = 256
+
+// webhookQueue delivers messages to a webhook from a goroutine of its own,
+// so that a slow or unreachable endpoint does not hold up a live receiver.
+// Messages that arrive while the queue is full are dropped with a warning.
+type webhookQueue struct {
+	messages chan pocsag.DecodedMessage
+	done     chan struct{}
+}
+
+// startWebhookQueue starts delivering to webhook until close. ctx cuts
+// short the delivery under way, and its retries, when it is done.
+func startWebhookQueue(ctx context.Context, webhook *pocsag.Webhook, size int) *webhookQueue {
+	q := &webhookQueue{
+		messages: make(chan pocsag.DecodedMessage, size),
+		done:     make(chan struct{}),
+	}
+	go func() {
+		defer close(q.done)
+		for msg := range q.messages {
+			if err := webhook.Notify(ctx, msg); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: webhook delivery failed for address %d: %v\n", msg.Address, err)
+			}
+		}
+	}()
+	return q
+}
+
+// send queues msg for delivery without waiting.
+func (q *webhookQueue) send(msg pocsag.DecodedMessage) {
+	select {
+	case q.messages <- msg:
+	default:
+		fmt.Fprintf(os.Stderr, "Warning: webhook queue full, dropped the message for address %d\n", msg.Address)
+	}
+}
+
+// close delivers the messages still queued and waits for the last one.
+func (q *webhookQueue) close() {
+	close(q.messages)
+	<-q.done
+}
+
+// writeOutput writes data to path, or to stdout when path is "-" so the
+// audio can be piped straight into aplay, sox, or ffmpeg.
+func writeOutput(path string, data []byte) error {
+	if path == "-" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func parseWAVFormat(format string) (pocsag.WAVFormat, bool) {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "", "pcm16", "pcm":
+		return pocsag.WAVPCM16, true
+	case "float32", "float":
+		return pocsag.WAVFloat32, true
+	default:
+		return pocsag.WAVPCM16, false
+	}
+}
+
+func normalizePayloadType(payloadType string) string {
+	switch strings.ToLower(strings.TrimSpace(payloadType)) {
+	case "":
+		return ""
+	case "numeric":
+		return pocsag.PayloadTypeNumeric
+	case "alpha", "alphanumeric":
+		return pocsag.PayloadTypeAlpha
+	default:
+		return ""
+	}
+}
+
+func displayPayloadType(payloadType string) string {
+	if payloadType == pocsag.PayloadTypeNumeric {
+		return "numeric"
+	}
+	if payloadType == pocsag.PayloadTypeAlpha {
+		return "alphanumeric"
+	}
+	return ""
+}
+
+// parseFrequency accepts a frequency in Hz with an optional k, M, or G
+// suffix, e.g. "439.9875M". Callers check the range their radio supports.
+func parseFrequency(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	mult := 1.0
+	switch {
+	case strings.HasSuffix(s, "G"), strings.HasSuffix(s, "g"):
+		mult = 1e9
+	case strings.HasSuffix(s, "M"), strings.HasSuffix(s, "m"):
+		mult = 1e6
+	case strings.HasSuffix(s, "k"), strings.HasSuffix(s, "K"):
+		mult = 1e3
+	}
+	if mult != 1 {
+		s = s[:len(s)-1]
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || v <= 0 {
+		return 0, fmt.Errorf("invalid frequency %q", s)
+	}
+	return int64(math.Round(v * mult)), nil
+}
+
+// parseChannels parses a comma-separated list of frequencies for
+// --channels. Numbers too small to be in Hz are taken as MHz, so
+// "439.9875,439.975" works.
+func parseChannels(list string) ([]int64, error) {
+	var channels []int64
+	for _, field := range strings.Split(list, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if v, err := strconv.ParseFloat(field, 64); err == nil && v < 1e6 {
+			field += "M"
+		}
+		f, err := parseFrequency(field)
+		if err != nil {
+			return nil, err
+		}
+		channels = append(channels, f)
+	}
+	if len(channels) == 0 {
+		return nil, fmt.Errorf("no frequencies in --channels")
+	}
+	return channels, nil
+}
+
+func parseWebhookFilter(addresses, pattern string) (pocsag.MessageFilter, error) {
+	var filter pocsag.MessageFilter
+	for _, field := range strings.Split(addresses, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		addr, err := strconv.ParseUint(field, 10, 32)
+		if err != nil {
+			return filter, fmt.Errorf("invalid webhook address %q", field)
+		}
+		filter.Addresses = append(filter.Addresses, uint32(addr))
+	}
+	if pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return filter, fmt.Errorf("invalid webhook pattern: %v", err)
+		}
+		filter.Pattern = re
+	}
+	return filter, nil
+}
+
+// printJSON writes v as indented JSON, the layout of every --json report.
+func printJSON(w io.Writer, v interface{}) {
+	jsonBytes, _ := json.MarshalIndent(v, "", "  ")
+	fmt.Fprintln(w, string(jsonBytes))
+}
+
+// printDryRun summarizes the bursts --dry-run checked: how many batches
+// each takes, how long it is on air, and where each message lands.
+func printDryRun(descs []pocsag.TransmissionDescription) {
+	fmt.Println("Dry run: no files written")
+	for _, desc := range descs {
+		fmt.Print("   ")
+		if desc.FrequencyHz != 0 {
+			fmt.Printf("Frequency: %s MHz, ", strconv.FormatFloat(float64(desc.FrequencyHz)/1e6, 'f', -1, 64))
+		}
+		fmt.Printf("Messages: %d, Batches: %d, Baud: %d, Duration: %.2f s\n", len(desc.Messages), len(desc.Batches), desc.BaudRate, desc.DurationSec)
+		for i, md := range desc.Messages {
+			fmt.Printf("   %d. Address: %d, Batch: %d, Frame: %d, Codewords: %d, Airtime: %.3f s\n", i+1, md.Address, md.Batch, md.Frame, md.Codewords, md.AirtimeSec)
+		}
+	}
+}
+
+// recordWriter writes decoded messages to stdout one record at a time, in
+// a --format for pipelines. Stdout is not buffered, so each record reaches
+// the reader as soon as its message is decoded, and an NDJSON line goes
+// out in a single write.
+type recordWriter struct {
+	format string
+	gob    *gob.Encoder
+	pcap   *pocsag.PcapWriter
+}
+
+// newRecordWriter returns a recordWriter for format, or nil if format is
+// empty. It exits if the format is unknown.
+func newRecordWriter(format string) *recordWriter {
+	switch format {
+	case "":
+		return nil
+	case "ndjson", "proto":
+		return &recordWriter{format: format}
+	case "gob":
+		return &recordWriter{format: format, gob: gob.NewEncoder(os.Stdout)}
+	case "pcapng":
+		pcap, err := pocsag.NewPcapWriter(os.Stdout)
+		if err != nil {
+			fail(exitIO, "writing output: %v", err)
+		}
+		return &recordWriter{format: format, pcap: pcap}
+	}
+	fail(exitUsage, "Invalid format %q. Supported formats: ndjson, proto, gob, pcapng", format)
+	return nil
+}
+
+// write writes msg, received at t, or with no time if t is zero.
+// baudRate stands in for the message's if that is not set.
+func (w *recordWriter) write(msg pocsag.DecodedMessage, baudRate int, t time.Time) {
+	if msg.BaudRate == 0 {
+		msg.BaudRate = baudRate
+	}
+	var err error
+	switch w.format {
+	case "ndjson":
+		result := messageJSON(msg)
+		if !t.IsZero() {
+			result["time"] = t.UTC().Format(time.RFC3339)
+		}
+		result["baud"] = msg.BaudRate
+		jsonBytes, _ := json.Marshal(result)
+		_, err = fmt.Println(string(jsonBytes))
+	case "proto":
+		err = pocsag.WriteProtoDelimited(os.Stdout, pocsag.MessageRecord{DecodedMessage: msg, Time: t})
+	case "gob":
+		err = w.gob.Encode(pocsag.MessageRecord{DecodedMessage: msg, Time: t})
+	case "pcapng":
+		err = w.pcap.WriteRecord(pocsag.MessageRecord{DecodedMessage: msg, Time: t})
+	}
+	if err != nil {
+		fail(exitIO, "writing output: %v", err)
+	}
+}
+
+// restoreSpellings turns custom spellings in an alphanumeric message back
+// into the characters they stand for.
+func restoreSpellings(t *pocsag.Transliterator, msg pocsag.DecodedMessage) pocsag.DecodedMessage {
+	if !msg.IsNumeric {
+		msg.Message = t.FromASCII(msg.Message)
+	}
+	return msg
+}
+
+// messageJSON is how every command reports a decoded message in JSON.
+func messageJSON(msg pocsag.DecodedMessage) map[string]interface{} {
+	msgType := "alphanumeric"
+	if msg.IsNumeric {
+		msgType = "numeric"
+	}
+	result := map[string]interface{}{
+		"address":  msg.Address,
+		"function": msg.Function,
+		"message":  msg.Message,
+		"partial":  msg.Partial,
+		"type":     msgType,
+	}
+	if msg.Codewords != nil {
+		result["codewords"] = codewordsHex(msg.Codewords)
+	}
+	if msg.Alias != "" {
+		result["alias"] = msg.Alias
+	}
+	if len(msg.Tags) > 0 {
+		result["tags"] = msg.Tags
+	}
+	if msg.Alternative != "" {
+		result["alternative"] = msg.Alternative
+	}
+	if msg.FrequencyHz != 0 {
+		result["frequency"] = msg.FrequencyHz
+	}
+	if msg.FrequencyOffsetHz != 0 {
+		result["frequency_offset"] = msg.FrequencyOffsetHz
+	}
+	if msg.SNRValid {
+		result["rssi_dbfs"] = math.Round(msg.RSSI*10) / 10
+		result["snr_db"] = math.Round(msg.SNR*10) / 10
+	}
+	if msg.Position != nil {
+		result["position"] = msg.Position
+	}
+	if msg.Verified {
+		result["verified"] = true
+	}
+	return result
+}
+
+// codewordsHex formats codewords the way --describe does: "0x7CD215D8".
+func codewordsHex(codewords []uint32) []string {
+	hex := make([]string, len(codewords))
+	for i, cw := range codewords {
+		hex[i] = fmt.Sprintf("0x%08X", cw)
+	}
+	return hex
+}
+
+// printCodewords lists the received codewords of msg under it, if any.
+func printCodewords(msg pocsag.DecodedMessage) {
+	if msg.Codewords != nil {
+		fmt.Printf("  Codewords: %s\n", strings.Join(codewordsHex(msg.Codewords), " "))
+	}
+}
@@ -0,0 +1,236 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// flagInfo is one option with all its spellings, e.g. --input and -i.
+type flagInfo struct {
+	Names  []string // long name first
+	Usage  string
+	Value  string // default, "" if none
+	IsBool bool
+}
+
+// Spellings returns the names as typed on the command line.
+func (f flagInfo) Spellings() []string {
+	out := make([]string, len(f.Names))
+	for i, name := range f.Names {
+		out[i] = dashed(name)
+	}
+	return out
+}
+
+func dashed(name string) string {
+	if len(name) == 1 {
+		return "-" + name
+	}
+	return "--" + name
+}
+
+// flagInfos groups a FlagSet's flags by the variable they set, so a long
+// flag and its short alias are listed together.
+func flagInfos(fs *flag.FlagSet) []flagInfo {
+	groups := make(map[uintptr]*flagInfo)
+	var order []uintptr
+	fs.VisitAll(func(f *flag.Flag) {
+		key := reflect.ValueOf(f.Value).Pointer()
+		g, ok := groups[key]
+		if !ok {
+			g = &flagInfo{Value: f.DefValue}
+			if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+				g.IsBool = true
+			}
+			groups[key] = g
+			order = append(order, key)
+		}
+		// The long name's help text is the full one
+		if len(g.Names) == 0 || len(f.Name) > len(g.Names[0]) {
+			g.Usage = f.Usage
+			g.Names = append([]string{f.Name}, g.Names...)
+		} else {
+			g.Names = append(g.Names, f.Name)
+		}
+	})
+
+	infos := make([]flagInfo, len(order))
+	for i, key := range order {
+		infos[i] = *groups[key]
+	}
+	sort.Slice(infos, func(a, b int) bool { return infos[a].Names[0] < infos[b].Names[0] })
+	return infos
+}
+
+// WriteCompletion writes a completion script for shell (bash, zsh, or fish)
+// covering the pocsag subcommands and the standalone binaries.
+func WriteCompletion(w io.Writer, shell string) error {
+	switch shell {
+	case "bash":
+		return writeBashCompletion(w)
+	case "zsh":
+		return writeZshCompletion(w)
+	case "fish":
+		return writeFishCompletion(w)
+	}
+	return fmt.Errorf("unsupported shell %q (supported: bash, zsh, fish)", shell)
+}
+
+func flagWords(c *Command) string {
+	var words []string
+	for _, f := range flagInfos(c.FlagSet(c.Binary)) {
+		words = append(words, f.Spellings()...)
+	}
+	return strings.Join(words, " ")
+}
+
+func subcommandNames() string {
+	names := []string{"completion", "help", "man"}
+	for _, c := range Commands {
+		names = append(names, c.Name)
+	}
+	return strings.Join(names, " ")
+}
+
+func writeBashCompletion(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("# bash completion for pocsag\n")
+	b.WriteString("# Load with: source <(pocsag completion bash)\n\n")
+	b.WriteString("_pocsag() {\n")
+	b.WriteString("    local cur=\"${COMP_WORDS[COMP_CWORD]}\" words\n")
+	b.WriteString("    if [[ $COMP_CWORD -eq 1 && $cur != -* ]]; then\n")
+	fmt.Fprintf(&b, "        COMPREPLY=($(compgen -W %q -- \"$cur\"))\n", subcommandNames())
+	b.WriteString("        return\n    fi\n")
+	b.WriteString("    case \"${COMP_WORDS[1]}\" in\n")
+	for _, c := range Commands {
+		fmt.Fprintf(&b, "    %s) words=%q ;;\n", c.Name, flagWords(c))
+	}
+	b.WriteString("    completion) words=\"bash zsh fish\" ;;\n")
+	fmt.Fprintf(&b, "    help) words=%q ;;\n", subcommandNames())
+	fmt.Fprintf(&b, "    -*) words=%q ;;\n", flagWords(Encode))
+	b.WriteString("    esac\n")
+	b.WriteString("    if [[ $cur == -* || ${COMP_WORDS[1]} == completion || ${COMP_WORDS[1]} == help ]]; then\n")
+	b.WriteString("        COMPREPLY=($(compgen -W \"$words\" -- \"$cur\"))\n")
+	b.WriteString("    else\n")
+	b.WriteString("        COMPREPLY=($(compgen -f -- \"$cur\"))\n")
+	b.WriteString("    fi\n")
+	b.WriteString("}\n")
+	b.WriteString("complete -o filenames -F _pocsag pocsag\n")
+	for _, c := range Commands {
+		if c.standalone() {
+			fmt.Fprintf(&b, "complete -o default -W %q %s\n", flagWords(c), c.Binary)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// zshQuote escapes s for an _arguments description inside single quotes.
+func zshQuote(s string) string {
+	return strings.NewReplacer(`'`, `'\''`, `[`, `\[`, `]`, `\]`, `:`, `\:`).Replace(s)
+}
+
+func writeZshCompletion(w io.Writer) error {
+	var b strings.Builder
+	binaries := []string{"pocsag"}
+	for _, c := range Commands {
+		if c.standalone() {
+			binaries = append(binaries, c.Binary)
+		}
+	}
+	fmt.Fprintf(&b, "#compdef %s\n\n", strings.Join(binaries, " "))
+
+	for _, c := range Commands {
+		fmt.Fprintf(&b, "_pocsag_%s() {\n    _arguments \\\n", c.Name)
+		for _, f := range flagInfos(c.FlagSet(c.Binary)) {
+			value := ":value:"
+			if f.IsBool {
+				value = ""
+			}
+			for _, name := range f.Spellings() {
+				fmt.Fprintf(&b, "        '%s[%s]%s' \\\n", name, zshQuote(f.Usage), value)
+			}
+		}
+		b.WriteString("        '*:file:_files'\n}\n\n")
+	}
+
+	b.WriteString("_pocsag() {\n    case $service in\n")
+	for _, c := range Commands {
+		if c.standalone() {
+			fmt.Fprintf(&b, "    %s) _pocsag_%s; return ;;\n", c.Binary, c.Name)
+		}
+	}
+	b.WriteString("    esac\n")
+	b.WriteString("    if (( CURRENT == 2 )) && [[ $words[2] != -* ]]; then\n")
+	b.WriteString("        local -a commands\n        commands=(\n")
+	for _, c := range Commands {
+		fmt.Fprintf(&b, "            '%s:%s'\n", c.Name, zshQuote(c.Summary))
+	}
+	b.WriteString("            'completion:Print a completion script'\n")
+	b.WriteString("            'man:Write man pages'\n")
+	b.WriteString("            'help:Show help for a command'\n")
+	b.WriteString("        )\n        _describe 'command' commands\n        return\n    fi\n")
+	b.WriteString("    case $words[2] in\n")
+	for _, c := range Commands {
+		fmt.Fprintf(&b, "    %s) shift words; (( CURRENT-- )); _pocsag_%s ;;\n", c.Name, c.Name)
+	}
+	b.WriteString("    completion) _values 'shell' bash zsh fish ;;\n")
+	b.WriteString("    man) _files -/ ;;\n")
+	b.WriteString("    help) ;;\n")
+	b.WriteString("    *) _pocsag_encode ;;\n")
+	b.WriteString("    esac\n}\n\n")
+	b.WriteString("_pocsag \"$@\"\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// fishQuote escapes s for a single-quoted fish string.
+func fishQuote(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s)
+}
+
+func fishFlag(b *strings.Builder, prefix string, f flagInfo) {
+	b.WriteString(prefix)
+	for _, name := range f.Names {
+		if len(name) == 1 {
+			fmt.Fprintf(b, " -s %s", name)
+		} else {
+			fmt.Fprintf(b, " -l %s", name)
+		}
+	}
+	if !f.IsBool {
+		b.WriteString(" -r")
+	}
+	fmt.Fprintf(b, " -d '%s'\n", fishQuote(f.Usage))
+}
+
+func writeFishCompletion(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("# fish completion for pocsag\n")
+	fmt.Fprintf(&b, "set -l pocsag_commands %s\n", subcommandNames())
+	for _, c := range Commands {
+		fmt.Fprintf(&b, "complete -c pocsag -f -n \"not __fish_seen_subcommand_from $pocsag_commands\" -a %s -d '%s'\n", c.Name, fishQuote(c.Summary))
+	}
+	b.WriteString("complete -c pocsag -f -n \"not __fish_seen_subcommand_from $pocsag_commands\" -a completion -d 'Print a completion script'\n")
+	b.WriteString("complete -c pocsag -f -n \"not __fish_seen_subcommand_from $pocsag_commands\" -a man -d 'Write man pages'\n")
+	b.WriteString("complete -c pocsag -f -n \"not __fish_seen_subcommand_from $pocsag_commands\" -a help -d 'Show help for a command'\n")
+	b.WriteString("complete -c pocsag -f -n '__fish_seen_subcommand_from completion' -a 'bash zsh fish'\n")
+
+	for _, c := range Commands {
+		infos := flagInfos(c.FlagSet(c.Binary))
+		for _, f := range infos {
+			fishFlag(&b, fmt.Sprintf("complete -c pocsag -n '__fish_seen_subcommand_from %s'", c.Name), f)
+		}
+		if c.standalone() {
+			for _, f := range infos {
+				fishFlag(&b, "complete -c "+c.Binary, f)
+			}
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,337 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	pocsag "github.com/sqpp/pocsag-golang/v2"
+)
+
+func decodeCommand(fs *flag.FlagSet) func() {
+	inputFile := fs.String("input", "", "Input WAV file to decode, or - for stdin (required)")
+	fs.StringVar(inputFile, "i", "", "Input WAV file to decode, or - for stdin (required) - short form")
+
+	device := deviceFlag(fs)
+
+	baudRate := baudFlag(fs)
+
+	jsonOutput := jsonFlag(fs, "Output result as JSON")
+	format := recordFormatFlag(fs)
+
+	version := versionFlag(fs)
+
+	keyStr := decryptKeyFlag(fs)
+	numericKeyStr := numericKeyFlag(fs)
+	keySource := keySourceFlag(fs)
+	keyring := keyringFlag(fs)
+
+	noDCBlock := fs.Bool("no-dc-block", false, "Disable DC offset removal on the input audio")
+	noAGC := fs.Bool("no-agc", false, "Disable automatic level normalization on the input audio")
+
+	reassemble := fs.Bool("reassemble", false, "Join [n/m] continuation pages for the same address into one message")
+
+	allBauds := fs.Bool("all-bauds", false, "Decode 512, 1200, and 2400 baud traffic in one pass (instead of --baud)")
+
+	squelch := fs.Bool("squelch", false, "Decode only the transmissions found in the recording and group messages by transmission")
+	gapTimeout := gapTimeoutFlag(fs)
+
+	raw := fs.Bool("raw", false, "Include each message's received codewords, for checking BCH and parity independently")
+
+	showStats := fs.Bool("stats", false, "Report demodulator diagnostics: clock drift, resyncs, and eye opening")
+
+	translitFile := translitFlag(fs, "JSON file of custom spellings, as given to encode, to turn back into their characters")
+
+	addressBook := addressBookFlag(fs)
+	payloadType := decodeTypeFlag(fs)
+	detectType := detectTypeFlag(fs)
+	terminator := terminatorFlag(fs)
+
+	placeholder := fs.String("placeholder", string(pocsag.DefaultPlaceholder), "Character shown in place of text lost to corrupted codewords")
+
+	webhookOpts := addWebhookFlags(fs)
+
+	return func() {
+		// Handle version flag
+		printVersion(*version)
+
+		if *inputFile == "" && *device == "" {
+			usageError(fs, "Input file or audio device required",
+				"",
+				"Usage examples:",
+				"  pocsag-decode --input message.wav",
+				"  pocsag-decode -i message.wav",
+				"  pocsag-decode -i message.wav --baud 512",
+				"  pocsag-decode -i message.wav -b 2400",
+				"  pocsag-decode --device default --json")
+		}
+		if *device != "" && (*inputFile != "" || *allBauds || *squelch || *showStats || *reassemble) {
+			fail(exitUsage, "--device cannot be used with --input, --all-bauds, --squelch, --stats, or --reassemble")
+		}
+
+		records := newRecordWriter(*format)
+		if records != nil && (*jsonOutput || *showStats) {
+			fail(exitUsage, "--format cannot be used with --json or --stats")
+		}
+
+		// Validate baud rate
+		checkBaud(*baudRate)
+		if *allBauds && isSet(fs, "baud", "b") {
+			fail(exitUsage, "--all-bauds and --baud cannot be used together")
+		}
+		if *allBauds && *showStats {
+			fail(exitUsage, "--stats is not available with --all-bauds")
+		}
+		if *allBauds && *squelch {
+			fail(exitUsage, "--squelch is not available with --all-bauds")
+		}
+
+		decodeOpts := pocsag.DecodeOptions{
+			DisableDCBlock: *noDCBlock,
+			DisableAGC:     *noAGC,
+			IncludeRaw:     *raw || *format == "pcapng",
+			Squelch:        *squelch,
+			GapTimeout:     *gapTimeout,
+		}
+		if r := []rune(*placeholder); len(r) > 0 {
+			decodeOpts.Placeholder = r[0]
+		}
+
+		decodeOpts.Keys = loadKeyring(*keyring)
+		decodeOpts.NumericKey = numericKey(*numericKeyStr)
+		decodeOpts.KeyProvider = loadKeyProvider(*keySource)
+
+		var book *pocsag.AddressBook
+		if *addressBook != "" {
+			book = loadAddressBook(*addressBook)
+		}
+		setDecodeType(&decodeOpts, *payloadType, *detectType, book)
+		decodeOpts.Terminator = parseTerminator(*terminator)
+
+		// Parse decryption key if provided
+		if *keyStr != "" {
+			decodeOpts.Encryption = pocsag.EncryptionConfig{
+				Method: pocsag.EncryptionAES256,
+				Key:    pocsag.KeyFromPassword(*keyStr, 32),
+			}
+		}
+
+		webhook := webhookOpts.webhook()
+
+		processors := loadPostProcessors(book, "", "")
+
+		if *device != "" {
+			var translit *pocsag.Transliterator
+			if *translitFile != "" {
+				translit = loadTransliterator(*translitFile)
+			}
+			if *jsonOutput {
+				records = newRecordWriter("ndjson")
+			}
+			decodeDevice(*device, *baudRate, decodeOpts, translit, processors, webhook, records)
+			return
+		}
+
+		// Read WAV file
+		data, err := readInput(*inputFile)
+		if err != nil {
+			fail(exitIO, "reading file: %v", err)
+		}
+
+		// Decode POCSAG
+		var messages []pocsag.DecodedMessage
+		var stats pocsag.DecodeStats
+		var transmissions []pocsag.Transmission
+		switch {
+		case *allBauds:
+			messages, err = pocsag.DecodeFromAudioMultiRate(data, decodeOpts)
+		case *squelch:
+			transmissions, err = pocsag.DecodeTransmissions(data, *baudRate, decodeOpts)
+		default:
+			messages, stats, err = pocsag.DecodeFromAudioWithStats(data, *baudRate, decodeOpts)
+		}
+		if err != nil {
+			fail(exitDecode, "decoding: %v", err)
+		}
+		if !*squelch {
+			// One transmission stands in for the whole recording
+			transmissions = []pocsag.Transmission{{Messages: messages}}
+		}
+
+		if *reassemble {
+			// The whole recording is at hand, so there is no timeout to wait
+			// for. A joined message belongs to the transmission it ends in.
+			r := pocsag.NewReassembler(0)
+			for i := range transmissions {
+				joined := make([]pocsag.DecodedMessage, 0, len(transmissions[i].Messages))
+				for _, msg := range transmissions[i].Messages {
+					joined = append(joined, r.Add(msg, time.Time{})...)
+				}
+				transmissions[i].Messages = joined
+			}
+			if rest := r.Flush(); len(rest) > 0 {
+				last := &transmissions[len(transmissions)-1]
+				last.Messages = append(last.Messages, rest...)
+			}
+		}
+
+		if *translitFile != "" {
+			translit := loadTransliterator(*translitFile)
+			for _, t := range transmissions {
+				for i, msg := range t.Messages {
+					t.Messages[i] = restoreSpellings(translit, msg)
+				}
+			}
+		}
+
+		for _, t := range transmissions {
+			for i, msg := range t.Messages {
+				t.Messages[i] = pocsag.ApplyPostProcessors(msg, processors...)
+			}
+		}
+
+		messages = make([]pocsag.DecodedMessage, 0, len(messages))
+		for _, t := range transmissions {
+			messages = append(messages, t.Messages...)
+		}
+
+		if webhook != nil {
+			for _, msg := range messages {
+				if err := webhook.Notify(context.Background(), msg); err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: webhook delivery failed for address %d: %v\n", msg.Address, err)
+				}
+			}
+		}
+
+		if *showStats && !*jsonOutput && !*squelch {
+			printStats(stats)
+		}
+
+		// Output messages
+		if records != nil {
+			for _, msg := range messages {
+				records.write(msg, *baudRate, time.Time{})
+			}
+		} else if *jsonOutput {
+			jsonMessages := make([]map[string]interface{}, len(messages))
+			for i, msg := range messages {
+				jsonMessages[i] = messageJSON(msg)
+				if *allBauds {
+					jsonMessages[i]["baud"] = msg.BaudRate
+				}
+			}
+			result := map[string]interface{}{
+				"success":  true,
+				"messages": jsonMessages,
+				"baud":     *baudRate,
+			}
+			if *allBauds {
+				result["baud"] = 0
+			}
+			if *showStats && !*squelch {
+				result["stats"] = stats
+			}
+			if *squelch {
+				jsonTransmissions := make([]map[string]interface{}, len(transmissions))
+				for i, t := range transmissions {
+					tMessages := make([]map[string]interface{}, len(t.Messages))
+					for j, msg := range t.Messages {
+						tMessages[j] = messageJSON(msg)
+					}
+					jsonTransmissions[i] = map[string]interface{}{
+						"index":    i,
+						"start_s":  t.Start.Seconds(),
+						"end_s":    t.End.Seconds(),
+						"preamble": t.Preamble,
+						"baud":     t.BaudRate,
+						"messages": tMessages,
+					}
+					if *showStats {
+						jsonTransmissions[i]["stats"] = t.Stats
+					}
+				}
+				result["transmissions"] = jsonTransmissions
+			}
+			printJSON(os.Stdout, result)
+		} else if *squelch && len(transmissions) == 0 {
+			fmt.Printf("No transmissions found (tried %d baud)\n", *baudRate)
+		} else if *squelch {
+			for i, t := range transmissions {
+				note := ""
+				if t.Preamble {
+					note = " (preamble)"
+				}
+				fmt.Printf("Transmission %d: %.2fs - %.2fs, POCSAG%d%s\n", i+1, t.Start.Seconds(), t.End.Seconds(), t.BaudRate, note)
+				if *showStats {
+					printStats(t.Stats)
+				}
+				if len(t.Messages) == 0 {
+					fmt.Println("No messages found")
+				}
+				for _, msg := range t.Messages {
+					fmt.Println(msg.String())
+					printCodewords(msg)
+				}
+			}
+		} else if len(messages) == 0 && *allBauds {
+			fmt.Println("No messages found (tried 512, 1200, and 2400 baud)")
+		} else if len(messages) == 0 {
+			fmt.Printf("No messages found (tried %d baud)\n", *baudRate)
+		} else if *allBauds {
+			fmt.Println("Decoded messages:")
+			for _, msg := range messages {
+				fmt.Printf("POCSAG%d: %s\n", msg.BaudRate, msg.String())
+				printCodewords(msg)
+			}
+		} else {
+			fmt.Printf("POCSAG%d: Decoded messages:\n", *baudRate)
+			for _, msg := range messages {
+				fmt.Println(msg.String())
+				printCodewords(msg)
+			}
+		}
+	}
+}
+
+// decodeDevice prints messages from a sound card as they are decoded, one
+// line each, until Ctrl-C.
+func decodeDevice(device string, baudRate int, opts pocsag.DecodeOptions, translit *pocsag.Transliterator, processors []pocsag.PostProcessor, webhook *pocsag.Webhook, records *recordWriter) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var hooks *webhookQueue
+	if webhook != nil {
+		hooks = startWebhookQueue(ctx, webhook, webhookQueueSize)
+	}
+
+	fmt.Fprintf(os.Stderr, "Decoding from sound card %s at %d baud (Ctrl-C to stop)\n", device, baudRate)
+	err := captureMessages(ctx, device, baudRate, opts, func(msg pocsag.DecodedMessage) {
+		if translit != nil {
+			msg = restoreSpellings(translit, msg)
+		}
+		msg = pocsag.ApplyPostProcessors(msg, processors...)
+		if records != nil {
+			records.write(msg, baudRate, time.Now())
+		} else {
+			fmt.Printf("%s %s\n", time.Now().Format("15:04:05"), msg.String())
+			printCodewords(msg)
+		}
+		if hooks != nil {
+			hooks.send(msg)
+		}
+	})
+	if hooks != nil {
+		hooks.close()
+	}
+	if err != nil {
+		fail(exitIO, "capturing audio: %v", err)
+	}
+}
+
+func printStats(stats pocsag.DecodeStats) {
+	fmt.Printf("Signal: %d batches, %d resyncs, %d bit slips, clock drift %+.1f ppm, eye opening %.0f%%, SNR %.1f dB\n",
+		stats.Batches, stats.Resyncs, stats.BitSlips, stats.ClockDriftPPM, stats.EyeOpening*100, stats.SNR)
+}
@@ -0,0 +1,459 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"math/cmplx"
+	"os"
+	"strings"
+	"time"
+
+	pocsag "github.com/sqpp/pocsag-golang/v2"
+)
+
+func encodeCommand(fs *flag.FlagSet) func() {
+	address := fs.Uint("address", 0, "Pager address (RIC) - REQUIRED")
+	fs.UintVar(address, "a", 0, "Pager address (RIC) - REQUIRED")
+
+	message := fs.String("message", "", "Message text to send - REQUIRED")
+	fs.StringVar(message, "m", "", "Message text to send - REQUIRED")
+
+	funcCode := fs.Uint("function", pocsag.FuncAlphanumeric, "2-bit POCSAG function value to transmit: 0, 1, 2, or 3")
+	fs.UintVar(funcCode, "f", pocsag.FuncAlphanumeric, "2-bit POCSAG function value to transmit: 0, 1, 2, or 3")
+
+	payloadType := fs.String("type", "", "Payload encoding: numeric or alpha - REQUIRED")
+
+	recipient := addRecipientFlags(fs)
+
+	baudRate := baudFlag(fs)
+
+	audio := addAudioFlags(fs, "output.wav")
+
+	layout := addLayoutFlags(fs)
+
+	waterfallFile := fs.String("waterfall", "", "Output waterfall PNG file path (optional)")
+	fs.StringVar(waterfallFile, "w", "", "Output waterfall PNG file path (optional)")
+
+	encrypt := fs.Bool("encrypt", false, "Enable AES-256 encryption")
+	fs.BoolVar(encrypt, "e", false, "Enable AES-256 encryption")
+
+	key := fs.String("key", "", "Encryption key (required if --encrypt is used)")
+	fs.StringVar(key, "k", "", "Encryption key (required if --encrypt is used)")
+
+	sign := fs.Bool("sign", false, "Append an HMAC tag made with --key instead of encrypting, so receivers with the key can tell the page is unaltered")
+	cipherName := fs.String("cipher", "aes256", "Encryption cipher: aes256, aes128 (needs --key-id), or ff1 (numeric pages, the default for them)")
+	keyID := fs.Int("key-id", -1, "Start encrypted pages with a header naming the cipher and this key ID, 0-255, for decoders with a --keyring")
+	keySource := keySourceFlag(fs)
+	checksumName := checksumFlag(fs)
+
+	policyFile := policyFlag(fs)
+	translitFile := translitFlag(fs, "JSON file of custom spellings for characters outside ASCII, e.g. {\"ü\": \"ue\"}")
+
+	fec := fs.Int("fec", 0, "Protect alpha pages with this many Reed-Solomon parity bytes (1-63, 0 = off) so they survive long fades")
+
+	chainLength := fs.Int("chain", 0, "Split messages longer than this many characters into [n/m] continuation pages (0 = off)")
+
+	deterministic := fs.Bool("deterministic", false, "Derive the encryption IV from the message so output is reproducible (for tests only; reveals repeated messages)")
+
+	jsonOutput := jsonFlag(fs, "Output result as JSON")
+
+	describe := fs.Bool("describe", false, "Print the batch/frame/codeword layout as JSON")
+	dryRun := fs.Bool("dry-run", false, "Check the inputs and report the layout and airtime without writing any file")
+
+	testPattern := fs.String("test-pattern", "", "Generate a test signal instead of a page: preamble, idle, or ber")
+	patternDuration := fs.Duration("duration", 10*time.Second, "Length of the --test-pattern signal")
+
+	version := versionFlag(fs)
+
+	return func() {
+		printVersion(*version)
+
+		if recipient.resolve(fs, address, funcCode, baudRate, key) {
+			*encrypt = true
+		}
+
+		checkBaud(*baudRate)
+
+		audioFileFormat, audioOpts := audio.parse(fs, *baudRate)
+		output := audio.output
+
+		if *testPattern != "" {
+			if *dryRun {
+				fail(exitUsage, "--dry-run checks a page, not a --test-pattern")
+			}
+			writeTestPattern(*testPattern, *patternDuration, *baudRate, *output, audioFileFormat, *jsonOutput, audioOpts...)
+			return
+		}
+
+		if *address == 0 || *message == "" || strings.TrimSpace(*payloadType) == "" {
+			usageError(fs, "Address, message, and payload type are required",
+				"",
+				"Note: POCSAG addresses must be multiples of 8",
+				"      (e.g., 8, 16, 24, 123456, 1234560)",
+				"",
+				"Usage examples:",
+				"  pocsag --address 123456 --message \"HELLO WORLD\" --function 3 --type alpha --output test.wav",
+				"  pocsag -a 123456 -m \"12345\" -f 1 --type numeric -o test.wav",
+				"  pocsag --address-book pagers.yaml --to ops-team -m \"CALL IN\" --type alpha",
+				"")
+		}
+
+		if *encrypt && *sign {
+			fail(exitUsage, "--sign and --encrypt cannot be used together; a signed page is sent as plain text")
+		}
+		if *keySource != "" {
+			if isSet(fs, "key", "k") {
+				fail(exitUsage, "--key and --key-source cannot be used together")
+			}
+			if !isSet(fs, "key-id") {
+				fail(exitUsage, "--key-source needs --key-id to name the key")
+			}
+		}
+		if (*encrypt || *sign) && *key == "" && *keySource == "" {
+			fail(exitUsage, "Encryption key is required when --encrypt or --sign is used")
+		}
+		if !*encrypt && isSet(fs, "cipher") {
+			fail(exitUsage, "--cipher needs --encrypt")
+		}
+		if !*encrypt && !*sign && isSet(fs, "key-id") {
+			fail(exitUsage, "--key-id needs --encrypt or --sign")
+		}
+		method := pocsag.EncryptionAES256
+		switch *cipherName {
+		case "aes256":
+		case "aes128":
+			if *keyID < 0 {
+				fail(exitUsage, "--cipher aes128 needs --key-id, so decoders can tell the cipher from the header")
+			}
+			method = pocsag.EncryptionAES128
+		case "ff1":
+			method = pocsag.EncryptionFF1
+		default:
+			fail(exitUsage, "Invalid cipher %q. Supported: aes256, aes128, ff1", *cipherName)
+		}
+		checksum := parseChecksum(*checksumName)
+		if isSet(fs, "checksum") && (!*encrypt || *keyID < 0) {
+			fail(exitUsage, "--checksum needs --encrypt and --key-id, as the header names it")
+		}
+		if *keyID > 255 || *keyID < -1 {
+			fail(exitUsage, "--key-id must be 0 to 255")
+		}
+		if *fec < 0 || *fec > 63 {
+			fail(exitUsage, "--fec must be 0 to 63 parity bytes")
+		}
+
+		normalizedPayloadType := normalizePayloadType(*payloadType)
+		if normalizedPayloadType == "" {
+			fail(exitUsage, "Invalid payload type. Supported types: numeric, alpha")
+		}
+
+		addressVal := uint32(*address)
+
+		var err error
+
+		txMessages := []pocsag.MessageInfo{{
+			Address:     addressVal,
+			Message:     *message,
+			Function:    uint8(*funcCode),
+			PayloadType: normalizedPayloadType,
+		}}
+		// Policies see the message before it is split or encrypted
+		txMessages, warnings, substitutions := prepareMessages(*policyFile, *translitFile, txMessages)
+		*message = txMessages[0].Message // report what is sent
+		if *chainLength > 0 {
+			txMessages, err = pocsag.ChainMessage(txMessages[0], *chainLength)
+			if err != nil {
+				fail(exitEncode, "%v", err)
+			}
+		}
+
+		if *sign {
+			if normalizedPayloadType == pocsag.PayloadTypeNumeric {
+				fail(exitUsage, "--type numeric cannot be used with --sign because the tag is Base64 text")
+			}
+			method = pocsag.EncryptionSignOnly
+		}
+		if *encrypt && normalizedPayloadType == pocsag.PayloadTypeNumeric {
+			// Numeric pages stay numeric with FF1, which has no header to
+			// carry a key ID: --key-id only picks the key then
+			if isSet(fs, "cipher") && method != pocsag.EncryptionFF1 {
+				fail(exitUsage, "--type numeric can only be encrypted with --cipher ff1, because AES payloads are Base64 text")
+			}
+			if isSet(fs, "checksum") {
+				fail(exitUsage, "--checksum applies to AES pages; FF1 pages have none")
+			}
+			method = pocsag.EncryptionFF1
+			*cipherName = "ff1"
+		} else if method == pocsag.EncryptionFF1 {
+			fail(exitUsage, "--cipher ff1 encrypts numeric pages; use --type numeric")
+		}
+		if *encrypt || *sign {
+			keyBytes := pocsag.KeyFromPassword(*key, 32)
+			if *keySource != "" {
+				keyBytes, err = loadKeyProvider(*keySource).Key(uint8(*keyID))
+				if err != nil {
+					fail(exitUsage, "%v", err)
+				}
+			}
+			encryptionConfig := pocsag.EncryptionConfig{
+				Method:        method,
+				Key:           keyBytes,
+				Deterministic: *deterministic,
+				Header:        *keyID >= 0 && method != pocsag.EncryptionFF1,
+				Checksum:      checksum,
+				KeyID:         uint8(max(*keyID, 0)),
+			}
+			// Each continuation page is encrypted or signed on its own so it
+			// can be opened before the pages are reassembled
+			txMessages, err = pocsag.EncryptMessages(txMessages, encryptionConfig)
+			if err != nil {
+				fail(exitEncode, "creating encrypted packet: %v", err)
+			}
+		}
+
+		if *fec > 0 {
+			if normalizedPayloadType == pocsag.PayloadTypeNumeric {
+				fail(exitUsage, "--type numeric cannot be used with --fec because FEC pages are Base64 text")
+			}
+			// FEC goes on last so it protects the ciphertext as sent
+			for i := range txMessages {
+				txMessages[i].Message, err = pocsag.EncodeFEC(txMessages[i].Message, *fec)
+				if err != nil {
+					fail(exitEncode, "%v", err)
+				}
+			}
+		}
+
+		encoder := pocsag.NewEncoder(append(append(layout.options(), pocsag.WithBaudRate(*baudRate)), audioOpts...)...)
+		if *dryRun {
+			desc := encoder.Describe(txMessages)
+			if *describe || *jsonOutput {
+				printJSON(os.Stdout, desc)
+			} else {
+				warnSubstitutions("", substitutions[0])
+				printDryRun([]pocsag.TransmissionDescription{desc})
+			}
+			return
+		}
+
+		burst, err := encoder.Encode(txMessages)
+		if err != nil {
+			fail(exitEncode, "%v", err)
+		}
+		packet := burst.Bytes()
+
+		// Generate waterfall PNG via OpenGL (headless offscreen rendering)
+		if *waterfallFile != "" {
+			iqSamples := pocsag.GenerateFSKSamples(packet, *baudRate)
+			cfg := pocsag.DefaultWaterfallConfig()
+
+			// Calculate the frequency bins we want to display
+			freqBinSize := float64(cfg.SampleRate) / float64(cfg.FFTSize)
+			halfFs := float64(cfg.SampleRate) / 2.0
+			minBin := int((cfg.MinFreq + halfFs) / freqBinSize)
+			maxBin := int((cfg.MaxFreq + halfFs) / freqBinSize)
+			if minBin < 0 {
+				minBin = 0
+			}
+			if maxBin > cfg.FFTSize {
+				maxBin = cfg.FFTSize
+			}
+			numBins := maxBin - minBin
+
+			// Create OpenGL renderer in headless mode (no window shown)
+			wgl, err := pocsag.NewWaterfallGL(numBins, cfg.Height, true)
+			if err != nil {
+				fail(exitEncode, "initializing OpenGL: %v", err)
+			}
+			defer wgl.Close()
+
+			// Convert IQ samples to complex
+			numComplexSamples := len(iqSamples) / 2
+			complexSamples := make([]complex128, numComplexSamples)
+			for i := 0; i < numComplexSamples; i++ {
+				complexSamples[i] = complex(float64(iqSamples[i*2])/32768.0, float64(iqSamples[i*2+1])/32768.0)
+			}
+
+			// Process FFT windows and upload each row to the OpenGL texture
+			stepSize := int(float64(cfg.FFTSize) * (1.0 - cfg.Overlap))
+			if stepSize < 1 {
+				stepSize = 1
+			}
+			numWindows := (numComplexSamples - cfg.FFTSize) / stepSize
+
+			for windowIdx := 0; windowIdx < numWindows; windowIdx++ {
+				startIdx := windowIdx * stepSize
+
+				// Apply Hann window
+				window := make([]complex128, cfg.FFTSize)
+				for i := 0; i < cfg.FFTSize; i++ {
+					hannWeight := 0.5 * (1.0 - math.Cos(2.0*math.Pi*float64(i)/float64(cfg.FFTSize-1)))
+					window[i] = complexSamples[startIdx+i] * complex(hannWeight, 0)
+				}
+
+				// FFT + normalize
+				coeffs := pocsag.ComplexFFT(window)
+				for i := range coeffs {
+					coeffs[i] /= complex(float64(cfg.FFTSize), 0)
+				}
+
+				// FFT shift so DC is centered
+				shifted := make([]complex128, cfg.FFTSize)
+				half := cfg.FFTSize / 2
+				for i := 0; i < cfg.FFTSize; i++ {
+					shifted[i] = coeffs[(i+half)%cfg.FFTSize]
+				}
+
+				// Extract only the frequency bins we want to display
+				floatData := make([]float32, numBins)
+				for i := 0; i < numBins; i++ {
+					binIdx := minBin + i
+					if binIdx >= len(shifted) {
+						break
+					}
+					mag := cmplx.Abs(shifted[binIdx])
+					floatData[i] = float32(mag * mag)
+				}
+
+				wgl.AddLine(floatData)
+			}
+
+			// Render once to flush everything to the framebuffer, then save
+			wgl.Render()
+			if err := wgl.SaveToPNG(*waterfallFile); err != nil {
+				fail(exitIO, "saving waterfall: %v", err)
+			}
+		}
+
+		// Convert to WAV
+		wavData := burst.WAV()
+		audioData, err := pocsag.EncodeAudio(wavData, audioFileFormat)
+		if err != nil {
+			fail(exitEncode, "%v", err)
+		}
+
+		err = writeOutput(*output, audioData)
+		if err != nil {
+			fail(exitIO, "writing audio file: %v", err)
+		}
+
+		// When the WAV goes to stdout, machine-readable reports move to stderr
+		// and the human-readable summary is suppressed.
+		report := os.Stdout
+		if *output == "-" {
+			report = os.Stderr
+		}
+
+		if !*describe && !*jsonOutput {
+			warnSubstitutions("", substitutions[0])
+		}
+		if *describe {
+			printJSON(report, burst.Describe())
+		} else if *jsonOutput {
+			result := map[string]interface{}{
+				"success":       true,
+				"output":        *output,
+				"address":       *address,
+				"function":      *funcCode,
+				"message":       *message,
+				"baud":          *baudRate,
+				"encrypted":     *encrypt,
+				"signed":        *sign,
+				"fec":           *fec,
+				"type":          displayPayloadType(normalizedPayloadType),
+				"pages":         len(txMessages),
+				"format":        string(audioFileFormat),
+				"size":          len(audioData),
+				"duration_s":    pocsag.WAVDuration(wavData).Seconds(),
+				"warnings":      warnings[0],
+				"substitutions": substitutionsJSON(substitutions[0]),
+			}
+			if *encrypt {
+				result["cipher"] = *cipherName
+			}
+			if (*encrypt || *sign) && *keyID >= 0 {
+				result["key_id"] = *keyID
+			}
+			printJSON(report, result)
+		} else if *output != "-" {
+			encryptionStatus := ""
+			if *encrypt {
+				encryptionStatus = " (encrypted)"
+			}
+			if *sign {
+				encryptionStatus = " (signed)"
+			}
+			if *fec > 0 {
+				encryptionStatus += fmt.Sprintf(" (FEC, %d parity bytes)", *fec)
+			}
+			fmt.Printf("✅ Generated %s%s\n", *output, encryptionStatus)
+			if *waterfallFile != "" {
+				fmt.Printf("✅ Generated waterfall: %s\n", *waterfallFile)
+			}
+			fmt.Printf("   Address: %d, Function: %d, Type: %s, Baud: %d, Message: %s\n", *address, *funcCode, displayPayloadType(normalizedPayloadType), *baudRate, *message)
+			if len(txMessages) > 1 {
+				fmt.Printf("   Sent as %d continuation pages\n", len(txMessages))
+			}
+			durationSec := pocsag.WAVDuration(wavData).Seconds()
+			fmt.Printf("   Size: %d bytes, Duration: %.2f s\n", len(audioData), durationSec)
+			if audioFileFormat == pocsag.AudioWAV {
+				fmt.Printf("\nDecode: pocsag-decode -i %s  or  multimon-ng -t wav -a POCSAG%d %s\n", *output, *baudRate, *output)
+			}
+			if *encrypt {
+				if method == pocsag.EncryptionFF1 {
+					fmt.Printf("Note: This message is encrypted with FF1. Use pocsag-decode with --numeric-key to decrypt.\n")
+				} else {
+					fmt.Printf("Note: This message is encrypted. Use pocsag-decode with --key to decrypt.\n")
+				}
+				if *keyID >= 0 && method != pocsag.EncryptionFF1 {
+					fmt.Printf("      Its header names key %d, which pocsag-decode --keyring finds by itself.\n", *keyID)
+				}
+			}
+			if *sign {
+				fmt.Printf("Note: This message is signed. pocsag-decode with --key or --keyring checks and removes its tag.\n")
+			}
+		}
+	}
+}
+
+func writeTestPattern(kind string, duration time.Duration, baudRate int, output string, format pocsag.AudioFormat, jsonOutput bool, audioOpts ...pocsag.Option) {
+	pattern, err := pocsag.GenerateTestPattern(kind, duration, baudRate)
+	if err != nil {
+		fail(exitUsage, "%v", err)
+	}
+
+	wavData := pocsag.ConvertToAudioWithOptions(pattern, baudRate, audioOpts...)
+	audioData, err := pocsag.EncodeAudio(wavData, format)
+	if err != nil {
+		fail(exitEncode, "%v", err)
+	}
+	if err := writeOutput(output, audioData); err != nil {
+		fail(exitIO, "writing audio file: %v", err)
+	}
+
+	report := os.Stdout
+	if output == "-" {
+		report = os.Stderr
+	}
+
+	durationSec := pocsag.WAVDuration(wavData).Seconds()
+	if jsonOutput {
+		result := map[string]interface{}{
+			"success":      true,
+			"output":       output,
+			"test_pattern": kind,
+			"baud":         baudRate,
+			"format":       string(format),
+			"size":         len(audioData),
+			"duration_s":   durationSec,
+		}
+		printJSON(report, result)
+		return
+	}
+	if output == "-" {
+		return
+	}
+	fmt.Printf("✅ Generated %s test pattern: %s\n", kind, output)
+	fmt.Printf("   Baud: %d, Size: %d bytes, Duration: %.2f s\n", baudRate, len(audioData), durationSec)
+}
@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Exit codes shared by every command, so scripts can tell failures apart
+// without reading stderr.
+const (
+	exitFailed = 1 // diff: the transmissions differ; selftest: a test failed
+	exitUsage  = 2 // bad or missing flags
+	exitEncode = 3 // the input could not be turned into a transmission
+	exitDecode = 4 // the recording or signal could not be decoded
+	exitIO     = 5 // a file, device, network, or helper program failed
+)
+
+// errorJSON is where fail reports errors when --json is given; nil means
+// plain text on stderr. lines selects one-line JSON for commands that
+// print a JSON object per line.
+var errorJSON struct {
+	w     io.Writer
+	lines bool
+}
+
+// reportErrorsAsJSON makes fail write {"success": false, "error": ...} to
+// w instead of text to stderr.
+func reportErrorsAsJSON(w io.Writer, lines bool) {
+	errorJSON.w, errorJSON.lines = w, lines
+}
+
+// fail reports an error and exits with code.
+func fail(code int, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	if errorJSON.w == nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", msg)
+		os.Exit(code)
+	}
+
+	result := map[string]interface{}{
+		"success":   false,
+		"error":     msg,
+		"exit_code": code,
+	}
+	if errorJSON.lines {
+		jsonBytes, _ := json.Marshal(result)
+		fmt.Fprintln(errorJSON.w, string(jsonBytes))
+	} else {
+		printJSON(errorJSON.w, result)
+	}
+	os.Exit(code)
+}
+
+// usageError reports a missing required flag. In text mode the help lines
+// (usage examples) and, if fs is not nil, the flag list follow the message.
+func usageError(fs *flag.FlagSet, msg string, help ...string) {
+	if errorJSON.w != nil {
+		fail(exitUsage, "%s", msg)
+	}
+	fmt.Fprintf(os.Stderr, "Error: %s\n", msg)
+	for _, line := range help {
+		fmt.Fprintln(os.Stderr, line)
+	}
+	if fs != nil {
+		fs.Usage()
+	}
+	os.Exit(exitUsage)
+}
+
+// jsonRequested reports whether args ask for JSON output through the flag
+// named name or one of its aliases. It is used when parsing fails, before
+// the flag's value is known.
+func jsonRequested(fs *flag.FlagSet, name string, args []string) bool {
+	var names []string
+	for _, f := range flagInfos(fs) {
+		if f.Names[0] == name {
+			names = f.Names
+		}
+	}
+	for _, arg := range args {
+		if arg == "--" {
+			break
+		}
+		arg = strings.TrimLeft(arg, "-")
+		value := "true"
+		if i := strings.IndexByte(arg, '='); i >= 0 {
+			arg, value = arg[:i], arg[i+1:]
+		}
+		for _, n := range names {
+			if arg == n && value != "false" && value != "0" {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,555 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	pocsag "github.com/sqpp/pocsag-golang/v2"
+)
+
+// The flags below are shared by several commands so that they are spelled,
+// defaulted, and checked the same way everywhere.
+
+func versionFlag(fs *flag.FlagSet) *bool {
+	version := fs.Bool("version", false, "Show version information")
+	fs.BoolVar(version, "v", false, "Show version information")
+	return version
+}
+
+func jsonFlag(fs *flag.FlagSet, usage string) *bool {
+	jsonOutput := fs.Bool("json", false, usage)
+	fs.BoolVar(jsonOutput, "j", false, usage)
+	return jsonOutput
+}
+
+func baudFlag(fs *flag.FlagSet) *int {
+	baudRate := fs.Int("baud", pocsag.BaudRate1200, "Baud rate: 512, 1200, or 2400 (default: 1200)")
+	fs.IntVar(baudRate, "b", pocsag.BaudRate1200, "Baud rate: 512, 1200, or 2400")
+	return baudRate
+}
+
+func decryptKeyFlag(fs *flag.FlagSet) *string {
+	key := fs.String("key", "", "Decryption key (password string)")
+	fs.StringVar(key, "k", "", "Decryption key (short form)")
+	return key
+}
+
+func checksumFlag(fs *flag.FlagSet) *string {
+	return fs.String("checksum", "crc32", "Checksum sealed in encrypted pages with a key ID: crc32, crc16 (4 characters shorter), or crc32c")
+}
+
+func parseChecksum(name string) pocsag.Checksum {
+	checksum, err := pocsag.ParseChecksum(name)
+	if err != nil {
+		fail(exitUsage, "%v", err)
+	}
+	return checksum
+}
+
+func numericKeyFlag(fs *flag.FlagSet) *string {
+	return fs.String("numeric-key", "", "Decrypt numeric pages sent with --cipher ff1 with this password; every numeric page is decrypted, as nothing marks encrypted ones")
+}
+
+// numericKey derives the key of --numeric-key, if one is given.
+func numericKey(password string) []byte {
+	if password == "" {
+		return nil
+	}
+	return pocsag.KeyFromPassword(password, 32)
+}
+
+func keyringFlag(fs *flag.FlagSet) *string {
+	return fs.String("keyring", "", "JSON file of key IDs and passwords, e.g. {\"1\": \"secret\", \"123456\": {\"5\": \"pager key\"}}, to decrypt pages sent with --key-id")
+}
+
+func keySourceFlag(fs *flag.FlagSet) *string {
+	return fs.String("key-source", "", "Look keys up by --key-id or the ID pages name instead of taking them on the command line: file:keyring.json, env:PREFIX (default POCSAG_KEY_), os:SERVICE (the OS keyring), or pkcs11:MODULE")
+}
+
+// loadKeyProvider opens the key source spec, if one is given.
+func loadKeyProvider(spec string) pocsag.KeyProvider {
+	if spec == "" {
+		return nil
+	}
+	provider, err := pocsag.OpenKeyProvider(spec)
+	if err != nil {
+		fail(exitUsage, "%v", err)
+	}
+	return provider
+}
+
+// loadKeyring reads the keyring at path, if one is given.
+func loadKeyring(path string) *pocsag.KeyStore {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fail(exitIO, "reading keyring: %v", err)
+	}
+	store, err := pocsag.LoadKeyStore(data)
+	if err != nil {
+		fail(exitUsage, "%s: %v", path, err)
+	}
+	return store
+}
+
+func policyFlag(fs *flag.FlagSet) *string {
+	return fs.String("policy", "", "JSON file of message policies (length limit, allowed characters, blocked words) to apply before sending")
+}
+
+func deviceFlag(fs *flag.FlagSet) *string {
+	return fs.String("device", "", "Decode live audio from this sound card, e.g. default or hw:1 (records with arecord on Linux, ffmpeg elsewhere)")
+}
+
+func gapTimeoutFlag(fs *flag.FlagSet) *time.Duration {
+	return fs.Duration("gap-timeout", 0, "Hold a message open across a carrier dropout this long (e.g. 2s) and join it up with the rest after sync is found again (0 = off)")
+}
+
+func recordFormatFlag(fs *flag.FlagSet) *string {
+	return fs.String("format", "", "Write each message as a record for pipelines: ndjson, proto (length-delimited, see schemas/decoded_message.proto), gob, or pcapng (a capture for Wireshark, with the codewords; see contrib/pocsag.lua)")
+}
+
+// layoutFlags select how unused codeword slots and the last batch are
+// filled and how alphanumeric messages end, for pagers that are particular
+// about it, and whether message codewords are interleaved.
+type layoutFlags struct {
+	codeword   *string
+	padTo      *string
+	interleave *int
+	terminator *string
+	alphaFill  *string
+}
+
+func addLayoutFlags(fs *flag.FlagSet) *layoutFlags {
+	return &layoutFlags{
+		codeword:   fs.String("pad-codeword", "idle", "Fill unused codeword slots with: idle, or last-address to repeat the latest address codeword (for bench tests)"),
+		padTo:      fs.String("pad-to", "batch", "Pad the last batch to: batch (standard), or frame to end with the last frame in use"),
+		interleave: fs.Int("interleave", 0, "Interleave message codewords in blocks of this many, 2-32, to survive fades (0 = off; needs a decoder that knows the scheme)"),
+		terminator: fs.String("terminator", "none", "End alphanumeric messages with: none, etx, or eot, for pagers that expect a terminator"),
+		alphaFill:  fs.String("alpha-fill", "zero", "Fill the rest of the last codeword of alphanumeric messages with: zero, etx, or etb, for pagers that show zero fill as junk"),
+	}
+}
+
+// options returns the encoder options the layout flags select.
+func (p *layoutFlags) options() []pocsag.Option {
+	var opts []pocsag.Option
+	switch *p.codeword {
+	case "idle":
+	case "last-address":
+		opts = append(opts, pocsag.WithPaddingCodeword(pocsag.PadLastAddress))
+	default:
+		fail(exitUsage, "Invalid padding codeword %q. Supported: idle, last-address", *p.codeword)
+	}
+	switch *p.padTo {
+	case "batch":
+	case "frame":
+		opts = append(opts, pocsag.WithPaddingStrategy(pocsag.PadToFrame))
+	default:
+		fail(exitUsage, "Invalid padding %q. Supported: batch, frame", *p.padTo)
+	}
+	switch {
+	case *p.interleave == 0:
+	case *p.interleave >= 2 && *p.interleave <= pocsag.MaxInterleaveDepth:
+		opts = append(opts, pocsag.WithInterleaving(*p.interleave))
+	default:
+		fail(exitUsage, "--interleave must be 0 (off) or 2 to %d codewords", pocsag.MaxInterleaveDepth)
+	}
+	if t := parseTerminator(*p.terminator); t != 0 {
+		opts = append(opts, pocsag.WithTerminator(t))
+	}
+	switch strings.ToLower(*p.alphaFill) {
+	case "zero":
+	case "etx":
+		opts = append(opts, pocsag.WithAlphaFill(pocsag.ETX))
+	case "etb":
+		opts = append(opts, pocsag.WithAlphaFill(pocsag.ETB))
+	default:
+		fail(exitUsage, "Invalid fill %q. Supported: zero, etx, etb", *p.alphaFill)
+	}
+	return opts
+}
+
+func terminatorFlag(fs *flag.FlagSet) *string {
+	return fs.String("terminator", "none", "Read alphanumeric messages up to this terminator: etx or eot, marking those without it partial (default: to the first control character)")
+}
+
+// parseTerminator returns the character --terminator names, or 0 for none.
+func parseTerminator(name string) byte {
+	switch strings.ToLower(name) {
+	case "none":
+		return 0
+	case "etx":
+		return pocsag.ETX
+	case "eot":
+		return pocsag.EOT
+	}
+	fail(exitUsage, "Invalid terminator %q. Supported: none, etx, eot", name)
+	return 0
+}
+
+func addressBookFlag(fs *flag.FlagSet) *string {
+	return fs.String("address-book", "", "YAML or CSV file of named pager addresses (name, address, function, baud, key, type)")
+}
+
+// loadAddressBook reads the address book at path, in CSV if the file
+// ends in .csv and in YAML otherwise.
+func loadAddressBook(path string) *pocsag.AddressBook {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fail(exitIO, "reading address book: %v", err)
+	}
+	format := "yaml"
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		format = "csv"
+	}
+	book, err := pocsag.LoadAddressBook(data, format)
+	if err != nil {
+		fail(exitUsage, "%s: %v", path, err)
+	}
+	return book
+}
+
+func decodeTypeFlag(fs *flag.FlagSet) *string {
+	return fs.String("type", "", "Decode every message as numeric or alpha, whatever its function (default: numeric on function 0, alpha otherwise)")
+}
+
+func detectTypeFlag(fs *flag.FlagSet) *bool {
+	return fs.Bool("detect-type", false, "Tell numeric from alpha messages by their content instead of their function, showing both readings when unsure")
+}
+
+// setDecodeType applies --type and --detect-type, and the types of the
+// address book entries that have one, to opts.
+func setDecodeType(opts *pocsag.DecodeOptions, payloadType string, detect bool, book *pocsag.AddressBook) {
+	if payloadType != "" && detect {
+		fail(exitUsage, "--type and --detect-type cannot be used together")
+	}
+	opts.DetectType = detect
+	switch normalizePayloadType(payloadType) {
+	case pocsag.PayloadTypeNumeric:
+		opts.ForceNumeric = true
+	case pocsag.PayloadTypeAlpha:
+		opts.ForceAlpha = true
+	default:
+		if payloadType != "" {
+			fail(exitUsage, "Invalid payload type. Supported types: numeric, alpha")
+		}
+	}
+	if book != nil {
+		opts.PayloadTypes = book.PayloadTypes()
+	}
+}
+
+// recipientFlags let a command page an address book entry by name.
+type recipientFlags struct {
+	to   *string
+	book *string
+}
+
+func addRecipientFlags(fs *flag.FlagSet) *recipientFlags {
+	return &recipientFlags{
+		to:   fs.String("to", "", "Page this address book entry by name instead of giving --address (needs --address-book)"),
+		book: addressBookFlag(fs),
+	}
+}
+
+// resolve fills in the address, function, and baud rate of the --to
+// entry, leaving those given on the command line. If the entry has a key
+// it fills in key too, unless given, and reports true; key is nil for
+// commands that cannot encrypt, which refuse such entries.
+func (r *recipientFlags) resolve(fs *flag.FlagSet, address, function *uint, baud *int, key *string) bool {
+	if *r.to == "" {
+		return false
+	}
+	if *r.book == "" {
+		fail(exitUsage, "--to needs --address-book")
+	}
+	if isSet(fs, "address", "a") {
+		fail(exitUsage, "--to and --address cannot be used together")
+	}
+	entry, ok := loadAddressBook(*r.book).Lookup(*r.to)
+	if !ok {
+		fail(exitUsage, "%q is not in %s", *r.to, *r.book)
+	}
+	*address = uint(entry.Address)
+	if !isSet(fs, "function", "f") {
+		*function = uint(entry.Function)
+	}
+	if entry.BaudRate != 0 && !isSet(fs, "baud", "b") {
+		*baud = entry.BaudRate
+	}
+	if entry.Key == "" {
+		return false
+	}
+	if key == nil {
+		fail(exitUsage, "pages to %s are encrypted, which this command cannot do", entry.Name)
+	}
+	if *key == "" {
+		*key = entry.Key
+	}
+	return true
+}
+
+func translitFlag(fs *flag.FlagSet, usage string) *string {
+	return fs.String("translit", "", usage)
+}
+
+// loadTransliterator returns the built-in transliteration with the custom
+// spellings in the file at path, if one is given.
+func loadTransliterator(path string) *pocsag.Transliterator {
+	if path == "" {
+		return pocsag.NewTransliterator(nil)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fail(exitIO, "reading transliteration file: %v", err)
+	}
+	t, err := pocsag.LoadTransliterator(data)
+	if err != nil {
+		fail(exitUsage, "%s: %v", path, err)
+	}
+	return t
+}
+
+// prepareMessages spells alphanumeric messages in ASCII, using the
+// custom spellings in translitPath, and runs them through the policies in
+// policyPath, so policies see the text that will be sent. It exits if a
+// message is refused. The other results list, per message, how the text
+// was changed and which characters of it were replaced.
+func prepareMessages(policyPath, translitPath string, messages []pocsag.MessageInfo) ([]pocsag.MessageInfo, [][]string, [][]pocsag.Substitution) {
+	translit := loadTransliterator(translitPath)
+	policies := []pocsag.MessagePolicy{translit}
+	if policyPath != "" {
+		data, err := os.ReadFile(policyPath)
+		if err != nil {
+			fail(exitIO, "reading policy file: %v", err)
+		}
+		loaded, err := pocsag.LoadPolicies(data)
+		if err != nil {
+			fail(exitUsage, "%s: %v", policyPath, err)
+		}
+		policies = append(policies, loaded...)
+	}
+	prepared, err := pocsag.ApplyPolicies(messages, policies...)
+	if err != nil {
+		fail(exitEncode, "refused by policy: %v", err)
+	}
+
+	enc := pocsag.NewEncoder(pocsag.WithTransliteration(translit))
+	warnings := make([][]string, len(messages))
+	substitutions := make([][]pocsag.Substitution, len(messages))
+	for i, msg := range messages {
+		warnings[i] = []string{}
+		substitutions[i] = enc.Substitutions(msg)
+		ascii := translit.Transform(msg).Message
+		if ascii != msg.Message {
+			spelled, unknown := 0, 0
+			for _, r := range msg.Message {
+				if r >= utf8.RuneSelf && translit.ToASCII(string(r)) == "?" {
+					unknown++
+				} else if r >= utf8.RuneSelf {
+					spelled++
+				}
+			}
+			if spelled > 0 {
+				warnings[i] = append(warnings[i], fmt.Sprintf("%s transliterated to ASCII", characters(spelled)))
+			}
+			if unknown > 0 {
+				warnings[i] = append(warnings[i], fmt.Sprintf("%s with no ASCII spelling sent as ?", characters(unknown)))
+			}
+		}
+		switch sent := prepared[i].Message; {
+		case sent == ascii:
+		case len(sent) < len(ascii) && strings.HasPrefix(ascii, sent):
+			warnings[i] = append(warnings[i], fmt.Sprintf("truncated from %d to %d characters", len(ascii), len(sent)))
+		default:
+			warnings[i] = append(warnings[i], "changed by policy")
+		}
+	}
+	return prepared, warnings, substitutions
+}
+
+// loadPostProcessors returns the name lookups of book and the fleet file
+// at fleetPath, and the post-processors configured in configPath. book may
+// be nil and the paths empty.
+func loadPostProcessors(book *pocsag.AddressBook, fleetPath, configPath string) []pocsag.PostProcessor {
+	var processors []pocsag.PostProcessor
+	if book != nil {
+		processors = append(processors, book)
+	}
+	if fleetPath != "" {
+		f, err := os.Open(fleetPath)
+		if err != nil {
+			fail(exitIO, "reading fleet file: %v", err)
+		}
+		fleet, err := pocsag.LoadFleet(f)
+		f.Close()
+		if err != nil {
+			fail(exitUsage, "%s: %v", fleetPath, err)
+		}
+		processors = append(processors, fleet)
+	}
+	if configPath != "" {
+		data, err := os.ReadFile(configPath)
+		if err != nil {
+			fail(exitIO, "reading post-processing file: %v", err)
+		}
+		loaded, err := pocsag.LoadPostProcessors(data)
+		if err != nil {
+			fail(exitUsage, "%s: %v", configPath, err)
+		}
+		processors = append(processors, loaded...)
+	}
+	return processors
+}
+
+// substitutionsJSON lists substitutions for JSON output.
+func substitutionsJSON(subs []pocsag.Substitution) []map[string]interface{} {
+	out := make([]map[string]interface{}, len(subs))
+	for i, sub := range subs {
+		out[i] = map[string]interface{}{
+			"offset":      sub.Offset,
+			"original":    string(sub.Original),
+			"replacement": sub.Replacement,
+		}
+	}
+	return out
+}
+
+// warnSubstitutions tells the operator on stderr which characters of a
+// message were replaced. label names the message, if there are several.
+func warnSubstitutions(label string, subs []pocsag.Substitution) {
+	for _, sub := range subs {
+		fmt.Fprintf(os.Stderr, "Warning: %s%q at offset %d sent as %q\n", label, string(sub.Original), sub.Offset, sub.Replacement)
+	}
+}
+
+// characters counts characters in words: "1 character", "2 characters".
+func characters(n int) string {
+	if n == 1 {
+		return "1 character"
+	}
+	return fmt.Sprintf("%d characters", n)
+}
+
+// printVersion handles --version for every command.
+func printVersion(show bool) {
+	if show {
+		fmt.Println(pocsag.GetFullVersionInfo())
+		os.Exit(0)
+	}
+}
+
+// checkBaud exits with an error unless baud is a POCSAG rate.
+func checkBaud(baud int) {
+	if baud != pocsag.BaudRate512 && baud != pocsag.BaudRate1200 && baud != pocsag.BaudRate2400 {
+		fail(exitUsage, "Invalid baud rate %d. Supported rates: 512, 1200, 2400", baud)
+	}
+}
+
+// isSet reports whether any of names was given on the command line.
+func isSet(fs *flag.FlagSet, names ...string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		for _, name := range names {
+			if f.Name == name {
+				set = true
+			}
+		}
+	})
+	return set
+}
+
+// audioFlags are the output options of the commands that write audio.
+type audioFlags struct {
+	output      *string
+	sampleRate  *int
+	wavFormat   *string
+	fileFormat  *string
+	launchDelay *time.Duration
+}
+
+func addAudioFlags(fs *flag.FlagSet, defaultOutput string) *audioFlags {
+	a := &audioFlags{}
+	a.output = fs.String("output", defaultOutput, "Output WAV file path, or - for stdout")
+	fs.StringVar(a.output, "o", defaultOutput, "Output WAV file path, or - for stdout")
+
+	a.sampleRate = fs.Int("rate", pocsag.SampleRate, "Output WAV sample rate in Hz (e.g. 8000 for SIP gateways)")
+	fs.IntVar(a.sampleRate, "r", pocsag.SampleRate, "Output WAV sample rate in Hz")
+
+	a.wavFormat = fs.String("wav-format", "pcm16", "Output WAV sample format: pcm16 or float32")
+
+	a.fileFormat = fs.String("format", "wav", "Output audio format: wav, flac, mp3, or opus (mp3/opus need a build with -tags ffmpeg)")
+
+	a.launchDelay = fs.Duration("launch-delay", 0, "Start the audio with this much silence, to the sample, e.g. 1.25ms for a simulcast transmitter")
+	return a
+}
+
+// parse checks the audio flags for baud and returns the file format and
+// the audio options. An output path left at its default takes the file
+// format's extension.
+func (a *audioFlags) parse(fs *flag.FlagSet, baud int) (pocsag.AudioFormat, []pocsag.Option) {
+	audioFormat, ok := parseWAVFormat(*a.wavFormat)
+	if !ok {
+		fail(exitUsage, "Invalid WAV format %q. Supported formats: pcm16, float32", *a.wavFormat)
+	}
+
+	a.checkSampleRate(baud)
+
+	audioFileFormat, err := pocsag.ParseAudioFormat(*a.fileFormat)
+	if err != nil {
+		fail(exitUsage, "%v", err)
+	}
+	if !isSet(fs, "output", "o") {
+		*a.output = strings.TrimSuffix(*a.output, ".wav") + audioFileFormat.FileExtension()
+	}
+
+	if *a.launchDelay < 0 {
+		fail(exitUsage, "--launch-delay must not be negative")
+	}
+
+	return audioFileFormat, []pocsag.Option{pocsag.WithSampleRate(*a.sampleRate), pocsag.WithWAVFormat(audioFormat), pocsag.WithLaunchDelay(*a.launchDelay)}
+}
+
+func (a *audioFlags) checkSampleRate(baud int) {
+	if *a.sampleRate < 2*baud {
+		fail(exitUsage, "Sample rate %d Hz is too low for %d baud", *a.sampleRate, baud)
+	}
+}
+
+// webhookFlags are the flags that POST decoded messages to a webhook.
+type webhookFlags struct {
+	url       *string
+	addresses *string
+	match     *string
+	retries   *int
+}
+
+func addWebhookFlags(fs *flag.FlagSet) *webhookFlags {
+	return &webhookFlags{
+		url:       fs.String("webhook", "", "POST each decoded message as JSON to this URL"),
+		addresses: fs.String("webhook-address", "", "Only forward messages for these comma-separated addresses"),
+		match:     fs.String("webhook-match", "", "Only forward messages whose text matches this regular expression"),
+		retries:   fs.Int("webhook-retries", pocsag.DefaultWebhookRetries, "Retry a failed webhook delivery this many times, with backoff (0 = no retries)"),
+	}
+}
+
+// webhook returns the Webhook the flags configure, or nil without --webhook.
+func (w *webhookFlags) webhook() *pocsag.Webhook {
+	if *w.url == "" {
+		return nil
+	}
+	filter, err := parseWebhookFilter(*w.addresses, *w.match)
+	if err != nil {
+		fail(exitUsage, "%v", err)
+	}
+	if *w.retries < 0 {
+		fail(exitUsage, "--webhook-retries must not be negative")
+	}
+	return pocsag.NewWebhook(pocsag.WebhookConfig{URL: *w.url, Filter: filter, MaxRetries: *w.retries})
+}
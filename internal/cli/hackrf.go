@@ -0,0 +1,263 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	pocsag "github.com/sqpp/pocsag-golang/v2"
+)
+
+func hackrfCommand(fs *flag.FlagSet) func() {
+	address := fs.Uint("address", 0, "Pager address (RIC) - REQUIRED")
+	fs.UintVar(address, "a", 0, "Pager address (RIC) - REQUIRED")
+
+	message := fs.String("message", "", "Message text to send - REQUIRED")
+	fs.StringVar(message, "m", "", "Message text to send - REQUIRED")
+
+	funcCode := fs.Uint("function", pocsag.FuncAlphanumeric, "2-bit POCSAG function value to transmit: 0, 1, 2, or 3")
+	fs.UintVar(funcCode, "f", pocsag.FuncAlphanumeric, "2-bit POCSAG function value to transmit: 0, 1, 2, or 3")
+
+	payloadType := fs.String("type", "", "Payload encoding: numeric or alpha - REQUIRED")
+
+	recipient := addRecipientFlags(fs)
+
+	input := fs.String("input", "", "Send the messages of a burst input file instead, each channel on the frequency its entries name")
+	fs.StringVar(input, "i", "", "Burst input file - short form")
+
+	baudRate := baudFlag(fs)
+
+	layout := addLayoutFlags(fs)
+
+	frequency := fs.String("freq", "", "Transmit frequency, e.g. 439987500, 439.9875M or 439987.5k - REQUIRED unless every --input entry names one")
+
+	deviation := fs.Float64("deviation", pocsag.DefaultDeviation, "FSK deviation in Hz")
+	invert := fs.Bool("invert", false, "Send bit 1 on the upper tone instead of the lower one")
+
+	txGain := fs.Int("gain", 20, "HackRF TX VGA gain in dB (0-47)")
+	fs.IntVar(txGain, "g", 20, "HackRF TX VGA gain in dB (0-47)")
+	amp := fs.Bool("amp", false, "Enable the HackRF RF amplifier (+14 dB)")
+
+	ppm := fs.Float64("ppm", 0, "Frequency correction for the HackRF's reference oscillator, in ppm")
+
+	sampleRate := fs.Int("sample-rate", pocsag.DefaultIQSampleRate, "IQ sample rate in Hz (HackRF supports 2-20 MHz)")
+
+	iqOutput := fs.String("iq-output", "", "Write the signed 8-bit IQ to this file instead of transmitting")
+
+	hackrfTransfer := fs.String("hackrf-transfer", "hackrf_transfer", "Path to the hackrf_transfer binary")
+
+	policyFile := policyFlag(fs)
+	translitFile := translitFlag(fs, "JSON file of custom spellings for characters outside ASCII, e.g. {\"ü\": \"ue\"}")
+
+	jsonOutput := jsonFlag(fs, "Output result as JSON")
+
+	version := versionFlag(fs)
+
+	return func() {
+		printVersion(*version)
+
+		if *input != "" && *recipient.to != "" {
+			fail(exitUsage, "--to and --input cannot be used together")
+		}
+		recipient.resolve(fs, address, funcCode, baudRate, nil)
+
+		if *input == "" && (*address == 0 || *message == "" || strings.TrimSpace(*payloadType) == "" || *frequency == "") {
+			usageError(fs, "Address, message, payload type, and frequency are required",
+				"",
+				"Usage examples:",
+				"  pocsag-hackrf -a 123456 -m \"HELLO WORLD\" --type alpha --freq 439.9875M",
+				"  pocsag-hackrf -a 123456 -m \"12345\" -f 0 --type numeric --freq 439.9875M -b 512 -g 30 --ppm -1.5",
+				"  pocsag-hackrf -a 123456 -m \"TEST\" --type alpha --freq 439.9875M --iq-output page.cs8",
+				"  pocsag-hackrf -i channels.json --type alpha",
+				"")
+		}
+
+		checkBaud(*baudRate)
+
+		var freqHz int64
+		if *frequency != "" {
+			var err error
+			freqHz, err = parseFrequency(*frequency)
+			if err != nil {
+				fail(exitUsage, "%v", err)
+			}
+		}
+
+		layoutOpts := layout.options()
+
+		if *txGain < 0 || *txGain > 47 {
+			fail(exitUsage, "Invalid gain %d. Supported range: 0-47 dB", *txGain)
+		}
+
+		if *sampleRate < 2000000 || *sampleRate > 20000000 {
+			fail(exitUsage, "Invalid sample rate %d. HackRF supports 2000000-20000000 Hz", *sampleRate)
+		}
+
+		var plan pocsag.ChannelPlan
+		if *input != "" {
+			data, err := readInput(*input)
+			if err != nil {
+				fail(exitIO, "reading input file: %v", err)
+			}
+			format := detectInputFormat(*input, data)
+			entries, err := parseMessages(data, format)
+			if err != nil {
+				fail(exitEncode, "parsing %s: %v", strings.ToUpper(format), err)
+			}
+			for i, entry := range entries {
+				if entry.Encrypt {
+					fail(exitUsage, "message %d is encrypted, which this command cannot do; make the burst with pocsag-burst", i+1)
+				}
+			}
+			messages, _, _ := prepareMessages(*policyFile, *translitFile, toMessageInfo(entries, *payloadType))
+			if plan, _, err = planChannels(entries, messages); err != nil {
+				fail(exitEncode, "%v", err)
+			}
+			for i, ch := range plan.Channels {
+				if ch.FrequencyHz == 0 {
+					if freqHz == 0 {
+						fail(exitUsage, "input entries name no frequency; give --freq")
+					}
+					plan.Channels[i].FrequencyHz = freqHz
+				}
+				if ch.BaudRate == 0 {
+					plan.Channels[i].BaudRate = *baudRate
+				}
+				checkBaud(plan.Channels[i].BaudRate)
+			}
+		} else {
+			normalizedPayloadType := normalizePayloadType(*payloadType)
+			if normalizedPayloadType == "" {
+				fail(exitUsage, "Invalid payload type. Supported types: numeric, alpha")
+			}
+			txMessages, _, _ := prepareMessages(*policyFile, *translitFile, []pocsag.MessageInfo{{
+				Address:     uint32(*address),
+				Message:     *message,
+				Function:    uint8(*funcCode),
+				PayloadType: normalizedPayloadType,
+			}})
+			*message = txMessages[0].Message // report what is sent
+			plan.Add(freqHz, *baudRate, txMessages[0])
+		}
+
+		for _, ch := range plan.Channels {
+			// HackRF One tunes 1 MHz to 6 GHz
+			if ch.FrequencyHz < 1000000 || ch.FrequencyHz > 6000000000 {
+				fail(exitUsage, "frequency %d Hz is outside the HackRF range (1 MHz - 6 GHz)", ch.FrequencyHz)
+			}
+		}
+
+		// Channels go out one after another, retuning in between
+		results := make([]map[string]interface{}, len(plan.Channels))
+		transmitted := false
+		for i, ch := range plan.Channels {
+			// A reference running ppm fast puts the carrier ppm high, so tune
+			// low by the same proportion.
+			tuneHz := int64(math.Round(float64(ch.FrequencyHz) / (1 + *ppm/1e6)))
+
+			packet := pocsag.NewEncoder(append(layoutOpts, pocsag.WithBaudRate(ch.BaudRate))...).CreateBurst(ch.Messages)
+			iq := pocsag.GenerateIQ(packet, ch.BaudRate, *sampleRate, *deviation, *invert)
+
+			// Pad with silence so the PA has settled before the preamble and
+			// the last codeword is out before hackrf_transfer closes the device.
+			pad := make([]byte, 2*(*sampleRate/10))
+			cs8 := append(append(pad, pocsag.IQToCS8(iq, 0.9)...), pad...)
+
+			iqFile := *iqOutput
+			if iqFile != "" && len(plan.Channels) > 1 {
+				iqFile = channelOutput(iqFile, ch.FrequencyHz)
+			}
+			if iqFile == "" {
+				tmp, err := os.CreateTemp("", "pocsag-*.cs8")
+				if err != nil {
+					fail(exitIO, "creating IQ file: %v", err)
+				}
+				tmp.Close()
+				iqFile = tmp.Name()
+				defer os.Remove(iqFile)
+			}
+			if err := os.WriteFile(iqFile, cs8, 0644); err != nil {
+				fail(exitIO, "writing IQ file: %v", err)
+			}
+
+			if *iqOutput == "" {
+				args := []string{
+					"-t", iqFile,
+					"-f", strconv.FormatInt(tuneHz, 10),
+					"-s", strconv.Itoa(*sampleRate),
+					"-x", strconv.Itoa(*txGain),
+					"-a", boolArg(*amp),
+				}
+				cmd := exec.Command(*hackrfTransfer, args...)
+				cmd.Stdout = os.Stderr
+				cmd.Stderr = os.Stderr
+				if err := cmd.Run(); err != nil {
+					os.Remove(iqFile)
+					fail(exitIO, "running %s: %v", *hackrfTransfer, err)
+				}
+				transmitted = true
+			}
+
+			durationSec := float64(len(packet)*8) / float64(ch.BaudRate)
+			results[i] = map[string]interface{}{
+				"frequency":  ch.FrequencyHz,
+				"tuned":      tuneHz,
+				"baud":       ch.BaudRate,
+				"count":      len(ch.Messages),
+				"duration_s": durationSec,
+			}
+			if *iqOutput != "" {
+				results[i]["iq_output"] = iqFile
+			}
+
+			if *jsonOutput {
+				continue
+			}
+			if transmitted {
+				fmt.Printf("✅ Transmitted on %.4f MHz (tuned %d Hz)\n", float64(ch.FrequencyHz)/1e6, tuneHz)
+			} else {
+				fmt.Printf("✅ Wrote IQ for %.4f MHz: %s\n", float64(ch.FrequencyHz)/1e6, iqFile)
+				fmt.Printf("   Send with: %s -t %s -f %d -s %d -x %d -a %s\n", *hackrfTransfer, iqFile, tuneHz, *sampleRate, *txGain, boolArg(*amp))
+			}
+			if *input == "" {
+				fmt.Printf("   Address: %d, Function: %d, Baud: %d, Deviation: %.0f Hz, Duration: %.2f s\n", *address, *funcCode, ch.BaudRate, *deviation, durationSec)
+			} else {
+				fmt.Printf("   Messages: %d, Baud: %d, Deviation: %.0f Hz, Duration: %.2f s\n", len(ch.Messages), ch.BaudRate, *deviation, durationSec)
+			}
+		}
+
+		if !*jsonOutput {
+			return
+		}
+		result := map[string]interface{}{
+			"success":     true,
+			"transmitted": transmitted,
+			"deviation":   *deviation,
+			"sample_rate": *sampleRate,
+		}
+		if *input != "" {
+			result["channels"] = results
+		} else {
+			for k, v := range results[0] {
+				if k != "count" {
+					result[k] = v
+				}
+			}
+			result["address"] = *address
+			result["function"] = *funcCode
+			result["message"] = *message
+		}
+		printJSON(os.Stdout, result)
+	}
+}
+
+func boolArg(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	pocsag "github.com/sqpp/pocsag-golang/v2"
+)
+
+// WriteManPages writes pocsag.1 and one pocsag-<command>.1 page per
+// subcommand into dir.
+func WriteManPages(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	pages := map[string]func(io.Writer){"pocsag.1": writeMainManPage}
+	for _, c := range Commands {
+		c := c
+		pages["pocsag-"+c.Name+".1"] = func(w io.Writer) { writeCommandManPage(w, c) }
+	}
+	for name, write := range pages {
+		var b strings.Builder
+		write(&b)
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(b.String()), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// roff escapes text for a man page line.
+func roff(s string) string {
+	s = strings.NewReplacer(`\`, `\e`, "-", `\-`).Replace(s)
+	if strings.HasPrefix(s, ".") || strings.HasPrefix(s, "'") {
+		s = `\&` + s
+	}
+	return s
+}
+
+func manHeader(w io.Writer, name string) {
+	fmt.Fprintf(w, ".TH %s 1 \"\" \"pocsag-golang %s\" \"User Commands\"\n", strings.ToUpper(roff(name)), roff(pocsag.Version))
+}
+
+func writeMainManPage(w io.Writer) {
+	manHeader(w, "pocsag")
+	fmt.Fprintln(w, ".SH NAME")
+	fmt.Fprintln(w, `pocsag \- POCSAG pager encoder and decoder`)
+	fmt.Fprintln(w, ".SH SYNOPSIS")
+	fmt.Fprintln(w, `.B pocsag`)
+	fmt.Fprintln(w, `\fIcommand\fR [\fIflags\fR]`)
+	fmt.Fprintln(w, ".SH DESCRIPTION")
+	fmt.Fprintln(w, "Encodes pages to POCSAG audio, decodes recordings, and drives an RTL\\-SDR or HackRF.")
+	fmt.Fprintln(w, "Flags given without a command run")
+	fmt.Fprintln(w, ".BR encode ,")
+	fmt.Fprintln(w, "as the original encoder did.")
+	fmt.Fprintln(w, ".SH COMMANDS")
+	for _, c := range Commands {
+		fmt.Fprintf(w, ".TP\n.B %s\n%s. See\n.BR pocsag\\-%s (1).\n", c.Name, roff(c.Summary), c.Name)
+	}
+	fmt.Fprintln(w, ".TP\n.BI completion \" shell\"\nPrint a bash, zsh, or fish completion script.")
+	fmt.Fprintln(w, ".TP\n.BI man \" [dir]\"\nWrite these man pages to \\fIdir\\fR (default: the current directory).")
+	fmt.Fprintln(w, ".SH SEE ALSO")
+	var refs []string
+	for _, c := range Commands {
+		refs = append(refs, fmt.Sprintf(".BR pocsag\\-%s (1)", c.Name))
+	}
+	fmt.Fprintln(w, strings.Join(refs, ",\n"))
+}
+
+func writeCommandManPage(w io.Writer, c *Command) {
+	manHeader(w, "pocsag-"+c.Name)
+	fmt.Fprintln(w, ".SH NAME")
+	fmt.Fprintf(w, "pocsag\\-%s \\- %s\n", c.Name, roff(c.Summary))
+	fmt.Fprintln(w, ".SH SYNOPSIS")
+	fmt.Fprintf(w, ".B pocsag %s\n[\\fIflags\\fR]\n", c.Name)
+	if c.standalone() {
+		fmt.Fprintf(w, ".br\n.B %s\n[\\fIflags\\fR]\n", roff(c.Binary))
+	}
+	fmt.Fprintln(w, ".SH OPTIONS")
+	for _, f := range flagInfos(c.FlagSet(c.Binary)) {
+		fmt.Fprintln(w, ".TP")
+		names := make([]string, len(f.Names))
+		for i, s := range f.Spellings() {
+			names[i] = `\fB` + roff(s) + `\fR`
+		}
+		line := strings.Join(names, ", ")
+		if !f.IsBool {
+			line += ` \fIvalue\fR`
+		}
+		fmt.Fprintln(w, line)
+		usage := roff(f.Usage)
+		if f.Value != "" && !(f.IsBool && f.Value == "false") && !strings.Contains(f.Usage, "(default") {
+			usage += fmt.Sprintf(" (default: %s)", roff(f.Value))
+		}
+		fmt.Fprintln(w, usage)
+	}
+	fmt.Fprintln(w, ".SH SEE ALSO")
+	fmt.Fprintln(w, ".BR pocsag (1)")
+}
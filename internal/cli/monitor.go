@@ -0,0 +1,502 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	pocsag "github.com/sqpp/pocsag-golang/v2"
+	"github.com/sqpp/pocsag-golang/v2/gpsd"
+	"github.com/sqpp/pocsag-golang/v2/sdr/rtltcp"
+)
+
+// readChunk is how many IQ samples are read from rtl_tcp at a time: about
+// 34 ms at the default sample rate.
+const readChunk = 8192
+
+func monitorCommand(fs *flag.FlagSet) func() {
+	frequency := fs.String("freq", "", "Receive frequency, e.g. 439987500, 439.9875M or 439987.5k - REQUIRED unless --device or --channels is given")
+
+	channelList := fs.String("channels", "", "Decode these comma-separated frequencies at once, in MHz or with a k/M/G suffix, e.g. 439.9875,439.975 (--freq then tunes the dongle between them)")
+
+	server := fs.String("rtl-tcp", rtltcp.DefaultAddress, "rtl_tcp server address (host:port)")
+
+	device := deviceFlag(fs)
+
+	baudRate := baudFlag(fs)
+
+	gain := fs.Float64("gain", 0, "Tuner gain in dB (0 = automatic)")
+	fs.Float64Var(gain, "g", 0, "Tuner gain in dB (0 = automatic)")
+
+	ppm := fs.Int("ppm", 0, "Frequency correction for the dongle's reference oscillator, in ppm")
+
+	biasTee := fs.Bool("bias-tee", false, "Power an active antenna or LNA through the coax")
+
+	noAFC := fs.Bool("no-afc", false, "Disable automatic frequency correction of tuning error")
+	gapTimeout := gapTimeoutFlag(fs)
+
+	sampleRate := fs.Int("sample-rate", 240000, "IQ sample rate in Hz (with --channels, default: the lowest that covers them)")
+
+	jsonOutput := jsonFlag(fs, "Print each message as a line of JSON")
+	format := recordFormatFlag(fs)
+	output := fs.String("output", "text", "Print messages as: text, or ndjson for one JSON object per line, each written out as it arrives, for jq, Vector, or Fluentd (same as --json)")
+
+	keyStr := decryptKeyFlag(fs)
+	numericKeyStr := numericKeyFlag(fs)
+	keySource := keySourceFlag(fs)
+	keyring := keyringFlag(fs)
+
+	raw := fs.Bool("raw", false, "Include each message's received codewords, for checking BCH and parity independently")
+
+	translitFile := translitFlag(fs, "JSON file of custom spellings, as given to encode, to turn back into their characters")
+
+	fleetFile := fs.String("fleet", "", "CSV file of address,name lines naming the pagers, to show with their messages")
+	addressBook := addressBookFlag(fs)
+	payloadType := decodeTypeFlag(fs)
+	detectType := detectTypeFlag(fs)
+	terminator := terminatorFlag(fs)
+	postprocessFile := fs.String("postprocess", "", "JSON file of keyword tags and redaction patterns to apply to each message")
+
+	webhookOpts := addWebhookFlags(fs)
+
+	reassembleTimeout := fs.Duration("reassemble", 0, "Join [n/m] continuation pages, waiting this long for missing ones (e.g. 30s; 0 = off)")
+
+	forwardTarget := fs.String("forward", "", "Forward messages to a serial device or tcp://host:port")
+	forwardFormat := fs.String("forward-format", "json", "Forward framing: json (one object per line), kiss (APRS over KISS/AX.25), or aprs-is")
+	callsign := fs.String("callsign", "", "Source callsign for kiss and aprs-is forwarding")
+	passcode := fs.String("passcode", "", "APRS-IS passcode (default: receive-only login)")
+	latitude := fs.Float64("lat", 0, "Latitude for APRS objects (with --lon, sends pages as objects)")
+	longitude := fs.Float64("lon", 0, "Longitude for APRS objects")
+
+	gpsdAddress := fs.String("gpsd", "", "Stamp each message with the receiver's position from gpsd at this address, e.g. "+gpsd.DefaultAddress+", for coverage surveys")
+	staticPosition := fs.String("position", "", "Stamp each message with this fixed position: lat,lon or lat,lon,alt in degrees and metres")
+
+	analyticsTarget := fs.String("analytics", "", "Write traffic analytics to an InfluxDB write URL (http://host:8086/api/v2/write?org=...&bucket=...), a file, or a pipe")
+	analyticsFormat := fs.String("analytics-format", "influx", "Analytics format: influx (line protocol) or sql (a PostgreSQL script to pipe into psql)")
+	analyticsInterval := fs.Duration("analytics-interval", pocsag.DefaultAnalyticsInterval, "Period to aggregate traffic over")
+	analyticsToken := fs.String("analytics-token", "", "InfluxDB API token (default: $INFLUX_TOKEN)")
+
+	version := versionFlag(fs)
+
+	return func() {
+		printVersion(*version)
+
+		if *frequency == "" && *device == "" && *channelList == "" {
+			usageError(fs, "Frequency or audio device required",
+				"",
+				"Usage examples:",
+				"  rtl_tcp -a 127.0.0.1 &",
+				"  pocsag-rx --freq 439.9875M",
+				"  pocsag-rx --freq 466.075M -b 512 --gain 38.6 --ppm 42 --rtl-tcp pi.local:1234 --json",
+				"  pocsag-rx --freq 439.9875M --output ndjson | jq -c 'select(.address == 123456)'",
+				"  pocsag-rx --freq 439.9875M --forward tcp://127.0.0.1:8001 --forward-format kiss --callsign N0CALL-10",
+				"  pocsag-rx --channels 439.9875,439.975,439.9625",
+				"  pocsag-rx --freq 439.9875M --gpsd 127.0.0.1:2947 --format ndjson >survey.ndjson",
+				"  pocsag-rx --device hw:1 -b 512",
+				"")
+		}
+
+		checkBaud(*baudRate)
+
+		var freqHz int64
+		var channels []int64
+		if *device != "" {
+			if *frequency != "" || *channelList != "" {
+				fail(exitUsage, "--device cannot be used with --freq or --channels; tune the radio feeding the sound card instead")
+			}
+		} else {
+			var err error
+			if *channelList != "" {
+				if channels, err = parseChannels(*channelList); err != nil {
+					fail(exitUsage, "%v", err)
+				}
+				freqHz = channelCenter(channels)
+				if !isSet(fs, "sample-rate") {
+					*sampleRate = channelSampleRate(channels)
+				}
+			}
+			if *frequency != "" {
+				if freqHz, err = parseFrequency(*frequency); err != nil {
+					fail(exitUsage, "%v", err)
+				}
+			}
+			if freqHz > math.MaxUint32 {
+				fail(exitUsage, "frequency %d Hz is out of range", freqHz)
+			}
+
+			// The RTL2832U only supports these two ranges
+			if !(*sampleRate > 225000 && *sampleRate <= 300000) && !(*sampleRate > 900000 && *sampleRate <= 3200000) {
+				fail(exitUsage, "Invalid sample rate %d. RTL-SDR supports 225001-300000 and 900001-3200000 Hz", *sampleRate)
+			}
+		}
+
+		switch *output {
+		case "text":
+		case "ndjson":
+			*jsonOutput = true
+		default:
+			fail(exitUsage, "Invalid output %q. Supported outputs: text, ndjson", *output)
+		}
+		records := newRecordWriter(*format)
+		if *jsonOutput {
+			if records != nil {
+				fail(exitUsage, "--json (or --output ndjson) and --format cannot be used together")
+			}
+			records = newRecordWriter("ndjson")
+		}
+
+		decodeOpts := pocsag.DecodeOptions{IncludeRaw: *raw || *format == "pcapng", Keys: loadKeyring(*keyring), KeyProvider: loadKeyProvider(*keySource), NumericKey: numericKey(*numericKeyStr), DisableAFC: *noAFC, GapTimeout: *gapTimeout}
+		if *keyStr != "" {
+			decodeOpts.Encryption = pocsag.EncryptionConfig{
+				Method: pocsag.EncryptionAES256,
+				Key:    pocsag.KeyFromPassword(*keyStr, 32),
+			}
+		}
+
+		var book *pocsag.AddressBook
+		if *addressBook != "" {
+			book = loadAddressBook(*addressBook)
+		}
+		setDecodeType(&decodeOpts, *payloadType, *detectType, book)
+		decodeOpts.Terminator = parseTerminator(*terminator)
+
+		var translit *pocsag.Transliterator
+		if *translitFile != "" {
+			translit = loadTransliterator(*translitFile)
+		}
+
+		processors := loadPostProcessors(book, *fleetFile, *postprocessFile)
+
+		var gps *gpsd.Client
+		switch {
+		case *gpsdAddress != "" && *staticPosition != "":
+			fail(exitUsage, "--gpsd and --position cannot be used together")
+		case *gpsdAddress != "":
+			var err error
+			gps, err = gpsd.Dial(*gpsdAddress)
+			if err != nil {
+				fail(exitIO, "%v", err)
+			}
+			defer gps.Close()
+			processors = append(processors, pocsag.StampPosition(gps))
+		case *staticPosition != "":
+			pos, err := pocsag.ParseStaticPosition(*staticPosition)
+			if err != nil {
+				fail(exitUsage, "%v", err)
+			}
+			processors = append(processors, pocsag.StampPosition(pos))
+		}
+
+		webhook := webhookOpts.webhook()
+
+		var forwarder *pocsag.Forwarder
+		if *forwardTarget != "" {
+			fwd, conn, err := pocsag.DialForwarder(*forwardTarget, pocsag.ForwarderConfig{
+				Format:    pocsag.ForwardFormat(*forwardFormat),
+				Callsign:  *callsign,
+				Passcode:  *passcode,
+				Objects:   *latitude != 0 || *longitude != 0,
+				Latitude:  *latitude,
+				Longitude: *longitude,
+			})
+			if err != nil {
+				fail(exitIO, "%v", err)
+			}
+			defer conn.Close()
+			forwarder = fwd
+		}
+
+		var analytics *pocsag.Analytics
+		if *analyticsTarget != "" {
+			if *analyticsInterval <= 0 {
+				fail(exitUsage, "--analytics-interval must be positive")
+			}
+			token := *analyticsToken
+			if token == "" {
+				token = os.Getenv("INFLUX_TOKEN")
+			}
+			sink, conn, err := pocsag.DialAnalytics(*analyticsTarget, pocsag.AnalyticsConfig{
+				Format:   pocsag.AnalyticsFormat(*analyticsFormat),
+				Interval: *analyticsInterval,
+				Token:    token,
+			})
+			if err != nil {
+				fail(exitIO, "%v", err)
+			}
+			defer conn.Close()
+			analytics = sink
+		}
+
+		var decoder iqDecoder
+		if channels != nil {
+			channelizer, err := pocsag.NewChannelizer(*sampleRate, freqHz, channels, *baudRate, decodeOpts)
+			if err != nil {
+				fail(exitUsage, "%v; choose --freq and --sample-rate to cover every channel", err)
+			}
+			decoder = channelizer
+		} else if *device == "" {
+			decoder = pocsag.NewIQDecoder(*sampleRate, *baudRate, decodeOpts)
+		}
+
+		var radio *rtltcp.Client
+		if *device == "" {
+			var err error
+			radio, err = rtltcp.Dial(*server)
+			if err != nil {
+				fail(exitIO, "%v", err)
+			}
+			defer radio.Close()
+
+			if err := configureRadio(radio, uint32(freqHz), uint32(*sampleRate), *gain, *ppm, *biasTee); err != nil {
+				fail(exitIO, "%v", err)
+			}
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		if radio != nil {
+			go func() {
+				// Unblock the read loop on Ctrl-C
+				<-ctx.Done()
+				radio.Close()
+			}()
+			if channels != nil {
+				fmt.Fprintf(os.Stderr, "Listening on %s MHz at %d baud via %s, %s tuner tuned to %.4f MHz (Ctrl-C to stop)\n", formatChannels(channels), *baudRate, *server, radio.TunerType(), float64(freqHz)/1e6)
+			} else {
+				fmt.Fprintf(os.Stderr, "Listening on %.4f MHz at %d baud via %s, %s tuner (Ctrl-C to stop)\n", float64(freqHz)/1e6, *baudRate, *server, radio.TunerType())
+			}
+		} else {
+			fmt.Fprintf(os.Stderr, "Listening to sound card %s at %d baud (Ctrl-C to stop)\n", *device, *baudRate)
+		}
+		if gps != nil {
+			fmt.Fprintf(os.Stderr, "Stamping messages with positions from gpsd at %s\n", *gpsdAddress)
+		}
+		gpsLost := false
+
+		// Webhook retries must not hold up the read loop, or IQ is lost
+		var hooks *webhookQueue
+		if webhook != nil {
+			hooks = startWebhookQueue(ctx, webhook, webhookQueueSize)
+		}
+
+		deliver := func(msg pocsag.DecodedMessage) {
+			if translit != nil {
+				msg = restoreSpellings(translit, msg)
+			}
+			msg = pocsag.ApplyPostProcessors(msg, processors...)
+			if gps != nil && !gpsLost && gps.Err() != nil {
+				// Keep receiving; messages go out without a position
+				gpsLost = true
+				fmt.Fprintf(os.Stderr, "Warning: lost gpsd: %v\n", gps.Err())
+			}
+			if records != nil {
+				records.write(msg, *baudRate, time.Now())
+			} else {
+				stamp := time.Now().Format("15:04:05")
+				if msg.FrequencyHz != 0 {
+					stamp += fmt.Sprintf(" %s MHz", mhz(msg.FrequencyHz))
+				}
+				if msg.Position != nil {
+					stamp += fmt.Sprintf(" %.5f,%.5f", msg.Position.Latitude, msg.Position.Longitude)
+				}
+				var signal []string
+				if msg.FrequencyOffsetHz != 0 {
+					signal = append(signal, fmt.Sprintf("%+.1f kHz", float64(msg.FrequencyOffsetHz)/1000))
+				}
+				if msg.SNRValid {
+					signal = append(signal, fmt.Sprintf("%.1f dBFS", msg.RSSI), fmt.Sprintf("SNR %.1f dB", msg.SNR))
+				}
+				if len(signal) > 0 {
+					stamp += " (" + strings.Join(signal, ", ") + ")"
+				}
+				fmt.Printf("%s %s\n", stamp, msg.String())
+				printCodewords(msg)
+			}
+
+			if hooks != nil {
+				hooks.send(msg)
+			}
+			if forwarder != nil {
+				if err := forwarder.Send(msg); err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+				}
+			}
+			if analytics != nil {
+				if msg.BaudRate == 0 {
+					msg.BaudRate = *baudRate
+				}
+				analytics.Record(pocsag.MessageRecord{DecodedMessage: msg, Time: time.Now()})
+			}
+		}
+		flushAnalytics := func(now time.Time) {
+			if _, err := analytics.Flush(now); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+		}
+		if analytics != nil {
+			go func() {
+				ticker := time.NewTicker(analytics.Interval())
+				defer ticker.Stop()
+				for {
+					select {
+					case <-ctx.Done():
+						return
+					case now := <-ticker.C:
+						flushAnalytics(now)
+					}
+				}
+			}()
+		}
+
+		var mu sync.Mutex
+		var reassembler *pocsag.Reassembler
+		emit := func(msg pocsag.DecodedMessage) {
+			mu.Lock()
+			defer mu.Unlock()
+			if reassembler == nil {
+				deliver(msg)
+				return
+			}
+			for _, m := range reassembler.Add(msg, time.Now()) {
+				deliver(m)
+			}
+		}
+		if *reassembleTimeout > 0 {
+			reassembler = pocsag.NewReassembler(*reassembleTimeout)
+			go func() {
+				// Deliver chains whose remaining pages never arrive
+				ticker := time.NewTicker(time.Second)
+				defer ticker.Stop()
+				for {
+					select {
+					case <-ctx.Done():
+						return
+					case now := <-ticker.C:
+						mu.Lock()
+						for _, m := range reassembler.Expire(now) {
+							deliver(m)
+						}
+						mu.Unlock()
+					}
+				}
+			}()
+		}
+
+		var readErr error
+		if radio != nil {
+			iq := make([]complex64, readChunk)
+			for {
+				n, err := radio.ReadIQ(iq)
+				for _, msg := range decoder.Write(iq[:n]) {
+					emit(msg)
+				}
+				if err != nil {
+					readErr = fmt.Errorf("reading from rtl_tcp: %v", err)
+					break
+				}
+			}
+			for _, msg := range decoder.Flush() {
+				emit(msg)
+			}
+		} else if err := captureMessages(ctx, *device, *baudRate, decodeOpts, emit); err != nil {
+			readErr = fmt.Errorf("capturing audio: %v", err)
+		}
+		if reassembler != nil {
+			mu.Lock()
+			for _, msg := range reassembler.Flush() {
+				deliver(msg)
+			}
+			mu.Unlock()
+		}
+		if analytics != nil {
+			flushAnalytics(time.Now())
+		}
+		if hooks != nil {
+			hooks.close()
+		}
+		if ctx.Err() == nil && readErr != nil {
+			fail(exitIO, "%v", readErr)
+		}
+	}
+}
+
+// iqDecoder is an IQDecoder or, with --channels, a Channelizer.
+type iqDecoder interface {
+	Write(iq []complex64) []pocsag.DecodedMessage
+	Flush() []pocsag.DecodedMessage
+}
+
+// channelRates are the sample rates --channels picks from, the lowest that
+// covers the channels first. The RTL2832U drops samples above 2.4 MHz.
+var channelRates = []int{240000, 1024000, 2048000, 2400000}
+
+// channelCenter is the frequency midway between the outermost channels.
+func channelCenter(channels []int64) int64 {
+	lo, hi := channels[0], channels[0]
+	for _, f := range channels {
+		lo, hi = min(lo, f), max(hi, f)
+	}
+	return (lo + hi) / 2
+}
+
+// channelSampleRate is the lowest of channelRates that covers channels
+// with a channel's width to spare either side, or the highest if none do.
+func channelSampleRate(channels []int64) int {
+	center := channelCenter(channels)
+	var reach int64
+	for _, f := range channels {
+		reach = max(reach, f-center, center-f)
+	}
+	for _, rate := range channelRates {
+		if reach+12500 <= int64(rate)/2 {
+			return rate
+		}
+	}
+	return channelRates[len(channelRates)-1]
+}
+
+// formatChannels lists frequencies in MHz: "439.9875, 439.975".
+func formatChannels(channels []int64) string {
+	list := make([]string, len(channels))
+	for i, f := range channels {
+		list[i] = mhz(f)
+	}
+	return strings.Join(list, ", ")
+}
+
+// mhz formats a frequency in MHz with no trailing zeros.
+func mhz(hz int64) string {
+	return strconv.FormatFloat(float64(hz)/1e6, 'f', -1, 64)
+}
+
+// configureRadio tunes the dongle. gain is in dB; 0 selects the tuner AGC.
+func configureRadio(radio *rtltcp.Client, freq, sampleRate uint32, gain float64, ppm int, biasTee bool) error {
+	if err := radio.SetSampleRate(sampleRate); err != nil {
+		return err
+	}
+	if ppm != 0 {
+		if err := radio.SetFrequencyCorrection(ppm); err != nil {
+			return err
+		}
+	}
+	if err := radio.SetFrequency(freq); err != nil {
+		return err
+	}
+	if biasTee {
+		if err := radio.SetBiasTee(true); err != nil {
+			return err
+		}
+	}
+	if gain == 0 {
+		return radio.SetAutoGain()
+	}
+	return radio.SetGain(gain)
+}
@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	pocsag "github.com/sqpp/pocsag-golang/v2"
+)
+
+func selftestCommand(fs *flag.FlagSet) func() {
+	jsonOutput := jsonFlag(fs, "Output result as JSON")
+
+	version := versionFlag(fs)
+
+	return func() {
+		printVersion(*version)
+
+		results := pocsag.SelfTest()
+		failed := 0
+		for _, r := range results {
+			if r.Err != nil {
+				failed++
+			}
+		}
+
+		if *jsonOutput {
+			list := make([]map[string]interface{}, len(results))
+			for i, r := range results {
+				list[i] = map[string]interface{}{
+					"name":     r.Name,
+					"baud":     r.BaudRate,
+					"inverted": r.Inverted,
+					"passed":   r.Err == nil,
+				}
+				if r.Err != nil {
+					list[i]["error"] = r.Err.Error()
+				}
+			}
+			printJSON(os.Stdout, map[string]interface{}{
+				"success": failed == 0,
+				"version": pocsag.Version,
+				"passed":  len(results) - failed,
+				"failed":  failed,
+				"results": list,
+			})
+		} else {
+			fmt.Printf("pocsag %s self-test\n", pocsag.Version)
+			for _, r := range results {
+				polarity := "normal"
+				if r.Inverted {
+					polarity = "inverted"
+				}
+				mark := "✅"
+				if r.Err != nil {
+					mark = "❌"
+				}
+				fmt.Printf("%s %4d baud, %-8s %s", mark, r.BaudRate, polarity, r.Name)
+				if r.Err != nil {
+					fmt.Printf(": %v", r.Err)
+				}
+				fmt.Println()
+			}
+			fmt.Println()
+			if failed == 0 {
+				fmt.Printf("✅ All %d tests passed\n", len(results))
+			} else {
+				fmt.Printf("❌ %d of %d tests failed\n", failed, len(results))
+			}
+		}
+		if failed > 0 {
+			os.Exit(exitFailed)
+		}
+	}
+}
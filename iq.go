@@ -0,0 +1,294 @@
+package pocsag
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// DefaultDeviation is the standard POCSAG FSK deviation in Hz.
+const DefaultDeviation = 4500.0
+
+// GenerateIQ renders POCSAG bytes as complex baseband FSK for an SDR
+// transmitter: phase-continuous NRZ FSK at ±deviation around the tuned
+// frequency, bit 1 on the lower tone and bit 0 on the upper one as in
+// ITU-R M.584-2. Set invert for networks using the opposite sense.
+func GenerateIQ(pocsagData []byte, baudRate int, sampleRate int, deviation float64, invert bool) []complex64 {
+	samplesPerBit := float64(sampleRate) / float64(baudRate)
+	numBits := len(pocsagData) * 8
+	iq := make([]complex64, int(math.Round(float64(numBits)*samplesPerBit)))
+
+	phase := 0.0
+	for bitIndex := 0; bitIndex < numBits; bitIndex++ {
+		bit := (pocsagData[bitIndex/8] >> (7 - uint(bitIndex%8))) & 1
+		dev := deviation
+		if (bit == 1) != invert {
+			dev = -deviation
+		}
+		step := 2.0 * math.Pi * dev / float64(sampleRate)
+
+		start := int(math.Round(float64(bitIndex) * samplesPerBit))
+		end := int(math.Round(float64(bitIndex+1) * samplesPerBit))
+		for i := start; i < end && i < len(iq); i++ {
+			phase = math.Mod(phase+step, 2.0*math.Pi)
+			iq[i] = complex(float32(math.Cos(phase)), float32(math.Sin(phase)))
+		}
+	}
+	return iq
+}
+
+// IQToCS8 converts IQ samples in [-1, 1] to interleaved signed 8-bit I/Q,
+// the format hackrf_transfer reads, scaled by gain (0-1).
+func IQToCS8(iq []complex64, gain float64) []byte {
+	out := make([]byte, 2*len(iq))
+	scale := 127.0 * math.Max(0, math.Min(1, gain))
+	for i, s := range iq {
+		out[2*i] = byte(int8(math.Round(float64(real(s)) * scale)))
+		out[2*i+1] = byte(int8(math.Round(float64(imag(s)) * scale)))
+	}
+	return out
+}
+
+// iqAudioRate is the rate IQDecoder decimates to before demodulating. It
+// leaves 20+ samples per bit at 2400 baud while keeping the slicer cheap at
+// multi-MHz SDR rates.
+const iqAudioRate = 48000
+
+const (
+	// afcBlockBits is how many bits of signal each AFC estimate is made
+	// over: enough to hold both tones whatever the data.
+	afcBlockBits = 16
+	// afcMaxOffset is the largest offset AFC corrects, in Hz: half a 12.5
+	// kHz channel, beyond which the signal is the next channel's.
+	afcMaxOffset = 6000
+	// afcCoarseStep is the residual offset in Hz beyond which AFC jumps
+	// straight to its estimate; smaller ones are followed gradually with
+	// afcFineGain.
+	afcCoarseStep = 1000
+	afcFineGain   = 0.25
+)
+
+// IQDecoder decodes POCSAG from complex baseband centered on the channel,
+// as delivered by an SDR receiver, in arbitrary pieces. Each block of
+// samples is averaged down to about 48 kHz (a boxcar channel filter),
+// FM-demodulated with a quadrature discriminator, and fed to a
+// StreamDecoder.
+//
+// Automatic frequency correction (AFC) follows tuning error from the
+// dongle's reference oscillator or the transmitter: every 16 bits of
+// signal it estimates the carrier's offset from the midpoint of the two
+// FSK tones, jumping to offsets of more than 1 kHz and easing towards
+// smaller ones, and shifts the samples back before demodulating. Offsets
+// up to 6 kHz are corrected. The estimate is reported with each message
+// as FrequencyOffsetHz, and the strength and SNR of the transmission it
+// came in as RSSI and SNR.
+type IQDecoder struct {
+	decim     int
+	scale     float32 // 1/decim
+	audioRate float64
+	sum       complex64
+	count     int
+	prev      complex64
+	audio     []float32
+	stream    *StreamDecoder
+
+	// AFC
+	afc        bool
+	offset     float64    // estimated carrier offset, Hz
+	nco        complex128 // shifts the carrier back by offset
+	ncoStep    complex128
+	block      []float64 // instantaneous frequencies, Hz
+	blockPower float64   // sum of the block's sample powers
+	blockLen   int
+
+	// The current transmission, over its blocks of FSK
+	power  float64 // sum of the blocks' mean sample power, full scale 1
+	tone   float64 // sum of the blocks' squared half tone shift
+	noise  float64 // sum of the blocks' squared spread about the tones
+	blocks int
+	quiet  int // blocks without FSK since the last one
+}
+
+// NewIQDecoder creates an IQDecoder for IQ samples at sampleRate. opts
+// apply as for NewStreamDecoder; DisableAFC turns off frequency
+// correction.
+func NewIQDecoder(sampleRate, baudRate int, opts DecodeOptions) *IQDecoder {
+	decim := sampleRate / iqAudioRate
+	if decim < 1 {
+		decim = 1
+	}
+	audioRate := sampleRate / decim
+	return &IQDecoder{
+		decim:     decim,
+		scale:     1 / float32(decim),
+		audioRate: float64(audioRate),
+		stream:    NewStreamDecoder(audioRate, baudRate, opts),
+		afc:       !opts.DisableAFC,
+		nco:       1,
+		ncoStep:   1,
+		blockLen:  afcBlockBits * audioRate / baudRate,
+	}
+}
+
+// Write demodulates iq and returns the messages completed by it.
+func (d *IQDecoder) Write(iq []complex64) []DecodedMessage {
+	d.audio = d.audio[:0]
+	for _, s := range iq {
+		d.sum += s
+		d.count++
+		if d.count < d.decim {
+			continue
+		}
+		x := d.sum * complex(d.scale, 0)
+		d.sum, d.count = 0, 0
+		if d.afc {
+			x = complex64(complex128(x) * d.nco)
+			d.nco *= d.ncoStep
+		}
+
+		// The phase step between samples is the instantaneous frequency
+		p := x * complex(real(d.prev), -imag(d.prev))
+		d.prev = x
+		f := math.Atan2(float64(imag(p)), float64(real(p))) * d.audioRate / (2 * math.Pi)
+		// Full deviation maps to about half of the 16-bit range
+		d.audio = append(d.audio, float32(f*16384/DefaultDeviation))
+
+		d.blockPower += float64(real(x)*real(x) + imag(x)*imag(x))
+		if d.block = append(d.block, f); len(d.block) == d.blockLen {
+			d.measure()
+			d.block, d.blockPower = d.block[:0], 0
+		}
+	}
+	// Keep the NCO's rounding errors from growing
+	d.nco /= complex(cmplx.Abs(d.nco), 0)
+
+	messages := d.stream.Write(d.audio)
+	d.label(messages)
+	return messages
+}
+
+// measure adds a block of instantaneous frequencies to the transmission's
+// measurements and updates the offset estimate from it, unless the block
+// is not all FSK: noise, an unmodulated carrier, or the start or end of a
+// transmission.
+func (d *IQDecoder) measure() {
+	var mean float64
+	for _, f := range d.block {
+		mean += f
+	}
+	mean /= float64(len(d.block))
+
+	// Split the block into its two tones
+	var hi, lo float64
+	var nHi, nLo int
+	for _, f := range d.block {
+		if f > mean {
+			hi += f
+			nHi++
+		} else {
+			lo += f
+			nLo++
+		}
+	}
+	if nHi == 0 || nLo == 0 {
+		d.quiet++
+		return
+	}
+	hi /= float64(nHi)
+	lo /= float64(nLo)
+	shift := hi - lo
+	// The spread about the tones, leaving out the samples either side of
+	// a change of tone, which are between the two
+	var spread float64
+	n := 0
+	for i := 1; i+1 < len(d.block); i++ {
+		f := d.block[i]
+		if (d.block[i-1] > mean) != (f > mean) || (d.block[i+1] > mean) != (f > mean) {
+			continue
+		}
+		if f > mean {
+			spread += (f - hi) * (f - hi)
+		} else {
+			spread += (f - lo) * (f - lo)
+		}
+		n++
+	}
+	if n == 0 {
+		d.quiet++
+		return
+	}
+	spread /= float64(n)
+	if shift < DefaultDeviation || shift > 4*DefaultDeviation || math.Sqrt(spread) > shift/3 {
+		d.quiet++
+		return
+	}
+
+	// A block of FSK after a gap starts a new transmission
+	if d.quiet > 1 {
+		d.power, d.tone, d.noise, d.blocks = 0, 0, 0, 0
+	}
+	d.quiet = 0
+	d.power += d.blockPower / float64(len(d.block))
+	d.tone += shift * shift / 4
+	d.noise += spread
+	d.blocks++
+
+	if !d.afc {
+		return
+	}
+	// The carrier sits midway between the tones, however many of each
+	// the block has
+	residual := (hi + lo) / 2
+	if math.Abs(d.offset+residual) > afcMaxOffset {
+		return
+	}
+	if math.Abs(residual) > afcCoarseStep {
+		d.offset += residual
+	} else {
+		d.offset += afcFineGain * residual
+	}
+	d.ncoStep = cmplx.Exp(complex(0, -2*math.Pi*d.offset/d.audioRate))
+}
+
+// FrequencyOffset returns the carrier's offset from the tuned frequency
+// in Hz as AFC last estimated it: positive when the signal is above it.
+func (d *IQDecoder) FrequencyOffset() float64 {
+	return d.offset
+}
+
+// label sets each message's FrequencyOffsetHz to the current estimate,
+// and its RSSI and SNR to those of the latest transmission.
+func (d *IQDecoder) label(messages []DecodedMessage) {
+	for i := range messages {
+		if d.afc {
+			messages[i].FrequencyOffsetHz = int(math.Round(d.offset))
+		}
+		if d.blocks > 0 {
+			messages[i].RSSI = 10 * math.Log10(d.power/float64(d.blocks))
+			messages[i].SNR = snrDB(d.tone, d.noise)
+			messages[i].SNRValid = true
+		}
+	}
+}
+
+// Flush ends the stream and returns any message still in progress.
+func (d *IQDecoder) Flush() []DecodedMessage {
+	messages := d.stream.Flush()
+	d.label(messages)
+	return messages
+}
+
+// DecodeFromIQ decodes all messages in a complete IQ recording.
+func DecodeFromIQ(iq []complex64, sampleRate, baudRate int, opts DecodeOptions) []DecodedMessage {
+	d := NewIQDecoder(sampleRate, baudRate, opts)
+	messages := d.Write(iq)
+	return append(messages, d.Flush()...)
+}
+
+// CU8ToIQ converts interleaved unsigned 8-bit I/Q, the format rtl_sdr and
+// rtl_tcp deliver, to complex samples in [-1, 1], appending to dst.
+func CU8ToIQ(dst []complex64, data []byte) []complex64 {
+	for i := 0; i+1 < len(data); i += 2 {
+		dst = append(dst, complex((float32(data[i])-127.5)/127.5, (float32(data[i+1])-127.5)/127.5))
+	}
+	return dst
+}
@@ -0,0 +1,261 @@
+package pocsag
+
+import (
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"testing"
+)
+
+func TestGenerateIQDeviation(t *testing.T) {
+	data := []byte{0xF0} // 1111 0000
+	const rate, baud = 48000, 1200
+	iq := GenerateIQ(data, baud, rate, DefaultDeviation, false)
+	if len(iq) != 8*rate/baud {
+		t.Fatalf("got %d samples, want %d", len(iq), 8*rate/baud)
+	}
+
+	// Instantaneous frequency from the phase step between samples
+	freqAt := func(iq []complex64, i int) float64 {
+		d := complex128(iq[i]) * cmplx.Conj(complex128(iq[i-1]))
+		return cmplx.Phase(d) * rate / (2 * math.Pi)
+	}
+	spb := rate / baud
+	for bit := 0; bit < 8; bit++ {
+		f := freqAt(iq, bit*spb+spb/2)
+		want := -DefaultDeviation
+		if bit >= 4 {
+			want = DefaultDeviation
+		}
+		if f < want-1 || f > want+1 {
+			t.Errorf("bit %d: frequency %.1f Hz, want %.1f", bit, f, want)
+		}
+	}
+
+	inverted := GenerateIQ(data, baud, rate, DefaultDeviation, true)
+	if f := freqAt(inverted, spb/2); f < DefaultDeviation-1 {
+		t.Errorf("inverted bit 1 at %.1f Hz, want +%.0f", f, DefaultDeviation)
+	}
+
+	// Gain is clamped, so full scale is ±127 without wrapping
+	cs8 := IQToCS8(iq, 2)
+	peak := 0
+	for _, b := range cs8 {
+		if v := int(int8(b)); v > peak {
+			peak = v
+		}
+	}
+	if len(cs8) != 2*len(iq) || peak != 127 {
+		t.Errorf("IQToCS8: len %d, peak %d", len(cs8), peak)
+	}
+}
+
+func TestDecodeFromIQ(t *testing.T) {
+	messages := []MessageInfo{
+		{Address: 123456, Message: "HELLO IQ", Function: 3},
+		{Address: 7, Message: "0123", Function: 0, PayloadType: PayloadTypeNumeric},
+	}
+
+	for _, tc := range []struct {
+		rate, baud int
+		offset     float64 // tuning error in Hz
+	}{
+		{240000, BaudRate1200, 0},
+		{240000, BaudRate512, 1500},
+		{2000000, BaudRate2400, -800},
+	} {
+		packet := CreatePOCSAGBurstWithBaudRate(messages, tc.baud)
+		iq := GenerateIQ(packet, tc.baud, tc.rate, DefaultDeviation, false)
+
+		// Receiver tuned off frequency, with a quiet lead-in and tail
+		pad := make([]complex64, tc.rate/10)
+		signal := append(append(pad, iq...), pad...)
+		for i := range signal {
+			signal[i] *= complex64(cmplx.Rect(1, 2*math.Pi*tc.offset*float64(i)/float64(tc.rate)))
+			signal[i] += complex(0.01, 0)
+		}
+
+		// Feed in uneven pieces as a radio would
+		d := NewIQDecoder(tc.rate, tc.baud, DecodeOptions{})
+		var got []DecodedMessage
+		for start := 0; start < len(signal); start += 3001 {
+			end := start + 3001
+			if end > len(signal) {
+				end = len(signal)
+			}
+			got = append(got, d.Write(signal[start:end])...)
+		}
+		got = append(got, d.Flush()...)
+
+		if len(got) != len(messages) {
+			t.Fatalf("%d Hz / %d baud / %+.0f Hz: decoded %d messages, want %d: %+v", tc.rate, tc.baud, tc.offset, len(got), len(messages), got)
+		}
+		for i, msg := range messages {
+			if got[i].Address != msg.Address || got[i].Message != msg.Message {
+				t.Errorf("%d Hz / %d baud: message %d = %d %q, want %d %q", tc.rate, tc.baud, i, got[i].Address, got[i].Message, msg.Address, msg.Message)
+			}
+		}
+	}
+
+	cu8 := CU8ToIQ(nil, []byte{0, 255, 128, 127})
+	if len(cu8) != 2 || real(cu8[0]) != -1 || imag(cu8[0]) != 1 {
+		t.Errorf("CU8ToIQ = %v", cu8)
+	}
+}
+
+func TestChannelizer(t *testing.T) {
+	const rate, center = 1024000, 439950000
+	channels := []struct {
+		frequency int64
+		msg       MessageInfo
+	}{
+		{439987500, MessageInfo{Address: 123456, Message: "UPPER CHANNEL", Function: 3}},
+		{439937500, MessageInfo{Address: 8, Message: "ADJACENT CHANNEL", Function: 3}},
+		{439925000, MessageInfo{Address: 16, Message: "NEXT ONE DOWN", Function: 3}},
+		{439750000, MessageInfo{}}, // quiet
+	}
+
+	// Pages on three channels at once, each starting at a different time
+	signal := make([]complex64, rate*3/2)
+	for i, ch := range channels {
+		if ch.msg.Message == "" {
+			continue
+		}
+		packet := CreatePOCSAGBurstWithBaudRate([]MessageInfo{ch.msg}, BaudRate1200)
+		iq := GenerateIQ(packet, BaudRate1200, rate, DefaultDeviation, false)
+		start := (i + 1) * rate / 20
+		offset := float64(ch.frequency - center)
+		for j, s := range iq {
+			signal[start+j] += s * complex64(cmplx.Rect(1, 2*math.Pi*offset*float64(j)/rate))
+		}
+	}
+
+	frequencies := make([]int64, len(channels))
+	for i, ch := range channels {
+		frequencies[i] = ch.frequency
+	}
+	c, err := NewChannelizer(rate, center, frequencies, BaudRate1200, DecodeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []DecodedMessage
+	for start := 0; start < len(signal); start += 8192 {
+		got = append(got, c.Write(signal[start:min(start+8192, len(signal))])...)
+	}
+	got = append(got, c.Flush()...)
+
+	if len(got) != 3 {
+		t.Fatalf("got %d messages, want 3: %v", len(got), got)
+	}
+	for _, msg := range got {
+		found := false
+		for _, ch := range channels {
+			if ch.frequency == msg.FrequencyHz {
+				found = msg.Address == ch.msg.Address && msg.Message == ch.msg.Message && !msg.Partial
+			}
+		}
+		if !found {
+			t.Errorf("%d Hz: got %+v", msg.FrequencyHz, msg)
+		}
+	}
+
+	if _, err := NewChannelizer(rate, center, []int64{center + rate/2}, BaudRate1200, DecodeOptions{}); err == nil {
+		t.Error("channel at the band edge accepted")
+	}
+}
+
+func TestIQFrequencyCorrection(t *testing.T) {
+	const rate = 240000
+	// Two transmissions from transmitters off frequency in opposite
+	// directions, with a carrier-less gap between them
+	offsets := []float64{4200, -2800}
+	var signal []complex64
+	for i, offset := range offsets {
+		packet := CreatePOCSAGBurstWithBaudRate([]MessageInfo{{Address: uint32(1000 + i), Message: "OFF FREQUENCY", Function: 3}}, BaudRate1200)
+		iq := GenerateIQ(packet, BaudRate1200, rate, DefaultDeviation, false)
+		for j := range iq {
+			iq[j] *= complex64(cmplx.Rect(1, 2*math.Pi*offset*float64(j)/rate))
+		}
+		signal = append(append(signal, make([]complex64, rate/5)...), iq...)
+	}
+
+	d := NewIQDecoder(rate, BaudRate1200, DecodeOptions{})
+	var got []DecodedMessage
+	for start := 0; start < len(signal); start += 8192 {
+		got = append(got, d.Write(signal[start:min(start+8192, len(signal))])...)
+	}
+	got = append(got, d.Flush()...)
+
+	if len(got) != len(offsets) {
+		t.Fatalf("got %d messages, want %d: %v", len(got), len(offsets), got)
+	}
+	for i, msg := range got {
+		if msg.Message != "OFF FREQUENCY" || msg.Partial {
+			t.Errorf("message %d: %+v", i, msg)
+		}
+		if math.Abs(float64(msg.FrequencyOffsetHz)-offsets[i]) > 150 {
+			t.Errorf("message %d: offset %d Hz, want %.0f", i, msg.FrequencyOffsetHz, offsets[i])
+		}
+		// Full scale and noiseless
+		if math.Abs(msg.RSSI) > 0.5 || msg.SNR < 30 {
+			t.Errorf("message %d: RSSI %.1f dBFS, SNR %.1f dB", i, msg.RSSI, msg.SNR)
+		}
+	}
+}
+
+func TestIQSignalStrength(t *testing.T) {
+	const rate = 240000
+	packet := CreatePOCSAGBurstWithBaudRate([]MessageInfo{{Address: 1000, Message: "SIGNAL REPORT", Function: 3}}, BaudRate1200)
+	clean := GenerateIQ(packet, BaudRate1200, rate, DefaultDeviation, false)
+
+	// A weaker copy with noise reads lower on both
+	r := rand.New(rand.NewSource(1))
+	noisy := make([]complex64, len(clean))
+	for i, s := range clean {
+		noisy[i] = s*0.1 + complex(float32(r.NormFloat64()*0.01), float32(r.NormFloat64()*0.01))
+	}
+
+	var snr [2]float64
+	for i, iq := range [][]complex64{clean, noisy} {
+		got := DecodeFromIQ(iq, rate, BaudRate1200, DecodeOptions{})
+		if len(got) != 1 {
+			t.Fatalf("got %v", got)
+		}
+		wantRSSI := float64(-20 * i)
+		if math.Abs(got[0].RSSI-wantRSSI) > 1 {
+			t.Errorf("RSSI %.1f dBFS, want %.0f", got[0].RSSI, wantRSSI)
+		}
+		snr[i] = got[0].SNR
+	}
+	if snr[1] > snr[0]-6 || snr[1] < 10 {
+		t.Errorf("SNR clean %.1f dB, noisy %.1f dB", snr[0], snr[1])
+	}
+}
+
+func BenchmarkChannelizer(b *testing.B) {
+	// Eight channels 25 kHz apart from 2.4 MS/s, as an RTL-SDR delivers
+	const rate, center = 2400000, 439950000
+	frequencies := make([]int64, 8)
+	for i := range frequencies {
+		frequencies[i] = center - 100000 + int64(i)*25000
+	}
+	packet := CreatePOCSAGPacketWithBaudRate(123456, "THE QUICK BROWN FOX JUMPS OVER THE LAZY DOG", FuncAlphanumeric, BaudRate1200)
+	iq := GenerateIQ(packet, BaudRate1200, rate, DefaultDeviation, false)
+	rng := rand.New(rand.NewSource(1))
+	for i := range iq {
+		iq[i] += complex(float32(rng.NormFloat64()*0.1), float32(rng.NormFloat64()*0.1))
+	}
+
+	c, err := NewChannelizer(rate, center, frequencies, BaudRate1200, DecodeOptions{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(iq)) * 2) // as CU8
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for start := 0; start < len(iq); start += 16384 {
+			c.Write(iq[start:min(start+16384, len(iq))])
+		}
+	}
+}
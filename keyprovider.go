@@ -0,0 +1,158 @@
+package pocsag
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// KeyProvider looks keys up by key ID, so that programs need not hold key
+// material, or take it on the command line, themselves.
+type KeyProvider interface {
+	Key(id uint8) ([]byte, error)
+}
+
+// Key returns key id of the keyring, making a Keyring a KeyProvider held
+// in memory.
+func (ring Keyring) Key(id uint8) ([]byte, error) {
+	key, ok := ring[id]
+	if !ok {
+		return nil, fmt.Errorf("key %d is not in the keyring", id)
+	}
+	return key, nil
+}
+
+// KeyringFile is a KeyProvider reading the shared keys of a keyring file,
+// as LoadKeyStore parses, on every lookup, so edits to the file take
+// effect without a restart.
+type KeyringFile string
+
+// Key reads the file and returns key id.
+func (path KeyringFile) Key(id uint8) ([]byte, error) {
+	data, err := os.ReadFile(string(path))
+	if err != nil {
+		return nil, fmt.Errorf("reading keyring: %v", err)
+	}
+	store, err := LoadKeyStore(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return store.Keyring(AnyAddress, time.Now()).Key(id)
+}
+
+// EnvKeys is a KeyProvider taking the password of key N from the
+// environment variable named by the prefix followed by N, as
+// POCSAG_KEY_7 for EnvKeys("POCSAG_KEY_").
+type EnvKeys string
+
+// Key returns the key derived from the variable's password.
+func (prefix EnvKeys) Key(id uint8) ([]byte, error) {
+	name := string(prefix) + strconv.Itoa(int(id))
+	password := os.Getenv(name)
+	if password == "" {
+		return nil, fmt.Errorf("key %d: $%s is not set", id, name)
+	}
+	return KeyFromPassword(password, 32), nil
+}
+
+// OSKeyring is a KeyProvider reading passwords from the operating
+// system's keyring under the service it names, with the key ID as the
+// account: through secret-tool (libsecret) on Linux and the security tool
+// (Keychain) on macOS. A key is stored with, e.g.,
+//
+//	secret-tool store --label="pocsag key 7" service pocsag key-id 7
+//	security add-generic-password -s pocsag -a 7 -w
+type OSKeyring string
+
+// Key returns the key derived from the stored password.
+func (service OSKeyring) Key(id uint8) ([]byte, error) {
+	account := strconv.Itoa(int(id))
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "linux", "freebsd", "openbsd":
+		cmd = exec.Command("secret-tool", "lookup", "service", string(service), "key-id", account)
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", string(service), "-a", account, "-w")
+	default:
+		return nil, fmt.Errorf("the OS keyring is not supported on %s", runtime.GOOS)
+	}
+	out, err := cmd.Output()
+	password := strings.TrimRight(string(out), "\r\n")
+	if err != nil || password == "" {
+		return nil, fmt.Errorf("key %d is not in the %s keyring of service %q", id, runtime.GOOS, service)
+	}
+	return KeyFromPassword(password, 32), nil
+}
+
+// PKCS11 names keys in a hardware security module reached through a
+// PKCS#11 module, by label. It is a stub: the keys never leave the HSM,
+// which would have to do the encryption itself, and that is not supported
+// yet, so every lookup fails.
+type PKCS11 struct {
+	Module string // path to the PKCS#11 library, e.g. /usr/lib/softhsm/libsofthsm2.so
+	Label  string // label prefix of the keys, followed by the key ID
+}
+
+// Key reports that PKCS#11 is not supported.
+func (p PKCS11) Key(id uint8) ([]byte, error) {
+	return nil, fmt.Errorf("key %d: PKCS#11 modules such as %s are not supported yet", id, p.Module)
+}
+
+// OpenKeyProvider returns the KeyProvider a spec names:
+//
+//	file:PATH     a keyring file (KeyringFile)
+//	env:PREFIX    environment variables PREFIX0 to PREFIX255 (EnvKeys)
+//	os:SERVICE    the operating system's keyring (OSKeyring)
+//	pkcs11:MODULE a hardware security module (PKCS11, not supported yet)
+//
+// "env" and "os" alone use POCSAG_KEY_ and the service "pocsag".
+func OpenKeyProvider(spec string) (KeyProvider, error) {
+	kind, arg, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "file":
+		if arg == "" {
+			return nil, fmt.Errorf("key source %q needs a path", spec)
+		}
+		return KeyringFile(arg), nil
+	case "env":
+		if arg == "" {
+			arg = "POCSAG_KEY_"
+		}
+		return EnvKeys(arg), nil
+	case "os":
+		if arg == "" {
+			arg = "pocsag"
+		}
+		return OSKeyring(arg), nil
+	case "pkcs11":
+		return PKCS11{Module: arg}, nil
+	default:
+		return nil, fmt.Errorf("unknown key source %q: use file:PATH, env:PREFIX, os:SERVICE, or pkcs11:MODULE", spec)
+	}
+}
+
+// providedKeyring returns the key the payload header or tag of message
+// names, from the KeyProvider of opts, or nil.
+func (opts DecodeOptions) providedKeyring(message string) Keyring {
+	if opts.KeyProvider == nil {
+		return nil
+	}
+	id := -1
+	if h, ok := ParsePayloadHeader(message); ok {
+		id = int(h.KeyID)
+	} else if _, _, _, keyID, ok := parseSignature(message); ok {
+		id = keyID
+	}
+	if id < 0 {
+		return nil
+	}
+	key, err := opts.KeyProvider.Key(uint8(id))
+	if err != nil {
+		return nil
+	}
+	return Keyring{uint8(id): key}
+}
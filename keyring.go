@@ -0,0 +1,123 @@
+package pocsag
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// An encrypted page may start with a PayloadHeader naming the cipher and
+// the key it was encrypted with, so a decoder holding a Keyring can
+// decrypt it without being told either. The header is six bytes in front
+// of the IV, inside the Base64 text:
+//
+//	bytes 0-1  magic 0xC5 0x9A
+//	byte  2    version, 1
+//	byte  3    cipher, an EncryptionMethod
+//	byte  4    key ID
+//	byte  5    flags: bits 0-1 the Checksum, the others zero
+//
+// Six bytes are eight Base64 characters, so pages with the same header
+// start with the same text.
+type PayloadHeader struct {
+	Cipher EncryptionMethod
+	KeyID  uint8
+	Flags  uint8
+}
+
+const (
+	payloadHeaderVersion = 1
+	payloadHeaderLen     = 6
+)
+
+var payloadMagic = [2]byte{0xC5, 0x9A}
+
+func (h PayloadHeader) marshal() []byte {
+	return []byte{payloadMagic[0], payloadMagic[1], payloadHeaderVersion, byte(h.Cipher), h.KeyID, h.Flags}
+}
+
+// parsePayloadHeader reads the header at the start of data, if it has one
+// this version understands.
+func parsePayloadHeader(data []byte) (PayloadHeader, bool) {
+	if len(data) < payloadHeaderLen || data[0] != payloadMagic[0] || data[1] != payloadMagic[1] || data[2] != payloadHeaderVersion {
+		return PayloadHeader{}, false
+	}
+	h := PayloadHeader{Cipher: EncryptionMethod(data[3]), KeyID: data[4], Flags: data[5]}
+	if (h.Cipher != EncryptionAES256 && h.Cipher != EncryptionAES128) || h.Flags > uint8(ChecksumCRC32C) {
+		return PayloadHeader{}, false
+	}
+	return h, true
+}
+
+// Checksum returns the checksum the page was sealed with.
+func (h PayloadHeader) Checksum() Checksum {
+	return Checksum(h.Flags)
+}
+
+// ParsePayloadHeader returns the header of an encrypted page, and false if
+// it has none.
+func ParsePayloadHeader(message string) (PayloadHeader, bool) {
+	data, err := decodePayload(message)
+	if err != nil {
+		return PayloadHeader{}, false
+	}
+	return parsePayloadHeader(data)
+}
+
+// Keyring holds decryption keys by the key ID pages name in their
+// PayloadHeader.
+type Keyring map[uint8][]byte
+
+// LoadKeyring parses a JSON object of key IDs (0-255) and the passwords
+// of the keys, as given to encode with --key.
+func LoadKeyring(data []byte) (Keyring, error) {
+	var passwords map[string]string
+	if err := json.Unmarshal(data, &passwords); err != nil {
+		return nil, fmt.Errorf("parsing keyring: %v", err)
+	}
+	ring := make(Keyring, len(passwords))
+	for id, password := range passwords {
+		n, err := strconv.ParseUint(id, 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("key ID %q is not 0-255", id)
+		}
+		if password == "" {
+			return nil, fmt.Errorf("key %d has no password", n)
+		}
+		ring[uint8(n)] = KeyFromPassword(password, 32)
+	}
+	return ring, nil
+}
+
+// DecryptWithKeyring decrypts a page that has a PayloadHeader with the
+// cipher and the key it names. If that key is missing or fails, as when a
+// sender reuses an ID during a key rotation, the other keys are tried in
+// order of ID.
+func DecryptWithKeyring(message string, ring Keyring) (string, error) {
+	data, err := decodePayload(message)
+	if err != nil {
+		return "", err
+	}
+	h, ok := parsePayloadHeader(data)
+	if !ok {
+		return "", fmt.Errorf("no payload header")
+	}
+	body := data[payloadHeaderLen:]
+	err = fmt.Errorf("key %d is not in the keyring", h.KeyID)
+	if key, ok := ring[h.KeyID]; ok {
+		plain, keyErr := decryptPayload(body, h.Cipher, key, h.Checksum())
+		if keyErr == nil {
+			return plain, nil
+		}
+		err = keyErr
+	}
+	for _, id := range ring.sortedIDs() {
+		if id == h.KeyID {
+			continue
+		}
+		if plain, err := decryptPayload(body, h.Cipher, ring[id], h.Checksum()); err == nil {
+			return plain, nil
+		}
+	}
+	return "", err
+}
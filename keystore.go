@@ -0,0 +1,181 @@
+package pocsag
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// AnyAddress stands in a KeyStore for every pager without keys of its
+// own. It is one past the largest RIC.
+const AnyAddress uint32 = 1 << 21
+
+// KeyStore holds several keys per pager by key ID, and rotates them. A
+// rotated-in key is used for new pages at once, while the keys it replaces
+// keep decrypting for a grace period, so pages queued or on air under the
+// old key are not lost. Keys given for AnyAddress serve pagers with none
+// of their own. A KeyStore is safe for concurrent use.
+type KeyStore struct {
+	mu   sync.Mutex
+	sets map[uint32]*keySet
+}
+
+// keySet is the keys of one pager.
+type keySet struct {
+	active    uint8
+	hasActive bool
+	keys      map[uint8]*storedKey
+}
+
+type storedKey struct {
+	key    []byte
+	retire time.Time // when it stops decrypting; zero while in use
+}
+
+// NewKeyStore creates an empty KeyStore.
+func NewKeyStore() *KeyStore {
+	return &KeyStore{sets: make(map[uint32]*keySet)}
+}
+
+func (s *KeyStore) set(address uint32) *keySet {
+	set, ok := s.sets[address]
+	if !ok {
+		set = &keySet{keys: make(map[uint8]*storedKey)}
+		s.sets[address] = set
+	}
+	return set
+}
+
+// Add gives address a key that decrypts its pages but is not used for
+// new ones, as for a receiver that only listens.
+func (s *KeyStore) Add(address uint32, id uint8, key []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.set(address).keys[id] = &storedKey{key: key}
+}
+
+// Rotate makes key, with ID id, the key new pages to address are encrypted
+// with. The keys it replaces keep decrypting until grace after now; a
+// grace of zero retires them at once.
+func (s *KeyStore) Rotate(address uint32, id uint8, key []byte, grace time.Duration, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	set := s.set(address)
+	for other, k := range set.keys {
+		if other != id && k.retire.IsZero() {
+			k.retire = now.Add(grace)
+		}
+	}
+	set.keys[id] = &storedKey{key: key}
+	set.active, set.hasActive = id, true
+}
+
+// Retire removes key id of address, ending a grace period early. It is
+// no longer used for new pages either.
+func (s *KeyStore) Retire(address uint32, id uint8) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if set, ok := s.sets[address]; ok {
+		delete(set.keys, id)
+		if set.active == id {
+			set.hasActive = false
+		}
+	}
+}
+
+// Encryption returns the configuration to encrypt a page to address with:
+// its active key, or that of AnyAddress, named in a PayloadHeader. Keys of
+// 16 bytes use AES-128 and others AES-256.
+func (s *KeyStore) Encryption(address uint32) (EncryptionConfig, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, a := range []uint32{address, AnyAddress} {
+		set, ok := s.sets[a]
+		if !ok || !set.hasActive {
+			continue
+		}
+		key := set.keys[set.active].key
+		method := EncryptionAES256
+		if len(key) == 16 {
+			method = EncryptionAES128
+		}
+		return EncryptionConfig{Method: method, Key: key, Header: true, KeyID: set.active}, nil
+	}
+	return EncryptionConfig{}, fmt.Errorf("no active key for address %d", address)
+}
+
+// Keyring returns the keys that decrypt pages to address at now: those of
+// AnyAddress, overridden by the pager's own, without those retired.
+func (s *KeyStore) Keyring(address uint32, now time.Time) Keyring {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ring := make(Keyring)
+	for _, a := range []uint32{AnyAddress, address} {
+		set, ok := s.sets[a]
+		if !ok {
+			continue
+		}
+		for id, k := range set.keys {
+			if k.retire.IsZero() || now.Before(k.retire) {
+				ring[id] = k.key
+			}
+		}
+	}
+	return ring
+}
+
+// LoadKeyStore parses a keyring as LoadKeyring does, whose keys serve
+// every pager, in which a RIC may also name an object of keys for that
+// pager alone:
+//
+//	{"1": "dispatch-2026", "123456": {"5": "ward-5"}}
+//
+// Keys are only added, not made active; use Rotate to encrypt with them.
+func LoadKeyStore(data []byte) (*KeyStore, error) {
+	var entries map[string]json.RawMessage
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("parsing keyring: %v", err)
+	}
+	store := NewKeyStore()
+	shared := make(map[string]string)
+	for name, raw := range entries {
+		var password string
+		if json.Unmarshal(raw, &password) == nil {
+			shared[name] = password
+			continue
+		}
+		address, err := strconv.ParseUint(name, 10, 32)
+		if err != nil || address >= uint64(AnyAddress) {
+			return nil, fmt.Errorf("%q is not a RIC", name)
+		}
+		ring, err := LoadKeyring(raw)
+		if err != nil {
+			return nil, fmt.Errorf("keys of %d: %v", address, err)
+		}
+		for id, key := range ring {
+			store.Add(uint32(address), id, key)
+		}
+	}
+	sharedJSON, _ := json.Marshal(shared)
+	ring, err := LoadKeyring(sharedJSON)
+	if err != nil {
+		return nil, err
+	}
+	for id, key := range ring {
+		store.Add(AnyAddress, id, key)
+	}
+	return store, nil
+}
+
+// sortedIDs returns the key IDs of ring in order.
+func (ring Keyring) sortedIDs() []uint8 {
+	ids := make([]uint8, 0, len(ring))
+	for id := range ring {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
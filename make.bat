@@ -32,6 +32,8 @@ if "%1"=="" goto build
 if "%1"=="build" goto build
 if "%1"=="install" goto install
 if "%1"=="test" goto test
+if "%1"=="interop" goto interop
+if "%1"=="bench" goto bench
 if "%1"=="clean" goto clean
 if "%1"=="version" goto version
 if "%1"=="cross-compile" goto cross
@@ -42,6 +44,10 @@ echo Building POCSAG-GO v%VERSION%...
 go build -ldflags "%LDFLAGS%" -o bin\pocsag.exe ./cmd/pocsag
 go build -ldflags "%LDFLAGS%" -o bin\pocsag-decode.exe ./cmd/pocsag-decode
 go build -ldflags "%LDFLAGS%" -o bin\pocsag-burst.exe ./cmd/pocsag-burst
+go build -ldflags "%LDFLAGS%" -o bin\pocsag-hackrf.exe ./cmd/pocsag-hackrf
+go build -ldflags "%LDFLAGS%" -o bin\pocsag-rx.exe ./cmd/pocsag-rx
+go build -ldflags "%LDFLAGS%" -o bin\pocsag-replay.exe ./cmd/pocsag-replay
+go build -ldflags "%LDFLAGS%" -o bin\pocsag-diff.exe ./cmd/pocsag-diff
 echo Build complete!
 goto end
 
@@ -49,12 +55,25 @@ goto end
 go install -ldflags "%LDFLAGS%" ./cmd/pocsag
 go install -ldflags "%LDFLAGS%" ./cmd/pocsag-decode
 go install -ldflags "%LDFLAGS%" ./cmd/pocsag-burst
+go install -ldflags "%LDFLAGS%" ./cmd/pocsag-hackrf
+go install -ldflags "%LDFLAGS%" ./cmd/pocsag-rx
+go install -ldflags "%LDFLAGS%" ./cmd/pocsag-replay
+go install -ldflags "%LDFLAGS%" ./cmd/pocsag-diff
 goto end
 
 :test
 go test -v ./...
 goto end
 
+:interop
+go test -tags interop -run Interop -v .
+goto end
+
+:bench
+go test -run "^$" -bench . -benchmem -count 6 . > bench.txt
+type bench.txt
+goto end
+
 :clean
 rmdir /s /q bin
 goto end
@@ -95,6 +114,8 @@ echo Available targets:
 echo build
 echo install
 echo test
+echo interop
+echo bench
 echo clean
 echo version
 echo cross-compile
@@ -0,0 +1,133 @@
+package pocsag
+
+import (
+	"fmt"
+	"sync"
+)
+
+// multiRateWindow is how close, in seconds of audio, the same message
+// decoded at two rates must be to count as one transmission.
+const multiRateWindow = 2
+
+// MultiRateBauds are the rates a MultiRateDecoder listens for.
+var MultiRateBauds = []int{BaudRate512, BaudRate1200, BaudRate2400}
+
+// MultiRateDecoder decodes audio that carries traffic at any POCSAG rate,
+// as off-air captures of a shared channel do. It runs a StreamDecoder for
+// each of MultiRateBauds side by side; each message's BaudRate tells which
+// rate it came at. A message decoded at more than one rate is returned
+// once, preferring an intact copy over a partial one.
+type MultiRateDecoder struct {
+	sampleRate int
+	decoders   []*StreamDecoder
+	written    int64 // samples written so far
+	recent     map[multiRateKey]multiRateSeen
+}
+
+type multiRateKey struct {
+	address uint32
+	message string
+}
+
+// multiRateSeen records when, in samples, and at which rate a message was
+// last returned.
+type multiRateSeen struct {
+	at   int64
+	baud int
+}
+
+// NewMultiRateDecoder creates a MultiRateDecoder for mono audio at
+// sampleRate. opts apply as for NewStreamDecoder.
+func NewMultiRateDecoder(sampleRate int, opts DecodeOptions) *MultiRateDecoder {
+	d := &MultiRateDecoder{sampleRate: sampleRate, recent: make(map[multiRateKey]multiRateSeen)}
+	for _, baud := range MultiRateBauds {
+		d.decoders = append(d.decoders, NewStreamDecoder(sampleRate, baud, opts))
+	}
+	return d
+}
+
+// Write demodulates samples at every rate and returns the messages
+// completed by them, slowest rate first.
+func (d *MultiRateDecoder) Write(samples []float32) []DecodedMessage {
+	d.written += int64(len(samples))
+	return d.each(func(dec *StreamDecoder) []DecodedMessage {
+		return dec.Write(samples)
+	})
+}
+
+// Flush ends the stream and returns any message still in progress.
+func (d *MultiRateDecoder) Flush() []DecodedMessage {
+	return d.each((*StreamDecoder).Flush)
+}
+
+// each runs fn on every rate's decoder concurrently and arbitrates
+// between their messages.
+func (d *MultiRateDecoder) each(fn func(*StreamDecoder) []DecodedMessage) []DecodedMessage {
+	results := make([][]DecodedMessage, len(d.decoders))
+	var wg sync.WaitGroup
+	for i, dec := range d.decoders {
+		wg.Add(1)
+		go func(i int, dec *StreamDecoder) {
+			defer wg.Done()
+			results[i] = fn(dec)
+		}(i, dec)
+	}
+	wg.Wait()
+
+	// The same page repeated at one rate is two pages; at two rates
+	// within the window it is one page seen twice.
+	window := multiRateWindow * int64(d.sampleRate)
+	var messages []DecodedMessage
+	index := make(map[multiRateKey]int) // position in messages
+	for _, result := range results {
+		for _, msg := range result {
+			key := multiRateKey{msg.Address, msg.Message}
+			if i, ok := index[key]; ok && messages[i].BaudRate != msg.BaudRate {
+				if messages[i].Partial && !msg.Partial {
+					messages[i] = msg
+				}
+				continue
+			}
+			if seen, ok := d.recent[key]; ok && seen.baud != msg.BaudRate && d.written-seen.at <= window {
+				continue
+			}
+			index[key] = len(messages)
+			messages = append(messages, msg)
+		}
+	}
+
+	for key, i := range index {
+		d.recent[key] = multiRateSeen{d.written, messages[i].BaudRate}
+	}
+	for key, seen := range d.recent {
+		if d.written-seen.at > window {
+			delete(d.recent, key)
+		}
+	}
+	return messages
+}
+
+// DecodeFromAudioMultiRate decodes a WAV recording that may hold traffic at
+// 512, 1200, and 2400 baud, returning the messages of every rate in the
+// order they end in the recording.
+func DecodeFromAudioMultiRate(wavData []byte, opts DecodeOptions) ([]DecodedMessage, error) {
+	if _, ok := parseWAVHeader(wavData); !ok {
+		return nil, fmt.Errorf("not a WAV file")
+	}
+	pcm, sampleRate := ParseWAVSamples(wavData)
+	if err := ValidateSampleRate(sampleRate); err != nil {
+		return nil, err
+	}
+
+	d := NewMultiRateDecoder(sampleRate, opts)
+	messages := make([]DecodedMessage, 0)
+	chunk := make([]float32, 0, streamReadSamples)
+	for start := 0; start < len(pcm); start += streamReadSamples {
+		chunk = chunk[:0]
+		for _, s := range pcm[start:min(start+streamReadSamples, len(pcm))] {
+			chunk = append(chunk, float32(s))
+		}
+		messages = append(messages, d.Write(chunk)...)
+	}
+	return append(messages, d.Flush()...), nil
+}
@@ -0,0 +1,576 @@
+package pocsag
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Encoder holds all settings used to turn messages into POCSAG bytes and
+// audio. An Encoder is immutable after NewEncoder returns, so one instance
+// can be shared between goroutines and several encoders with different
+// settings can run side by side. The package-level Create*/ConvertToAudio*
+// functions are wrappers around an Encoder with default settings.
+type Encoder struct {
+	baudRate     int
+	sampleRate   int
+	symbolHigh   int16
+	symbolLow    int16
+	preambleBits int
+	wavFormat    WAVFormat
+	syncWord     uint32
+	idleWord     uint32
+	continuous   bool
+	policies     []MessagePolicy
+	translit     *Transliterator
+	launchDelay  time.Duration
+	padding      PaddingCodeword
+	padTo        PaddingStrategy
+	selfVerify   bool
+	interleave   int
+	terminator   byte
+	alphaFill    byte
+	encryption   []EncryptionConfig
+}
+
+// PaddingCodeword selects what fills the codeword slots no message uses.
+type PaddingCodeword int
+
+const (
+	// PadIdle sends the idle codeword, IdleCodeword unless replaced with
+	// WithIdleCodeword.
+	PadIdle PaddingCodeword = iota
+	// PadLastAddress repeats the most recent address codeword, and sends
+	// the idle codeword before the first. Some pager firmwares expect it,
+	// but a pager in another frame whose address shares the upper 18 bits
+	// takes the repeats for tone-only pages, so it is for bench tests.
+	PadLastAddress
+)
+
+// PaddingStrategy selects where a transmission may end.
+type PaddingStrategy int
+
+const (
+	// PadToBatch completes the last batch, as ITU-R M.584-2 requires.
+	PadToBatch PaddingStrategy = iota
+	// PadToFrame ends the transmission with the last frame in use, saving
+	// up to 14 codewords of airtime. The last batch is then short, which
+	// not every pager accepts.
+	PadToFrame
+)
+
+// Option configures an Encoder.
+type Option func(*Encoder)
+
+// NewEncoder creates an Encoder with defaults of 1200 baud, 48 kHz 16-bit
+// PCM output, the standard symbol levels, and a 576-bit preamble.
+func NewEncoder(opts ...Option) *Encoder {
+	e := &Encoder{
+		baudRate:     BaudRate1200,
+		sampleRate:   SampleRate,
+		symbolHigh:   SymbolHigh,
+		symbolLow:    SymbolLow,
+		preambleBits: PreambleLength,
+		wavFormat:    WAVPCM16,
+		syncWord:     FrameSyncWord,
+		idleWord:     IdleCodeword,
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// WithBaudRate selects the transmission baud rate.
+func WithBaudRate(baudRate int) Option {
+	return func(e *Encoder) {
+		if baudRate > 0 {
+			e.baudRate = baudRate
+		}
+	}
+}
+
+// WithSampleRate selects the output sample rate of generated audio. The
+// baseband is resampled from SampleRate when they differ.
+func WithSampleRate(sampleRate int) Option {
+	return func(e *Encoder) {
+		if sampleRate > 0 {
+			e.sampleRate = sampleRate
+		}
+	}
+}
+
+// WithSymbols sets the baseband sample levels for 1 and 0 bits. Swapping
+// them inverts the output polarity.
+func WithSymbols(high, low int16) Option {
+	return func(e *Encoder) {
+		e.symbolHigh = high
+		e.symbolLow = low
+	}
+}
+
+// WithPreambleBits sets the preamble length, rounded up to whole bytes.
+// ITU-R M.584-2 requires at least 576 bits; longer preambles help pagers
+// with long battery-save cycles.
+func WithPreambleBits(bits int) Option {
+	return func(e *Encoder) {
+		if bits >= 0 {
+			e.preambleBits = (bits + 7) / 8 * 8
+		}
+	}
+}
+
+// WithWAVFormat selects the WAV sample format of generated audio.
+func WithWAVFormat(format WAVFormat) Option {
+	return func(e *Encoder) {
+		e.wavFormat = format
+	}
+}
+
+// WithSyncWord replaces the standard frame sync word FrameSyncWord, for
+// POCSAG-like networks that use a modified one. Receivers need the same
+// word in DecodeOptions.SyncWord.
+func WithSyncWord(word uint32) Option {
+	return func(e *Encoder) {
+		e.syncWord = word
+	}
+}
+
+// WithIdleCodeword replaces the standard IdleCodeword that fills unused
+// slots. Receivers need the same word in DecodeOptions.IdleCodeword.
+func WithIdleCodeword(word uint32) Option {
+	return func(e *Encoder) {
+		e.idleWord = word
+	}
+}
+
+// WithPaddingCodeword selects what fills unused codeword slots.
+func WithPaddingCodeword(p PaddingCodeword) Option {
+	return func(e *Encoder) {
+		e.padding = p
+	}
+}
+
+// WithPaddingStrategy selects whether the last batch is sent whole or
+// ends with the last frame in use.
+func WithPaddingStrategy(p PaddingStrategy) Option {
+	return func(e *Encoder) {
+		e.padTo = p
+	}
+}
+
+// WithSelfVerify checks every codeword the Encoder sends with
+// VerifyCodeword, as a guard against regressions in the encoding code.
+// A codeword that fails is a bug: Encode and EncodeWithBudget return a
+// *SelfVerifyError for it, and the methods without an error result, such
+// as CreateBurst and EncodeWAV, return nil rather than a bad burst.
+// A custom idle codeword that is not a valid codeword is not checked.
+func WithSelfVerify() Option {
+	return func(e *Encoder) {
+		e.selfVerify = true
+	}
+}
+
+// WithInterleaving sends the message codewords of every page in blocks of
+// depth, 2 to MaxInterleaveDepth, with their bits interleaved, so that a
+// fade of up to 2×depth bits leaves no codeword with more bad bits than
+// BCH repairs. Each page starts with two flag codewords announcing it,
+// so it costs two codewords more, and a receiver can show nothing of a block
+// until all of it has arrived. Pagers and decoders that do not know the
+// scheme show garbage; decoders in this package recognize the flag by
+// themselves. Pages over 255 codewords are sent as usual, and depth 0 or
+// 1 turns interleaving off.
+func WithInterleaving(depth int) Option {
+	return func(e *Encoder) {
+		if depth <= MaxInterleaveDepth {
+			e.interleave = depth
+		}
+	}
+}
+
+// WithTerminator ends every alphanumeric message with terminator, usually
+// ETX or EOT, which some pagers expect. Messages have none by default, and
+// numeric and tone-only messages never do. 0 turns it off.
+func WithTerminator(terminator byte) Option {
+	return func(e *Encoder) {
+		e.terminator = terminator
+	}
+}
+
+// WithAlphaFill fills the bits after the text of alphanumeric messages
+// with fill repeated, usually ETX or ETB, instead of zeros, for pagers
+// that show the zeros as junk characters. 0 keeps the zeros.
+func WithAlphaFill(fill byte) Option {
+	return func(e *Encoder) {
+		e.alphaFill = fill & 0x7F
+	}
+}
+
+// WithEncryption makes the Encoder encrypt or sign messages as
+// EncryptMessages does, with one config for all of them or one per
+// message, before every method encodes or measures them. Methods that
+// return no error return nil when encryption fails, as CreateBurst does;
+// Encode reports why.
+func WithEncryption(configs ...EncryptionConfig) Option {
+	return func(e *Encoder) {
+		e.encryption = configs
+	}
+}
+
+// WithContinuousMode selects how EncodeTransmissions joins transmissions.
+// By default (battery-saver mode) each one starts with its own preamble and
+// the carrier drops in between, since a pager that sleeps between batches
+// needs a fresh preamble to wake up. Continuous mode sends one preamble and
+// keeps the carrier up with idle batches instead, which saves airtime when
+// the pagers stay awake.
+func WithContinuousMode(continuous bool) Option {
+	return func(e *Encoder) {
+		e.continuous = continuous
+	}
+}
+
+// WithPolicies adds message policies for Prepare to apply.
+func WithPolicies(policies ...MessagePolicy) Option {
+	return func(e *Encoder) {
+		e.policies = append(e.policies, policies...)
+	}
+}
+
+// WithTransliteration selects how characters outside ASCII are spelled in
+// alphanumeric messages, in place of the built-in table.
+func WithTransliteration(t *Transliterator) Option {
+	return func(e *Encoder) {
+		e.translit = t
+	}
+}
+
+// WithLaunchDelay starts generated audio with d of silence, for simulcast
+// networks that feed several transmitters the same stream, each delayed to
+// make up for its link. The delay is rounded to whole samples at the output
+// rate and added after resampling, so audio with a delay is exactly the
+// samples without one, shifted by that many; two encoders that differ only
+// in launch delay are offset by the difference of their LaunchDelay to the
+// sample.
+func WithLaunchDelay(d time.Duration) Option {
+	return func(e *Encoder) {
+		if d >= 0 {
+			e.launchDelay = d
+		}
+	}
+}
+
+// BaudRate returns the Encoder's baud rate.
+func (e *Encoder) BaudRate() int {
+	return e.baudRate
+}
+
+// SampleRate returns the Encoder's output sample rate.
+func (e *Encoder) SampleRate() int {
+	return e.sampleRate
+}
+
+// WAVFormat returns the Encoder's WAV sample format.
+func (e *Encoder) WAVFormat() WAVFormat {
+	return e.wavFormat
+}
+
+// LaunchDelay returns the launch delay as applied, rounded to whole
+// samples at the output sample rate.
+func (e *Encoder) LaunchDelay() time.Duration {
+	return time.Duration(int64(e.launchDelaySamples()) * int64(time.Second) / int64(e.sampleRate))
+}
+
+func (e *Encoder) launchDelaySamples() int {
+	return int((int64(e.launchDelay)*int64(e.sampleRate) + int64(time.Second)/2) / int64(time.Second))
+}
+
+// render resamples baseband samples to the output rate, adds the launch
+// delay, and wraps them in a WAV file.
+func (e *Encoder) render(samples []int16) []byte {
+	if e.sampleRate != SampleRate {
+		samples = Resample(samples, SampleRate, e.sampleRate)
+	}
+	if n := e.launchDelaySamples(); n > 0 {
+		samples = append(make([]int16, n, n+len(samples)), samples...)
+	}
+	return encodeWAV(samples, e.sampleRate, e.wavFormat)
+}
+
+// Prepare applies the Encoder's policies to messages before they are
+// encoded, returning the messages to send or why one was refused. The
+// encoding methods do not apply policies themselves, so callers that
+// transmit messages from users should run them through Prepare first.
+func (e *Encoder) Prepare(messages []MessageInfo) ([]MessageInfo, error) {
+	return ApplyPolicies(messages, e.policies...)
+}
+
+// CreateBurst encodes messages into POCSAG bytes: preamble followed by
+// batches of a frame sync word and 16 codewords. It returns nil if
+// encryption fails, or with WithSelfVerify if a codeword fails the check;
+// Encode reports why.
+func (e *Encoder) CreateBurst(messages []MessageInfo) []byte {
+	burst, err := e.createBurst(messages)
+	if err != nil {
+		return nil
+	}
+	return burst
+}
+
+func (e *Encoder) createBurst(messages []MessageInfo) ([]byte, error) {
+	messages, err := e.encrypt(messages)
+	if err != nil {
+		return nil, err
+	}
+	return e.burst(messages)
+}
+
+// encrypt applies WithEncryption to messages, or returns them as given
+// without it.
+func (e *Encoder) encrypt(messages []MessageInfo) ([]MessageInfo, error) {
+	if len(e.encryption) == 0 {
+		return messages, nil
+	}
+	return EncryptMessages(messages, e.encryption...)
+}
+
+// encryptGroups encrypts messages sent in several transmissions as one
+// run, so that one config per message and the IV offsets count across all
+// of them.
+func (e *Encoder) encryptGroups(groups [][]MessageInfo) ([][]MessageInfo, error) {
+	if len(e.encryption) == 0 {
+		return groups, nil
+	}
+	var all []MessageInfo
+	for _, group := range groups {
+		all = append(all, group...)
+	}
+	all, err := EncryptMessages(all, e.encryption...)
+	if err != nil {
+		return nil, err
+	}
+	out := make([][]MessageInfo, len(groups))
+	for i, group := range groups {
+		out[i], all = all[:len(group):len(group)], all[len(group):]
+	}
+	return out, nil
+}
+
+// burst encodes messages, already encrypted, behind a preamble.
+func (e *Encoder) burst(messages []MessageInfo) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Write(bytes.Repeat([]byte{0xAA}, e.preambleBits/8))
+	if err := e.writeBatches(&buf, messages); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeBatches writes the batches carrying messages, without a preamble.
+func (e *Encoder) writeBatches(buf *bytes.Buffer, messages []MessageInfo) error {
+	batches, owners := e.layout(messages)
+	return e.writeLayout(buf, batches, owners)
+}
+
+// writeLayout writes batches laid out by layout, checking each codeword
+// with WithSelfVerify.
+func (e *Encoder) writeLayout(buf *bytes.Buffer, batches [][]uint32, owners [][]int) error {
+	for b, batch := range batches {
+		writeUint32BE(buf, e.syncWord)
+		for slot, cw := range batch {
+			if e.selfVerify && (owners[b][slot] != -1 || cw != e.idleWord || cw == IdleCodeword) {
+				if err := VerifyCodeword(cw); err != nil {
+					return &SelfVerifyError{Batch: b, Slot: slot, Codeword: cw, Err: err}
+				}
+			}
+			writeUint32BE(buf, cw)
+		}
+	}
+	return nil
+}
+
+// SelfVerifyError reports a codeword the Encoder produced that failed the
+// check WithSelfVerify turns on, which is a bug in the encoder.
+type SelfVerifyError struct {
+	Batch, Slot int
+	Codeword    uint32
+	Err         error // from VerifyCodeword
+}
+
+func (e *SelfVerifyError) Error() string {
+	return fmt.Sprintf("encoder produced a bad codeword 0x%08X in batch %d, slot %d: %v", e.Codeword, e.Batch, e.Slot, e.Err)
+}
+
+func (e *SelfVerifyError) Unwrap() error {
+	return e.Err
+}
+
+// layout places messages in batches as CreateBurst sends them, with unused
+// slots padded as the Encoder is configured to. owners gives the message
+// in each slot, or -1 for padding.
+func (e *Encoder) layout(messages []MessageInfo) (batches [][]uint32, owners [][]int) {
+	batches, owners = layoutBatches(e.terminate(e.transliterate(messages)), e.interleave, e.alphaFill)
+	lastAddress, lastOwner := e.idleWord, -1
+	for b := range batches {
+		for slot, owner := range owners[b] {
+			switch {
+			case owner == -1 && e.padding == PadLastAddress:
+				batches[b][slot] = lastAddress
+			case owner == -1:
+				batches[b][slot] = e.idleWord
+			case owner != lastOwner:
+				// A message starts with its address codeword
+				lastAddress, lastOwner = batches[b][slot], owner
+			}
+		}
+	}
+
+	if e.padTo == PadToFrame {
+		last := len(batches) - 1
+		used := 0
+		for slot, owner := range owners[last] {
+			if owner != -1 {
+				used = (slot/CodewordsPerFrame + 1) * CodewordsPerFrame
+			}
+		}
+		batches[last], owners[last] = batches[last][:used], owners[last][:used]
+	}
+	return batches, owners
+}
+
+// transliterate applies the Encoder's transliteration, if any, to
+// messages. Without one, messageCodewords uses the built-in table.
+func (e *Encoder) transliterate(messages []MessageInfo) []MessageInfo {
+	if e.translit == nil {
+		return messages
+	}
+	out := make([]MessageInfo, len(messages))
+	for i, msg := range messages {
+		out[i] = e.translit.Transform(msg)
+	}
+	return out
+}
+
+// terminate appends the Encoder's terminator to alphanumeric messages,
+// leaving tone-only pages empty.
+func (e *Encoder) terminate(messages []MessageInfo) []MessageInfo {
+	if e.terminator == 0 {
+		return messages
+	}
+	out := make([]MessageInfo, len(messages))
+	for i, msg := range messages {
+		if msg.Message != "" && messagePayloadType(msg) == PayloadTypeAlpha {
+			msg.Message += string(rune(e.terminator))
+		}
+		out[i] = msg
+	}
+	return out
+}
+
+// ConvertToAudio renders POCSAG bytes as a baseband WAV file.
+func (e *Encoder) ConvertToAudio(pocsagData []byte) []byte {
+	return e.render(e.basebandSamples(pocsagData))
+}
+
+// EncodeWAV encodes messages and renders them as a WAV file in one step.
+func (e *Encoder) EncodeWAV(messages []MessageInfo) []byte {
+	burst := e.CreateBurst(messages)
+	if burst == nil {
+		return nil
+	}
+	return e.ConvertToAudio(burst)
+}
+
+// EncodeTransmissions renders several transmissions into one WAV file, the
+// start of each gap after the end of the one before. WithContinuousMode
+// selects whether the gaps are silence or idle batches.
+func (e *Encoder) EncodeTransmissions(transmissions [][]MessageInfo, gap time.Duration) []byte {
+	transmissions, err := e.encryptGroups(transmissions)
+	if err != nil {
+		return nil
+	}
+	if e.continuous {
+		burst, err := e.continuousBurst(transmissions, gap)
+		if err != nil {
+			return nil
+		}
+		return e.ConvertToAudio(burst)
+	}
+
+	var samples []int16
+	silence := make([]int16, int(gap.Seconds()*SampleRate))
+	for i, messages := range transmissions {
+		if i > 0 {
+			samples = append(samples, silence...)
+		}
+		burst, err := e.burst(messages)
+		if err != nil {
+			return nil
+		}
+		samples = append(samples, e.basebandSamples(burst)...)
+	}
+	return e.render(samples)
+}
+
+// continuousBurst encodes transmissions, already encrypted, behind a
+// single preamble, with
+// gap rounded up to whole batches of idle codewords between them.
+func (e *Encoder) continuousBurst(transmissions [][]MessageInfo, gap time.Duration) ([]byte, error) {
+	gapBits := int64(gap) * int64(e.baudRate) / int64(time.Second)
+	idleBatches := int((gapBits + BatchBits - 1) / BatchBits)
+
+	// Only the last transmission may end mid-batch, so that the idle
+	// batches after the others keep to the batch clock
+	whole := *e
+	whole.padTo = PadToBatch
+
+	var buf bytes.Buffer
+	buf.Write(bytes.Repeat([]byte{0xAA}, e.preambleBits/8))
+	for i, messages := range transmissions {
+		if i > 0 {
+			for b := 0; b < idleBatches; b++ {
+				writeUint32BE(&buf, e.syncWord)
+				for slot := 0; slot < CodewordsPerBatch; slot++ {
+					writeUint32BE(&buf, e.idleWord)
+				}
+			}
+		}
+		w := e
+		if i < len(transmissions)-1 {
+			w = &whole
+		}
+		if err := w.writeBatches(&buf, messages); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// basebandSamples renders POCSAG bytes as baseband DC levels at SampleRate.
+func (e *Encoder) basebandSamples(pocsagData []byte) []int16 {
+	samplesPerSymbol := float64(SampleRate) / float64(e.baudRate)
+	numBits := len(pocsagData) * 8
+	numSamples := int(float64(numBits) * samplesPerSymbol)
+
+	audioData := make([]int16, numSamples)
+
+	for byteIdx, b := range pocsagData {
+		for bitPos := 7; bitPos >= 0; bitPos-- {
+			bit := (b >> bitPos) & 1
+			sample := e.symbolLow
+			if bit == 1 {
+				sample = e.symbolHigh
+			}
+
+			bitIndex := byteIdx*8 + (7 - bitPos)
+			startIdx := int(math.Round(float64(bitIndex) * samplesPerSymbol))
+			endIdx := int(math.Round(float64(bitIndex+1) * samplesPerSymbol))
+
+			for j := startIdx; j < endIdx; j++ {
+				audioData[j] = sample
+			}
+		}
+	}
+
+	return audioData
+}
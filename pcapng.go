@@ -0,0 +1,253 @@
+package pocsag
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+)
+
+// PcapWriter writes decoded messages as a PCAP-ng capture, one packet per
+// message stamped with its receive time, so that paging traffic can be
+// filtered and analyzed with Wireshark and other capture tools.
+// contrib/pocsag.lua is a Wireshark dissector for the packets, which use
+// LinkTypePOCSAG and carry, big-endian:
+//
+//	byte  0      version, 1
+//	byte  1      flags: 0x01 partial, 0x02 numeric, 0x04 verified
+//	byte  2      function
+//	byte  3      reserved, zero
+//	bytes 4-7    address (RIC)
+//	bytes 8-9    baud rate, 0 if unknown
+//	bytes 10-11  number of codewords N
+//	bytes 12-19  frequency in Hz, 0 if unknown
+//	N×4 bytes    the codewords as received (DecodeOptions.IncludeRaw)
+//	the rest     the message text, UTF-8
+type PcapWriter struct {
+	w io.Writer
+}
+
+// LinkTypePOCSAG is the link type of the packets PcapWriter writes,
+// LINKTYPE_USER0, which is reserved for private use.
+const LinkTypePOCSAG = 147
+
+const (
+	pcapVersion      = 1
+	pcapHeaderLen    = 20
+	pcapFlagPartial  = 0x01
+	pcapFlagNumeric  = 0x02
+	pcapFlagVerified = 0x04
+
+	pcapBlockSHB     = 0x0A0D0D0A
+	pcapBlockIDB     = 0x00000001
+	pcapBlockEPB     = 0x00000006
+	pcapByteOrder    = 0x1A2B3C4D
+	pcapOptUserAppl  = 4
+	pcapOptEndOfOpts = 0
+)
+
+// NewPcapWriter writes the section and interface headers of a capture to
+// w and returns a PcapWriter for its packets.
+func NewPcapWriter(w io.Writer) (*PcapWriter, error) {
+	p := &PcapWriter{w: w}
+
+	// Section header: byte order, version 1.0, unknown section length,
+	// and the writing application
+	shb := binary.LittleEndian.AppendUint32(nil, pcapByteOrder)
+	shb = binary.LittleEndian.AppendUint16(shb, 1)
+	shb = binary.LittleEndian.AppendUint16(shb, 0)
+	shb = binary.LittleEndian.AppendUint64(shb, ^uint64(0))
+	shb = appendPcapOption(shb, pcapOptUserAppl, []byte("pocsag-golang "+Version))
+	shb = appendPcapOption(shb, pcapOptEndOfOpts, nil)
+	if err := p.writeBlock(pcapBlockSHB, shb); err != nil {
+		return nil, err
+	}
+
+	// Interface: LinkTypePOCSAG, no snap length, microsecond timestamps
+	idb := binary.LittleEndian.AppendUint16(nil, LinkTypePOCSAG)
+	idb = binary.LittleEndian.AppendUint16(idb, 0)
+	idb = binary.LittleEndian.AppendUint32(idb, 0)
+	if err := p.writeBlock(pcapBlockIDB, idb); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// WriteRecord writes one message as a packet stamped with its Time, or
+// with the Unix epoch if that is zero.
+func (p *PcapWriter) WriteRecord(r MessageRecord) error {
+	packet := marshalPcapPacket(r.DecodedMessage)
+
+	var ts uint64
+	if !r.Time.IsZero() {
+		ts = uint64(r.Time.UnixMicro())
+	}
+	epb := binary.LittleEndian.AppendUint32(nil, 0) // interface
+	epb = binary.LittleEndian.AppendUint32(epb, uint32(ts>>32))
+	epb = binary.LittleEndian.AppendUint32(epb, uint32(ts))
+	epb = binary.LittleEndian.AppendUint32(epb, uint32(len(packet)))
+	epb = binary.LittleEndian.AppendUint32(epb, uint32(len(packet)))
+	epb = append(epb, packet...)
+	epb = append(epb, make([]byte, pad4(len(packet)))...)
+	return p.writeBlock(pcapBlockEPB, epb)
+}
+
+// writeBlock writes a block of the given type around body, whose length
+// must be a multiple of four.
+func (p *PcapWriter) writeBlock(blockType uint32, body []byte) error {
+	total := uint32(12 + len(body))
+	block := binary.LittleEndian.AppendUint32(nil, blockType)
+	block = binary.LittleEndian.AppendUint32(block, total)
+	block = append(block, body...)
+	block = binary.LittleEndian.AppendUint32(block, total)
+	if _, err := p.w.Write(block); err != nil {
+		return fmt.Errorf("writing capture: %v", err)
+	}
+	return nil
+}
+
+func appendPcapOption(b []byte, code uint16, value []byte) []byte {
+	b = binary.LittleEndian.AppendUint16(b, code)
+	b = binary.LittleEndian.AppendUint16(b, uint16(len(value)))
+	b = append(b, value...)
+	return append(b, make([]byte, pad4(len(value)))...)
+}
+
+func pad4(n int) int {
+	return (4 - n%4) % 4
+}
+
+func marshalPcapPacket(msg DecodedMessage) []byte {
+	var flags byte
+	if msg.Partial {
+		flags |= pcapFlagPartial
+	}
+	if msg.IsNumeric {
+		flags |= pcapFlagNumeric
+	}
+	if msg.Verified {
+		flags |= pcapFlagVerified
+	}
+	b := []byte{pcapVersion, flags, msg.Function, 0}
+	b = binary.BigEndian.AppendUint32(b, msg.Address)
+	b = binary.BigEndian.AppendUint16(b, uint16(msg.BaudRate))
+	b = binary.BigEndian.AppendUint16(b, uint16(len(msg.Codewords)))
+	b = binary.BigEndian.AppendUint64(b, uint64(msg.FrequencyHz))
+	for _, cw := range msg.Codewords {
+		b = binary.BigEndian.AppendUint32(b, cw)
+	}
+	return append(b, msg.Message...)
+}
+
+func unmarshalPcapPacket(b []byte) (DecodedMessage, error) {
+	if len(b) < pcapHeaderLen || b[0] != pcapVersion {
+		return DecodedMessage{}, fmt.Errorf("not a version %d POCSAG packet", pcapVersion)
+	}
+	n := int(binary.BigEndian.Uint16(b[10:]))
+	if len(b) < pcapHeaderLen+4*n {
+		return DecodedMessage{}, fmt.Errorf("packet shorter than its %d codewords", n)
+	}
+	msg := DecodedMessage{
+		Partial:     b[1]&pcapFlagPartial != 0,
+		IsNumeric:   b[1]&pcapFlagNumeric != 0,
+		Verified:    b[1]&pcapFlagVerified != 0,
+		Function:    b[2],
+		Address:     binary.BigEndian.Uint32(b[4:]),
+		BaudRate:    int(binary.BigEndian.Uint16(b[8:])),
+		FrequencyHz: int64(binary.BigEndian.Uint64(b[12:])),
+	}
+	for i := 0; i < n; i++ {
+		msg.Codewords = append(msg.Codewords, binary.BigEndian.Uint32(b[pcapHeaderLen+4*i:]))
+	}
+	msg.Message = string(b[pcapHeaderLen+4*n:])
+	return msg, nil
+}
+
+// ReadPcap reads the messages of a capture PcapWriter wrote. Blocks other
+// than packets on a LinkTypePOCSAG interface are skipped, and timestamps
+// are read in the interface's resolution if it gives one.
+func ReadPcap(r io.Reader) ([]MessageRecord, error) {
+	var records []MessageRecord
+	var order binary.ByteOrder = binary.LittleEndian
+	type iface struct {
+		pocsag bool
+		unit   time.Duration
+	}
+	var ifaces []iface
+	for {
+		head := make([]byte, 8)
+		if _, err := io.ReadFull(r, head); err == io.EOF {
+			return records, nil
+		} else if err != nil {
+			return records, fmt.Errorf("reading capture: %v", err)
+		}
+		blockType := binary.LittleEndian.Uint32(head)
+		if blockType == pcapBlockSHB {
+			// The byte-order magic follows the length, which is in that order
+			rest := make([]byte, 4)
+			if _, err := io.ReadFull(r, rest); err != nil {
+				return records, fmt.Errorf("reading capture: %v", err)
+			}
+			order = binary.LittleEndian
+			if binary.BigEndian.Uint32(rest) == pcapByteOrder {
+				order = binary.BigEndian
+			}
+			head = append(head, rest...)
+			ifaces = nil
+		} else {
+			blockType = order.Uint32(head)
+		}
+		total := int(order.Uint32(head[4:]))
+		if total < len(head)+4 || total%4 != 0 {
+			return records, fmt.Errorf("bad block length %d", total)
+		}
+		block := make([]byte, total-len(head))
+		if _, err := io.ReadFull(r, block); err != nil {
+			return records, fmt.Errorf("reading capture: %v", err)
+		}
+		body := block[:len(block)-4]
+
+		switch blockType {
+		case pcapBlockIDB:
+			if len(body) < 8 {
+				return records, fmt.Errorf("short interface block")
+			}
+			ifc := iface{pocsag: order.Uint16(body) == LinkTypePOCSAG, unit: time.Microsecond}
+			for opts := body[8:]; len(opts) >= 4; {
+				code, n := order.Uint16(opts), int(order.Uint16(opts[2:]))
+				if code == pcapOptEndOfOpts || len(opts) < 4+n {
+					break
+				}
+				if code == 9 && n == 1 && opts[4]&0x80 == 0 { // if_tsresol, a power of ten
+					ifc.unit = time.Second
+					for i := byte(0); i < opts[4]; i++ {
+						ifc.unit /= 10
+					}
+				}
+				opts = opts[4+n+pad4(n):]
+			}
+			ifaces = append(ifaces, ifc)
+		case pcapBlockEPB:
+			if len(body) < 20 {
+				return records, fmt.Errorf("short packet block")
+			}
+			id := int(order.Uint32(body))
+			if id >= len(ifaces) || !ifaces[id].pocsag {
+				continue
+			}
+			captured := int(order.Uint32(body[12:]))
+			if len(body) < 20+captured {
+				return records, fmt.Errorf("packet block shorter than its packet")
+			}
+			msg, err := unmarshalPcapPacket(body[20 : 20+captured])
+			if err != nil {
+				return records, err
+			}
+			rec := MessageRecord{DecodedMessage: msg}
+			if ts := uint64(order.Uint32(body[4:]))<<32 | uint64(order.Uint32(body[8:])); ts != 0 {
+				rec.Time = time.Unix(0, 0).Add(time.Duration(ts) * ifaces[id].unit)
+			}
+			records = append(records, rec)
+		}
+	}
+}
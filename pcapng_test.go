@@ -0,0 +1,67 @@
+package pocsag
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPcapWriter(t *testing.T) {
+	records := []MessageRecord{
+		{DecodedMessage: DecodedMessage{Address: 1234, Function: 3, Message: "HELLO", BaudRate: 1200, Codewords: []uint32{0x7CD215D8, 0x7A89C197}}, Time: time.Date(2026, 10, 15, 9, 30, 0, 123456000, time.UTC)},
+		{DecodedMessage: DecodedMessage{Address: 8, Message: "0123", IsNumeric: true, Partial: true, Verified: true, BaudRate: 512, FrequencyHz: 439987500}},
+	}
+	var buf bytes.Buffer
+	w, err := NewPcapWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range records {
+		if err := w.WriteRecord(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Blocks chain by their lengths, repeated at the end: a section, an
+	// interface of LinkTypePOCSAG, and a packet per message
+	data := buf.Bytes()
+	var types []uint32
+	for off := 0; off < len(data); {
+		n := int(binary.LittleEndian.Uint32(data[off+4:]))
+		if n%4 != 0 || off+n > len(data) || binary.LittleEndian.Uint32(data[off+n-4:]) != uint32(n) {
+			t.Fatalf("bad block at %d", off)
+		}
+		types = append(types, binary.LittleEndian.Uint32(data[off:]))
+		off += n
+	}
+	if want := []uint32{pcapBlockSHB, pcapBlockIDB, pcapBlockEPB, pcapBlockEPB}; !reflect.DeepEqual(types, want) {
+		t.Fatalf("block types %x, want %x", types, want)
+	}
+	if got := binary.LittleEndian.Uint16(data[binary.LittleEndian.Uint32(data[4:])+8:]); got != LinkTypePOCSAG {
+		t.Errorf("link type %d", got)
+	}
+
+	got, err := ReadPcap(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(records) {
+		t.Fatalf("read %d records, want %d", len(got), len(records))
+	}
+	for i, want := range records {
+		if !got[i].Time.Equal(want.Time) {
+			t.Errorf("record %d: time %v, want %v", i, got[i].Time, want.Time)
+		}
+		if !reflect.DeepEqual(got[i].DecodedMessage, want.DecodedMessage) {
+			t.Errorf("record %d: got %+v, want %+v", i, got[i].DecodedMessage, want.DecodedMessage)
+		}
+	}
+
+	// Archives in the format replay
+	archived, err := ReadArchive(bytes.NewReader(data))
+	if err != nil || len(archived) != len(records) {
+		t.Errorf("ReadArchive: %d records, %v", len(archived), err)
+	}
+}
@@ -0,0 +1,181 @@
+package pocsag
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// MessagePolicy is a hook that checks and rewrites messages before they
+// are encoded, so operators can enforce character sets, length limits, and
+// content rules in one place. Transform runs first and may sanitize the
+// message; Validate then rejects what is still not acceptable.
+type MessagePolicy interface {
+	Transform(msg MessageInfo) MessageInfo
+	Validate(msg MessageInfo) error
+}
+
+// ApplyPolicies runs each message through policies in order and returns
+// the transformed messages, or an error naming the first message a policy
+// rejects. messages is not modified.
+func ApplyPolicies(messages []MessageInfo, policies ...MessagePolicy) ([]MessageInfo, error) {
+	out := make([]MessageInfo, len(messages))
+	for i, msg := range messages {
+		for _, p := range policies {
+			msg = p.Transform(msg)
+			if err := p.Validate(msg); err != nil {
+				return nil, fmt.Errorf("message %d: %v", i, err)
+			}
+		}
+		out[i] = msg
+	}
+	return out, nil
+}
+
+type maxLengthPolicy struct {
+	length   int
+	truncate bool
+}
+
+// MaxLength limits messages to length characters. Longer messages are
+// cut to fit if truncate is set and rejected otherwise.
+func MaxLength(length int, truncate bool) MessagePolicy {
+	return maxLengthPolicy{length, truncate}
+}
+
+func (p maxLengthPolicy) Transform(msg MessageInfo) MessageInfo {
+	if p.truncate && len(msg.Message) > p.length {
+		cut := p.length
+		for cut > 0 && !utf8.RuneStart(msg.Message[cut]) {
+			cut--
+		}
+		msg.Message = msg.Message[:cut]
+	}
+	return msg
+}
+
+func (p maxLengthPolicy) Validate(msg MessageInfo) error {
+	if len(msg.Message) > p.length {
+		return fmt.Errorf("%d characters exceeds the limit of %d", len(msg.Message), p.length)
+	}
+	return nil
+}
+
+type allowedCharsPolicy struct {
+	allowed     string
+	replacement rune
+}
+
+// AllowedCharacters restricts messages to the characters in allowed.
+// Others are replaced with replacement, or rejected if it is 0.
+func AllowedCharacters(allowed string, replacement rune) MessagePolicy {
+	return allowedCharsPolicy{allowed, replacement}
+}
+
+func (p allowedCharsPolicy) Transform(msg MessageInfo) MessageInfo {
+	if p.replacement == 0 {
+		return msg
+	}
+	msg.Message = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(p.allowed, r) {
+			return r
+		}
+		return p.replacement
+	}, msg.Message)
+	return msg
+}
+
+func (p allowedCharsPolicy) Validate(msg MessageInfo) error {
+	for i, r := range msg.Message {
+		if !strings.ContainsRune(p.allowed, r) && (r != p.replacement || r == 0) {
+			return fmt.Errorf("character %q at offset %d is not allowed", r, i)
+		}
+	}
+	return nil
+}
+
+type blocklistPolicy struct {
+	re   *regexp.Regexp
+	mask bool
+}
+
+// Blocklist refuses messages containing any of words, matched as whole
+// words regardless of case. With mask set, the words are replaced with
+// asterisks instead.
+func Blocklist(words []string, mask bool) MessagePolicy {
+	var quoted []string
+	for _, w := range words {
+		if w = strings.TrimSpace(w); w != "" {
+			quoted = append(quoted, regexp.QuoteMeta(w))
+		}
+	}
+	if len(quoted) == 0 {
+		return blocklistPolicy{}
+	}
+	re := regexp.MustCompile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)\b`)
+	return blocklistPolicy{re, mask}
+}
+
+func (p blocklistPolicy) Transform(msg MessageInfo) MessageInfo {
+	if p.re != nil && p.mask {
+		msg.Message = p.re.ReplaceAllStringFunc(msg.Message, func(w string) string {
+			return strings.Repeat("*", utf8.RuneCountInString(w))
+		})
+	}
+	return msg
+}
+
+func (p blocklistPolicy) Validate(msg MessageInfo) error {
+	if p.re == nil {
+		return nil
+	}
+	if w := p.re.FindString(msg.Message); w != "" {
+		return fmt.Errorf("contains blocked word %q", w)
+	}
+	return nil
+}
+
+// PolicyConfig is the JSON form of a set of message policies, for keeping
+// them in one file shared by every tool that transmits. Zero fields apply
+// no limit.
+type PolicyConfig struct {
+	MaxLength    int      `json:"max_length"`
+	Truncate     bool     `json:"truncate"`
+	AllowedChars string   `json:"allowed_chars"`
+	Replacement  string   `json:"replacement"`
+	Blocklist    []string `json:"blocklist"`
+	Mask         bool     `json:"mask"`
+}
+
+// LoadPolicies parses a PolicyConfig and returns its policies in the order
+// they apply: blocked words, then characters, then length.
+func LoadPolicies(data []byte) ([]MessagePolicy, error) {
+	var cfg PolicyConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing policy: %v", err)
+	}
+
+	var policies []MessagePolicy
+	if len(cfg.Blocklist) > 0 {
+		policies = append(policies, Blocklist(cfg.Blocklist, cfg.Mask))
+	}
+	if cfg.AllowedChars != "" {
+		var replacement rune
+		if cfg.Replacement != "" {
+			if utf8.RuneCountInString(cfg.Replacement) != 1 {
+				return nil, fmt.Errorf("replacement must be a single character, not %q", cfg.Replacement)
+			}
+			replacement, _ = utf8.DecodeRuneInString(cfg.Replacement)
+		}
+		policies = append(policies, AllowedCharacters(cfg.AllowedChars, replacement))
+	}
+	if cfg.MaxLength < 0 {
+		return nil, fmt.Errorf("max_length must not be negative")
+	}
+	if cfg.MaxLength > 0 {
+		policies = append(policies, MaxLength(cfg.MaxLength, cfg.Truncate))
+	}
+	return policies, nil
+}
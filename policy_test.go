@@ -0,0 +1,55 @@
+package pocsag
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMessagePolicies(t *testing.T) {
+	policies, err := LoadPolicies([]byte(`{
+		"max_length": 12,
+		"truncate": true,
+		"allowed_chars": "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789 *",
+		"replacement": "?",
+		"blocklist": ["darn"],
+		"mask": true
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := NewEncoder(WithPolicies(policies...))
+	in := []MessageInfo{
+		{Address: 1234, Message: "DARN PUMP #3 DOWN", Function: FuncAlphanumeric},
+		{Address: 5678, Message: "DARNING", Function: FuncAlphanumeric},
+	}
+	out, err := enc.Prepare(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out[0].Message != "**** PUMP ?3" || out[1].Message != "DARNING" {
+		t.Errorf("got %q, %q", out[0].Message, out[1].Message)
+	}
+	if in[0].Message != "DARN PUMP #3 DOWN" {
+		t.Error("Prepare modified its input")
+	}
+
+	// Without transforms the same rules refuse instead
+	for _, tt := range []struct {
+		policy MessagePolicy
+		msg    string
+		want   string
+	}{
+		{MaxLength(4, false), "TOO LONG", "exceeds"},
+		{AllowedCharacters("ABC", 0), "ABD", "'D'"},
+		{Blocklist([]string{"darn"}, false), "oh Darn.", "blocked"},
+	} {
+		_, err := ApplyPolicies([]MessageInfo{{Message: "ABC"}, {Message: tt.msg}}, tt.policy)
+		if err == nil || !strings.Contains(err.Error(), "message 1") || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%q: err %v, want %q", tt.msg, err, tt.want)
+		}
+	}
+
+	if _, err := LoadPolicies([]byte(`{"allowed_chars": "AB", "replacement": "??"}`)); err == nil {
+		t.Error("two-character replacement accepted")
+	}
+}
@@ -0,0 +1,72 @@
+package pocsag
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Position is where the receiver was when a message arrived, for drive
+// tests and coverage surveys of paging transmitters.
+type Position struct {
+	Latitude  float64   `json:"lat"`           // degrees north, WGS 84
+	Longitude float64   `json:"lon"`           // degrees east
+	Altitude  float64   `json:"alt,omitempty"` // metres above mean sea level, if known
+	Time      time.Time `json:"time"`          // when the position was fixed
+}
+
+// PositionSource reports where the receiver is. ok is false while the
+// position is not known, as before a GPS receiver has a fix.
+type PositionSource interface {
+	Position() (pos Position, ok bool)
+}
+
+// StaticPosition is the PositionSource of a receiver that does not move.
+type StaticPosition struct {
+	Latitude, Longitude, Altitude float64
+}
+
+// Position returns p, fixed now.
+func (p StaticPosition) Position() (Position, bool) {
+	return Position{Latitude: p.Latitude, Longitude: p.Longitude, Altitude: p.Altitude, Time: time.Now().UTC()}, true
+}
+
+// ParseStaticPosition parses "lat,lon" or "lat,lon,alt" in degrees and
+// metres, e.g. "52.5200,13.4050,34".
+func ParseStaticPosition(s string) (StaticPosition, error) {
+	fields := strings.Split(s, ",")
+	if len(fields) < 2 || len(fields) > 3 {
+		return StaticPosition{}, fmt.Errorf("invalid position %q: want lat,lon or lat,lon,alt", s)
+	}
+	var v [3]float64
+	for i, field := range fields {
+		f, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil {
+			return StaticPosition{}, fmt.Errorf("invalid position %q: %v", s, err)
+		}
+		v[i] = f
+	}
+	if v[0] < -90 || v[0] > 90 || v[1] < -180 || v[1] > 180 {
+		return StaticPosition{}, fmt.Errorf("invalid position %q: out of range", s)
+	}
+	return StaticPosition{Latitude: v[0], Longitude: v[1], Altitude: v[2]}, nil
+}
+
+type positionProcessor struct {
+	source PositionSource
+}
+
+// StampPosition returns a PostProcessor that sets each message's Position
+// to where source says the receiver is, leaving it nil while source does
+// not know.
+func StampPosition(source PositionSource) PostProcessor {
+	return positionProcessor{source}
+}
+
+func (p positionProcessor) Process(msg DecodedMessage) DecodedMessage {
+	if pos, ok := p.source.Position(); ok {
+		msg.Position = &pos
+	}
+	return msg
+}
@@ -0,0 +1,30 @@
+package pocsag
+
+import "testing"
+
+func TestStampPosition(t *testing.T) {
+	static, err := ParseStaticPosition("52.5200, 13.4050,34")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if static != (StaticPosition{52.52, 13.405, 34}) {
+		t.Errorf("parsed %+v", static)
+	}
+	for _, bad := range []string{"52.52", "52.52,13.4,34,1", "north,13.4", "91,0", "0,-181"} {
+		if _, err := ParseStaticPosition(bad); err == nil {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+
+	msg := StampPosition(static).Process(DecodedMessage{Address: 8})
+	if msg.Position == nil || msg.Position.Latitude != 52.52 || msg.Position.Altitude != 34 || msg.Position.Time.IsZero() {
+		t.Errorf("stamped %+v", msg.Position)
+	}
+	if msg := StampPosition(noFix{}).Process(DecodedMessage{Address: 8}); msg.Position != nil {
+		t.Errorf("stamped without a fix: %+v", msg.Position)
+	}
+}
+
+type noFix struct{}
+
+func (noFix) Position() (Position, bool) { return Position{}, false }
@@ -0,0 +1,167 @@
+package pocsag
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PostProcessor is a hook that annotates or rewrites decoded messages
+// before they are shown or passed on, the receiving counterpart of
+// MessagePolicy.
+type PostProcessor interface {
+	Process(msg DecodedMessage) DecodedMessage
+}
+
+// ApplyPostProcessors runs msg through processors in order.
+func ApplyPostProcessors(msg DecodedMessage, processors ...PostProcessor) DecodedMessage {
+	for _, p := range processors {
+		msg = p.Process(msg)
+	}
+	return msg
+}
+
+type redactProcessor struct {
+	re          *regexp.Regexp
+	replacement string
+}
+
+// Redact replaces text matching re with replacement, which may refer to
+// submatches as in regexp.Regexp.ReplaceAllString, e.g. to blank out phone
+// numbers or patient names before pages are logged.
+func Redact(re *regexp.Regexp, replacement string) PostProcessor {
+	return redactProcessor{re, replacement}
+}
+
+func (p redactProcessor) Process(msg DecodedMessage) DecodedMessage {
+	msg.Message = p.re.ReplaceAllString(msg.Message, p.replacement)
+	return msg
+}
+
+type tagProcessor struct {
+	tag string
+	re  *regexp.Regexp
+}
+
+// TagKeywords adds tag to messages containing any of words, matched as
+// whole words regardless of case, as Blocklist matches them.
+func TagKeywords(tag string, words []string) PostProcessor {
+	var quoted []string
+	for _, w := range words {
+		if w = strings.TrimSpace(w); w != "" {
+			quoted = append(quoted, regexp.QuoteMeta(w))
+		}
+	}
+	if len(quoted) == 0 {
+		return tagProcessor{}
+	}
+	return tagProcessor{tag, regexp.MustCompile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)\b`)}
+}
+
+func (p tagProcessor) Process(msg DecodedMessage) DecodedMessage {
+	if p.re == nil || !p.re.MatchString(msg.Message) {
+		return msg
+	}
+	for _, t := range msg.Tags {
+		if t == p.tag {
+			return msg
+		}
+	}
+	msg.Tags = append(msg.Tags[:len(msg.Tags):len(msg.Tags)], p.tag)
+	return msg
+}
+
+// Fleet names the pagers on a channel by address, so messages read "Fire
+// Station 3" rather than a RIC. As a PostProcessor it sets the Alias of
+// messages to known addresses.
+type Fleet map[uint32]string
+
+// LoadFleet reads a fleet file: CSV with an address and a name on each
+// line. A first line whose address is not a number is taken as a header,
+// and lines starting with # are comments.
+func LoadFleet(r io.Reader) (Fleet, error) {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = 2
+	cr.TrimLeadingSpace = true
+	fleet := make(Fleet)
+	for first := true; ; first = false {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return fleet, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading fleet file: %v", err)
+		}
+		address, err := strconv.ParseUint(strings.TrimSpace(rec[0]), 10, 32)
+		if err != nil && first {
+			continue
+		}
+		line, _ := cr.FieldPos(0)
+		if err != nil || address > MaxAddress {
+			return nil, fmt.Errorf("fleet file line %d: invalid address %q", line, rec[0])
+		}
+		fleet[uint32(address)] = strings.TrimSpace(rec[1])
+	}
+}
+
+func (f Fleet) Process(msg DecodedMessage) DecodedMessage {
+	if name, ok := f[msg.Address]; ok {
+		msg.Alias = name
+	}
+	return msg
+}
+
+// PostProcessConfig is the JSON form of a set of post-processors. Redact
+// maps regular expressions to their replacements, and Tags maps tag names
+// to the keywords that add them.
+type PostProcessConfig struct {
+	Tags   map[string][]string `json:"tags"`
+	Redact []RedactRule        `json:"redact"`
+}
+
+// RedactRule is a Redact post-processor in a PostProcessConfig. The
+// replacement defaults to [REDACTED].
+type RedactRule struct {
+	Pattern     string `json:"pattern"`
+	Replacement string `json:"replacement"`
+}
+
+// LoadPostProcessors parses a PostProcessConfig and returns its
+// post-processors in the order they apply: tags, in order of name, then
+// redactions, so keywords in redacted text still tag the message.
+func LoadPostProcessors(data []byte) ([]PostProcessor, error) {
+	var cfg PostProcessConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing post-processing config: %v", err)
+	}
+
+	var processors []PostProcessor
+	tags := make([]string, 0, len(cfg.Tags))
+	for tag := range cfg.Tags {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	for _, tag := range tags {
+		if tag == "" {
+			return nil, fmt.Errorf("tag name must not be empty")
+		}
+		processors = append(processors, TagKeywords(tag, cfg.Tags[tag]))
+	}
+	for _, rule := range cfg.Redact {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("redact pattern %q: %v", rule.Pattern, err)
+		}
+		if rule.Replacement == "" {
+			rule.Replacement = "[REDACTED]"
+		}
+		processors = append(processors, Redact(re, rule.Replacement))
+	}
+	return processors, nil
+}
@@ -0,0 +1,44 @@
+package pocsag
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPostProcessors(t *testing.T) {
+	fleet, err := LoadFleet(strings.NewReader("address,name\n# county\n123456, Fire Station 3\n8,\"Ops, night\"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fleet) != 2 || fleet[8] != "Ops, night" {
+		t.Errorf("fleet %v", fleet)
+	}
+	if _, err := LoadFleet(strings.NewReader("123456,A\nnine,B\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("bad address: %v", err)
+	}
+
+	loaded, err := LoadPostProcessors([]byte(`{
+		"tags": {"fire": ["fire", "smoke"], "medical": ["cardiac"]},
+		"redact": [{"pattern": "\\b0\\d{9}\\b"}, {"pattern": "PT (\\w+)", "replacement": "PT $1."}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	processors := append([]PostProcessor{fleet}, loaded...)
+	msg := ApplyPostProcessors(DecodedMessage{Address: 123456, Message: "SMOKE AT 12 MAIN, CALL 0123456789, PT Smith"}, processors...)
+	want := DecodedMessage{Address: 123456, Message: "SMOKE AT 12 MAIN, CALL [REDACTED], PT Smith.", Alias: "Fire Station 3", Tags: []string{"fire"}}
+	if !reflect.DeepEqual(msg, want) {
+		t.Errorf("got %+v", msg)
+	}
+	if !strings.Contains(msg.String(), "123456 (Fire Station 3)") || !strings.HasSuffix(msg.String(), "Tags: fire") {
+		t.Errorf("String: %s", msg.String())
+	}
+	if msg := ApplyPostProcessors(DecodedMessage{Address: 9, Message: "FIREWORKS"}, processors...); msg.Alias != "" || msg.Tags != nil {
+		t.Errorf("unrelated message changed: %+v", msg)
+	}
+
+	if _, err := LoadPostProcessors([]byte(`{"redact": [{"pattern": "("}]}`)); err == nil {
+		t.Error("bad pattern accepted")
+	}
+}
@@ -0,0 +1,317 @@
+package pocsag
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+	"unicode/utf8"
+)
+
+// MessageRecord is a decoded message as handed to a pipeline, with the
+// time it was received. Time is zero when it is not known, as for
+// recordings. Records are gob-encodable as they are, and MarshalProto
+// encodes them as the pocsag.v1.DecodedMessage protobuf message defined in
+// schemas/decoded_message.proto.
+type MessageRecord struct {
+	DecodedMessage
+	Time time.Time
+}
+
+// Field numbers of pocsag.v1.DecodedMessage
+const (
+	protoAddress     = 1
+	protoFunction    = 2
+	protoMessage     = 3
+	protoNumeric     = 4
+	protoPartial     = 5
+	protoBaud        = 6
+	protoCodewords   = 7
+	protoTime        = 8
+	protoAlias       = 9
+	protoTags        = 10
+	protoAlternative = 11
+	protoFrequency   = 12
+	protoOffset      = 13
+	protoRSSI        = 14
+	protoSNR         = 15
+	protoPosition    = 16
+	protoVerified    = 17
+)
+
+// Field numbers of pocsag.v1.Position
+const (
+	protoLatitude  = 1
+	protoLongitude = 2
+	protoAltitude  = 3
+	protoFixTime   = 4
+)
+
+// maxProtoRecord bounds the records ReadProtoDelimited accepts, well above
+// the longest message a pager takes.
+const maxProtoRecord = 1 << 20
+
+// Protobuf wire types
+const (
+	wireVarint = 0
+	wireI64    = 1
+	wireLen    = 2
+	wireI32    = 5
+)
+
+// MarshalProto encodes r in the protobuf wire format. Fields at their zero
+// value are left out, as proto3 does.
+func (r MessageRecord) MarshalProto() []byte {
+	var b []byte
+	varint := func(field int, v uint64) {
+		if v != 0 {
+			b = binary.AppendUvarint(b, uint64(field<<3|wireVarint))
+			b = binary.AppendUvarint(b, v)
+		}
+	}
+	boolean := func(field int, v bool) {
+		if v {
+			varint(field, 1)
+		}
+	}
+	str := func(field int, s string) {
+		b = binary.AppendUvarint(b, uint64(field<<3|wireLen))
+		b = binary.AppendUvarint(b, uint64(len(s)))
+		b = append(b, s...)
+	}
+
+	varint(protoAddress, uint64(r.Address))
+	varint(protoFunction, uint64(r.Function))
+	if r.Message != "" {
+		str(protoMessage, r.Message)
+	}
+	boolean(protoNumeric, r.IsNumeric)
+	boolean(protoPartial, r.Partial)
+	varint(protoBaud, uint64(r.BaudRate))
+	if len(r.Codewords) > 0 {
+		var packed []byte
+		for _, cw := range r.Codewords {
+			packed = binary.AppendUvarint(packed, uint64(cw))
+		}
+		b = binary.AppendUvarint(b, protoCodewords<<3|wireLen)
+		b = binary.AppendUvarint(b, uint64(len(packed)))
+		b = append(b, packed...)
+	}
+	if !r.Time.IsZero() {
+		varint(protoTime, uint64(r.Time.UnixNano()))
+	}
+	if r.Alias != "" {
+		str(protoAlias, r.Alias)
+	}
+	for _, tag := range r.Tags {
+		str(protoTags, tag)
+	}
+	if r.Alternative != "" {
+		str(protoAlternative, r.Alternative)
+	}
+	varint(protoFrequency, uint64(r.FrequencyHz))
+	// sint32 is zigzag encoded, keeping small negative values short
+	offset := int64(r.FrequencyOffsetHz)
+	varint(protoOffset, uint64(offset<<1^offset>>63))
+	if r.SNRValid {
+		b = binary.AppendUvarint(b, protoRSSI<<3|wireI32)
+		b = binary.LittleEndian.AppendUint32(b, math.Float32bits(float32(r.RSSI)))
+		b = binary.AppendUvarint(b, protoSNR<<3|wireI32)
+		b = binary.LittleEndian.AppendUint32(b, math.Float32bits(float32(r.SNR)))
+	}
+	boolean(protoVerified, r.Verified)
+	if r.Position != nil {
+		pos := marshalPosition(*r.Position)
+		b = binary.AppendUvarint(b, protoPosition<<3|wireLen)
+		b = binary.AppendUvarint(b, uint64(len(pos)))
+		b = append(b, pos...)
+	}
+	return b
+}
+
+func marshalPosition(p Position) []byte {
+	var b []byte
+	double := func(field int, v float64) {
+		if v != 0 {
+			b = binary.AppendUvarint(b, uint64(field<<3|wireI64))
+			b = binary.LittleEndian.AppendUint64(b, math.Float64bits(v))
+		}
+	}
+	double(protoLatitude, p.Latitude)
+	double(protoLongitude, p.Longitude)
+	double(protoAltitude, p.Altitude)
+	if !p.Time.IsZero() {
+		b = binary.AppendUvarint(b, protoFixTime<<3|wireVarint)
+		b = binary.AppendUvarint(b, uint64(p.Time.UnixNano()))
+	}
+	return b
+}
+
+// UnmarshalProto decodes a record encoded by MarshalProto or by any
+// protobuf library from schemas/decoded_message.proto. Unknown fields are
+// skipped.
+func (r *MessageRecord) UnmarshalProto(data []byte) error {
+	*r = MessageRecord{}
+	return parseProto(data, func(field, wire int, v uint64, payload []byte) error {
+		switch {
+		case field == protoAddress && wire == wireVarint:
+			r.Address = uint32(v)
+		case field == protoFunction && wire == wireVarint:
+			r.Function = uint8(v)
+		case field == protoMessage && wire == wireLen:
+			if !utf8.Valid(payload) {
+				return fmt.Errorf("message is not valid UTF-8")
+			}
+			r.Message = string(payload)
+		case field == protoNumeric && wire == wireVarint:
+			r.IsNumeric = v != 0
+		case field == protoPartial && wire == wireVarint:
+			r.Partial = v != 0
+		case field == protoBaud && wire == wireVarint:
+			r.BaudRate = int(v)
+		case field == protoCodewords && wire == wireVarint:
+			r.Codewords = append(r.Codewords, uint32(v))
+		case field == protoCodewords && wire == wireLen:
+			for len(payload) > 0 {
+				cw, n := binary.Uvarint(payload)
+				if n <= 0 {
+					return fmt.Errorf("codewords: malformed varint")
+				}
+				r.Codewords = append(r.Codewords, uint32(cw))
+				payload = payload[n:]
+			}
+		case field == protoFrequency && wire == wireVarint:
+			r.FrequencyHz = int64(v)
+		case field == protoRSSI && wire == wireI32:
+			r.RSSI = float64(math.Float32frombits(uint32(v)))
+		case field == protoSNR && wire == wireI32:
+			r.SNR = float64(math.Float32frombits(uint32(v)))
+			r.SNRValid = true
+		case field == protoOffset && wire == wireVarint:
+			r.FrequencyOffsetHz = int(int64(v>>1) ^ -int64(v&1))
+		case field == protoTime && wire == wireVarint:
+			r.Time = time.Unix(0, int64(v)).UTC()
+		case (field == protoAlias || field == protoTags || field == protoAlternative) && wire == wireLen:
+			if !utf8.Valid(payload) {
+				return fmt.Errorf("field %d is not valid UTF-8", field)
+			}
+			switch field {
+			case protoAlias:
+				r.Alias = string(payload)
+			case protoTags:
+				r.Tags = append(r.Tags, string(payload))
+			default:
+				r.Alternative = string(payload)
+			}
+		case field == protoVerified && wire == wireVarint:
+			r.Verified = v != 0
+		case field == protoPosition && wire == wireLen:
+			pos, err := unmarshalPosition(payload)
+			if err != nil {
+				return fmt.Errorf("position: %v", err)
+			}
+			r.Position = &pos
+		}
+		return nil
+	})
+}
+
+func unmarshalPosition(data []byte) (Position, error) {
+	var p Position
+	err := parseProto(data, func(field, wire int, v uint64, _ []byte) error {
+		switch {
+		case field == protoLatitude && wire == wireI64:
+			p.Latitude = math.Float64frombits(v)
+		case field == protoLongitude && wire == wireI64:
+			p.Longitude = math.Float64frombits(v)
+		case field == protoAltitude && wire == wireI64:
+			p.Altitude = math.Float64frombits(v)
+		case field == protoFixTime && wire == wireVarint:
+			p.Time = time.Unix(0, int64(v)).UTC()
+		}
+		return nil
+	})
+	return p, err
+}
+
+// parseProto calls fn with each field of a protobuf message in turn:
+// varint and fixed-size values in v, length-delimited ones in payload.
+func parseProto(data []byte, fn func(field, wire int, v uint64, payload []byte) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return fmt.Errorf("malformed field key")
+		}
+		data = data[n:]
+		field, wire := int(key>>3), int(key&7)
+
+		var v uint64
+		var payload []byte
+		switch wire {
+		case wireVarint:
+			v, n = binary.Uvarint(data)
+			if n <= 0 {
+				return fmt.Errorf("field %d: malformed varint", field)
+			}
+			data = data[n:]
+		case wireI64, wireI32:
+			size := 8
+			if wire == wireI32 {
+				size = 4
+			}
+			if len(data) < size {
+				return fmt.Errorf("field %d: truncated", field)
+			}
+			if wire == wireI32 {
+				v = uint64(binary.LittleEndian.Uint32(data))
+			} else {
+				v = binary.LittleEndian.Uint64(data)
+			}
+			data = data[size:]
+		case wireLen:
+			length, n := binary.Uvarint(data)
+			if n <= 0 || length > uint64(len(data)-n) {
+				return fmt.Errorf("field %d: truncated", field)
+			}
+			payload = data[n : n+int(length)]
+			data = data[n+int(length):]
+		default:
+			return fmt.Errorf("field %d: unsupported wire type %d", field, wire)
+		}
+
+		if err := fn(field, wire, v, payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteProtoDelimited writes r to w as a protobuf message preceded by its
+// length as a varint, the framing protobuf libraries read streams of
+// messages with (parseDelimitedFrom in Java, protodelim in Go).
+func WriteProtoDelimited(w io.Writer, r MessageRecord) error {
+	body := r.MarshalProto()
+	_, err := w.Write(append(binary.AppendUvarint(nil, uint64(len(body))), body...))
+	return err
+}
+
+// ReadProtoDelimited reads the next record WriteProtoDelimited wrote. It
+// returns io.EOF at the end of the stream.
+func ReadProtoDelimited(r *bufio.Reader) (MessageRecord, error) {
+	var rec MessageRecord
+	length, err := binary.ReadUvarint(r)
+	if err != nil {
+		return rec, err
+	}
+	if length > maxProtoRecord {
+		return rec, fmt.Errorf("record of %d bytes is too long", length)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return rec, io.ErrUnexpectedEOF
+	}
+	err = rec.UnmarshalProto(body)
+	return rec, err
+}
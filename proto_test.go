@@ -0,0 +1,66 @@
+package pocsag
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMessageRecordProto(t *testing.T) {
+	rec := MessageRecord{DecodedMessage: DecodedMessage{
+		Address:   1234,
+		Function:  3,
+		Message:   "HI",
+		BaudRate:  1200,
+		Codewords: []uint32{0x7CD215D8},
+	}}
+	// As protoc-generated code encodes it, with codewords packed
+	want := []byte{
+		0x08, 0xD2, 0x09,
+		0x10, 0x03,
+		0x1A, 0x02, 'H', 'I',
+		0x30, 0xB0, 0x09,
+		0x3A, 0x05, 0xD8, 0xAB, 0xC8, 0xE6, 0x07,
+	}
+	if got := rec.MarshalProto(); !bytes.Equal(got, want) {
+		t.Errorf("encoded % X, want % X", got, want)
+	}
+
+	// A stream of records reads back as written
+	records := []MessageRecord{
+		rec,
+		{DecodedMessage: DecodedMessage{Address: 8, Message: "0123", IsNumeric: true, Partial: true, BaudRate: 512, Alias: "Ops", Tags: []string{"fire", "medical"}, Alternative: "HI", FrequencyHz: 439987500, FrequencyOffsetHz: -1234, RSSI: -42.5, SNR: 18.25, SNRValid: true, Position: &Position{Latitude: 52.52, Longitude: -13.405, Time: time.Date(2026, 10, 15, 9, 29, 59, 0, time.UTC)}, Verified: true}, Time: time.Date(2026, 10, 15, 9, 30, 0, 5, time.UTC)},
+		{},
+	}
+	var buf bytes.Buffer
+	for _, r := range records {
+		if err := WriteProtoDelimited(&buf, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := bufio.NewReader(&buf)
+	for i, wantRec := range records {
+		got, err := ReadProtoDelimited(r)
+		if err != nil {
+			t.Fatalf("record %d: %v", i, err)
+		}
+		if !reflect.DeepEqual(got, wantRec) {
+			t.Errorf("record %d: got %+v, want %+v", i, got, wantRec)
+		}
+	}
+	if _, err := ReadProtoDelimited(r); err != io.EOF {
+		t.Errorf("after the last record: %v", err)
+	}
+
+	// Unknown fields of later versions are skipped
+	var got MessageRecord
+	if err := got.UnmarshalProto(append([]byte{0x48, 0x01, 0x55, 1, 2, 3, 4}, want...)); err != nil || !reflect.DeepEqual(got, rec) {
+		t.Errorf("with unknown fields: %+v, %v", got, err)
+	}
+	if err := got.UnmarshalProto([]byte{0x1A, 0x05, 'H'}); err == nil {
+		t.Error("truncated record accepted")
+	}
+}
@@ -0,0 +1,210 @@
+package pocsag
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// PendingPage is a one-off page waiting in a Scheduler's queue.
+type PendingPage struct {
+	// ID identifies the page for Cancel and EnqueueOnce. Enqueue makes
+	// one up.
+	ID     string
+	Queued time.Time
+	MessageInfo
+}
+
+// QueueLog keeps a Scheduler's one-off queue on disk, so pages queued but
+// not yet sent survive a restart. It is a write-ahead log of JSON lines,
+// one per page queued, sent, or cancelled, synced to disk before the
+// Scheduler acts on it, and rewritten with only the pending pages when it
+// is opened and as it grows. A page whose burst was on the air when the
+// process stopped is sent again.
+type QueueLog struct {
+	mu      sync.Mutex
+	path    string
+	f       *os.File
+	pending []PendingPage // as of opening
+	records int           // lines in the file
+}
+
+// queueRecord is a line of a QueueLog.
+type queueRecord struct {
+	Op          string    `json:"op"` // add, sent, or cancel
+	ID          string    `json:"id"`
+	Queued      time.Time `json:"queued"`
+	Address     uint32    `json:"address,omitempty"`
+	Function    uint8     `json:"function,omitempty"`
+	PayloadType string    `json:"payload_type,omitempty"`
+	Message     string    `json:"message,omitempty"`
+}
+
+// queueCompactMin is how many lines a QueueLog may hold before it is
+// rewritten, when most of them are for pages no longer pending.
+const queueCompactMin = 1024
+
+// OpenQueueLog opens the queue log at path, creating it if need be, and
+// reads the pages left pending in it. A line cut short by a crash at the
+// end of the file is ignored.
+func OpenQueueLog(path string) (*QueueLog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("reading queue log: %v", err)
+	}
+
+	var pending []PendingPage
+	index := make(map[string]int)
+	lines := bytes.Split(data, []byte("\n"))
+	for i, line := range lines {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var rec queueRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			if i == len(lines)-1 {
+				break // torn final write
+			}
+			return nil, fmt.Errorf("queue log line %d: %v", i+1, err)
+		}
+		switch rec.Op {
+		case "add":
+			if _, dup := index[rec.ID]; !dup {
+				index[rec.ID] = len(pending)
+				pending = append(pending, rec.page())
+			}
+		case "sent", "cancel":
+			if j, ok := index[rec.ID]; ok {
+				pending[j].ID = ""
+				delete(index, rec.ID)
+			}
+		default:
+			return nil, fmt.Errorf("queue log line %d: unknown op %q", i+1, rec.Op)
+		}
+	}
+	live := pending[:0]
+	for _, p := range pending {
+		if p.ID != "" {
+			live = append(live, p)
+		}
+	}
+
+	l := &QueueLog{path: path, pending: live}
+	if err := l.compact(live); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Close closes the log file.
+func (l *QueueLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.f.Close()
+}
+
+func (rec queueRecord) page() PendingPage {
+	return PendingPage{
+		ID:     rec.ID,
+		Queued: rec.Queued,
+		MessageInfo: MessageInfo{
+			Address:     rec.Address,
+			Function:    rec.Function,
+			PayloadType: rec.PayloadType,
+			Message:     rec.Message,
+		},
+	}
+}
+
+func addRecord(p PendingPage) queueRecord {
+	return queueRecord{
+		Op:          "add",
+		ID:          p.ID,
+		Queued:      p.Queued,
+		Address:     p.Address,
+		Function:    p.Function,
+		PayloadType: p.PayloadType,
+		Message:     p.Message,
+	}
+}
+
+// append writes records and syncs them to disk. pending is the queue
+// afterwards, written out instead if the log has grown mostly dead.
+func (l *QueueLog) append(pending []PendingPage, recs ...queueRecord) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.records+len(recs) > max(queueCompactMin, 4*len(pending)) {
+		return l.compactLocked(pending)
+	}
+	var buf bytes.Buffer
+	for _, rec := range recs {
+		line, _ := json.Marshal(rec)
+		buf.Write(append(line, '\n'))
+	}
+	if _, err := l.f.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("writing queue log: %v", err)
+	}
+	if err := l.f.Sync(); err != nil {
+		return fmt.Errorf("syncing queue log: %v", err)
+	}
+	l.records += len(recs)
+	return nil
+}
+
+func (l *QueueLog) compact(pending []PendingPage) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.compactLocked(pending)
+}
+
+// compactLocked replaces the log with one holding only pending, by way of
+// a temporary file renamed over it so a crash leaves one or the other.
+func (l *QueueLog) compactLocked(pending []PendingPage) error {
+	tmp := l.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("rewriting queue log: %v", err)
+	}
+	w := bufio.NewWriter(f)
+	for _, p := range pending {
+		line, _ := json.Marshal(addRecord(p))
+		w.Write(append(line, '\n'))
+	}
+	err = w.Flush()
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, l.path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("rewriting queue log: %v", err)
+	}
+
+	if l.f != nil {
+		l.f.Close()
+	}
+	l.f, err = os.OpenFile(l.path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("opening queue log: %v", err)
+	}
+	l.records = len(pending)
+	return nil
+}
+
+// newPageID returns a random ID for a page queued without one.
+func newPageID() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,162 @@
+package pocsag
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// TimedMessage is a page sent Offset after the start of a timeline.
+type TimedMessage struct {
+	Offset time.Duration
+	// BaudRate is the rate to send the page at, or 0 for the Encoder's.
+	BaudRate int
+	MessageInfo
+}
+
+// EncodeTimeline renders pages into one WAV file, each page in a
+// transmission of its own starting at its Offset, or as soon as the one
+// before has ended. Pages must be in order of Offset.
+func (e *Encoder) EncodeTimeline(pages []TimedMessage) []byte {
+	groups := make([][]MessageInfo, len(pages))
+	for i, page := range pages {
+		groups[i] = []MessageInfo{page.MessageInfo}
+	}
+	groups, err := e.encryptGroups(groups)
+	if err != nil {
+		return nil
+	}
+	var samples []int16
+	for i, page := range pages {
+		if start := int(page.Offset.Seconds() * SampleRate); start > len(samples) {
+			samples = append(samples, make([]int16, start-len(samples))...)
+		}
+		rate := *e
+		if page.BaudRate != 0 {
+			rate.baudRate = page.BaudRate
+		}
+		burst, err := rate.burst(groups[i])
+		if err != nil {
+			return nil
+		}
+		samples = append(samples, rate.basebandSamples(burst)...)
+	}
+	return e.render(samples)
+}
+
+// archiveLine is a line of an NDJSON archive, as pocsag monitor --json
+// writes it.
+type archiveLine struct {
+	Time     string   `json:"time"`
+	Address  uint32   `json:"address"`
+	Function uint8    `json:"function"`
+	Message  string   `json:"message"`
+	Type     string   `json:"type"`
+	Partial  bool     `json:"partial"`
+	Baud     int      `json:"baud"`
+	Alias    string   `json:"alias"`
+	Tags     []string `json:"tags"`
+}
+
+// ReadArchive reads the messages in an archive of pocsag monitor or
+// pocsag decode output with --format ndjson (or monitor --json), --format
+// proto, or --format pcapng, telling them apart by the first bytes. NDJSON
+// lines that are not messages, such as error reports, are skipped.
+func ReadArchive(r io.Reader) ([]MessageRecord, error) {
+	br := bufio.NewReader(r)
+	first, err := br.Peek(1)
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if magic, _ := br.Peek(4); string(magic) == "\x0a\x0d\x0d\x0a" {
+		return ReadPcap(br)
+	}
+	var records []MessageRecord
+	if first[0] != '{' {
+		for {
+			rec, err := ReadProtoDelimited(br)
+			if err == io.EOF {
+				return records, nil
+			}
+			if err != nil {
+				return nil, fmt.Errorf("record %d: %v", len(records)+1, err)
+			}
+			records = append(records, rec)
+		}
+	}
+
+	scanner := bufio.NewScanner(br)
+	scanner.Buffer(nil, maxProtoRecord)
+	for n := 1; scanner.Scan(); n++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var line archiveLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		if line.Type == "" {
+			continue
+		}
+		rec := MessageRecord{DecodedMessage: DecodedMessage{
+			Address:   line.Address,
+			Function:  line.Function,
+			Message:   line.Message,
+			IsNumeric: line.Type == "numeric",
+			Partial:   line.Partial,
+			BaudRate:  line.Baud,
+			Alias:     line.Alias,
+			Tags:      line.Tags,
+		}}
+		if line.Time != "" {
+			if rec.Time, err = time.Parse(time.RFC3339Nano, line.Time); err != nil {
+				return nil, fmt.Errorf("line %d: %v", n, err)
+			}
+		}
+		records = append(records, rec)
+	}
+	return records, scanner.Err()
+}
+
+// ReplayTimeline places archived messages on a timeline with the time
+// between them divided by speed and, if maxGap is not zero, cut to at
+// most maxGap, the first at the start. A message without a time, or with
+// one before the message ahead of it, follows that one at once.
+func ReplayTimeline(records []MessageRecord, speed float64, maxGap time.Duration) []TimedMessage {
+	pages := make([]TimedMessage, len(records))
+	var offset time.Duration
+	var last time.Time
+	for i, rec := range records {
+		if i > 0 && !rec.Time.IsZero() && !last.IsZero() && rec.Time.After(last) {
+			gap := time.Duration(float64(rec.Time.Sub(last)) / speed)
+			if maxGap > 0 && gap > maxGap {
+				gap = maxGap
+			}
+			offset += gap
+		}
+		if !rec.Time.IsZero() {
+			last = rec.Time
+		}
+		payloadType := PayloadTypeAlpha
+		if rec.IsNumeric {
+			payloadType = PayloadTypeNumeric
+		}
+		pages[i] = TimedMessage{
+			Offset:   offset,
+			BaudRate: rec.BaudRate,
+			MessageInfo: MessageInfo{
+				Address:     rec.Address,
+				Function:    rec.Function,
+				PayloadType: payloadType,
+				Message:     rec.Message,
+			},
+		}
+	}
+	return pages
+}
@@ -0,0 +1,60 @@
+package pocsag
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReplay(t *testing.T) {
+	archive := strings.Join([]string{
+		`{"time":"2026-10-15T09:00:00Z","address":123456,"function":3,"message":"FIRST","type":"alphanumeric","partial":false,"baud":1200}`,
+		`{"success":false,"error":"reading from rtl_tcp: EOF"}`,
+		``,
+		`{"time":"2026-10-15T09:00:30Z","address":8,"function":0,"message":"0123","type":"numeric","partial":false,"baud":512,"alias":"ops"}`,
+		`{"time":"2026-10-15T09:10:00Z","address":16,"function":3,"message":"THIRD","type":"alphanumeric","partial":false,"baud":1200}`,
+	}, "\n")
+	records, err := ReadArchive(strings.NewReader(archive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || !records[1].IsNumeric || records[1].Alias != "ops" || records[1].BaudRate != 512 {
+		t.Fatalf("read %+v", records)
+	}
+
+	// The same from a protobuf archive
+	var buf bytes.Buffer
+	for _, rec := range records {
+		WriteProtoDelimited(&buf, rec)
+	}
+	fromProto, err := ReadArchive(&buf)
+	if err != nil || len(fromProto) != 3 || !fromProto[2].Time.Equal(records[2].Time) {
+		t.Fatalf("proto archive: %+v, %v", fromProto, err)
+	}
+
+	// Ten times as fast, with the ten-minute lull cut to five seconds
+	pages := ReplayTimeline(records, 10, 5*time.Second)
+	if pages[0].Offset != 0 || pages[1].Offset != 3*time.Second || pages[2].Offset != 8*time.Second {
+		t.Errorf("offsets %v %v %v", pages[0].Offset, pages[1].Offset, pages[2].Offset)
+	}
+	if pages[1].PayloadType != PayloadTypeNumeric || pages[1].BaudRate != 512 {
+		t.Errorf("page %+v", pages[1])
+	}
+
+	wav := NewEncoder().EncodeTimeline(pages)
+	if d := WAVDuration(wav); d < 8*time.Second || d > 10*time.Second {
+		t.Errorf("duration %v", d)
+	}
+	decoded, err := DecodeFromAudioMultiRate(wav, DecodeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, msg := range decoded {
+		got = append(got, msg.Message)
+	}
+	if strings.Join(got, ",") != "FIRST,0123,THIRD" {
+		t.Errorf("decoded %q", got)
+	}
+}
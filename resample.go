@@ -0,0 +1,136 @@
+package pocsag
+
+import (
+	"fmt"
+	"math"
+)
+
+// MinSampleRate and MaxSampleRate bound the sample rates of the WAV files
+// the decoders read. Below twice the fastest baud rate a recording cannot
+// carry POCSAG, and resampling from a rate far outside them takes memory
+// in proportion to the ratio, so a header claiming 1 Hz would otherwise
+// exhaust it.
+const (
+	MinSampleRate = 2 * BaudRate2400
+	MaxSampleRate = 4000000
+)
+
+// ValidateSampleRate returns an error if a WAV file at rate is not to be
+// decoded or resampled.
+func ValidateSampleRate(rate int) error {
+	if rate < MinSampleRate || rate > MaxSampleRate {
+		return fmt.Errorf("sample rate %d Hz is outside the %d Hz to %d MHz supported", rate, MinSampleRate, MaxSampleRate/1000000)
+	}
+	return nil
+}
+
+// resampleTapsPerPhase is the number of FIR taps applied per output sample
+// when upsampling. 24 taps gives a steep enough low-pass for baseband
+// POCSAG without making large conversion ratios (e.g. 44.1k -> 48k, L=160)
+// expensive. Downsampling by M/L scales it by M/L, so that the filter
+// always spans 24 periods of its cutoff and still rejects what would alias.
+const resampleTapsPerPhase = 24
+
+// resampleMaxTable is the largest prototype filter Resample keeps in a
+// table. Ratios whose L and M are both large, such as 3999999 -> 48000,
+// would need a filter of hundreds of megabytes; for them each tap is
+// computed as it is used instead.
+const resampleMaxTable = 1 << 20
+
+// Resample converts 16-bit mono samples from one sample rate to another using
+// a polyphase windowed-sinc filter. The conversion ratio is reduced to L/M
+// (upsample by L, downsample by M) and only the filter phases that contribute
+// to an output sample are evaluated.
+//
+// The decoder uses it to normalize inputs to SampleRate before demodulation
+// and the encoder uses it to emit WAVs at arbitrary target rates (e.g. 8 kHz
+// for SIP gateways).
+func Resample(samples []int16, from, to int) []int16 {
+	if from <= 0 || to <= 0 || from == to || len(samples) == 0 {
+		out := make([]int16, len(samples))
+		copy(out, samples)
+		return out
+	}
+
+	g := gcd(from, to)
+	up := to / g     // L
+	down := from / g // M
+
+	// Prototype low-pass at the upsampled rate. The cutoff sits at the lower
+	// of the two Nyquist frequencies so downsampling does not alias.
+	taps := (resampleTapsPerPhase*max(up, down) + up - 1) / up
+	filterLen := up * taps
+	cutoff := 0.5 / float64(max(up, down))
+	center := float64(filterLen-1) / 2.0
+	tap := func(i int) float64 {
+		x := float64(i) - center
+		var sinc float64
+		if x != 0 {
+			sinc = math.Sin(2*math.Pi*cutoff*x) / (math.Pi * x)
+		} else {
+			sinc = 2 * cutoff
+		}
+		// Blackman window
+		w := 0.42 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(filterLen-1)) +
+			0.08*math.Cos(4*math.Pi*float64(i)/float64(filterLen-1))
+		return sinc * w * float64(up) // gain of L compensates for zero stuffing
+	}
+	var h []float64
+	if filterLen <= resampleMaxTable {
+		h = make([]float64, filterLen)
+		for i := range h {
+			h[i] = tap(i)
+		}
+	}
+
+	outLen := int((int64(len(samples))*int64(up) + int64(down) - 1) / int64(down))
+	out := make([]int16, outLen)
+
+	// Delay compensation so the output stays time-aligned with the input.
+	delay := (filterLen - 1) / 2
+
+	for n := 0; n < outLen; n++ {
+		// Position of this output sample on the upsampled time grid,
+		// shifted by the filter group delay.
+		pos := n*down + delay
+		phase := pos % up
+		base := pos / up
+
+		var acc float64
+		for k := 0; k < taps; k++ {
+			idx := base - k
+			if idx < 0 {
+				break
+			}
+			if idx >= len(samples) {
+				continue
+			}
+			if h != nil {
+				acc += float64(samples[idx]) * h[phase+k*up]
+			} else {
+				acc += float64(samples[idx]) * tap(phase+k*up)
+			}
+		}
+		out[n] = clampInt16(acc)
+	}
+
+	return out
+}
+
+func clampInt16(v float64) int16 {
+	v = math.Round(v)
+	if v > math.MaxInt16 {
+		return math.MaxInt16
+	}
+	if v < math.MinInt16 {
+		return math.MinInt16
+	}
+	return int16(v)
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
@@ -0,0 +1,76 @@
+package pocsag
+
+import (
+	"math"
+	"testing"
+)
+
+func TestResampleLength(t *testing.T) {
+	samples := make([]int16, 48000)
+	cases := []struct {
+		to   int
+		want int
+	}{
+		{8000, 8000},
+		{44100, 44100},
+		{48000, 48000},
+		{96000, 96000},
+	}
+	for _, c := range cases {
+		if got := len(Resample(samples, 48000, c.to)); got != c.want {
+			t.Errorf("Resample 48000->%d: got %d samples, want %d", c.to, got, c.want)
+		}
+	}
+}
+
+func TestDecodeResampledAudio(t *testing.T) {
+	for _, rate := range []int{8000, 22050, 44100, 96000} {
+		packet := CreatePOCSAGPacketWithBaudRate(123456, "RATE TEST", FuncAlphanumeric, BaudRate1200)
+		wavData := ConvertToAudioWithSampleRate(packet, BaudRate1200, rate)
+
+		_, gotRate := ParseWAVSamples(wavData)
+		if gotRate != rate {
+			t.Fatalf("WAV header sample rate: got %d, want %d", gotRate, rate)
+		}
+
+		decoded, err := DecodeFromAudioWithBaudRate(wavData, BaudRate1200)
+		if err != nil {
+			t.Fatalf("decode at %d Hz failed: %v", rate, err)
+		}
+		if len(decoded) != 1 || decoded[0].Message != "RATE TEST" {
+			t.Errorf("decode at %d Hz: got %v", rate, decoded)
+		}
+	}
+}
+
+// toneAmplitude returns the peak of samples away from the ends, where the
+// filter is still filling.
+func toneAmplitude(samples []int16) float64 {
+	var peak float64
+	for _, s := range samples[len(samples)/4 : 3*len(samples)/4] {
+		peak = math.Max(peak, math.Abs(float64(s)))
+	}
+	return peak
+}
+
+func tone(freq float64, rate, n int) []int16 {
+	samples := make([]int16, n)
+	for i := range samples {
+		samples[i] = int16(10000 * math.Sin(2*math.Pi*freq*float64(i)/float64(rate)))
+	}
+	return samples
+}
+
+func TestResampleFilter(t *testing.T) {
+	// A tone well inside the output band keeps its level, and one that would
+	// alias to 8 kHz is rejected, up to the highest rate accepted
+	for _, from := range []int{250000, 1000000, 2400000, MaxSampleRate, MaxSampleRate - 1} {
+		n := from / 20
+		if got := toneAmplitude(Resample(tone(1000, from, n), from, SampleRate)); math.Abs(got-10000) > 100 {
+			t.Errorf("%d Hz: 1 kHz tone came out at %.0f, want 10000", from, got)
+		}
+		if got := toneAmplitude(Resample(tone(40000, from, n), from, SampleRate)); got > 100 {
+			t.Errorf("%d Hz: 40 kHz tone aliased at %.0f, want under 100", from, got)
+		}
+	}
+}
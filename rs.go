@@ -0,0 +1,257 @@
+package pocsag
+
+import "fmt"
+
+// Reed-Solomon codes over GF(256) for FEC pages. Polynomials are byte
+// slices with the highest degree first, so a codeword reads as sent.
+
+const gfPrimitive = 0x11D // x^8 + x^4 + x^3 + x^2 + 1
+
+var gfExp, gfLog = gfTables()
+
+func gfTables() (exp [510]byte, log [256]int) {
+	x := 1
+	for i := 0; i < 255; i++ {
+		exp[i] = byte(x)
+		log[x] = i
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= gfPrimitive
+		}
+	}
+	for i := 255; i < len(exp); i++ {
+		exp[i] = exp[i-255]
+	}
+	return exp, log
+}
+
+func gfMul(x, y byte) byte {
+	if x == 0 || y == 0 {
+		return 0
+	}
+	return gfExp[gfLog[x]+gfLog[y]]
+}
+
+func gfDiv(x, y byte) byte {
+	if x == 0 {
+		return 0
+	}
+	return gfExp[gfLog[x]+255-gfLog[y]]
+}
+
+// gfPow2 returns α^n for any n, α being 2.
+func gfPow2(n int) byte {
+	return gfExp[(n%255+255)%255]
+}
+
+func polyMul(p, q []byte) []byte {
+	r := make([]byte, len(p)+len(q)-1)
+	for j, b := range q {
+		for i, a := range p {
+			r[i+j] ^= gfMul(a, b)
+		}
+	}
+	return r
+}
+
+func polyAdd(p, q []byte) []byte {
+	r := make([]byte, max(len(p), len(q)))
+	for i, a := range p {
+		r[i+len(r)-len(p)] = a
+	}
+	for i, b := range q {
+		r[i+len(r)-len(q)] ^= b
+	}
+	return r
+}
+
+func polyScale(p []byte, x byte) []byte {
+	r := make([]byte, len(p))
+	for i, a := range p {
+		r[i] = gfMul(a, x)
+	}
+	return r
+}
+
+func polyEval(p []byte, x byte) byte {
+	y := p[0]
+	for _, a := range p[1:] {
+		y = gfMul(y, x) ^ a
+	}
+	return y
+}
+
+// rsGenerator returns the generator polynomial with roots α^0..α^(nsym-1).
+func rsGenerator(nsym int) []byte {
+	g := []byte{1}
+	for i := 0; i < nsym; i++ {
+		g = polyMul(g, []byte{1, gfPow2(i)})
+	}
+	return g
+}
+
+// rsEncode returns data followed by nsym parity bytes.
+func rsEncode(data []byte, nsym int) []byte {
+	gen := rsGenerator(nsym)
+	out := make([]byte, len(data)+nsym)
+	copy(out, data)
+	for i := range data {
+		if coef := out[i]; coef != 0 {
+			for j := 1; j < len(gen); j++ {
+				out[i+j] ^= gfMul(gen[j], coef)
+			}
+		}
+	}
+	copy(out, data)
+	return out
+}
+
+// rsCorrect repairs a codeword with nsym parity bytes in which the bytes at
+// erasures are known to be lost and others may be wrong, and returns its
+// data. Each erasure uses up one parity byte and each unknown error two.
+func rsCorrect(msg []byte, nsym int, erasures []int) ([]byte, error) {
+	if len(erasures) > nsym {
+		return nil, fmt.Errorf("%d bytes lost, at most %d can be recovered", len(erasures), nsym)
+	}
+	out := append([]byte(nil), msg...)
+	for _, e := range erasures {
+		out[e] = 0
+	}
+	synd := rsSyndromes(out, nsym)
+	if isZero(synd) {
+		return out[:len(out)-nsym], nil
+	}
+
+	fsynd := rsForneySyndromes(synd, erasures, len(out))
+	errLoc, err := rsErrorLocator(fsynd, nsym, len(erasures))
+	if err != nil {
+		return nil, err
+	}
+	errPos, err := rsFindErrors(reversed(errLoc), len(out))
+	if err != nil {
+		return nil, err
+	}
+	out = rsCorrectErrata(out, synd, append(append([]int(nil), erasures...), errPos...))
+	if !isZero(rsSyndromes(out, nsym)) {
+		return nil, fmt.Errorf("too many errors to correct")
+	}
+	return out[:len(out)-nsym], nil
+}
+
+// rsSyndromes returns the codeword evaluated at each root of the generator,
+// after a leading zero.
+func rsSyndromes(msg []byte, nsym int) []byte {
+	synd := make([]byte, nsym+1)
+	for i := 0; i < nsym; i++ {
+		synd[i+1] = polyEval(msg, gfPow2(i))
+	}
+	return synd
+}
+
+// rsForneySyndromes removes the erasures' effect from the syndromes, so
+// the error locator search sees only unknown errors.
+func rsForneySyndromes(synd []byte, erasures []int, n int) []byte {
+	fsynd := append([]byte(nil), synd[1:]...)
+	for _, p := range erasures {
+		x := gfPow2(n - 1 - p)
+		for j := 0; j < len(fsynd)-1; j++ {
+			fsynd[j] = gfMul(fsynd[j], x) ^ fsynd[j+1]
+		}
+	}
+	return fsynd
+}
+
+// rsErrorLocator runs Berlekamp-Massey over the Forney syndromes.
+func rsErrorLocator(synd []byte, nsym, erasures int) ([]byte, error) {
+	errLoc, oldLoc := []byte{1}, []byte{1}
+	shift := max(len(synd)-nsym, 0)
+	for i := 0; i < nsym-erasures; i++ {
+		k := i + shift
+		delta := synd[k]
+		for j := 1; j < len(errLoc); j++ {
+			delta ^= gfMul(errLoc[len(errLoc)-1-j], synd[k-j])
+		}
+		oldLoc = append(oldLoc, 0)
+		if delta != 0 {
+			if len(oldLoc) > len(errLoc) {
+				newLoc := polyScale(oldLoc, delta)
+				oldLoc = polyScale(errLoc, gfDiv(1, delta))
+				errLoc = newLoc
+			}
+			errLoc = polyAdd(errLoc, polyScale(oldLoc, delta))
+		}
+	}
+	for len(errLoc) > 0 && errLoc[0] == 0 {
+		errLoc = errLoc[1:]
+	}
+	if errs := len(errLoc) - 1; errs*2+erasures > nsym {
+		return nil, fmt.Errorf("too many errors to correct")
+	}
+	return errLoc, nil
+}
+
+// rsFindErrors returns the positions the error locator's roots point at.
+func rsFindErrors(errLoc []byte, n int) ([]int, error) {
+	var pos []int
+	for i := 0; i < n; i++ {
+		if polyEval(errLoc, gfPow2(i)) == 0 {
+			pos = append(pos, n-1-i)
+		}
+	}
+	if len(pos) != len(errLoc)-1 {
+		return nil, fmt.Errorf("too many errors to correct")
+	}
+	return pos, nil
+}
+
+// rsCorrectErrata computes the value of each known bad byte with Forney's
+// algorithm and fixes it.
+func rsCorrectErrata(msg, synd []byte, pos []int) []byte {
+	coefPos := make([]int, len(pos))
+	for i, p := range pos {
+		coefPos[i] = len(msg) - 1 - p
+	}
+	loc := []byte{1}
+	for _, c := range coefPos {
+		loc = polyMul(loc, polyAdd([]byte{1}, []byte{gfPow2(c), 0}))
+	}
+
+	// The error evaluator is synd × loc mod x^(len(loc))
+	product := polyMul(reversed(synd), loc)
+	eval := reversed(product[len(product)-len(loc):])
+
+	x := make([]byte, len(coefPos))
+	for i, c := range coefPos {
+		x[i] = gfPow2(c)
+	}
+	errs := make([]byte, len(msg))
+	for i, xi := range x {
+		xiInv := gfDiv(1, xi)
+		var prime byte = 1
+		for j, xj := range x {
+			if j != i {
+				prime = gfMul(prime, 1^gfMul(xiInv, xj))
+			}
+		}
+		y := gfMul(xi, polyEval(reversed(eval), xiInv))
+		errs[pos[i]] = gfDiv(y, prime)
+	}
+	return polyAdd(msg, errs)
+}
+
+func reversed(p []byte) []byte {
+	r := make([]byte, len(p))
+	for i, a := range p {
+		r[len(p)-1-i] = a
+	}
+	return r
+}
+
+func isZero(p []byte) bool {
+	for _, a := range p {
+		if a != 0 {
+			return false
+		}
+	}
+	return true
+}
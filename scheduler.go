@@ -0,0 +1,522 @@
+package pocsag
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// TransmitFunc receives each burst produced by the Scheduler: the encoded
+// POCSAG packet and the messages it contains.
+type TransmitFunc func(packet []byte, messages []MessageInfo) error
+
+// SchedulerConfig holds Scheduler settings.
+type SchedulerConfig struct {
+	BaudRate int          // baud rate for generated bursts (default 1200)
+	Transmit TransmitFunc // called with every burst (required)
+
+	// Controller, if set, guards every burst with listen-before-talk and
+	// PTT hooks as described by GuardTransmit.
+	Controller    TransmitController
+	ChannelAccess ChannelAccess
+
+	// DutyCycle, if set, limits how much and how often the Scheduler
+	// transmits.
+	DutyCycle DutyCycle
+
+	// Queue, if set, keeps one-off pages on disk until they are sent, and
+	// the Scheduler starts with the pages left in it. Recurring pages are
+	// not kept; they fire again on their schedule.
+	Queue *QueueLog
+
+	// OnError, if set, is called by Run with the error of each recurring
+	// page whose Data callback or template fails. The page is skipped for
+	// that firing and Run carries on; without OnError the error is dropped.
+	OnError func(error)
+}
+
+// DutyCycle limits a Scheduler's airtime, as unlicensed bands and shared
+// repeaters require. Pages that would break a limit wait in the queue, in
+// order, until they can go; a burst is cut short, with the rest following
+// later, when only some of its pages fit. Airtime is counted from the time
+// a burst is scheduled, preamble included.
+type DutyCycle struct {
+	// MaxFraction is the share of any Window the transmitter may be keyed,
+	// e.g. 0.1 for 10%. Zero means no limit.
+	MaxFraction float64
+	// Window is the rolling period MaxFraction applies to (default 1h).
+	Window time.Duration
+	// MinGap is the least time from the end of one burst to the start of
+	// the next.
+	MinGap time.Duration
+}
+
+func (dc DutyCycle) window() time.Duration {
+	if dc.Window <= 0 {
+		return time.Hour
+	}
+	return dc.Window
+}
+
+// limit returns the airtime allowed in a window.
+func (dc DutyCycle) limit() time.Duration {
+	if dc.MaxFraction <= 0 {
+		return math.MaxInt64
+	}
+	return time.Duration(dc.MaxFraction * float64(dc.window()))
+}
+
+// SchedulerStats reports a Scheduler's use of the channel.
+type SchedulerStats struct {
+	Window      time.Duration // the DutyCycle window the figures cover
+	Airtime     time.Duration // transmitted in the window up to now
+	Utilization float64       // Airtime as a share of Window
+	Pending     int           // one-off pages waiting to go, e.g. for airtime
+	Deferred    int           // bursts held back or cut short by the DutyCycle so far
+	NextAllowed time.Time     // when waiting pages may go, zero if none wait
+}
+
+// RecurringPage is a page sent on a Schedule. Its body is a Go text/template
+// rendered with TemplateData each time it fires, e.g.
+// "TIME {{.Time.Format \"15:04\"}} #{{.Count}}".
+type RecurringPage struct {
+	Schedule    Schedule
+	Address     uint32
+	Function    uint8
+	PayloadType string
+	Template    string
+	// Data, if set, is called at fire time and its result exposed to the
+	// template as .Data, for bodies that pull in external values.
+	Data func(now time.Time) (interface{}, error)
+}
+
+// TemplateData is the value RecurringPage templates are executed with.
+type TemplateData struct {
+	Time  time.Time   // fire time
+	Count int         // how many times this page has fired, starting at 1
+	Data  interface{} // result of RecurringPage.Data, if any
+}
+
+type recurringEntry struct {
+	page  RecurringPage
+	tmpl  *template.Template
+	next  time.Time
+	count int
+}
+
+// Scheduler batches one-off and recurring pages into bursts and hands them
+// to a TransmitFunc. Pages due at the same moment go out in a single burst.
+type Scheduler struct {
+	config SchedulerConfig
+
+	mu        sync.Mutex
+	queue     []PendingPage
+	inflight  []PendingPage // handed to Transmit
+	recurring []*recurringEntry
+	wake      chan struct{}
+	logErr    error // from writing the QueueLog, for Tick to return
+
+	bursts   []burstTime // within the last DutyCycle window
+	retryAt  time.Time   // when the waiting pages may go
+	deferred int
+}
+
+// burstTime is when a burst was on the air.
+type burstTime struct {
+	start, end time.Time
+}
+
+// NewScheduler creates a Scheduler. Call Run to start dispatching.
+func NewScheduler(config SchedulerConfig) *Scheduler {
+	if config.BaudRate == 0 {
+		config.BaudRate = BaudRate1200
+	}
+	if config.Controller != nil {
+		config.Transmit = GuardTransmit(config.Controller, config.ChannelAccess, config.Transmit)
+	}
+	s := &Scheduler{
+		config: config,
+		wake:   make(chan struct{}, 1),
+	}
+	if config.Queue != nil {
+		s.queue = append(s.queue, config.Queue.pending...)
+	}
+	return s
+}
+
+// Enqueue adds a one-off page to go out with the next burst. With a
+// QueueLog, an error writing the page to it is returned by the next Tick.
+func (s *Scheduler) Enqueue(msg MessageInfo) {
+	s.add(newPageID(), msg)
+}
+
+// EnqueueOnce adds a one-off page under id unless a page with that id is
+// still pending, so a client that submits again after a timeout or a
+// restart does not page twice. It reports whether the page was added.
+func (s *Scheduler) EnqueueOnce(id string, msg MessageInfo) bool {
+	return s.add(id, msg)
+}
+
+func (s *Scheduler) add(id string, msg MessageInfo) bool {
+	s.mu.Lock()
+	for _, p := range append(s.inflight[:len(s.inflight):len(s.inflight)], s.queue...) {
+		if p.ID == id {
+			s.mu.Unlock()
+			return false
+		}
+	}
+	p := PendingPage{ID: id, Queued: time.Now(), MessageInfo: msg}
+	s.queue = append(s.queue, p)
+	s.logLocked(addRecord(p))
+	s.mu.Unlock()
+	s.signal()
+	return true
+}
+
+// Pending returns the one-off pages waiting to be sent, in order.
+func (s *Scheduler) Pending() []PendingPage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]PendingPage(nil), s.queue...)
+}
+
+// Cancel removes the pending page with id from the queue. It reports
+// false if there is none, as when the page is already being sent.
+func (s *Scheduler) Cancel(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, p := range s.queue {
+		if p.ID == id {
+			s.queue = append(s.queue[:i:i], s.queue[i+1:]...)
+			s.logLocked(queueRecord{Op: "cancel", ID: id})
+			s.retryAt = s.nextAllowed(time.Now())
+			return true
+		}
+	}
+	return false
+}
+
+// logLocked writes recs to the QueueLog, if there is one, and keeps the
+// first error for Tick to return.
+func (s *Scheduler) logLocked(recs ...queueRecord) {
+	if s.config.Queue == nil || len(recs) == 0 {
+		return
+	}
+	pending := append(s.inflight[:len(s.inflight):len(s.inflight)], s.queue...)
+	if err := s.config.Queue.append(pending, recs...); err != nil && s.logErr == nil {
+		s.logErr = err
+	}
+}
+
+// AddRecurring registers a recurring page. The template is parsed up front
+// so syntax errors are reported here rather than at fire time.
+func (s *Scheduler) AddRecurring(page RecurringPage) error {
+	if page.Schedule == nil {
+		return fmt.Errorf("recurring page for address %d has no schedule", page.Address)
+	}
+	tmpl, err := template.New(fmt.Sprintf("page-%d", page.Address)).Parse(page.Template)
+	if err != nil {
+		return fmt.Errorf("invalid template for address %d: %v", page.Address, err)
+	}
+
+	s.mu.Lock()
+	s.recurring = append(s.recurring, &recurringEntry{
+		page: page,
+		tmpl: tmpl,
+		next: page.Schedule.Next(time.Now()),
+	})
+	s.mu.Unlock()
+	s.signal()
+	return nil
+}
+
+func (s *Scheduler) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run dispatches pages until ctx is cancelled. Transmit errors are
+// returned and stop the scheduler; a recurring page that fails to render
+// is passed to SchedulerConfig.OnError and skipped.
+func (s *Scheduler) Run(ctx context.Context) error {
+	for {
+		renderErr, err := s.tick(time.Now())
+		if renderErr != nil && s.config.OnError != nil {
+			s.config.OnError(renderErr)
+		}
+		if err != nil {
+			return err
+		}
+
+		var timer *time.Timer
+		var fire <-chan time.Time
+		if next, ok := s.nextFire(); ok {
+			timer = time.NewTimer(time.Until(next))
+			fire = timer.C
+		}
+
+		select {
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			return ctx.Err()
+		case <-s.wake:
+		case <-fire:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+func (s *Scheduler) nextFire() (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var next time.Time
+	if len(s.queue) > 0 {
+		next = s.retryAt
+	}
+	for _, e := range s.recurring {
+		if !e.next.IsZero() && (next.IsZero() || e.next.Before(next)) {
+			next = e.next
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// Tick renders every recurring page due at or before now, drains the
+// one-off queue, and transmits them together as one burst. Pages whose
+// template fails are skipped and the error is returned after the others
+// have been sent. Pages the DutyCycle holds back stay queued for a later
+// Tick, as do the pages of a burst Transmit fails. Tick is what Run calls
+// on each wake-up and can be driven directly with a fake clock.
+func (s *Scheduler) Tick(now time.Time) error {
+	renderErr, err := s.tick(now)
+	return errors.Join(err, renderErr)
+}
+
+// firing is a recurring page that is due, taken under the lock so that it
+// can be rendered without it.
+type firing struct {
+	entry *recurringEntry
+	at    time.Time
+	count int
+}
+
+// tick does the work of Tick, returning the errors of recurring pages that
+// failed to render apart from the error that stops Run.
+func (s *Scheduler) tick(now time.Time) (renderErr, err error) {
+	s.mu.Lock()
+	if err := s.logErr; err != nil {
+		s.logErr = nil
+		s.mu.Unlock()
+		return nil, err
+	}
+
+	var due []firing
+	for _, e := range s.recurring {
+		if e.next.IsZero() || e.next.After(now) {
+			continue
+		}
+		e.count++
+		due = append(due, firing{e, e.next, e.count})
+		e.next = e.page.Schedule.Next(now)
+	}
+	s.mu.Unlock()
+
+	// Data callbacks may be slow or call back into the Scheduler, so pages
+	// are rendered with the lock released. The one-off queue stays where
+	// Enqueue, EnqueueOnce, and Cancel see it until admit takes it.
+	var rendered []PendingPage
+	var renderErrs []error
+	for _, f := range due {
+		msg, err := f.entry.render(f.at, f.count)
+		if err != nil {
+			renderErrs = append(renderErrs, err)
+			continue
+		}
+		rendered = append(rendered, PendingPage{ID: newPageID(), Queued: f.at, MessageInfo: msg})
+	}
+	renderErr = errors.Join(renderErrs...)
+
+	packet, sent, err := s.admit(now, rendered)
+	if err != nil || len(sent) == 0 {
+		return renderErr, err
+	}
+	messages := make([]MessageInfo, len(sent))
+	for i, p := range sent {
+		messages[i] = p.MessageInfo
+	}
+	err = s.config.Transmit(packet, messages)
+	s.settle(sent, err == nil)
+	return renderErr, err
+}
+
+// settle ends the flight of sent: pages that went out are logged as sent,
+// and pages that did not go back to the front of the queue.
+func (s *Scheduler) settle(sent []PendingPage, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inflight = nil
+	if !ok {
+		s.queue = append(sent, s.queue...)
+		return
+	}
+	recs := make([]queueRecord, len(sent))
+	for i, p := range sent {
+		recs[i] = queueRecord{Op: "sent", ID: p.ID}
+	}
+	s.logLocked(recs...)
+}
+
+// admit takes the one-off queue and the rendered recurring pages, encodes
+// the longest run of them the DutyCycle lets go out at now, records its
+// airtime, and puts the rest back at the front of the queue. Taking the
+// queue and marking the run in flight happen under one lock, so a page is
+// always where add and Cancel look for it. A page too long to ever fit is
+// dropped with an error.
+func (s *Scheduler) admit(now time.Time, rendered []PendingPage) ([]byte, []PendingPage, error) {
+	dc := s.config.DutyCycle
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pages := append(s.queue[:len(s.queue):len(s.queue)], rendered...)
+	s.queue = nil
+	if len(pages) == 0 {
+		return nil, nil, nil
+	}
+	messages := make([]MessageInfo, len(pages))
+	for i, p := range pages {
+		messages[i] = p.MessageInfo
+	}
+	s.prune(now)
+
+	budget := dc.limit() - s.airtime(now)
+	if n := len(s.bursts); n > 0 && now.Before(s.bursts[n-1].end.Add(dc.MinGap)) {
+		budget = 0
+	}
+	packet, rest, err := EncodeWithBudget(messages, s.config.BaudRate, budget)
+	if err != nil && EstimateAirtime(messages[0], s.config.BaudRate) > dc.limit() {
+		s.queue = append(pages[1:], s.queue...)
+		s.logLocked(queueRecord{Op: "cancel", ID: pages[0].ID})
+		s.retryAt = s.nextAllowed(now)
+		return nil, nil, fmt.Errorf("page for address %d needs more airtime than the duty cycle allows in %v", messages[0].Address, dc.window())
+	}
+	sent := pages[:len(pages)-len(rest)]
+	if len(rest) > 0 {
+		s.deferred++
+		s.queue = append(pages[len(sent):], s.queue...)
+	}
+	s.inflight = sent
+	if len(sent) > 0 {
+		airtime := bitsDuration(len(packet)*8, s.config.BaudRate)
+		s.bursts = append(s.bursts, burstTime{now, now.Add(airtime)})
+	}
+	s.retryAt = s.nextAllowed(now)
+	return packet, sent, nil
+}
+
+// prune forgets bursts that ended before the DutyCycle window.
+func (s *Scheduler) prune(now time.Time) {
+	from := now.Add(-s.config.DutyCycle.window())
+	for len(s.bursts) > 0 && !s.bursts[0].end.After(from) {
+		s.bursts = s.bursts[1:]
+	}
+}
+
+// airtime returns the time on air in the DutyCycle window ending at t.
+func (s *Scheduler) airtime(t time.Time) time.Duration {
+	from := t.Add(-s.config.DutyCycle.window())
+	var total time.Duration
+	for _, b := range s.bursts {
+		start, end := b.start, b.end
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(t) {
+			end = t
+		}
+		if end.After(start) {
+			total += end.Sub(start)
+		}
+	}
+	return total
+}
+
+// nextAllowed returns when the first queued page may go, or zero if none
+// is queued. Airtime only frees up as bursts leave the window, so it is
+// enough to try the moments the gap ends and each burst has left.
+func (s *Scheduler) nextAllowed(now time.Time) time.Time {
+	if len(s.queue) == 0 {
+		return time.Time{}
+	}
+	dc := s.config.DutyCycle
+	need := EstimateAirtime(s.queue[0].MessageInfo, s.config.BaudRate)
+	earliest := now
+	if n := len(s.bursts); n > 0 && s.bursts[n-1].end.Add(dc.MinGap).After(earliest) {
+		earliest = s.bursts[n-1].end.Add(dc.MinGap)
+	}
+	candidates := []time.Time{earliest}
+	for _, b := range s.bursts {
+		if t := b.end.Add(dc.window()); t.After(earliest) {
+			candidates = append(candidates, t)
+		}
+	}
+	for _, t := range candidates {
+		if s.airtime(t)+need <= dc.limit() {
+			return t
+		}
+	}
+	return candidates[len(candidates)-1]
+}
+
+// Stats reports the airtime used in the DutyCycle window up to now and
+// the pages waiting for more.
+func (s *Scheduler) Stats(now time.Time) SchedulerStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	window := s.config.DutyCycle.window()
+	airtime := s.airtime(now)
+	stats := SchedulerStats{
+		Window:      window,
+		Airtime:     airtime,
+		Utilization: float64(airtime) / float64(window),
+		Pending:     len(s.queue),
+		Deferred:    s.deferred,
+	}
+	if len(s.queue) > 0 {
+		stats.NextAllowed = s.retryAt
+	}
+	return stats
+}
+
+func (e *recurringEntry) render(fireTime time.Time, count int) (MessageInfo, error) {
+	data := TemplateData{Time: fireTime, Count: count}
+	if e.page.Data != nil {
+		d, err := e.page.Data(fireTime)
+		if err != nil {
+			return MessageInfo{}, fmt.Errorf("data callback for address %d failed: %v", e.page.Address, err)
+		}
+		data.Data = d
+	}
+
+	var buf bytes.Buffer
+	if err := e.tmpl.Execute(&buf, data); err != nil {
+		return MessageInfo{}, fmt.Errorf("template for address %d failed: %v", e.page.Address, err)
+	}
+
+	return MessageInfo{
+		Address:     e.page.Address,
+		Message:     buf.String(),
+		Function:    e.page.Function,
+		PayloadType: e.page.PayloadType,
+	}, nil
+}
@@ -0,0 +1,337 @@
+package pocsag
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	base := time.Date(2026, 3, 14, 10, 7, 30, 0, time.UTC)
+	cases := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2026, 3, 14, 10, 8, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 3, 14, 10, 15, 0, 0, time.UTC)},
+		{"0 9-17 * * *", time.Date(2026, 3, 14, 11, 0, 0, 0, time.UTC)},
+		{"30 6 * * 1", time.Date(2026, 3, 16, 6, 30, 0, 0, time.UTC)},
+		{"0 0 1 1 *", time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		sched, err := ParseCron(c.expr)
+		if err != nil {
+			t.Fatalf("ParseCron(%q): %v", c.expr, err)
+		}
+		if got := sched.Next(base); !got.Equal(c.want) {
+			t.Errorf("%q: Next = %v, want %v", c.expr, got, c.want)
+		}
+	}
+
+	if _, err := ParseCron("61 * * * *"); err == nil {
+		t.Error("ParseCron accepted minute 61")
+	}
+}
+
+func TestSchedulerRecurringTemplate(t *testing.T) {
+	var sent []MessageInfo
+	s := NewScheduler(SchedulerConfig{
+		Transmit: func(packet []byte, messages []MessageInfo) error {
+			sent = append(sent, messages...)
+			return nil
+		},
+	})
+
+	err := s.AddRecurring(RecurringPage{
+		Schedule:    Every(time.Minute),
+		Address:     8,
+		Function:    FuncAlphanumeric,
+		PayloadType: PayloadTypeAlpha,
+		Template:    `CLOCK {{.Time.Format "15:04"}} #{{.Count}} {{.Data}}`,
+		Data:        func(time.Time) (interface{}, error) { return "OK", nil },
+	})
+	if err != nil {
+		t.Fatalf("AddRecurring: %v", err)
+	}
+	s.Enqueue(MessageInfo{Address: 16, Message: "ONE OFF", Function: FuncAlphanumeric})
+
+	now := time.Now().Add(2 * time.Minute)
+	if err := s.Tick(now); err != nil {
+		t.Fatalf("Tick: %v", err)
+	}
+	if len(sent) != 2 {
+		t.Fatalf("got %d messages, want 2: %v", len(sent), sent)
+	}
+	if sent[0].Message != "ONE OFF" {
+		t.Errorf("one-off message: got %q", sent[0].Message)
+	}
+	fire := Every(time.Minute).Next(now.Add(-2 * time.Minute))
+	want := "CLOCK " + fire.Format("15:04") + " #1 OK"
+	if sent[1].Message != want {
+		t.Errorf("recurring message: got %q, want %q", sent[1].Message, want)
+	}
+
+	// Nothing new is due at the same instant.
+	if err := s.Tick(now); err != nil || len(sent) != 2 {
+		t.Errorf("second Tick sent %d messages (err %v), want none", len(sent)-2, err)
+	}
+}
+
+func TestSchedulerEnqueueOnceDuringTick(t *testing.T) {
+	var sent []MessageInfo
+	s := NewScheduler(SchedulerConfig{
+		Transmit: func(packet []byte, messages []MessageInfo) error {
+			sent = append(sent, messages...)
+			return nil
+		},
+	})
+	s.EnqueueOnce("dup", MessageInfo{Address: 16, Message: "ONCE", Function: FuncAlphanumeric})
+	s.EnqueueOnce("gone", MessageInfo{Address: 24, Message: "CANCELLED", Function: FuncAlphanumeric})
+
+	// The recurring page renders while the queue is pending: a resubmitted
+	// page must still be seen as queued, and a cancelled one must go
+	var added, cancelled bool
+	err := s.AddRecurring(RecurringPage{
+		Schedule: Every(time.Minute),
+		Address:  8,
+		Function: FuncAlphanumeric,
+		Template: "TICK",
+		Data: func(time.Time) (interface{}, error) {
+			added = s.EnqueueOnce("dup", MessageInfo{Address: 16, Message: "ONCE", Function: FuncAlphanumeric})
+			cancelled = s.Cancel("gone")
+			return nil, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("AddRecurring: %v", err)
+	}
+	if err := s.Tick(time.Now().Add(2 * time.Minute)); err != nil {
+		t.Fatalf("Tick: %v", err)
+	}
+	if added {
+		t.Error("EnqueueOnce added a page already pending")
+	}
+	if !cancelled {
+		t.Error("Cancel did not find a pending page")
+	}
+	var got []string
+	for _, msg := range sent {
+		got = append(got, msg.Message)
+	}
+	if strings.Join(got, ",") != "ONCE,TICK" {
+		t.Errorf("sent %v, want [ONCE TICK]", got)
+	}
+}
+
+func TestSchedulerRecurringDataError(t *testing.T) {
+	sent := make(chan MessageInfo, 16)
+	errs := make(chan error, 16)
+	var s *Scheduler
+	s = NewScheduler(SchedulerConfig{
+		Transmit: func(packet []byte, messages []MessageInfo) error {
+			for _, msg := range messages {
+				sent <- msg
+			}
+			return nil
+		},
+		OnError: func(err error) { errs <- err },
+	})
+	calls := 0
+	err := s.AddRecurring(RecurringPage{
+		Schedule: Every(10 * time.Millisecond),
+		Address:  8,
+		Function: FuncAlphanumeric,
+		Template: "#{{.Count}} {{.Data}}",
+		Data: func(time.Time) (interface{}, error) {
+			if calls++; calls == 1 {
+				return nil, fmt.Errorf("feed down")
+			}
+			// Calling back into the Scheduler must not deadlock
+			return len(s.Pending()), nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("AddRecurring: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx) }()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	select {
+	case err := <-errs:
+		if !strings.Contains(err.Error(), "feed down") {
+			t.Errorf("OnError got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the failed page was not reported")
+	}
+	select {
+	case msg := <-sent:
+		if msg.Message != "#2 0" {
+			t.Errorf("got %q, want %q", msg.Message, "#2 0")
+		}
+	case err := <-done:
+		t.Fatalf("Run stopped: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no page after the failed one")
+	}
+}
+
+func TestSchedulerListenBeforeTalk(t *testing.T) {
+	var events []string
+	busyPolls := 3
+	s := NewScheduler(SchedulerConfig{
+		Transmit: func(packet []byte, messages []MessageInfo) error {
+			events = append(events, "transmit")
+			return nil
+		},
+		Controller: TransmitHooks{
+			Busy: func() (bool, error) {
+				busyPolls--
+				return busyPolls >= 0, nil
+			},
+			Before: func([]byte) error { events = append(events, "before"); return nil },
+			After:  func(_ []byte, err error) { events = append(events, fmt.Sprintf("after %v", err)) },
+		},
+		ChannelAccess: ChannelAccess{PollInterval: time.Millisecond, Holdoff: 2 * time.Millisecond},
+	})
+
+	s.Enqueue(MessageInfo{Address: 16, Message: "LBT", Function: FuncAlphanumeric})
+	if err := s.Tick(time.Now()); err != nil {
+		t.Fatalf("Tick: %v", err)
+	}
+	if got := strings.Join(events, ","); got != "before,transmit,after <nil>" {
+		t.Errorf("events = %s", got)
+	}
+	if busyPolls >= 0 {
+		t.Errorf("transmitted while the channel was busy (%d busy polls left)", busyPolls)
+	}
+
+	// A channel that never clears gives up without keying
+	events = nil
+	guarded := GuardTransmit(TransmitHooks{Busy: func() (bool, error) { return true, nil }},
+		ChannelAccess{PollInterval: time.Millisecond, MaxWait: 5 * time.Millisecond},
+		func([]byte, []MessageInfo) error { events = append(events, "transmit"); return nil })
+	if err := guarded(nil, nil); err != ErrChannelBusy || len(events) != 0 {
+		t.Errorf("busy channel: err %v, events %v", err, events)
+	}
+}
+
+func TestSchedulerDutyCycle(t *testing.T) {
+	var sent []string
+	s := NewScheduler(SchedulerConfig{
+		Transmit: func(packet []byte, messages []MessageInfo) error {
+			for _, m := range messages {
+				sent = append(sent, m.Message)
+			}
+			return nil
+		},
+		// One second of airtime in ten, two seconds apart
+		DutyCycle: DutyCycle{MaxFraction: 0.1, Window: 10 * time.Second, MinGap: 2 * time.Second},
+	})
+	// A one-batch burst at 1200 baud: 576 preamble bits and 17 words
+	burst := bitsDuration(PreambleLength+17*CodewordBits, BaudRate1200)
+
+	t0 := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	s.Enqueue(MessageInfo{Address: 8, Message: "A", Function: FuncAlphanumeric})
+	if err := s.Tick(t0); err != nil || len(sent) != 1 {
+		t.Fatalf("first page: sent %v, err %v", sent, err)
+	}
+	if st := s.Stats(t0.Add(time.Second)); st.Airtime != burst || st.Utilization != float64(burst)/float64(10*time.Second) {
+		t.Errorf("stats after one burst: %+v", st)
+	}
+
+	// The gap ends first, but a second burst would go over a second
+	s.Enqueue(MessageInfo{Address: 16, Message: "B", Function: FuncAlphanumeric})
+	if err := s.Tick(t0.Add(5 * time.Second)); err != nil || len(sent) != 1 {
+		t.Fatalf("over the limit: sent %v, err %v", sent, err)
+	}
+	st := s.Stats(t0.Add(5 * time.Second))
+	if want := t0.Add(burst + 10*time.Second); st.Pending != 1 || st.Deferred != 1 || !st.NextAllowed.Equal(want) {
+		t.Errorf("held back: %+v, want next allowed at %v", st, want)
+	}
+	if next, ok := s.nextFire(); !ok || !next.Equal(st.NextAllowed) {
+		t.Errorf("Run would wake at %v", next)
+	}
+	if err := s.Tick(st.NextAllowed); err != nil || len(sent) != 2 || sent[1] != "B" {
+		t.Errorf("when allowed: sent %v, err %v", sent, err)
+	}
+
+	// A page longer than the whole allowance is dropped
+	s.Enqueue(MessageInfo{Address: 24, Message: strings.Repeat("X", 400), Function: FuncAlphanumeric})
+	if err := s.Tick(t0.Add(time.Hour)); err == nil || s.Stats(t0.Add(time.Hour)).Pending != 0 {
+		t.Errorf("overlong page: err %v", err)
+	}
+}
+
+func TestSchedulerQueueLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.log")
+	log, err := OpenQueueLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fail := true
+	var sent []string
+	config := SchedulerConfig{
+		Transmit: func(packet []byte, messages []MessageInfo) error {
+			if fail {
+				return fmt.Errorf("transmitter off")
+			}
+			for _, m := range messages {
+				sent = append(sent, m.Message)
+			}
+			return nil
+		},
+		Queue: log,
+	}
+	s := NewScheduler(config)
+	s.Enqueue(MessageInfo{Address: 8, Message: "A", Function: FuncAlphanumeric})
+	if !s.EnqueueOnce("b", MessageInfo{Address: 16, Message: "B", Function: FuncAlphanumeric}) ||
+		s.EnqueueOnce("b", MessageInfo{Address: 16, Message: "B again", Function: FuncAlphanumeric}) {
+		t.Error("EnqueueOnce did not drop the repeat")
+	}
+	s.EnqueueOnce("c", MessageInfo{Address: 24, Message: "C", Function: FuncAlphanumeric})
+	if !s.Cancel("c") || s.Cancel("c") {
+		t.Error("Cancel")
+	}
+	// A failed burst stays queued
+	if err := s.Tick(time.Now()); err == nil || len(s.Pending()) != 2 {
+		t.Fatalf("failed burst: err %v, pending %v", err, s.Pending())
+	}
+	log.Close()
+
+	// After a restart, with a write cut short at the end of the log
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	f.WriteString(`{"op":"add","id":"d","addr`)
+	f.Close()
+	if config.Queue, err = OpenQueueLog(path); err != nil {
+		t.Fatal(err)
+	}
+	s = NewScheduler(config)
+	pending := s.Pending()
+	if len(pending) != 2 || pending[0].Message != "A" || pending[1].ID != "b" || pending[1].Queued.IsZero() {
+		t.Fatalf("recovered %+v", pending)
+	}
+	if s.EnqueueOnce("b", MessageInfo{Address: 16, Message: "B", Function: FuncAlphanumeric}) {
+		t.Error("requeued after the restart")
+	}
+	fail = false
+	if err := s.Tick(time.Now()); err != nil || strings.Join(sent, ",") != "A,B" {
+		t.Fatalf("sent %v, err %v", sent, err)
+	}
+	config.Queue.Close()
+
+	if log, err = OpenQueueLog(path); err != nil || len(log.pending) != 0 {
+		t.Errorf("sent pages still pending: %v, err %v", log.pending, err)
+	}
+	log.Close()
+}
@@ -0,0 +1,392 @@
+package pocsag
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Names of the embedded JSON Schemas, for Schema and ValidateJSON.
+const (
+	SchemaBurstInput     = "burst-input"     // pocsag-burst JSON input file
+	SchemaEncodeOutput   = "encode-output"   // pocsag --json
+	SchemaBurstOutput    = "burst-output"    // pocsag-burst --json-output
+	SchemaDecodeOutput   = "decode-output"   // pocsag-decode --json
+	SchemaMonitorMessage = "monitor-message" // each pocsag-rx --json line
+	SchemaHackRFOutput   = "hackrf-output"   // pocsag-hackrf --json
+	SchemaReplayOutput   = "replay-output"   // pocsag-replay --json
+	SchemaDiffOutput     = "diff-output"     // pocsag-diff --json
+	SchemaSelfTestOutput = "selftest-output" // pocsag selftest --json
+	SchemaDescribe       = "describe"        // --describe
+)
+
+//go:embed schemas/*.schema.json
+var schemaFiles embed.FS
+
+// SchemaNames lists the embedded JSON Schemas.
+func SchemaNames() []string {
+	entries, _ := schemaFiles.ReadDir("schemas")
+	names := make([]string, len(entries))
+	for i, e := range entries {
+		names[i] = strings.TrimSuffix(e.Name(), ".schema.json")
+	}
+	return names
+}
+
+// Schema returns the JSON Schema document with the given name, which
+// describes the layout of one CLI input or output.
+func Schema(name string) ([]byte, error) {
+	data, err := schemaFiles.ReadFile(path.Join("schemas", name+".schema.json"))
+	if err != nil {
+		return nil, fmt.Errorf("unknown schema %q", name)
+	}
+	return data, nil
+}
+
+// ValidationError reports where a JSON document breaks its schema.
+type ValidationError struct {
+	Line  int    // 1-based line of the offending value
+	Field string // path to the value, e.g. "[2].function"; empty for the top level
+	Msg   string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+	}
+	return fmt.Sprintf("line %d: %s: %s", e.Line, e.Field, e.Msg)
+}
+
+// ValidateBurstJSON checks a burst input file against the burst-input
+// schema. The error for malformed input is a *ValidationError naming the
+// line and field, e.g. "line 4: [1].function: 7 is above the maximum 3".
+func ValidateBurstJSON(data []byte) error {
+	return ValidateJSON(SchemaBurstInput, data)
+}
+
+// ValidateJSON checks data against the named embedded schema and returns
+// the first problem as a *ValidationError. Only the parts of JSON Schema
+// the embedded schemas use are supported: type, properties, required,
+// additionalProperties, items, enum, minimum, maximum, minLength,
+// maxLength, and minItems.
+func ValidateJSON(name string, data []byte) error {
+	doc, err := Schema(name)
+	if err != nil {
+		return err
+	}
+	var s jsonSchema
+	if err := json.Unmarshal(doc, &s); err != nil {
+		return fmt.Errorf("invalid schema %s: %v", name, err)
+	}
+
+	p := &jsonParser{data: data, dec: json.NewDecoder(bytes.NewReader(data))}
+	p.dec.UseNumber()
+	root, err := p.parse()
+	if err != nil {
+		return err
+	}
+	if _, err := p.dec.Token(); err != io.EOF {
+		return &ValidationError{Line: p.line(p.dec.InputOffset()), Msg: "unexpected data after the top-level value"}
+	}
+	return s.validate(root, "")
+}
+
+// jsonSchema is the subset of JSON Schema that ValidateJSON understands.
+type jsonSchema struct {
+	Type                 schemaTypes            `json:"type"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	Required             []string               `json:"required"`
+	AdditionalProperties *bool                  `json:"additionalProperties"`
+	Items                *jsonSchema            `json:"items"`
+	Enum                 []interface{}          `json:"enum"`
+	Minimum              *float64               `json:"minimum"`
+	Maximum              *float64               `json:"maximum"`
+	MinLength            *int                   `json:"minLength"`
+	MaxLength            *int                   `json:"maxLength"`
+	MinItems             *int                   `json:"minItems"`
+}
+
+// schemaTypes accepts "type" as a single name or a list of names.
+type schemaTypes []string
+
+func (t *schemaTypes) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*t = schemaTypes{one}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(t))
+}
+
+// jsonNode is a parsed JSON value that remembers the line it started on.
+type jsonNode struct {
+	line   int
+	kind   string // object, array, string, number, boolean, or null
+	value  interface{}
+	keys   []string // object keys in document order
+	fields map[string]*jsonNode
+	items  []*jsonNode
+}
+
+func (s *jsonSchema) validate(n *jsonNode, field string) error {
+	fail := func(format string, args ...interface{}) error {
+		return &ValidationError{Line: n.line, Field: field, Msg: fmt.Sprintf(format, args...)}
+	}
+
+	if len(s.Type) > 0 && !n.hasType(s.Type) {
+		return fail("expected %s, got %s", strings.Join(s.Type, " or "), n.describe())
+	}
+	if len(s.Enum) > 0 && !n.inEnum(s.Enum) {
+		allowed := make([]string, len(s.Enum))
+		for i, v := range s.Enum {
+			b, _ := json.Marshal(v)
+			allowed[i] = string(b)
+		}
+		return fail("%s is not one of %s", n.describe(), strings.Join(allowed, ", "))
+	}
+
+	switch n.kind {
+	case "number":
+		v, _ := n.value.(json.Number).Float64()
+		if s.Minimum != nil && v < *s.Minimum {
+			return fail("%s is below the minimum %s", n.value, formatLimit(*s.Minimum))
+		}
+		if s.Maximum != nil && v > *s.Maximum {
+			return fail("%s is above the maximum %s", n.value, formatLimit(*s.Maximum))
+		}
+	case "string":
+		length := len([]rune(n.value.(string)))
+		if s.MinLength != nil && length < *s.MinLength {
+			return fail("must be at least %d characters", *s.MinLength)
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			return fail("must be at most %d characters", *s.MaxLength)
+		}
+	case "array":
+		if s.MinItems != nil && len(n.items) < *s.MinItems {
+			return fail("must have at least %d entries", *s.MinItems)
+		}
+		if s.Items != nil {
+			for i, item := range n.items {
+				if err := s.Items.validate(item, fmt.Sprintf("%s[%d]", field, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case "object":
+		for _, name := range n.keys {
+			child := joinField(field, name)
+			prop, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					return &ValidationError{Line: n.fields[name].line, Field: child, Msg: "unknown field" + suggestField(name, s.Properties)}
+				}
+				continue
+			}
+			if err := prop.validate(n.fields[name], child); err != nil {
+				return err
+			}
+		}
+		// After the unknown fields, which are usually a misspelt required one
+		for _, name := range s.Required {
+			if _, ok := n.fields[name]; !ok {
+				return fail("missing required field %q", name)
+			}
+		}
+	}
+	return nil
+}
+
+func joinField(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
+
+// suggestField names a known field that differs from name only in case or
+// by a typo of one character, the usual cause of an unknown field.
+func suggestField(name string, known map[string]*jsonSchema) string {
+	var candidates []string
+	for k := range known {
+		candidates = append(candidates, k)
+	}
+	sort.Strings(candidates)
+	for _, k := range candidates {
+		if strings.EqualFold(k, name) || editDistance(k, name) == 1 {
+			return fmt.Sprintf(" (did you mean %q?)", k)
+		}
+	}
+	return ""
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func formatLimit(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func (n *jsonNode) hasType(types []string) bool {
+	for _, t := range types {
+		switch {
+		case t == n.kind:
+			return true
+		case t == "integer" && n.kind == "number":
+			v, err := n.value.(json.Number).Float64()
+			if err == nil && v == math.Trunc(v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (n *jsonNode) inEnum(enum []interface{}) bool {
+	for _, e := range enum {
+		switch e := e.(type) {
+		case string:
+			if s, ok := n.value.(string); ok && s == e {
+				return true
+			}
+		case float64:
+			if num, ok := n.value.(json.Number); ok {
+				if v, err := num.Float64(); err == nil && v == e {
+					return true
+				}
+			}
+		case bool:
+			if b, ok := n.value.(bool); ok && b == e {
+				return true
+			}
+		case nil:
+			if n.kind == "null" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (n *jsonNode) describe() string {
+	switch n.kind {
+	case "string":
+		return strconv.Quote(n.value.(string))
+	case "number":
+		return n.value.(json.Number).String()
+	case "boolean":
+		return fmt.Sprint(n.value)
+	}
+	return n.kind
+}
+
+// jsonParser builds a jsonNode tree from encoding/json tokens, tracking the
+// line each value starts on.
+type jsonParser struct {
+	data []byte
+	dec  *json.Decoder
+}
+
+func (p *jsonParser) parse() (*jsonNode, error) {
+	start := p.dec.InputOffset()
+	tok, err := p.dec.Token()
+	if err != nil {
+		return nil, p.syntaxError(err)
+	}
+	n := &jsonNode{line: p.line(start)}
+
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			n.kind = "object"
+			n.fields = make(map[string]*jsonNode)
+			for p.dec.More() {
+				keyTok, err := p.dec.Token()
+				if err != nil {
+					return nil, p.syntaxError(err)
+				}
+				key := keyTok.(string)
+				value, err := p.parse()
+				if err != nil {
+					return nil, err
+				}
+				if _, dup := n.fields[key]; !dup {
+					n.keys = append(n.keys, key)
+				}
+				n.fields[key] = value
+			}
+		case '[':
+			n.kind = "array"
+			for p.dec.More() {
+				item, err := p.parse()
+				if err != nil {
+					return nil, err
+				}
+				n.items = append(n.items, item)
+			}
+		}
+		// The closing delimiter
+		if _, err := p.dec.Token(); err != nil {
+			return nil, p.syntaxError(err)
+		}
+	case string:
+		n.kind, n.value = "string", t
+	case json.Number:
+		n.kind, n.value = "number", t
+	case bool:
+		n.kind, n.value = "boolean", t
+	case nil:
+		n.kind = "null"
+	}
+	return n, nil
+}
+
+// line returns the line of the first value at or after offset, skipping
+// the separators the decoder has not consumed yet.
+func (p *jsonParser) line(offset int64) int {
+	i := int(offset)
+	for i < len(p.data) && strings.IndexByte(" \t\r\n,:", p.data[i]) >= 0 {
+		i++
+	}
+	if i > len(p.data) {
+		i = len(p.data)
+	}
+	return bytes.Count(p.data[:i], []byte("\n")) + 1
+}
+
+func (p *jsonParser) syntaxError(err error) error {
+	var syn *json.SyntaxError
+	if errors.As(err, &syn) {
+		return &ValidationError{Line: bytes.Count(p.data[:min(int(syn.Offset), len(p.data))], []byte("\n")) + 1, Msg: syn.Error()}
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return &ValidationError{Line: bytes.Count(p.data, []byte("\n")) + 1, Msg: "unexpected end of JSON input"}
+	}
+	return &ValidationError{Line: p.line(p.dec.InputOffset()), Msg: err.Error()}
+}
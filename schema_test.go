@@ -0,0 +1,67 @@
+package pocsag
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestValidateBurstJSON(t *testing.T) {
+	good := `[
+  {"address": 123456, "message": "HELLO", "function": 3, "payload_type": "alpha"},
+  {"address": 8, "message": "0007", "payload_type": "numeric", "baud": 1200}
+]`
+	if err := ValidateBurstJSON([]byte(good)); err != nil {
+		t.Fatalf("valid input rejected: %v", err)
+	}
+
+	cases := []struct {
+		input string
+		want  ValidationError
+	}{
+		{"[\n  {\"address\": 1, \"message\": \"A\"},\n  {\"address\": 2,\n   \"message\": \"B\", \"function\": 7}\n]",
+			ValidationError{Line: 4, Field: "[1].function", Msg: "7 is above the maximum 3"}},
+		{"[\n  {\"address\": \"123\", \"message\": \"A\"}\n]",
+			ValidationError{Line: 2, Field: "[0].address", Msg: `expected integer, got "123"`}},
+		{"[\n  {\"address\": 1}\n]",
+			ValidationError{Line: 2, Field: "[0]", Msg: `missing required field "message"`}},
+		{"[\n  {\"address\": 1, \"message\": \"A\",\n   \"payload-type\": \"alpha\"}\n]",
+			ValidationError{Line: 3, Field: "[0].payload-type", Msg: `unknown field (did you mean "payload_type"?)`}},
+		{"[{\"address\": 1, \"message\": \"A\", \"baud\": 9600}]",
+			ValidationError{Line: 1, Field: "[0].baud", Msg: "9600 is not one of 512, 1200, 2400"}},
+		{"{\"address\": 1}",
+			ValidationError{Line: 1, Msg: "expected array, got object"}},
+		{"[\n  {\"address\": 1, \"message\": \"A\"},\n  {\"address\": 2 \"message\": \"B\"}\n]",
+			ValidationError{Line: 3}},
+	}
+	for _, c := range cases {
+		err := ValidateBurstJSON([]byte(c.input))
+		var verr *ValidationError
+		if !errors.As(err, &verr) {
+			t.Errorf("%s: got %v, want a ValidationError", c.input, err)
+			continue
+		}
+		if verr.Line != c.want.Line || verr.Field != c.want.Field || (c.want.Msg != "" && verr.Msg != c.want.Msg) {
+			t.Errorf("%s:\n got %+v\nwant %+v", c.input, *verr, c.want)
+		}
+	}
+}
+
+func TestSchemasMatchOutput(t *testing.T) {
+	for _, name := range SchemaNames() {
+		doc, _ := Schema(name)
+		var s jsonSchema
+		if err := json.Unmarshal(doc, &s); err != nil {
+			t.Errorf("schema %s: %v", name, err)
+		}
+	}
+	if _, err := Schema("nope"); err == nil {
+		t.Error("unknown schema found")
+	}
+
+	messages := []MessageInfo{{Address: 1234, Message: "HELLO", Function: 3, PayloadType: PayloadTypeAlpha}}
+	desc, _ := json.Marshal(DescribeTransmission(messages, BaudRate1200))
+	if err := ValidateJSON(SchemaDescribe, desc); err != nil {
+		t.Errorf("describe output: %v", err)
+	}
+}
@@ -0,0 +1,39 @@
+# benchcheck.awk compares two `go test -bench` outputs and fails when a
+# benchmark's mean ns/op in the second is more than limit percent above the
+# first. Benchmarks missing from either file are skipped.
+#
+#   awk -v limit=20 -f scripts/benchcheck.awk base.txt new.txt
+
+FNR == 1 {
+	file = (file == "") ? "base" : "new"
+}
+
+/^Benchmark/ {
+	for (i = 3; i < NF; i++) {
+		if ($(i+1) == "ns/op") {
+			sum[file, $1] += $i
+			n[file, $1]++
+			names[$1] = 1
+		}
+	}
+}
+
+END {
+	failed = 0
+	for (name in names) {
+		if (!n["base", name] || !n["new", name]) {
+			continue
+		}
+		base = sum["base", name] / n["base", name]
+		cur = sum["new", name] / n["new", name]
+		change = (cur - base) / base * 100
+		if (change > limit) {
+			printf "%s: %.0f -> %.0f ns/op (%+.1f%%, limit %d%%)\n", name, base, cur, change, limit
+			failed = 1
+		}
+	}
+	if (failed) {
+		exit 1
+	}
+	print "no benchmark regressed by more than " limit "%"
+}
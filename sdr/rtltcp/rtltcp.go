@@ -0,0 +1,236 @@
+// Package rtltcp is a client for rtl_tcp, the network server shipped with
+// librtlsdr. It controls a remote RTL-SDR dongle and streams its IQ samples
+// without cgo, ready to feed into pocsag.IQDecoder or pocsag.DecodeFromIQ.
+package rtltcp
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"sync"
+	"time"
+
+	pocsag "github.com/sqpp/pocsag-golang/v2"
+)
+
+// DefaultAddress is where rtl_tcp listens when started without options.
+const DefaultAddress = "127.0.0.1:1234"
+
+// rtl_tcp command codes, from rtl_tcp.c
+const (
+	cmdSetFrequency      = 0x01
+	cmdSetSampleRate     = 0x02
+	cmdSetGainMode       = 0x03
+	cmdSetGain           = 0x04
+	cmdSetFreqCorrection = 0x05
+	cmdSetIFGain         = 0x06
+	cmdSetTestMode       = 0x07
+	cmdSetAGCMode        = 0x08
+	cmdSetDirectSampling = 0x09
+	cmdSetOffsetTuning   = 0x0a
+	cmdSetGainByIndex    = 0x0d
+	cmdSetBiasTee        = 0x0e
+)
+
+// TunerType identifies the tuner chip reported by the server.
+type TunerType uint32
+
+const (
+	TunerUnknown TunerType = iota
+	TunerE4000
+	TunerFC0012
+	TunerFC0013
+	TunerFC2580
+	TunerR820T
+	TunerR828D
+)
+
+func (t TunerType) String() string {
+	switch t {
+	case TunerE4000:
+		return "E4000"
+	case TunerFC0012:
+		return "FC0012"
+	case TunerFC0013:
+		return "FC0013"
+	case TunerFC2580:
+		return "FC2580"
+	case TunerR820T:
+		return "R820T"
+	case TunerR828D:
+		return "R828D"
+	default:
+		return fmt.Sprintf("unknown (%d)", uint32(t))
+	}
+}
+
+// Client is a connection to an rtl_tcp server. Control methods may be
+// called while another goroutine reads samples.
+type Client struct {
+	conn      net.Conn
+	r         *bufio.Reader
+	tuner     TunerType
+	gainCount int
+
+	mu  sync.Mutex // serializes commands
+	raw []byte
+}
+
+// Dial connects to an rtl_tcp server and reads its greeting.
+func Dial(address string) (*Client, error) {
+	conn, err := net.DialTimeout("tcp", address, 5*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to rtl_tcp at %s: %v", address, err)
+	}
+	c, err := NewClient(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// NewClient wraps an established connection, e.g. one tunnelled over SSH,
+// and reads the server greeting from it.
+func NewClient(conn net.Conn) (*Client, error) {
+	// The server greets with "RTL0", the tuner type, and its gain count
+	var hello [12]byte
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.ReadFull(conn, hello[:]); err != nil {
+		return nil, fmt.Errorf("failed to read rtl_tcp header: %v", err)
+	}
+	conn.SetReadDeadline(time.Time{})
+	if string(hello[0:4]) != "RTL0" {
+		return nil, fmt.Errorf("not an rtl_tcp server")
+	}
+	return &Client{
+		conn:      conn,
+		r:         bufio.NewReaderSize(conn, 64*1024),
+		tuner:     TunerType(binary.BigEndian.Uint32(hello[4:])),
+		gainCount: int(binary.BigEndian.Uint32(hello[8:])),
+	}, nil
+}
+
+// TunerType returns the tuner chip reported by the server.
+func (c *Client) TunerType() TunerType { return c.tuner }
+
+// GainCount returns how many discrete gain steps the tuner has.
+func (c *Client) GainCount() int { return c.gainCount }
+
+func (c *Client) command(cmd byte, param uint32) error {
+	var buf [5]byte
+	buf[0] = cmd
+	binary.BigEndian.PutUint32(buf[1:], param)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := c.conn.Write(buf[:]); err != nil {
+		return fmt.Errorf("rtl_tcp command 0x%02x failed: %v", cmd, err)
+	}
+	return nil
+}
+
+func boolParam(b bool) uint32 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// SetFrequency tunes the dongle to hz.
+func (c *Client) SetFrequency(hz uint32) error {
+	return c.command(cmdSetFrequency, hz)
+}
+
+// SetSampleRate sets the IQ sample rate. The RTL2832U supports
+// 225001-300000 and 900001-3200000 Hz.
+func (c *Client) SetSampleRate(hz uint32) error {
+	return c.command(cmdSetSampleRate, hz)
+}
+
+// SetFrequencyCorrection compensates the reference oscillator error, in ppm.
+func (c *Client) SetFrequencyCorrection(ppm int) error {
+	return c.command(cmdSetFreqCorrection, uint32(int32(ppm)))
+}
+
+// SetGain switches the tuner to manual gain and sets it in dB. The tuner
+// rounds to its nearest supported step.
+func (c *Client) SetGain(db float64) error {
+	if err := c.command(cmdSetGainMode, 1); err != nil {
+		return err
+	}
+	return c.command(cmdSetGain, uint32(int32(math.Round(db*10)))) // tenths of a dB
+}
+
+// SetGainIndex switches to manual gain and selects step i (0 to
+// GainCount()-1) of the tuner's gain table.
+func (c *Client) SetGainIndex(i int) error {
+	if err := c.command(cmdSetGainMode, 1); err != nil {
+		return err
+	}
+	return c.command(cmdSetGainByIndex, uint32(i))
+}
+
+// SetAutoGain hands gain control to the tuner's AGC.
+func (c *Client) SetAutoGain() error {
+	return c.command(cmdSetGainMode, 0)
+}
+
+// SetIFGain sets the gain of one E4000 IF stage, in dB.
+func (c *Client) SetIFGain(stage int, db float64) error {
+	return c.command(cmdSetIFGain, uint32(stage)<<16|uint32(uint16(int16(math.Round(db*10)))))
+}
+
+// SetRTLAGC enables the RTL2832U's digital AGC, separate from the tuner
+// gain mode.
+func (c *Client) SetRTLAGC(on bool) error {
+	return c.command(cmdSetAGCMode, boolParam(on))
+}
+
+// SetTestMode makes the dongle send a counter instead of samples.
+func (c *Client) SetTestMode(on bool) error {
+	return c.command(cmdSetTestMode, boolParam(on))
+}
+
+// SetDirectSampling selects direct sampling for HF: 0 off, 1 I branch,
+// 2 Q branch.
+func (c *Client) SetDirectSampling(mode int) error {
+	return c.command(cmdSetDirectSampling, uint32(mode))
+}
+
+// SetOffsetTuning enables offset tuning on E4000 tuners, which moves the
+// DC spike away from the center frequency.
+func (c *Client) SetOffsetTuning(on bool) error {
+	return c.command(cmdSetOffsetTuning, boolParam(on))
+}
+
+// SetBiasTee powers an active antenna or LNA through the coax.
+func (c *Client) SetBiasTee(on bool) error {
+	return c.command(cmdSetBiasTee, boolParam(on))
+}
+
+// Read reads raw interleaved unsigned 8-bit I/Q.
+func (c *Client) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+// ReadIQ fills buf with the next len(buf) samples, converted to complex
+// values in [-1, 1]. It blocks until buf is full or the stream fails.
+func (c *Client) ReadIQ(buf []complex64) (int, error) {
+	need := 2 * len(buf)
+	if cap(c.raw) < need {
+		c.raw = make([]byte, need)
+	}
+	raw := c.raw[:need]
+	n, err := io.ReadFull(c.r, raw)
+	iq := pocsag.CU8ToIQ(buf[:0], raw[:n&^1])
+	return len(iq), err
+}
+
+// Close closes the connection, unblocking any pending read.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
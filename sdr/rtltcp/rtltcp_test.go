@@ -0,0 +1,89 @@
+package rtltcp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+
+	pocsag "github.com/sqpp/pocsag-golang/v2"
+)
+
+func TestClientCommandsAndDecode(t *testing.T) {
+	const rate = 240000
+	packet := pocsag.CreatePOCSAGPacketWithBaudRateAndPayloadType(123456, "OVER TCP", 3, pocsag.BaudRate1200, pocsag.PayloadTypeAlpha)
+	iq := pocsag.GenerateIQ(packet, pocsag.BaudRate1200, rate, pocsag.DefaultDeviation, false)
+
+	// What an RTL-SDR would deliver: silence, the page, silence
+	var stream []byte
+	stream = append(stream, bytes.Repeat([]byte{128}, rate/5)...)
+	for _, s := range iq {
+		stream = append(stream, byte(real(s)*100+128), byte(imag(s)*100+128))
+	}
+	stream = append(stream, bytes.Repeat([]byte{128}, rate/5+1)...) // odd tail
+
+	server, client := net.Pipe()
+	commands := make(chan []byte, 1)
+	go func() {
+		defer server.Close()
+		hello := []byte("RTL0")
+		hello = binary.BigEndian.AppendUint32(hello, uint32(TunerR820T))
+		hello = binary.BigEndian.AppendUint32(hello, 29)
+		server.Write(hello)
+
+		cmds := make([]byte, 4*5)
+		io.ReadFull(server, cmds)
+		commands <- cmds
+		server.Write(stream)
+	}()
+
+	c, err := NewClient(client)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	defer c.Close()
+	if c.TunerType() != TunerR820T || c.GainCount() != 29 {
+		t.Errorf("greeting: tuner %v, %d gains", c.TunerType(), c.GainCount())
+	}
+
+	if err := c.SetFrequency(439987500); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetSampleRate(rate); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetGain(-1.5); err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		cmdSetFrequency, 0x1a, 0x39, 0xad, 0x2c,
+		cmdSetSampleRate, 0x00, 0x03, 0xa9, 0x80,
+		cmdSetGainMode, 0, 0, 0, 1,
+		cmdSetGain, 0xff, 0xff, 0xff, 0xf1, // -15 tenths
+	}
+	if got := <-commands; !bytes.Equal(got, want) {
+		t.Errorf("commands\n got %x\nwant %x", got, want)
+	}
+
+	decoder := pocsag.NewIQDecoder(rate, pocsag.BaudRate1200, pocsag.DecodeOptions{})
+	var messages []pocsag.DecodedMessage
+	buf := make([]complex64, 5000)
+	total := 0
+	for {
+		n, err := c.ReadIQ(buf)
+		total += n
+		messages = append(messages, decoder.Write(buf[:n])...)
+		if err != nil {
+			break
+		}
+	}
+	messages = append(messages, decoder.Flush()...)
+
+	if total != len(stream)/2 {
+		t.Errorf("read %d samples, want %d", total, len(stream)/2)
+	}
+	if len(messages) != 1 || messages[0].Address != 123456 || messages[0].Message != "OVER TCP" {
+		t.Errorf("decoded %+v", messages)
+	}
+}
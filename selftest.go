@@ -0,0 +1,117 @@
+package pocsag
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SelfTestResult is the outcome of one self-test page at one baud rate and
+// polarity.
+type SelfTestResult struct {
+	Name     string // the test vector, e.g. "numeric"
+	BaudRate int
+	Inverted bool  // sent with the symbol levels swapped
+	Err      error // nil when the page decoded as it was sent
+}
+
+// selfTestKey encrypts the "encrypted" vector. It is not a secret.
+var selfTestKey = KeyFromPassword("pocsag selftest", 32)
+
+// selfTestVector is one page of the self-test and the text it must decode
+// to.
+type selfTestVector struct {
+	name    string
+	msg     MessageInfo
+	want    string
+	encrypt bool
+}
+
+func selfTestVectors() []selfTestVector {
+	var printable strings.Builder
+	for c := byte(0x20); c <= 0x7E; c++ {
+		printable.WriteByte(c)
+	}
+	return []selfTestVector{
+		{name: "numeric", msg: MessageInfo{Address: 8, Function: FuncNumeric, PayloadType: PayloadTypeNumeric, Message: NumericAlphabet + "0"}},
+		{name: "alphanumeric", msg: MessageInfo{Address: 1234567, Function: FuncAlphanumeric, PayloadType: PayloadTypeAlpha, Message: printable.String()}},
+		{name: "one character", msg: MessageInfo{Address: MaxAddress, Function: 1, PayloadType: PayloadTypeAlpha, Message: "A"}},
+		{name: "transliterated", msg: MessageInfo{Address: 16, Function: FuncAlphanumeric, PayloadType: PayloadTypeAlpha, Message: "Grüße aus Köln"}, want: "Grusse aus Koln"},
+		{name: "encrypted", msg: MessageInfo{Address: 24, Function: FuncAlphanumeric, PayloadType: PayloadTypeAlpha, Message: "SECRET 42"}, encrypt: true},
+	}
+}
+
+// SelfTest encodes a fixed set of pages (numeric, every printable ASCII
+// character, transliterated, and encrypted) at each baud rate in
+// MultiRateBauds with both polarities, decodes the audio back, and reports
+// whether each page survived. It needs nothing outside the library, so
+// it checks a build on a new platform.
+func SelfTest() []SelfTestResult {
+	vectors := selfTestVectors()
+	encryption := EncryptionConfig{Method: EncryptionAES256, Key: selfTestKey}
+
+	messages := make([]MessageInfo, len(vectors))
+	for i, v := range vectors {
+		messages[i] = v.msg
+		if v.encrypt {
+			encrypted, err := EncryptMessage(v.msg.Message, encryption)
+			if err != nil {
+				return []SelfTestResult{{Name: v.name, Err: fmt.Errorf("encrypting: %v", err)}}
+			}
+			messages[i].Message = encrypted
+		}
+	}
+
+	results := make([]SelfTestResult, 0, len(MultiRateBauds)*2*len(vectors))
+	for _, baud := range MultiRateBauds {
+		for _, inverted := range []bool{false, true} {
+			opts := []Option{WithBaudRate(baud), WithSelfVerify()}
+			if inverted {
+				opts = append(opts, WithSymbols(SymbolLow, SymbolHigh))
+			}
+			var decoded []DecodedMessage
+			burst, err := NewEncoder(opts...).Encode(messages)
+			if err != nil {
+				err = fmt.Errorf("encoding: %v", err)
+			} else {
+				decoded, err = DecodeFromAudioWithOptions(burst.WAV(), baud, DecodeOptions{Encryption: encryption})
+			}
+
+			for _, v := range vectors {
+				r := SelfTestResult{Name: v.name, BaudRate: baud, Inverted: inverted}
+				if err != nil {
+					r.Err = fmt.Errorf("decoding: %v", err)
+				} else {
+					r.Err = v.check(decoded)
+				}
+				results = append(results, r)
+			}
+		}
+	}
+	return results
+}
+
+// check finds the vector's page among decoded and compares it with what
+// was sent.
+func (v selfTestVector) check(decoded []DecodedMessage) error {
+	want := v.want
+	if want == "" {
+		want = v.msg.Message
+	}
+	for _, msg := range decoded {
+		if msg.Address != v.msg.Address {
+			continue
+		}
+		switch {
+		case msg.Function != v.msg.Function:
+			return fmt.Errorf("function %d, sent %d", msg.Function, v.msg.Function)
+		case msg.IsNumeric != (v.msg.PayloadType == PayloadTypeNumeric):
+			return fmt.Errorf("decoded as the wrong payload type")
+		case msg.Partial:
+			return fmt.Errorf("only part of the page was received: %q", msg.Message)
+		case msg.Message != want:
+			return fmt.Errorf("got %q, want %q", msg.Message, want)
+		}
+		return nil
+	}
+	return fmt.Errorf("page for address %d not decoded", v.msg.Address)
+}
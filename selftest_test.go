@@ -0,0 +1,15 @@
+package pocsag
+
+import "testing"
+
+func TestSelfTest(t *testing.T) {
+	results := SelfTest()
+	if len(results) != len(MultiRateBauds)*2*len(selfTestVectors()) {
+		t.Fatalf("got %d results", len(results))
+	}
+	for _, r := range results {
+		if r.Err != nil {
+			t.Errorf("%s at %d baud, inverted %v: %v", r.Name, r.BaudRate, r.Inverted, r.Err)
+		}
+	}
+}
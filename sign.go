@@ -0,0 +1,94 @@
+package pocsag
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A page sent with EncryptionSignOnly stays readable on any pager, with a
+// tag appended that receivers holding the key check it against:
+//
+//	CALL DISPATCH #7:xP3bq0Y_a1E
+//
+// " #", the key ID and a colon when EncryptionConfig.Header is set, and the
+// first 64 bits of the HMAC-SHA256 of everything before the tag, in
+// unpadded URL-safe Base64. The tag costs 13 characters, 15-17 with a key
+// ID.
+const signTagLen = 8
+
+// signMessage appends the tag of a signed page to message.
+func signMessage(message string, key []byte, header bool, keyID uint8) string {
+	signed := message + " #"
+	if header {
+		signed += strconv.Itoa(int(keyID)) + ":"
+	}
+	return signed + base64.RawURLEncoding.EncodeToString(pageMAC(key, signed))
+}
+
+func pageMAC(key []byte, signed string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(signed))
+	return mac.Sum(nil)[:signTagLen]
+}
+
+// parseSignature splits a signed page into the text its tag covers, the
+// message, the tag, and the key ID it names (-1 for none).
+func parseSignature(page string) (signed, message string, tag []byte, keyID int, ok bool) {
+	page = strings.TrimRight(page, "\x00\x03\x04\r\n ")
+	at := strings.LastIndex(page, " #")
+	if at < 0 {
+		return "", "", nil, -1, false
+	}
+	rest := page[at+2:]
+	keyID = -1
+	if id, encoded, found := strings.Cut(rest, ":"); found {
+		n, err := strconv.ParseUint(id, 10, 8)
+		if err != nil {
+			return "", "", nil, -1, false
+		}
+		keyID, rest = int(n), encoded
+	}
+	tag, err := base64.RawURLEncoding.DecodeString(rest)
+	if err != nil || len(tag) != signTagLen {
+		return "", "", nil, -1, false
+	}
+	return page[:len(page)-len(rest)], page[:at], tag, keyID, true
+}
+
+// VerifyMessage checks the tag of a page sent with EncryptionSignOnly
+// against key and returns the message without it.
+func VerifyMessage(page string, key []byte) (string, error) {
+	signed, message, tag, _, ok := parseSignature(page)
+	if !ok {
+		return "", fmt.Errorf("page is not signed")
+	}
+	if !hmac.Equal(pageMAC(key, signed), tag) {
+		return "", fmt.Errorf("signature verification failed")
+	}
+	return message, nil
+}
+
+// VerifyWithKeyring checks the tag of a signed page against the key its
+// tag names, falling back to the other keys of ring in order of ID.
+func VerifyWithKeyring(page string, ring Keyring) (string, error) {
+	signed, message, tag, keyID, ok := parseSignature(page)
+	if !ok {
+		return "", fmt.Errorf("page is not signed")
+	}
+	ids := ring.sortedIDs()
+	if keyID >= 0 {
+		if _, ok := ring[uint8(keyID)]; ok {
+			ids = append([]uint8{uint8(keyID)}, ids...)
+		}
+	}
+	for _, id := range ids {
+		if hmac.Equal(pageMAC(ring[id], signed), tag) {
+			return message, nil
+		}
+	}
+	return "", fmt.Errorf("signature verification failed")
+}
@@ -0,0 +1,212 @@
+package pocsag
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// The squelch looks at the audio a codeword's length at a time and calls a
+// window active when its zero crossings fall on whole bit periods, which
+// POCSAG does at any level and static does not. A window whose crossings
+// are almost all one bit apart is the 1010... preamble.
+const (
+	squelchWindowBits = CodewordBits
+	squelchMinCross   = 4    // crossings a window needs to be judged
+	squelchJitter     = 0.2  // of a bit period, for a crossing to be on the clock
+	squelchRegular    = 0.75 // share of on-clock crossings in an active window
+	squelchPreamble   = 0.9  // share one bit apart in a preamble window
+	squelchHysteresis = 0.1  // of the window's RMS level
+)
+
+// Segment is a stretch of a recording that carries a transmission.
+type Segment struct {
+	Start, End time.Duration
+	// Preamble reports that the segment contains a preamble, as a whole
+	// transmission does. Recordings that start mid-transmission may have
+	// a first segment without one.
+	Preamble bool
+}
+
+// Transmission is one transmission in a recording and what was decoded
+// from it.
+type Transmission struct {
+	Segment
+	BaudRate int
+	Messages []DecodedMessage
+	// Stats describes the demodulation of this transmission alone.
+	Stats DecodeStats
+}
+
+// DetectTransmissions finds the transmissions at baudRate in a WAV
+// recording, for locating pages in hours of audio or reporting channel
+// activity. DecodeOptions.Squelch decodes only these segments.
+func DetectTransmissions(wavData []byte, baudRate int) ([]Segment, error) {
+	if _, ok := parseWAVHeader(wavData); !ok {
+		return nil, fmt.Errorf("not a WAV file")
+	}
+	samples, sampleRate, err := audioBaseband(wavData, DecodeOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var segments []Segment
+	for _, sp := range findTransmissions(samples, float64(sampleRate)/float64(baudRate)) {
+		segments = append(segments, sp.segment(sampleRate))
+	}
+	return segments, nil
+}
+
+// DecodeTransmissions decodes each transmission DetectTransmissions finds
+// in a WAV recording on its own and returns them in order with their
+// messages, for logging a channel by transmission rather than by page.
+// Transmissions in which nothing decoded are included.
+func DecodeTransmissions(wavData []byte, baudRate int, opts DecodeOptions) ([]Transmission, error) {
+	if _, ok := parseWAVHeader(wavData); !ok {
+		return nil, fmt.Errorf("not a WAV file")
+	}
+	samples, sampleRate, err := audioBaseband(wavData, opts)
+	if err != nil {
+		return nil, err
+	}
+	spans, messages, stats, err := demodulateTransmissions(samples, sampleRate, baudRate, opts)
+	if err != nil {
+		return nil, err
+	}
+	transmissions := make([]Transmission, len(spans))
+	for i, sp := range spans {
+		transmissions[i] = Transmission{
+			Segment:  sp.segment(sampleRate),
+			BaudRate: baudRate,
+			Messages: finishMessages(messages[i], baudRate, opts),
+			Stats:    stats[i],
+		}
+	}
+	return transmissions, nil
+}
+
+// span is a segment in samples.
+type span struct {
+	start, end int
+	preamble   bool
+}
+
+func (sp span) segment(sampleRate int) Segment {
+	return Segment{
+		Start:    time.Duration(int64(sp.start) * int64(time.Second) / int64(sampleRate)),
+		End:      time.Duration(int64(sp.end) * int64(time.Second) / int64(sampleRate)),
+		Preamble: sp.preamble,
+	}
+}
+
+// findTransmissions returns the active stretches of samples, at least a
+// batch long, widened by a window on each side so no edge bits are lost.
+// A single quiet window, such as a codeword with few transitions, does not
+// end a stretch.
+func findTransmissions(samples []float32, samplesPerBit float64) []span {
+	window := int(samplesPerBit * squelchWindowBits)
+	if window < 1 || len(samples) == 0 {
+		return nil
+	}
+	active, preamble := classifyWindows(samples, window, samplesPerBit)
+
+	minWindows := BatchBits / squelchWindowBits
+	var spans []span
+	for w := 0; w < len(active); {
+		if !active[w] {
+			w++
+			continue
+		}
+		first, last, hasPreamble := w, w, false
+		for ; w < len(active); w++ {
+			if active[w] {
+				last = w
+				hasPreamble = hasPreamble || preamble[w]
+			} else if w > last+1 {
+				break
+			}
+		}
+		if last-first+1 < minWindows {
+			continue
+		}
+		spans = append(spans, span{
+			start:    max(first-1, 0) * window,
+			end:      min((last+2)*window, len(samples)),
+			preamble: hasPreamble,
+		})
+	}
+	return spans
+}
+
+// classifyWindows reports for each window of samples whether it looks
+// like POCSAG and whether it looks like a preamble.
+func classifyWindows(samples []float32, window int, samplesPerBit float64) (active, preamble []bool) {
+	n := (len(samples) + window - 1) / window
+	active = make([]bool, n)
+	preamble = make([]bool, n)
+
+	state := 0
+	last := -1 // previous crossing
+	for w := 0; w < n; w++ {
+		part := samples[w*window : min((w+1)*window, len(samples))]
+		var power float64
+		for _, s := range part {
+			power += float64(s) * float64(s)
+		}
+		h := float32(squelchHysteresis * math.Sqrt(power/float64(len(part))))
+		if h == 0 {
+			state, last = 0, -1 // digital silence
+			continue
+		}
+
+		var crossings, regular, oneBit int
+		for i, s := range part {
+			next := state
+			if s > h {
+				next = 1
+			} else if s < -h {
+				next = -1
+			}
+			if next == state {
+				continue
+			}
+			at := w*window + i
+			if state != 0 && last >= 0 {
+				bits := float64(at-last) / samplesPerBit
+				k := math.Round(bits)
+				crossings++
+				if k >= 1 && math.Abs(bits-k) < squelchJitter {
+					regular++
+					if k == 1 {
+						oneBit++
+					}
+				}
+			}
+			state, last = next, at
+		}
+
+		if crossings >= squelchMinCross {
+			active[w] = float64(regular) >= squelchRegular*float64(crossings)
+			preamble[w] = crossings >= squelchWindowBits*3/4 && float64(oneBit) >= squelchPreamble*float64(crossings)
+		}
+	}
+	return active, preamble
+}
+
+// joinSpans merges spans separated by at most gap samples, so that a
+// transmission interrupted by a dropout is decoded as one.
+func joinSpans(spans []span, gap int) []span {
+	if gap <= 0 || len(spans) == 0 {
+		return spans
+	}
+	joined := spans[:1]
+	for _, sp := range spans[1:] {
+		last := &joined[len(joined)-1]
+		if sp.start-last.end <= gap {
+			last.end = sp.end
+			last.preamble = last.preamble || sp.preamble
+			continue
+		}
+		joined = append(joined, sp)
+	}
+	return joined
+}
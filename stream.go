@@ -0,0 +1,293 @@
+package pocsag
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+const (
+	// streamClockGain is how far the bit clock moves toward each observed
+	// zero crossing. High enough to lock within the 576-bit preamble, low
+	// enough that noisy crossings do not throw it off mid-batch.
+	streamClockGain = 0.1
+
+	// streamSliceWindow is the centered fraction of each bit that is
+	// integrated for the bit decision.
+	streamSliceWindow = 0.7
+
+	// streamReadSamples is how many samples DecodeStream reads at a time.
+	streamReadSamples = 4096
+)
+
+// StreamDecoder demodulates POCSAG from audio delivered in arbitrary
+// pieces, holding only a few batches of state, so network streams and
+// recordings larger than memory can be decoded. It works at the input's
+// own sample rate and recovers the bit clock with a DPLL instead of the
+// exhaustive phase search DecodeFromAudio does, and it tries both signal
+// polarities at once unless opts.Polarity picks one.
+type StreamDecoder struct {
+	baudRate      int
+	samplesPerBit float64
+	opts          DecodeOptions
+
+	// DC blocker state
+	dcR     float32
+	dcIn    float32
+	dcOut   float32
+	started bool
+
+	// Bit clock: position of the current sample within its bit, in samples
+	phase  float64
+	acc    float32
+	count  int // samples in acc
+	prev   float32
+	decode [2]*bitstreamDecoder // normal and inverted polarity
+
+	pending []DecodedMessage
+}
+
+// NewStreamDecoder creates a StreamDecoder for mono audio at sampleRate.
+// opts apply as for DecodeFromAudioWithOptions, except DisableAGC, as AGC
+// is not needed when the slicer only looks at the signal's sign, and
+// ClockTolerancePPM. Without AGC, Threshold is in the input's own level.
+func NewStreamDecoder(sampleRate, baudRate int, opts DecodeOptions) *StreamDecoder {
+	d := &StreamDecoder{
+		baudRate:      baudRate,
+		samplesPerBit: float64(sampleRate) / float64(baudRate),
+		opts:          opts,
+		dcR:           float32(1.0 - 2.0*math.Pi*dcBlockCutoff/float64(sampleRate)),
+	}
+	for i := range d.decode {
+		d.decode[i] = newBitstreamDecoder("", func(msg DecodedMessage) {
+			d.pending = append(d.pending, msg)
+		})
+		d.decode[i].setOptions(opts, baudRate)
+	}
+	return d
+}
+
+// Write demodulates samples (in the 16-bit range) and returns the messages
+// completed by them. A message completes when the next address codeword
+// arrives or its transmission ends.
+func (d *StreamDecoder) Write(samples []float32) []DecodedMessage {
+	var bits [2][]byte
+	winStart := d.samplesPerBit * (1.0 - streamSliceWindow) / 2.0
+	winEnd := d.samplesPerBit - winStart
+
+	for _, x := range samples {
+		if !d.opts.DisableDCBlock {
+			if !d.started {
+				// Start from the first sample so a static offset does
+				// not ring through the filter.
+				d.dcIn = x
+			}
+			y := x - d.dcIn + d.dcR*d.dcOut
+			d.dcIn, d.dcOut = x, y
+			x = y
+		}
+
+		// Nudge the bit clock so zero crossings land on bit boundaries
+		if d.started && (d.prev > 0) != (x > 0) {
+			cross := d.phase - 1 + float64(d.prev/(d.prev-x))
+			if cross > d.samplesPerBit/2 {
+				cross -= d.samplesPerBit
+			}
+			d.phase -= streamClockGain * cross
+		}
+		d.prev = x
+		d.started = true
+
+		if d.phase >= winStart && d.phase < winEnd {
+			d.acc += x
+			d.count++
+		}
+		d.phase++
+		if d.phase >= d.samplesPerBit {
+			d.phase -= d.samplesPerBit
+			bit := byte(0)
+			if d.acc < d.opts.Threshold*float32(d.count) {
+				bit = 1 // bit 1 is the negative level
+			}
+			bits[0] = append(bits[0], bit)
+			bits[1] = append(bits[1], bit^1)
+			d.acc, d.count = 0, 0
+		}
+	}
+
+	for i, dec := range d.decode {
+		if d.opts.Polarity.allows(i == 0) {
+			dec.write(bits[i])
+		}
+	}
+	return d.take()
+}
+
+// Flush ends the stream and returns any message still in progress.
+func (d *StreamDecoder) Flush() []DecodedMessage {
+	for _, dec := range d.decode {
+		dec.close()
+	}
+	return d.take()
+}
+
+func (d *StreamDecoder) take() []DecodedMessage {
+	messages := d.pending
+	d.pending = nil
+	for i := range messages {
+		messages[i].BaudRate = d.baudRate
+		d.opts.open(&messages[i])
+	}
+	return messages
+}
+
+// DecodeStream reads a WAV stream from r and calls handle for each message
+// as soon as it is decoded. Only the header and one read buffer are held in
+// memory.
+func DecodeStream(r io.Reader, baudRate int, opts DecodeOptions, handle func(DecodedMessage)) error {
+	wav, err := newWAVStreamReader(r)
+	if err != nil {
+		return err
+	}
+
+	decoder := NewStreamDecoder(wav.sampleRate, baudRate, opts)
+	buf := make([]float32, streamReadSamples)
+	for {
+		n, err := wav.readSamples(buf)
+		for _, msg := range decoder.Write(buf[:n]) {
+			handle(msg)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for _, msg := range decoder.Flush() {
+		handle(msg)
+	}
+	return nil
+}
+
+// DecodeReaderWithOptions decodes a WAV stream from r at baudRate and
+// returns all messages once the stream ends.
+func DecodeReaderWithOptions(r io.Reader, baudRate int, opts DecodeOptions) ([]DecodedMessage, error) {
+	messages := make([]DecodedMessage, 0)
+	err := DecodeStream(r, baudRate, opts, func(msg DecodedMessage) {
+		messages = append(messages, msg)
+	})
+	return messages, err
+}
+
+// wavStreamReader parses a WAV header from a reader and then yields the
+// first channel of the data chunk as float samples in the 16-bit range.
+type wavStreamReader struct {
+	r          io.Reader
+	info       wavInfo
+	sampleRate int
+	remaining  int64 // data bytes left, or -1 when the size is unknown
+	frame      []byte
+}
+
+func newWAVStreamReader(r io.Reader) (*wavStreamReader, error) {
+	r = bufio.NewReader(r)
+
+	var riff [12]byte
+	if _, err := io.ReadFull(r, riff[:]); err != nil {
+		return nil, fmt.Errorf("failed to read WAV header: %v", err)
+	}
+	if string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return nil, fmt.Errorf("not a WAV stream")
+	}
+
+	info := wavInfo{channels: 1}
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(r, chunk[:]); err != nil {
+			return nil, fmt.Errorf("WAV stream has no data chunk: %v", err)
+		}
+		id := string(chunk[0:4])
+		size := int64(binary.LittleEndian.Uint32(chunk[4:]))
+
+		switch id {
+		case "fmt ":
+			if size < 16 || size > 1024 {
+				return nil, fmt.Errorf("invalid WAV fmt chunk")
+			}
+			body := make([]byte, size+size%2)
+			if _, err := io.ReadFull(r, body); err != nil {
+				return nil, fmt.Errorf("failed to read WAV fmt chunk: %v", err)
+			}
+			info.formatCode = binary.LittleEndian.Uint16(body)
+			info.channels = int(binary.LittleEndian.Uint16(body[2:]))
+			info.sampleRate = int(binary.LittleEndian.Uint32(body[4:]))
+			info.bitsPerSample = int(binary.LittleEndian.Uint16(body[14:]))
+			if info.formatCode == wavFormatExtensible && size >= 26 {
+				info.formatCode = binary.LittleEndian.Uint16(body[24:])
+			}
+		case "data":
+			if info.formatCode == 0 || info.sampleRate <= 0 {
+				return nil, fmt.Errorf("WAV data chunk before fmt chunk")
+			}
+			isPCM16 := info.formatCode == wavFormatPCM && info.bitsPerSample == 16
+			isFloat32 := info.formatCode == wavFormatFloat && info.bitsPerSample == 32
+			if !isPCM16 && !isFloat32 {
+				return nil, fmt.Errorf("unsupported WAV format %d with %d bits per sample", info.formatCode, info.bitsPerSample)
+			}
+			if err := ValidateSampleRate(info.sampleRate); err != nil {
+				return nil, err
+			}
+			if info.channels < 1 {
+				info.channels = 1
+			}
+			remaining := size
+			// Streamed WAVs often leave the size at 0 or 0xFFFFFFFF.
+			if size == 0 || size == 0xFFFFFFFF {
+				remaining = -1
+			}
+			return &wavStreamReader{
+				r:          r,
+				info:       info,
+				sampleRate: info.sampleRate,
+				remaining:  remaining,
+				frame:      make([]byte, info.channels*info.bitsPerSample/8),
+			}, nil
+		default:
+			if _, err := io.CopyN(io.Discard, r, size+size%2); err != nil {
+				return nil, fmt.Errorf("WAV stream has no data chunk: %v", err)
+			}
+		}
+	}
+}
+
+// readSamples fills buf and returns the number of samples read. It returns
+// io.EOF at the end of the data chunk or stream.
+func (w *wavStreamReader) readSamples(buf []float32) (int, error) {
+	frameSize := int64(len(w.frame))
+	n := 0
+	for n < len(buf) {
+		if w.remaining >= 0 && w.remaining < frameSize {
+			return n, io.EOF
+		}
+		if _, err := io.ReadFull(w.r, w.frame); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				err = io.EOF
+			}
+			return n, err
+		}
+		if w.remaining > 0 {
+			w.remaining -= frameSize
+		}
+
+		if w.info.formatCode == wavFormatFloat {
+			buf[n] = math.Float32frombits(binary.LittleEndian.Uint32(w.frame)) * 32768.0
+		} else {
+			buf[n] = float32(int16(binary.LittleEndian.Uint16(w.frame)))
+		}
+		n++
+	}
+	return n, nil
+}
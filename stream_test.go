@@ -0,0 +1,76 @@
+package pocsag
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"testing/iotest"
+)
+
+func TestDecodeStreamIncremental(t *testing.T) {
+	messages := []MessageInfo{
+		{Address: 123456, Message: "STREAMED PAGE", Function: FuncAlphanumeric},
+		{Address: 345678, Message: "0123456789", Function: FuncNumeric},
+	}
+	packet := CreatePOCSAGBurst(messages)
+
+	for _, tc := range []struct {
+		name string
+		enc  *Encoder
+	}{
+		{"48k pcm16", NewEncoder()},
+		{"8k pcm16", NewEncoder(WithSampleRate(8000))},
+		{"44.1k float32", NewEncoder(WithSampleRate(44100), WithWAVFormat(WAVFloat32))},
+		{"inverted", NewEncoder(WithSymbols(SymbolLow, SymbolHigh))},
+	} {
+		wavData := tc.enc.ConvertToAudio(packet)
+		// Two back-to-back transmissions with silence between them
+		samples, rate := ParseWAVSamples(wavData)
+		samples = append(append(samples, make([]int16, rate/2)...), samples...)
+		stream := encodeWAV(samples, rate, WAVPCM16)
+		want := 2 * len(messages)
+		if tc.enc.wavFormat == WAVFloat32 {
+			stream, want = wavData, len(messages)
+		}
+
+		var got []DecodedMessage
+		err := DecodeStream(iotest.OneByteReader(bytes.NewReader(stream)), BaudRate1200, DecodeOptions{}, func(msg DecodedMessage) {
+			got = append(got, msg)
+		})
+		if err != nil {
+			t.Fatalf("%s: DecodeStream failed: %v", tc.name, err)
+		}
+		if len(got) != want {
+			t.Fatalf("%s: got %d messages: %v", tc.name, len(got), got)
+		}
+		for i, msg := range got {
+			want := messages[i%len(messages)]
+			if msg.Address != want.Address || msg.Message != want.Message {
+				t.Errorf("%s: message %d = %v, want %q to %d", tc.name, i, msg, want.Message, want.Address)
+			}
+		}
+	}
+
+	// Weak signal on a drifting DC offset
+	packet = CreatePOCSAGPacketWithBaudRate(123456, "OFF AIR", FuncAlphanumeric, BaudRate512)
+	samples, rate := ParseWAVSamples(ConvertToAudioWithBaudRate(packet, BaudRate512))
+	for i, s := range samples {
+		drift := 400 + 200*math.Sin(2*math.Pi*0.2*float64(i)/float64(rate))
+		samples[i] = int16(float64(s)/100 + drift)
+	}
+	decoded, err := DecodeReaderWithOptions(bytes.NewReader(encodeWAV(samples, rate, WAVPCM16)), BaudRate512, DecodeOptions{})
+	if err != nil || len(decoded) != 1 || decoded[0].Message != "OFF AIR" {
+		t.Errorf("drifting input: got %v, err %v", decoded, err)
+	}
+
+	if _, err := DecodeReader(bytes.NewReader([]byte("not a wav file"))); err == nil {
+		t.Error("DecodeReader accepted non-WAV input")
+	}
+
+	// The same rates are refused as by the buffered decoders
+	for _, rate := range []int{1, MaxSampleRate + 1} {
+		if _, err := DecodeReader(bytes.NewReader(SamplesToWAV(make([]int16, 4800), rate, WAVPCM16))); err == nil {
+			t.Errorf("DecodeReader accepted a WAV at %d Hz", rate)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package pocsag
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+)
+
+// Test pattern kinds accepted by GenerateTestPattern
+const (
+	// TestPatternPreamble is a continuous 1010... reversal pattern, useful
+	// for setting deviation and checking the modulator's symmetry.
+	TestPatternPreamble = "preamble"
+	// TestPatternIdle is a preamble followed by batches containing only a
+	// sync word and idle codewords; pagers lock on but receive nothing.
+	TestPatternIdle = "idle"
+	// TestPatternBER is a PRBS-9 (x^9 + x^5 + 1, ITU-T O.153) sequence for
+	// bit error rate measurement.
+	TestPatternBER = "ber"
+)
+
+// GenerateTestPattern returns a bitstream (packed MSB first, like the
+// packets from CreatePOCSAGBurst) lasting at least duration at baudRate, for
+// transmitter alignment and deviation calibration. Pass the result to
+// ConvertToAudioWithBaudRate or GenerateFSKSamples.
+func GenerateTestPattern(kind string, duration time.Duration, baudRate int) ([]byte, error) {
+	if baudRate <= 0 {
+		return nil, fmt.Errorf("invalid baud rate %d", baudRate)
+	}
+	if duration <= 0 {
+		return nil, fmt.Errorf("test pattern duration must be positive")
+	}
+	numBits := int((duration*time.Duration(baudRate) + time.Second - 1) / time.Second)
+	numBytes := (numBits + 7) / 8
+
+	switch kind {
+	case TestPatternPreamble:
+		return bytes.Repeat([]byte{0xAA}, numBytes), nil
+
+	case TestPatternIdle:
+		var buf bytes.Buffer
+		buf.Write(bytes.Repeat([]byte{0xAA}, PreambleLength/8))
+		for buf.Len() < numBytes || buf.Len() == PreambleLength/8 {
+			writeUint32BE(&buf, FrameSyncWord)
+			for i := 0; i < CodewordsPerBatch; i++ {
+				writeUint32BE(&buf, IdleCodeword)
+			}
+		}
+		return buf.Bytes(), nil
+
+	case TestPatternBER:
+		out := make([]byte, numBytes)
+		lfsr := uint16(0x1FF)
+		for i := 0; i < numBytes*8; i++ {
+			bit := byte(((lfsr >> 8) ^ (lfsr >> 4)) & 1)
+			lfsr = ((lfsr << 1) | uint16(bit)) & 0x1FF
+			out[i/8] |= bit << (7 - uint(i%8))
+		}
+		return out, nil
+
+	default:
+		return nil, fmt.Errorf("unknown test pattern %q (supported: %s, %s, %s)", kind, TestPatternPreamble, TestPatternIdle, TestPatternBER)
+	}
+}
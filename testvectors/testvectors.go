@@ -0,0 +1,68 @@
+// Package testvectors publishes golden POCSAG test vectors: pages, the
+// batches of codewords this library encodes them to, the SHA-256 of the
+// WAV file it renders, and the messages it decodes back. They are kept in
+// vectors.json, embedded here, so that implementations in other languages
+// can check themselves against the same file, and the tests of this
+// package hold the library to it.
+package testvectors
+
+import (
+	_ "embed"
+	"encoding/json"
+
+	pocsag "github.com/sqpp/pocsag-golang/v2"
+)
+
+//go:embed vectors.json
+var vectorsJSON []byte
+
+// Vector is one transmission: what goes in and what must come out.
+type Vector struct {
+	Name  string `json:"name"`
+	Baud  int    `json:"baud"`
+	Pages []Page `json:"pages"`
+	// PreambleBits of alternating 1s and 0s, starting with 1, come before
+	// the first batch.
+	PreambleBits int `json:"preamble_bits"`
+	// Batches are the codewords sent after the preamble in hex, each batch
+	// its sync word followed by 16 codewords.
+	Batches [][]string `json:"batches"`
+	// WAVSHA256 is the SHA-256 of the WAV file the default Encoder renders
+	// at Baud: 48 kHz 16-bit PCM, bit 1 at -12287 and bit 0 at 12287.
+	WAVSHA256 string `json:"wav_sha256"`
+	// Decoded lists the messages decoding that WAV file gives, in order.
+	Decoded []Page `json:"decoded"`
+}
+
+// Page is a message to one pager. Type is "numeric" or "alpha", as in
+// pocsag.PayloadTypeNumeric and pocsag.PayloadTypeAlpha.
+type Page struct {
+	Address  uint32 `json:"address"`
+	Function uint8  `json:"function"`
+	Type     string `json:"type"`
+	Message  string `json:"message"`
+}
+
+// Vectors returns the golden vectors.
+func Vectors() []Vector {
+	var vectors []Vector
+	if err := json.Unmarshal(vectorsJSON, &vectors); err != nil {
+		panic("testvectors: vectors.json: " + err.Error())
+	}
+	return vectors
+}
+
+// JSON returns vectors.json as published, for writing out or serving to
+// other implementations.
+func JSON() []byte {
+	return append([]byte(nil), vectorsJSON...)
+}
+
+// MessageInfos returns the vector's pages in the form the encoder takes.
+func (v Vector) MessageInfos() []pocsag.MessageInfo {
+	messages := make([]pocsag.MessageInfo, len(v.Pages))
+	for i, p := range v.Pages {
+		messages[i] = pocsag.MessageInfo{Address: p.Address, Function: p.Function, PayloadType: p.Type, Message: p.Message}
+	}
+	return messages
+}
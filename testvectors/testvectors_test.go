@@ -0,0 +1,125 @@
+package testvectors
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	pocsag "github.com/sqpp/pocsag-golang/v2"
+)
+
+var update = flag.Bool("update", false, "rewrite vectors.json from what the library does now")
+
+// inputs are the pages of the vectors, in the order vectors.json lists them.
+var inputs = []struct {
+	name  string
+	baud  int
+	pages []Page
+}{
+	{"alpha-512", pocsag.BaudRate512, []Page{{123456, 3, pocsag.PayloadTypeAlpha, "HELLO WORLD"}}},
+	{"alpha-1200", pocsag.BaudRate1200, []Page{{123456, 3, pocsag.PayloadTypeAlpha, "HELLO WORLD"}}},
+	{"alpha-2400", pocsag.BaudRate2400, []Page{{123456, 3, pocsag.PayloadTypeAlpha, "HELLO WORLD"}}},
+	{"numeric", pocsag.BaudRate1200, []Page{{8, 0, pocsag.PayloadTypeNumeric, "0123456789"}}},
+	{"numeric-symbols", pocsag.BaudRate1200, []Page{{9, 0, pocsag.PayloadTypeNumeric, pocsag.NumericAlphabet}}},
+	{"tone-only", pocsag.BaudRate1200, []Page{{16, 2, pocsag.PayloadTypeAlpha, ""}}},
+	{"printable-ascii", pocsag.BaudRate1200, []Page{{1234567, 3, pocsag.PayloadTypeAlpha, printableASCII()}}},
+	{"max-address", pocsag.BaudRate1200, []Page{{pocsag.MaxAddress, 1, pocsag.PayloadTypeAlpha, "A"}}},
+	{"burst", pocsag.BaudRate1200, []Page{
+		{111111, 3, pocsag.PayloadTypeAlpha, "MSG 1"},
+		{222222, 3, pocsag.PayloadTypeAlpha, "MSG 2 IS LONG ENOUGH TO RUN INTO THE NEXT BATCH OF THE BURST"},
+		{333333, 0, pocsag.PayloadTypeNumeric, "987654321"},
+		{7, 1, pocsag.PayloadTypeAlpha, "x"},
+	}},
+}
+
+func printableASCII() string {
+	var b strings.Builder
+	for c := byte(0x20); c <= 0x7E; c++ {
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// generate builds the vector for pages from what the library does.
+func generate(name string, baud int, pages []Page) (Vector, error) {
+	v := Vector{Name: name, Baud: baud, Pages: pages, PreambleBits: pocsag.PreambleLength}
+	encoder := pocsag.NewEncoder(pocsag.WithBaudRate(baud))
+	burst := encoder.CreateBurst(v.MessageInfos())
+	if !bytes.Equal(burst[:pocsag.PreambleLength/8], bytes.Repeat([]byte{0xAA}, pocsag.PreambleLength/8)) {
+		return v, fmt.Errorf("burst does not start with the preamble")
+	}
+	batches, err := pocsag.ReadBatches(burst, baud)
+	if err != nil {
+		return v, err
+	}
+	for _, b := range batches {
+		batch := []string{fmt.Sprintf("0x%08X", b.Sync)}
+		for _, cw := range b.Codewords() {
+			batch = append(batch, fmt.Sprintf("0x%08X", cw))
+		}
+		v.Batches = append(v.Batches, batch)
+	}
+
+	wav := encoder.EncodeWAV(v.MessageInfos())
+	sum := sha256.Sum256(wav)
+	v.WAVSHA256 = hex.EncodeToString(sum[:])
+
+	decoded, err := pocsag.DecodeFromAudioWithBaudRate(wav, baud)
+	if err != nil {
+		return v, err
+	}
+	for _, msg := range decoded {
+		payloadType := pocsag.PayloadTypeAlpha
+		if msg.IsNumeric {
+			payloadType = pocsag.PayloadTypeNumeric
+		}
+		v.Decoded = append(v.Decoded, Page{msg.Address, msg.Function, payloadType, msg.Message})
+	}
+	return v, nil
+}
+
+func TestVectors(t *testing.T) {
+	var want []Vector
+	for _, in := range inputs {
+		v, err := generate(in.name, in.baud, in.pages)
+		if err != nil {
+			t.Fatalf("%s: %v", in.name, err)
+		}
+		want = append(want, v)
+	}
+
+	if *update {
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(want); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile("vectors.json", buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	got := Vectors()
+	if len(got) != len(want) {
+		t.Fatalf("vectors.json has %d vectors, want %d; run go test -update", len(got), len(want))
+	}
+	for i := range want {
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Errorf("%s: the library no longer matches vectors.json\n got  %+v\n want %+v", want[i].Name, got[i], want[i])
+		}
+		// Every page comes back as sent
+		if !reflect.DeepEqual(want[i].Decoded, want[i].Pages) {
+			t.Errorf("%s: decoded %+v, sent %+v", want[i].Name, want[i].Decoded, want[i].Pages)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package pocsag
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TimePageLayouts are the numeric clock-page layouts understood by
+// ParseTimePage, in time.Format notation. Only digits, space and '-' are
+// used so every layout fits the numeric alphabet. The first entry is the
+// layout NewTimePage emits.
+var TimePageLayouts = []string{
+	"150405 020106",     // HHMMSS DDMMYY
+	"1504 020106",       // HHMM DDMMYY
+	"150405020106",      // HHMMSSDDMMYY
+	"1504020106",        // HHMMDDMMYY
+	"15-04-05 02-01-06", // HH-MM-SS DD-MM-YY
+	"150405",            // HHMMSS, time only
+	"1504",              // HHMM, time only
+}
+
+// NewTimePage builds a numeric clock-sync page for ric carrying t in the
+// first TimePageLayouts layout. t is formatted in its own location; convert
+// it first (e.g. t.UTC()) to choose the zone pagers should display.
+func NewTimePage(ric uint32, t time.Time) MessageInfo {
+	return MessageInfo{
+		Address:     ric,
+		Message:     t.Format(TimePageLayouts[0]),
+		Function:    FuncNumeric,
+		PayloadType: PayloadTypeNumeric,
+	}
+}
+
+// ParseTimePage parses the body of a numeric clock page using
+// TimePageLayouts, interpreting it in loc (UTC if nil). Time-only layouts
+// take their date from now in loc, the same way pagers apply them.
+func ParseTimePage(message string, loc *time.Location) (time.Time, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	message = strings.TrimSpace(message)
+
+	for _, layout := range TimePageLayouts {
+		if len(layout) != len(message) {
+			continue
+		}
+		t, err := time.ParseInLocation(layout, message, loc)
+		if err != nil {
+			continue
+		}
+		if t.Year() == 0 {
+			now := time.Now().In(loc)
+			t = time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), t.Second(), 0, loc)
+		}
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("not a recognized time page: %q", message)
+}
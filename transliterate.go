@@ -0,0 +1,193 @@
+package pocsag
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// transliterations spell common accented letters and typographic marks in
+// ASCII, which is all an alphanumeric page can carry.
+var transliterations = map[rune]string{
+	'À': "A", 'Á': "A", 'Â': "A", 'Ã': "A", 'Ä': "A", 'Å': "A", 'Æ': "AE", 'Ç': "C",
+	'È': "E", 'É': "E", 'Ê': "E", 'Ë': "E", 'Ì': "I", 'Í': "I", 'Î': "I", 'Ï': "I",
+	'Ð': "D", 'Ñ': "N", 'Ò': "O", 'Ó': "O", 'Ô': "O", 'Õ': "O", 'Ö': "O", 'Ø': "O",
+	'Ù': "U", 'Ú': "U", 'Û': "U", 'Ü': "U", 'Ý': "Y", 'Þ': "TH", 'ß': "ss",
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'æ': "ae", 'ç': "c",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ì': "i", 'í': "i", 'î': "i", 'ï': "i",
+	'ð': "d", 'ñ': "n", 'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ý': "y", 'þ': "th", 'ÿ': "y",
+	'Ą': "A", 'ą': "a", 'Ć': "C", 'ć': "c", 'Č': "C", 'č': "c", 'Ď': "D", 'ď': "d",
+	'Ę': "E", 'ę': "e", 'Ě': "E", 'ě': "e", 'Ğ': "G", 'ğ': "g", 'İ': "I", 'ı': "i",
+	'Ł': "L", 'ł': "l", 'Ń': "N", 'ń': "n", 'Ň': "N", 'ň': "n", 'Ő': "O", 'ő': "o",
+	'Œ': "OE", 'œ': "oe", 'Ř': "R", 'ř': "r", 'Ś': "S", 'ś': "s", 'Ş': "S", 'ş': "s",
+	'Š': "S", 'š': "s", 'Ť': "T", 'ť': "t", 'Ů': "U", 'ů': "u", 'Ű': "U", 'ű': "u",
+	'Ź': "Z", 'ź': "z", 'Ż': "Z", 'ż': "z", 'Ž': "Z", 'ž': "z",
+	' ': " ", '‘': "'", '’': "'", '‚': "'", '“': "\"", '”': "\"", '„': "\"",
+	'«': "\"", '»': "\"", '–': "-", '—': "-", '…': "...", '€': "EUR", '£': "GBP",
+	'×': "x", '©': "(C)", '°': "deg",
+}
+
+// defaultTransliterator is what alphanumeric encoding falls back on for
+// characters outside ASCII.
+var defaultTransliterator = NewTransliterator(nil)
+
+// Transliterator rewrites text in ASCII for alphanumeric pages. Custom
+// overrides, such as "ü" to "ue" or to "}" for a pager with a German
+// character ROM, take precedence over the built-in table, and FromASCII
+// reverses them for display on the receiving side. Characters with no
+// mapping become "?".
+//
+// A Transliterator is also a MessagePolicy whose Transform rewrites
+// alphanumeric messages.
+type Transliterator struct {
+	table   map[rune]string
+	reverse *strings.Replacer
+}
+
+// NewTransliterator returns a Transliterator with the built-in table plus
+// overrides.
+func NewTransliterator(overrides map[rune]string) *Transliterator {
+	t := &Transliterator{table: make(map[rune]string, len(transliterations)+len(overrides))}
+	for r, s := range transliterations {
+		t.table[r] = s
+	}
+	for r, s := range overrides {
+		t.table[r] = s
+	}
+
+	// Longer spellings are tried first, and when several characters share
+	// one spelling the lowest one is restored.
+	runes := make([]rune, 0, len(overrides))
+	for r, s := range overrides {
+		if s != "" {
+			runes = append(runes, r)
+		}
+	}
+	sort.Slice(runes, func(i, j int) bool {
+		a, b := overrides[runes[i]], overrides[runes[j]]
+		if len(a) != len(b) {
+			return len(a) > len(b)
+		}
+		return runes[i] < runes[j]
+	})
+	var pairs []string
+	seen := make(map[string]bool)
+	for _, r := range runes {
+		if s := overrides[r]; !seen[s] {
+			seen[s] = true
+			pairs = append(pairs, s, string(r))
+		}
+	}
+	t.reverse = strings.NewReplacer(pairs...)
+	return t
+}
+
+// LoadTransliterator reads overrides from a JSON object mapping single
+// characters to their ASCII spelling, e.g. {"ü": "ue", "ß": "ss"}.
+func LoadTransliterator(data []byte) (*Transliterator, error) {
+	var raw map[string]string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parsing transliteration table: %v", err)
+	}
+	overrides := make(map[rune]string, len(raw))
+	for k, v := range raw {
+		r, size := utf8.DecodeRuneInString(k)
+		if size == 0 || size != len(k) {
+			return nil, fmt.Errorf("key %q is not a single character", k)
+		}
+		if r < utf8.RuneSelf {
+			return nil, fmt.Errorf("key %q is already ASCII", k)
+		}
+		for i := 0; i < len(v); i++ {
+			if v[i] < 0x20 || v[i] > 0x7E {
+				return nil, fmt.Errorf("mapping for %q is not printable ASCII: %q", k, v)
+			}
+		}
+		overrides[r] = v
+	}
+	return NewTransliterator(overrides), nil
+}
+
+// ToASCII rewrites s in ASCII.
+func (t *Transliterator) ToASCII(s string) string {
+	if isASCII(s) {
+		return s
+	}
+	var b strings.Builder
+	for _, r := range s {
+		switch spelling, ok := t.table[r]; {
+		case r < utf8.RuneSelf:
+			b.WriteRune(r)
+		case ok:
+			b.WriteString(spelling)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// Substitution is a character of a message that is not sent as given.
+type Substitution struct {
+	Offset      int // in bytes, in the message as given
+	Original    rune
+	Replacement string // what is sent in its place
+}
+
+// Substitutions lists the characters ToASCII replaces in s.
+func (t *Transliterator) Substitutions(s string) []Substitution {
+	var subs []Substitution
+	for i, r := range s {
+		if r >= utf8.RuneSelf {
+			subs = append(subs, Substitution{i, r, t.ToASCII(string(r))})
+		}
+	}
+	return subs
+}
+
+// FromASCII puts back the characters of the custom overrides in received
+// text. It is exact when each override has its own spelling that does not
+// occur in ordinary text, as with ISO 646 national variants.
+func (t *Transliterator) FromASCII(s string) string {
+	return t.reverse.Replace(s)
+}
+
+// Substitutions lists the characters of msg the Encoder cannot send as
+// given, so operators can be told a message was altered. Alphanumeric
+// messages are spelled in ASCII and numeric messages carry a space for each
+// byte outside NumericAlphabet.
+func (e *Encoder) Substitutions(msg MessageInfo) []Substitution {
+	if messagePayloadType(msg) == PayloadTypeNumeric {
+		return numericSubstitutions(msg.Message)
+	}
+	t := e.translit
+	if t == nil {
+		t = defaultTransliterator
+	}
+	return t.Substitutions(msg.Message)
+}
+
+// Transform rewrites alphanumeric messages in ASCII.
+func (t *Transliterator) Transform(msg MessageInfo) MessageInfo {
+	if messagePayloadType(msg) == PayloadTypeAlpha {
+		msg.Message = t.ToASCII(msg.Message)
+	}
+	return msg
+}
+
+// Validate accepts every message.
+func (t *Transliterator) Validate(MessageInfo) error {
+	return nil
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,51 @@
+package pocsag
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTransliteration(t *testing.T) {
+	// Without a table of its own the encoder still spells accents in ASCII
+	decoded, err := DecodeFromBinary(CreatePOCSAGPacket(1234, "Café “Zoë” 5€", FuncAlphanumeric))
+	if err != nil || len(decoded) != 1 || decoded[0].Message != "Cafe \"Zoe\" 5EUR" {
+		t.Fatalf("got %v, err %v", decoded, err)
+	}
+
+	// A German character ROM shows {|}~ as äöüß
+	translit, err := LoadTransliterator([]byte(`{"ä": "{", "ö": "|", "ü": "}", "ß": "~", "Ü": "UE"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := NewEncoder(WithTransliteration(translit))
+	decoded, err = DecodeFromBinary(enc.CreateBurst([]MessageInfo{
+		{Address: 1234, Message: "Grüße aus Übersee, Ω", Function: FuncAlphanumeric},
+	}))
+	if err != nil || len(decoded) != 1 {
+		t.Fatalf("got %v, err %v", decoded, err)
+	}
+	if decoded[0].Message != "Gr}~e aus UEbersee, ?" {
+		t.Errorf("sent %q", decoded[0].Message)
+	}
+	if got := translit.FromASCII(decoded[0].Message); got != "Grüße aus Übersee, ?" {
+		t.Errorf("restored %q", got)
+	}
+
+	// Each replaced character is reported where it was given
+	subs := enc.Substitutions(MessageInfo{Message: "Grüße, Ω", Function: FuncAlphanumeric})
+	want := []Substitution{{2, 'ü', "}"}, {4, 'ß', "~"}, {9, 'Ω', "?"}}
+	if !reflect.DeepEqual(subs, want) {
+		t.Errorf("alpha substitutions %v, want %v", subs, want)
+	}
+	subs = enc.Substitutions(MessageInfo{Message: "12a4 [5]€", PayloadType: PayloadTypeNumeric})
+	want = []Substitution{{2, 'a', " "}, {8, '€', "   "}}
+	if !reflect.DeepEqual(subs, want) {
+		t.Errorf("numeric substitutions %v, want %v", subs, want)
+	}
+
+	for _, bad := range []string{`{"ue": "u"}`, `{"a": "b"}`, `{"ü": "ü"}`, `[]`} {
+		if _, err := LoadTransliterator([]byte(bad)); err == nil {
+			t.Errorf("%s accepted", bad)
+		}
+	}
+}
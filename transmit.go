@@ -0,0 +1,109 @@
+package pocsag
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrChannelBusy is returned when the channel did not become clear within
+// ChannelAccess.MaxWait.
+var ErrChannelBusy = errors.New("channel busy")
+
+// TransmitController brackets each generated burst so a gateway can
+// listen before talking on a shared channel.
+type TransmitController interface {
+	// ChannelBusy reports whether someone else is on the channel, e.g.
+	// from a squelch GPIO or an RSSI reading.
+	ChannelBusy() (bool, error)
+	// BeforeTransmit is called once the channel is clear, just before the
+	// burst is sent, e.g. to key PTT. An error cancels the burst.
+	BeforeTransmit(packet []byte) error
+	// AfterTransmit is called after the burst with the transmit result,
+	// e.g. to unkey PTT. It is called only if BeforeTransmit succeeded.
+	AfterTransmit(packet []byte, err error)
+}
+
+// TransmitHooks is a TransmitController built from optional functions.
+// Nil functions are skipped; a nil Busy means the channel is always clear.
+type TransmitHooks struct {
+	Busy   func() (bool, error)
+	Before func(packet []byte) error
+	After  func(packet []byte, err error)
+}
+
+func (h TransmitHooks) ChannelBusy() (bool, error) {
+	if h.Busy == nil {
+		return false, nil
+	}
+	return h.Busy()
+}
+
+func (h TransmitHooks) BeforeTransmit(packet []byte) error {
+	if h.Before == nil {
+		return nil
+	}
+	return h.Before(packet)
+}
+
+func (h TransmitHooks) AfterTransmit(packet []byte, err error) {
+	if h.After != nil {
+		h.After(packet, err)
+	}
+}
+
+// ChannelAccess sets how long to wait for a clear channel.
+type ChannelAccess struct {
+	PollInterval time.Duration // how often ChannelBusy is checked (default 100ms)
+	Holdoff      time.Duration // how long the channel must stay clear before keying
+	MaxWait      time.Duration // give up with ErrChannelBusy after this long (default 30s)
+}
+
+// GuardTransmit wraps transmit so each burst waits for a clear channel and
+// runs between c's BeforeTransmit and AfterTransmit hooks.
+func GuardTransmit(c TransmitController, access ChannelAccess, transmit TransmitFunc) TransmitFunc {
+	if access.PollInterval <= 0 {
+		access.PollInterval = 100 * time.Millisecond
+	}
+	if access.MaxWait <= 0 {
+		access.MaxWait = 30 * time.Second
+	}
+
+	return func(packet []byte, messages []MessageInfo) error {
+		if err := access.waitClear(c); err != nil {
+			return err
+		}
+		if err := c.BeforeTransmit(packet); err != nil {
+			return fmt.Errorf("before-transmit hook failed: %v", err)
+		}
+		err := transmit(packet, messages)
+		c.AfterTransmit(packet, err)
+		return err
+	}
+}
+
+func (a ChannelAccess) waitClear(c TransmitController) error {
+	deadline := time.Now().Add(a.MaxWait)
+	var clearSince time.Time
+	for {
+		busy, err := c.ChannelBusy()
+		if err != nil {
+			return fmt.Errorf("channel busy check failed: %v", err)
+		}
+		now := time.Now()
+		if busy {
+			clearSince = time.Time{}
+		} else {
+			if clearSince.IsZero() {
+				clearSince = now
+			}
+			if now.Sub(clearSince) >= a.Holdoff {
+				return nil
+			}
+		}
+		if now.After(deadline) {
+			return ErrChannelBusy
+		}
+		time.Sleep(a.PollInterval)
+	}
+}
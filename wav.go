@@ -0,0 +1,232 @@
+package pocsag
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"time"
+)
+
+// WAVFormat selects the sample encoding of generated WAV files.
+type WAVFormat int
+
+const (
+	// WAVPCM16 is 16-bit signed integer PCM (format code 1), the default.
+	WAVPCM16 WAVFormat = iota
+	// WAVFloat32 is 32-bit IEEE float (format code 3), as produced and
+	// expected by many DAWs and GNU Radio audio sinks.
+	WAVFloat32
+)
+
+// WAV format codes from the fmt chunk
+const (
+	wavFormatPCM        = 1
+	wavFormatFloat      = 3
+	wavFormatExtensible = 0xFFFE
+)
+
+// encodeWAV wraps mono samples in a RIFF/WAVE container.
+func encodeWAV(samples []int16, sampleRate int, format WAVFormat) []byte {
+	var buf bytes.Buffer
+
+	formatCode := uint16(wavFormatPCM)
+	bitsPerSample := BitsPerSample
+	fmtSize := uint32(16)
+	if format == WAVFloat32 {
+		// Non-PCM formats carry a cbSize field and need a fact chunk.
+		formatCode = wavFormatFloat
+		bitsPerSample = 32
+		fmtSize = 18
+	}
+	bytesPerSample := bitsPerSample / 8
+
+	dataSize := uint32(len(samples) * bytesPerSample)
+	fileSize := 4 + (8 + fmtSize) + 8 + dataSize
+	if format == WAVFloat32 {
+		fileSize += 8 + 4 // fact chunk
+	}
+	byteRate := uint32(sampleRate * NumChannels * bytesPerSample)
+	blockAlign := uint16(NumChannels * bytesPerSample) // Correct block align for Firefox compatibility
+
+	// RIFF header
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, fileSize)
+	buf.WriteString("WAVE")
+
+	// fmt chunk
+	buf.WriteString("fmt ")
+	binary.Write(&buf, binary.LittleEndian, fmtSize)               // chunk size
+	binary.Write(&buf, binary.LittleEndian, formatCode)            // PCM or IEEE float
+	binary.Write(&buf, binary.LittleEndian, uint16(NumChannels))   // channels
+	binary.Write(&buf, binary.LittleEndian, uint32(sampleRate))    // sample rate
+	binary.Write(&buf, binary.LittleEndian, byteRate)              // byte rate
+	binary.Write(&buf, binary.LittleEndian, blockAlign)            // block align
+	binary.Write(&buf, binary.LittleEndian, uint16(bitsPerSample)) // bits per sample
+	if format == WAVFloat32 {
+		binary.Write(&buf, binary.LittleEndian, uint16(0)) // cbSize
+
+		buf.WriteString("fact")
+		binary.Write(&buf, binary.LittleEndian, uint32(4))
+		binary.Write(&buf, binary.LittleEndian, uint32(len(samples)))
+	}
+
+	// data chunk
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, dataSize) // Write actual data size for Firefox compatibility
+
+	// Write samples
+	sample := make([]byte, bytesPerSample)
+	for _, s := range samples {
+		if format == WAVFloat32 {
+			binary.LittleEndian.PutUint32(sample, math.Float32bits(float32(s)/32768.0))
+		} else {
+			binary.LittleEndian.PutUint16(sample, uint16(s))
+		}
+		buf.Write(sample)
+	}
+
+	return buf.Bytes()
+}
+
+// SamplesToWAV wraps mono samples at sampleRate in a WAV file of the given
+// format.
+func SamplesToWAV(samples []int16, sampleRate int, format WAVFormat) []byte {
+	return encodeWAV(samples, sampleRate, format)
+}
+
+// DetectWAVFormat reports the sample format of a WAV file. ok is false for
+// data that is not a 16-bit PCM or 32-bit float WAV file.
+func DetectWAVFormat(wavData []byte) (format WAVFormat, ok bool) {
+	info, ok := parseWAVHeader(wavData)
+	switch {
+	case !ok:
+		return WAVPCM16, false
+	case info.formatCode == wavFormatFloat && info.bitsPerSample == 32:
+		return WAVFloat32, true
+	case info.formatCode == wavFormatPCM && info.bitsPerSample == 16:
+		return WAVPCM16, true
+	}
+	return WAVPCM16, false
+}
+
+// wavInfo is what the decoder needs from a WAV header.
+type wavInfo struct {
+	formatCode    uint16
+	channels      int
+	sampleRate    int
+	bitsPerSample int
+	dataOffset    int
+	dataSize      int
+}
+
+// parseWAVHeader walks the RIFF chunks of a WAV file. ok is false when the
+// data does not look like a RIFF/WAVE file.
+func parseWAVHeader(wavData []byte) (info wavInfo, ok bool) {
+	if len(wavData) < 12 || string(wavData[0:4]) != "RIFF" || string(wavData[8:12]) != "WAVE" {
+		return info, false
+	}
+
+	info.channels = 1
+	pos := 12
+	for pos+8 <= len(wavData) {
+		id := string(wavData[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(wavData[pos+4:]))
+		body := pos + 8
+
+		switch id {
+		case "fmt ":
+			if body+16 > len(wavData) {
+				return info, false
+			}
+			info.formatCode = binary.LittleEndian.Uint16(wavData[body:])
+			info.channels = int(binary.LittleEndian.Uint16(wavData[body+2:]))
+			info.sampleRate = int(binary.LittleEndian.Uint32(wavData[body+4:]))
+			info.bitsPerSample = int(binary.LittleEndian.Uint16(wavData[body+14:]))
+			// WAVE_FORMAT_EXTENSIBLE keeps the real format code in the
+			// first two bytes of the SubFormat GUID.
+			if info.formatCode == wavFormatExtensible && size >= 40 && body+26 <= len(wavData) {
+				info.formatCode = binary.LittleEndian.Uint16(wavData[body+24:])
+			}
+		case "data":
+			info.dataOffset = body
+			info.dataSize = size
+			// Streamed WAVs often leave the size at 0 or 0xFFFFFFFF.
+			if size == 0 || body+size > len(wavData) {
+				info.dataSize = len(wavData) - body
+			}
+			return info, info.formatCode != 0
+		}
+
+		pos = body + size + size%2 // chunks are word aligned
+	}
+	return info, false
+}
+
+// ParseWAVSamples extracts mono samples and the sample rate from a WAV
+// file. 16-bit PCM and 32-bit IEEE float data are supported; float samples
+// are scaled to the 16-bit range and multi-channel files are reduced to
+// their first channel. Files without a recognizable header are treated as
+// 16-bit samples following a "data" tag (or a 44-byte header), matching the
+// decoder's historic behavior.
+func ParseWAVSamples(wavData []byte) ([]int16, int) {
+	info, ok := parseWAVHeader(wavData)
+	if !ok {
+		return parseRawWAVSamples(wavData)
+	}
+	if info.channels < 1 {
+		info.channels = 1
+	}
+
+	data := wavData[info.dataOffset : info.dataOffset+info.dataSize]
+	bytesPerSample := info.bitsPerSample / 8
+	frameSize := bytesPerSample * info.channels
+	if frameSize == 0 {
+		return parseRawWAVSamples(wavData)
+	}
+
+	samples := make([]int16, 0, len(data)/frameSize)
+	for i := 0; i+frameSize <= len(data); i += frameSize {
+		switch {
+		case info.formatCode == wavFormatFloat && bytesPerSample == 4:
+			f := math.Float32frombits(binary.LittleEndian.Uint32(data[i:]))
+			samples = append(samples, clampInt16(float64(f)*32768.0))
+		case bytesPerSample == 2:
+			samples = append(samples, int16(binary.LittleEndian.Uint16(data[i:])))
+		default:
+			return parseRawWAVSamples(wavData)
+		}
+	}
+	return samples, info.sampleRate
+}
+
+func parseRawWAVSamples(wavData []byte) ([]int16, int) {
+	// Find data chunk
+	// Standard WAV has "data" chunk followed by 4-byte size, then actual samples
+	dataOffset := bytes.Index(wavData, []byte("data"))
+	startIdx := 44
+	if dataOffset != -1 {
+		startIdx = dataOffset + 8 // "data" (4) + size (4)
+	}
+
+	// Read sample rate from WAV header (bytes 24-27)
+	sampleRate := SampleRate
+	if len(wavData) > 28 {
+		sampleRate = int(binary.LittleEndian.Uint32(wavData[24:28]))
+	}
+
+	samples := make([]int16, 0, max(0, (len(wavData)-startIdx)/2))
+	for i := startIdx; i < len(wavData)-1; i += 2 {
+		samples = append(samples, int16(binary.LittleEndian.Uint16(wavData[i:])))
+	}
+	return samples, sampleRate
+}
+
+// WAVDuration returns the playing time of a WAV file produced by this
+// package (or any PCM16/float32 WAV).
+func WAVDuration(wavData []byte) time.Duration {
+	samples, sampleRate := ParseWAVSamples(wavData)
+	if sampleRate <= 0 {
+		return 0
+	}
+	return time.Duration(int64(len(samples)) * int64(time.Second) / int64(sampleRate))
+}
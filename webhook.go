@@ -0,0 +1,166 @@
+package pocsag
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+// MessageFilter selects which decoded messages are forwarded. An empty
+// filter matches everything; when both fields are set a message must match
+// both.
+type MessageFilter struct {
+	Addresses []uint32       // only these RICs (empty = any)
+	Pattern   *regexp.Regexp // only messages whose text matches (nil = any)
+}
+
+// Match reports whether msg passes the filter.
+func (f MessageFilter) Match(msg DecodedMessage) bool {
+	if len(f.Addresses) > 0 {
+		found := false
+		for _, addr := range f.Addresses {
+			if addr == msg.Address {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if f.Pattern != nil && !f.Pattern.MatchString(msg.Message) {
+		return false
+	}
+	return true
+}
+
+// WebhookConfig configures HTTP POST notifications for decoded messages.
+type WebhookConfig struct {
+	URL        string
+	Headers    map[string]string // extra request headers, e.g. Authorization
+	Filter     MessageFilter
+	MaxRetries int           // retries after the first attempt: 0 for none, negative for DefaultWebhookRetries
+	Backoff    time.Duration // delay before the first retry, doubled each time (default 1s)
+	Timeout    time.Duration // per-request timeout (default 10s)
+}
+
+// WebhookPayload is the JSON body POSTed for each decoded message.
+type WebhookPayload struct {
+	Address     uint32    `json:"address"`
+	Function    uint8     `json:"function"`
+	Message     string    `json:"message"`
+	Type        string    `json:"type"`
+	Timestamp   time.Time `json:"timestamp"`
+	Partial     bool      `json:"partial,omitempty"`
+	Alias       string    `json:"alias,omitempty"`
+	Tags        []string  `json:"tags,omitempty"`
+	Alternative string    `json:"alternative,omitempty"`
+	FrequencyHz int64     `json:"frequency,omitempty"`
+	Position    *Position `json:"position,omitempty"`
+	Verified    bool      `json:"verified,omitempty"`
+}
+
+func newWebhookPayload(msg DecodedMessage) WebhookPayload {
+	msgType := "alphanumeric"
+	if msg.IsNumeric {
+		msgType = "numeric"
+	}
+	return WebhookPayload{
+		Address:     msg.Address,
+		Function:    msg.Function,
+		Message:     msg.Message,
+		Type:        msgType,
+		Timestamp:   time.Now().UTC(),
+		Partial:     msg.Partial,
+		Alias:       msg.Alias,
+		Tags:        msg.Tags,
+		Alternative: msg.Alternative,
+		FrequencyHz: msg.FrequencyHz,
+		Position:    msg.Position,
+		Verified:    msg.Verified,
+	}
+}
+
+// Webhook forwards decoded messages to an HTTP endpoint, retrying failed
+// deliveries with exponential backoff.
+type Webhook struct {
+	config WebhookConfig
+	client *http.Client
+}
+
+// DefaultWebhookRetries is how many times a failed delivery is retried
+// when WebhookConfig.MaxRetries is negative.
+const DefaultWebhookRetries = 3
+
+// NewWebhook creates a webhook notifier, filling in defaults for a
+// negative MaxRetries and unset backoff and timeout settings.
+func NewWebhook(config WebhookConfig) *Webhook {
+	if config.MaxRetries < 0 {
+		config.MaxRetries = DefaultWebhookRetries
+	}
+	if config.Backoff == 0 {
+		config.Backoff = time.Second
+	}
+	if config.Timeout == 0 {
+		config.Timeout = 10 * time.Second
+	}
+	return &Webhook{
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+	}
+}
+
+// Notify POSTs msg to the webhook if it passes the filter. It returns nil
+// for filtered-out messages and the last delivery error once retries are
+// exhausted.
+func (w *Webhook) Notify(ctx context.Context, msg DecodedMessage) error {
+	if !w.config.Filter.Match(msg) {
+		return nil
+	}
+
+	body, err := json.Marshal(newWebhookPayload(msg))
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %v", err)
+	}
+
+	backoff := w.config.Backoff
+	for attempt := 0; ; attempt++ {
+		err = w.post(ctx, body)
+		if err == nil || attempt >= w.config.MaxRetries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (w *Webhook) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range w.config.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
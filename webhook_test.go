@@ -0,0 +1,60 @@
+package pocsag
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWebhookRetriesAndFilters(t *testing.T) {
+	var attempts atomic.Int32
+	var received WebhookPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer server.Close()
+
+	hook := NewWebhook(WebhookConfig{
+		URL:        server.URL,
+		MaxRetries: -1,
+		Backoff:    time.Millisecond,
+		Filter: MessageFilter{
+			Addresses: []uint32{123456},
+			Pattern:   regexp.MustCompile("FIRE"),
+		},
+	})
+
+	// Filtered out by address and by pattern: no request is made.
+	hook.Notify(context.Background(), DecodedMessage{Address: 999, Message: "FIRE"})
+	hook.Notify(context.Background(), DecodedMessage{Address: 123456, Message: "TEST"})
+	if attempts.Load() != 0 {
+		t.Fatalf("filtered messages triggered %d requests", attempts.Load())
+	}
+
+	err := hook.Notify(context.Background(), DecodedMessage{Address: 123456, Function: 3, Message: "FIRE STATION 3"})
+	if err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	if attempts.Load() != 3 {
+		t.Errorf("got %d attempts, want 3", attempts.Load())
+	}
+	if received.Address != 123456 || received.Message != "FIRE STATION 3" || received.Type != "alphanumeric" {
+		t.Errorf("unexpected payload: %+v", received)
+	}
+
+	// MaxRetries 0 makes a single attempt
+	attempts.Store(0)
+	once := NewWebhook(WebhookConfig{URL: server.URL, Backoff: time.Millisecond})
+	if err := once.Notify(context.Background(), DecodedMessage{Address: 8}); err == nil || attempts.Load() != 1 {
+		t.Errorf("without retries: %d attempts, err %v", attempts.Load(), err)
+	}
+}